@@ -12,6 +12,7 @@ import (
 	"chat_ollama/internal/api"
 	"chat_ollama/internal/config"
 	"chat_ollama/internal/database"
+	"chat_ollama/internal/services"
 	"chat_ollama/internal/utils"
 )
 
@@ -55,10 +56,46 @@ func main() {
 
 	logger.Info().Msg("Database migrations completed")
 
+	// Make sure the configured embedding model matches the stored vectors
+	if cfg.EnableSemanticMemory {
+		checkCtx, cancelCheck := context.WithTimeout(context.Background(), cfg.OllamaTimeout)
+		registry := services.NewEmbeddingRegistry(db, services.NewEmbeddingService(db, cfg, logger), cfg.EmbeddingModel, logger)
+		registry.SetIndexes(services.NewVectorIndexService(db, cfg, logger))
+		err := registry.CheckSchema(checkCtx)
+		cancelCheck()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Embedding schema check failed")
+		}
+
+		// Embed messages that were saved while embedding was unavailable
+		backfillCtx, cancelBackfill := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = services.NewJobQueue(db, cfg, logger).Enqueue(backfillCtx, services.JobTypeEmbeddingBackfill, nil, services.JobOptions{
+			DedupeKey: services.JobTypeEmbeddingBackfill,
+		})
+		cancelBackfill()
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to queue embedding backfill")
+		}
+	}
+
+	// Queue the model downloads a restart interrupted again
+	recoverCtx, cancelRecover := context.WithTimeout(context.Background(), 10*time.Second)
+	modelManager := services.NewModelManager(db, services.NewOllamaClient(cfg, logger), logger)
+	modelManager.SetJobQueue(services.NewJobQueue(db, cfg, logger))
+	if _, err := modelManager.RecoverDownloads(recoverCtx); err != nil {
+		logger.Error().Err(err).Msg("Failed to recover model downloads")
+	}
+	cancelRecover()
+
 	// Initialize router
 	router := api.NewRouter(db, cfg, logger)
 	handler := router.GetHandler()
 
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	router.StartWorkers(workerCtx)
+
 	// Configure HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
@@ -105,6 +142,11 @@ func main() {
 			}
 		}
 
+		// Stop background workers and wait for in-flight work to finish; queued jobs
+		// still running after JOB_DRAIN_TIMEOUT are released for the next start
+		stopWorkers()
+		router.WaitForWorkers()
+
 		logger.Info().Msg("Server shutdown completed")
 	}
 }
\ No newline at end of file
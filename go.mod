@@ -12,6 +12,7 @@ require (
 	github.com/pgvector/pgvector-go v0.1.1
 	github.com/rs/zerolog v1.32.0
 	golang.org/x/crypto v0.18.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -19,26 +20,33 @@ import (
 type ChatHandler struct {
 	chatService    *services.ChatService
 	semanticMemory *services.SemanticMemoryService
+	retention      *services.RetentionService
+	summarizer     *services.SummarizerService
+	config         *config.Config
 	logger         *utils.Logger
 }
 
 // NewChatHandler creates a new chat handler
 func NewChatHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *ChatHandler {
 	// Create Ollama client
-	ollamaClient := services.NewOllamaClient(cfg.OllamaHost, cfg.OllamaTimeout, logger)
+	ollamaClient := services.NewOllamaClient(cfg, logger)
 	
 	// Create embedding service
-	embeddingService := services.NewEmbeddingService(cfg, logger)
+	embeddingService := services.NewEmbeddingService(db, cfg, logger)
 	
 	// Create chat service
 	chatService := services.NewChatService(db, ollamaClient, embeddingService, cfg, logger)
 	
 	// Create semantic memory service with configured embedding model
 	semanticMemory := services.NewSemanticMemoryServiceWithModel(db, embeddingService, logger, cfg.EmbeddingModel)
+	semanticMemory.SetGapDetection(cfg.MemoryGapThreshold, cfg.TopicDriftThreshold)
 
 	return &ChatHandler{
 		chatService:    chatService,
 		semanticMemory: semanticMemory,
+		retention:      services.NewRetentionService(db, cfg, logger),
+		summarizer:     services.NewSummarizerService(db, ollamaClient, embeddingService, cfg, logger),
+		config:         cfg,
 		logger:         logger.WithComponent("chat_handler"),
 	}
 }
@@ -99,6 +107,15 @@ func (h *ChatHandler) Chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject requests the model cannot handle, e.g. images sent to a text-only model,
+	// before any response is started
+	if err := h.chatService.ValidateChatRequest(r.Context(), req); err != nil {
+		logger.Warn().Err(err).Str("model", req.Model).Msg("Chat request rejected")
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
 	logger.Info().
 		Str("session_id", req.SessionID).
 		Str("user_id", authContext.UserID).
@@ -149,6 +166,9 @@ func (h *ChatHandler) handleStreamingChat(w http.ResponseWriter, r *http.Request
 		case response, ok := <-responseChan:
 			if !ok {
 				// Channel closed, streaming complete
+				logger.Info().
+					Str("session_id", req.SessionID).
+					Msg("Streaming chat response completed")
 				return
 			}
 
@@ -157,12 +177,13 @@ func (h *ChatHandler) handleStreamingChat(w http.ResponseWriter, r *http.Request
 				return
 			}
 
-			// If error or done, stop streaming
-			if response.Type == "error" || response.Type == "done" {
+			// Stop on an error; after done the stream stays open for the
+			// session metadata event until the channel is closed
+			if response.Type == "error" {
 				logger.Info().
 					Str("session_id", req.SessionID).
 					Str("type", response.Type).
-					Msg("Streaming chat response completed")
+					Msg("Streaming chat response failed")
 				return
 			}
 
@@ -311,9 +332,15 @@ func (h *ChatHandler) GetSessionMessages(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	suggestions, err := h.chatService.GetSessionSuggestions(ctx, sessionID)
+	if err != nil {
+		logger.Warn().Err(err).Str("session_id", sessionID).Msg("Failed to get session suggestions")
+	}
+
 	response := models.MessagesResponse{
-		SessionID: sessionID,
-		Messages:  messages,
+		SessionID:   sessionID,
+		Messages:    messages,
+		Suggestions: suggestions,
 	}
 
 	logger.Info().
@@ -361,14 +388,48 @@ func (h *ChatHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 		logger.Info().Str("session_id", sessionID).Msg("Skipping session ownership verification for debug user")
 	}
 
+	// forget=true also purges the session's embeddings, summaries and gaps, which
+	// otherwise outlive it as semantic memory
+	forget := false
+	if forgetParam := r.URL.Query().Get("forget"); forgetParam != "" {
+		parsed, err := strconv.ParseBool(forgetParam)
+		if err != nil {
+			apiErr := utils.NewValidationError("forget must be true or false", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		forget = parsed
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
 	logger.Info().
 		Str("session_id", sessionID).
 		Str("user_id", authContext.UserID).
+		Bool("forget", forget).
 		Msg("Deleting session")
 
+	if forget {
+		purged, err := h.retention.ForgetSession(ctx, sessionID)
+		if err != nil {
+			logger.Error().Err(err).
+				Str("session_id", sessionID).
+				Str("user_id", authContext.UserID).
+				Msg("Failed to forget session")
+			apiErr := utils.NewInternalError("Failed to delete session", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+
+		utils.WriteSuccess(w, map[string]interface{}{
+			"message":    "Session deleted and forgotten successfully",
+			"session_id": sessionID,
+			"purged":     purged,
+		})
+		return
+	}
+
 	err := h.chatService.DeleteSession(ctx, sessionID)
 	if err != nil {
 		logger.Error().Err(err).
@@ -392,6 +453,66 @@ func (h *ChatHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// RegenerateTitle handles POST /v1/sessions/{sessionID}/title
+func (h *ChatHandler) RegenerateTitle(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for title regeneration, using debug user")
+	}
+
+	sessionID := chi.URLParam(r, "sessionID")
+	if sessionID == "" {
+		apiErr := utils.NewValidationError("Session ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	// Verify session belongs to user (skip for debug user)
+	if authContext.UserID != "debug-user-id" {
+		if err := h.verifySessionOwnership(sessionID, authContext.UserID); err.Type != "" {
+			utils.WriteError(w, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.config.OllamaTimeout)
+	defer cancel()
+
+	logger.Info().
+		Str("session_id", sessionID).
+		Str("user_id", authContext.UserID).
+		Msg("Regenerating session title")
+
+	metadata, err := h.chatService.RegenerateSessionTitle(ctx, sessionID)
+	if err != nil {
+		logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to regenerate session title")
+		if err.Error() == "session not found" {
+			utils.WriteError(w, utils.NewNotFoundError("Session not found", r.URL.Path))
+			return
+		}
+		utils.WriteError(w, utils.NewInternalError("Failed to regenerate session title", r.URL.Path))
+		return
+	}
+
+	logger.Info().
+		Str("session_id", sessionID).
+		Str("title", metadata.Title).
+		Msg("Session title regenerated successfully")
+
+	utils.WriteSuccess(w, metadata)
+}
+
 // SearchMemory handles POST /v1/memory/search
 func (h *ChatHandler) SearchMemory(w http.ResponseWriter, r *http.Request) {
 	logger := utils.FromContext(r.Context())
@@ -403,6 +524,8 @@ func (h *ChatHandler) SearchMemory(w http.ResponseWriter, r *http.Request) {
 		Query     string `json:"query"`
 		SessionID string `json:"session_id,omitempty"`
 		Limit     int    `json:"limit,omitempty"`
+		EfSearch  int    `json:"ef_search,omitempty"`
+		Probes    int    `json:"probes,omitempty"`
 	}
 
 	if err := utils.ParseJSON(r, &req); err != nil {
@@ -422,6 +545,13 @@ func (h *ChatHandler) SearchMemory(w http.ResponseWriter, r *http.Request) {
 		req.Limit = 10 // Default limit
 	}
 
+	tuning := database.SearchTuning{EfSearch: req.EfSearch, Probes: req.Probes}
+	if err := services.ValidateSearchTuning(tuning); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
@@ -431,7 +561,7 @@ func (h *ChatHandler) SearchMemory(w http.ResponseWriter, r *http.Request) {
 		Int("limit", req.Limit).
 		Msg("Searching semantic memory")
 
-	results, err := h.semanticMemory.SearchSimilarMessages(ctx, req.Query, req.Limit, req.SessionID)
+	results, err := h.semanticMemory.SearchSimilarMessages(ctx, req.Query, req.Limit, req.SessionID, tuning)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to search semantic memory")
 		apiErr := utils.NewInternalError("Failed to search memory", r.URL.Path)
@@ -552,6 +682,82 @@ func (h *ChatHandler) CreateMemorySummary(w http.ResponseWriter, r *http.Request
 	utils.WriteSuccess(w, summary)
 }
 
+// GenerateMemorySummary handles POST /v1/memory/summaries/generate
+func (h *ChatHandler) GenerateMemorySummary(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for summary generation, using debug user")
+	}
+
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse summary generation request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if req.SessionID == "" {
+		apiErr := utils.NewValidationError("session_id field is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	// Verify session belongs to user (skip for debug user)
+	if authContext.UserID != "debug-user-id" {
+		if err := h.verifySessionOwnership(req.SessionID, authContext.UserID); err.Type != "" {
+			utils.WriteError(w, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.config.OllamaTimeout)
+	defer cancel()
+
+	logger.Info().
+		Str("session_id", req.SessionID).
+		Str("user_id", authContext.UserID).
+		Msg("Generating conversation summary")
+
+	summary, err := h.summarizer.SummarizeSession(ctx, req.SessionID)
+	if err != nil {
+		logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Failed to generate conversation summary")
+		if err.Error() == "session not found" {
+			utils.WriteError(w, utils.NewNotFoundError("Session not found", r.URL.Path))
+			return
+		}
+		utils.WriteError(w, utils.NewInternalError("Failed to generate conversation summary", r.URL.Path))
+		return
+	}
+
+	if summary == nil {
+		apiErr := utils.NewValidationError("Session has no new messages to summarize", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	logger.Info().
+		Str("session_id", req.SessionID).
+		Int("message_count", summary.MessageCount).
+		Msg("Conversation summary generated")
+
+	utils.WriteSuccess(w, summary)
+}
+
 // GetMemoryGaps handles GET /v1/memory/gaps/{sessionID}
 func (h *ChatHandler) GetMemoryGaps(w http.ResponseWriter, r *http.Request) {
 	logger := utils.FromContext(r.Context())
@@ -573,8 +779,8 @@ func (h *ChatHandler) GetMemoryGaps(w http.ResponseWriter, r *http.Request) {
 		Str("session_id", sessionID).
 		Msg("Detecting memory gaps")
 
-	// Default threshold of 1 hour
-	threshold := 1 * time.Hour
+	// Default to the configured gap threshold
+	threshold := h.config.MemoryGapThreshold
 	if thresholdParam := r.URL.Query().Get("threshold"); thresholdParam != "" {
 		if parsedThreshold, err := time.ParseDuration(thresholdParam); err == nil {
 			threshold = parsedThreshold
@@ -22,7 +22,7 @@ type HealthHandler struct {
 // NewHealthHandler creates a new health handler
 func NewHealthHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *HealthHandler {
 	// Create Ollama client for health checks
-	ollamaClient := services.NewOllamaClient(cfg.OllamaHost, cfg.OllamaTimeout, logger)
+	ollamaClient := services.NewOllamaClient(cfg, logger)
 
 	return &HealthHandler{
 		db:           db,
@@ -55,6 +55,9 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Check Ollama health
 	ollamaStatus := h.checkOllamaHealth(ctx)
 	response.Services["ollama"] = ollamaStatus
+	if h.ollamaClient != nil {
+		response.Metadata["ollama_hosts"] = h.ollamaClient.Pool().Status()
+	}
 
 	// Determine overall status
 	overallStatus := models.StatusHealthy
@@ -124,7 +127,8 @@ func (h *HealthHandler) checkDatabaseHealth(ctx context.Context) string {
 	}
 }
 
-// checkOllamaHealth checks if Ollama is healthy
+// checkOllamaHealth checks the Ollama hosts: degraded when some of them are down and
+// unhealthy when all of them are
 func (h *HealthHandler) checkOllamaHealth(ctx context.Context) string {
 	if h.ollamaClient == nil {
 		return models.StatusUnhealthy
@@ -142,6 +146,11 @@ func (h *HealthHandler) checkOllamaHealth(ctx context.Context) string {
 			h.logger.Error().Err(err).Msg("Ollama health check failed")
 			return models.StatusUnhealthy
 		}
+		for _, host := range h.ollamaClient.Pool().Status() {
+			if host.Status != models.StatusHealthy {
+				return models.StatusDegraded
+			}
+		}
 		return models.StatusHealthy
 	case <-ctx.Done():
 		h.logger.Error().Msg("Ollama health check timed out")
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// JobsHandler handles administration of the background job queue
+type JobsHandler struct {
+	jobs   *services.JobQueue
+	config *config.Config
+	logger *utils.Logger
+}
+
+// NewJobsHandler creates a new jobs handler
+func NewJobsHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *JobsHandler {
+	return &JobsHandler{
+		jobs:   services.NewJobQueue(db, cfg, logger),
+		config: cfg,
+		logger: logger.WithComponent("jobs_handler"),
+	}
+}
+
+// ListJobs handles GET /v1/admin/jobs
+func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.JobStatusPending, models.JobStatusRunning, models.JobStatusCompleted, models.JobStatusDead:
+	default:
+		apiErr := utils.NewValidationError("status must be one of pending, running, completed or dead", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+	jobType := r.URL.Query().Get("type")
+
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > 200 {
+			apiErr := utils.NewValidationError("limit must be between 1 and 200", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		parsed, err := strconv.Atoi(offsetParam)
+		if err != nil || parsed < 0 {
+			apiErr := utils.NewValidationError("offset must be a non-negative integer", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		offset = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	jobs, err := h.jobs.ListJobs(ctx, status, jobType, limit, offset)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list jobs")
+		apiErr := utils.NewInternalError("Failed to retrieve jobs", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	counts, err := h.jobs.CountJobs(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to count jobs")
+		apiErr := utils.NewInternalError("Failed to retrieve jobs", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	utils.WriteSuccess(w, models.JobsResponse{
+		Jobs:   jobs,
+		Counts: counts,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// GetJob handles GET /v1/admin/jobs/{jobID}
+func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	job, err := h.jobs.GetJob(ctx, jobID)
+	if err != nil {
+		logger.Error().Err(err).Str("job_id", jobID).Msg("Failed to get job")
+		utils.WriteError(w, h.jobError(err, r.URL.Path, "Failed to retrieve job"))
+		return
+	}
+
+	utils.WriteSuccess(w, job)
+}
+
+// RetryJob handles POST /v1/admin/jobs/{jobID}/retry
+func (h *JobsHandler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	job, err := h.jobs.RetryJob(ctx, jobID)
+	if err != nil {
+		logger.Error().Err(err).Str("job_id", jobID).Msg("Failed to retry job")
+		utils.WriteError(w, h.jobError(err, r.URL.Path, "Failed to retry job"))
+		return
+	}
+
+	utils.WriteSuccess(w, job)
+}
+
+// BackfillEmbeddings handles POST /v1/admin/embeddings/backfill
+func (h *JobsHandler) BackfillEmbeddings(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	jobID, err := h.jobs.Enqueue(ctx, services.JobTypeEmbeddingBackfill, nil, services.JobOptions{
+		DedupeKey: services.JobTypeEmbeddingBackfill,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to queue embedding backfill")
+		apiErr := utils.NewInternalError("Failed to queue embedding backfill", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+	if jobID == "" {
+		apiErr := utils.NewValidationError("an embedding backfill is already queued", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	job, err := h.jobs.GetJob(ctx, jobID)
+	if err != nil {
+		logger.Error().Err(err).Str("job_id", jobID).Msg("Failed to get job")
+		apiErr := utils.NewInternalError("Failed to retrieve job", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	logger.Info().Str("job_id", jobID).Msg("Embedding backfill queued")
+
+	utils.WriteCreated(w, job)
+}
+
+// jobError maps job queue errors to API errors
+func (h *JobsHandler) jobError(err error, path, fallback string) utils.APIError {
+	switch err.Error() {
+	case "job not found":
+		return utils.NewNotFoundError("Job not found", path)
+	case "job cannot be retried", "an equivalent job is already pending":
+		return utils.NewValidationError(err.Error(), path)
+	default:
+		return utils.NewInternalError(fallback, path)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,19 +21,29 @@ import (
 // ModelsHandler handles model management requests
 type ModelsHandler struct {
 	modelManager *services.ModelManager
+	imports      *services.ModelImportService
+	benchmarks   *services.ModelBenchmarkService
 	logger       *utils.Logger
 }
 
 // NewModelsHandler creates a new models handler
 func NewModelsHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *ModelsHandler {
 	// Create Ollama client
-	ollamaClient := services.NewOllamaClient(cfg.OllamaHost, cfg.OllamaTimeout, logger)
+	ollamaClient := services.NewOllamaClient(cfg, logger)
 	
 	// Create model manager
 	modelManager := services.NewModelManager(db, ollamaClient, logger)
+	modelManager.SetJobQueue(services.NewJobQueue(db, cfg, logger))
+	modelManager.SetCatalogSources(services.NewCatalogSources(cfg, ollamaClient)...)
+	modelManager.SetStorageBudget(cfg.ModelStorageBudget, cfg.EmbeddingModel, cfg.TitleModel, cfg.SummaryModel, cfg.FactModel)
+
+	benchmarks := services.NewModelBenchmarkService(db, modelManager, ollamaClient, cfg, logger)
+	benchmarks.SetJobQueue(services.NewJobQueue(db, cfg, logger))
 
 	return &ModelsHandler{
 		modelManager: modelManager,
+		imports:      services.NewModelImportService(modelManager, ollamaClient, cfg, logger),
+		benchmarks:   benchmarks,
 		logger:       logger.WithComponent("models_handler"),
 	}
 }
@@ -397,6 +410,14 @@ func (h *ModelsHandler) UpdateModelConfig(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if req.KeepAlive != nil {
+		if err := services.ValidateKeepAlive(*req.KeepAlive); err != nil {
+			apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
@@ -550,29 +571,55 @@ func (h *ModelsHandler) DownloadModel(w http.ResponseWriter, r *http.Request) {
 			utils.WriteError(w, apiErr)
 			return
 		}
+
+		if err.Error() == "model download already in progress" {
+			apiErr := utils.NewValidationError("Model download already in progress", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
 		
 		apiErr := utils.NewInternalError("Failed to start model download", r.URL.Path)
 		utils.WriteError(w, apiErr)
 		return
 	}
 
-	logger.Info().Str("model_name", req.Name).Str("model_id", response.ID).Msg("Model download started successfully")
+	logger.Info().Str("model_name", req.Name).Str("model_id", response.ID).Str("download_id", response.DownloadID).Msg("Model download queued successfully")
 	utils.WriteSuccess(w, response)
 }
 
-// GetAvailableModels handles GET /v1/models/available
+// GetAvailableModels handles GET /v1/models/available. The catalog can be searched with
+// q and filtered by family, size (parameter size, e.g. 8b), max_size (download size in
+// bytes), capability and tag.
 func (h *ModelsHandler) GetAvailableModels(w http.ResponseWriter, r *http.Request) {
 	logger := utils.FromContext(r.Context())
 	if logger == nil {
 		logger = h.logger
 	}
 
+	query := r.URL.Query()
+	filter := models.CatalogFilter{
+		Query:      query.Get("q"),
+		Family:     query.Get("family"),
+		Size:       query.Get("size"),
+		Capability: query.Get("capability"),
+		Tag:        query.Get("tag"),
+	}
+	if maxSizeParam := query.Get("max_size"); maxSizeParam != "" {
+		parsed, err := strconv.ParseInt(maxSizeParam, 10, 64)
+		if err != nil || parsed < 1 {
+			apiErr := utils.NewValidationError("max_size must be a positive number of bytes", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		filter.MaxSize = parsed
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
 	logger.Info().Msg("Getting available models for download")
 
-	availableModels, err := h.modelManager.GetAvailableModelsFromOllama(ctx)
+	catalog, err := h.modelManager.GetCatalogModels(ctx, filter)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to get available models")
 		apiErr := utils.NewInternalError("Failed to retrieve available models", r.URL.Path)
@@ -580,18 +627,34 @@ func (h *ModelsHandler) GetAvailableModels(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	response := struct {
-		Models []string `json:"models"`
-		Total  int      `json:"total"`
-	}{
-		Models: availableModels,
-		Total:  len(availableModels),
-	}
+	response := catalogResponse(catalog)
 
-	logger.Info().Int("model_count", len(availableModels)).Msg("Available models retrieved successfully")
+	logger.Info().Int("model_count", len(catalog)).Msg("Available models retrieved successfully")
 	utils.WriteSuccess(w, response)
 }
 
+// availableModelsResponse lists catalog models, with their names alone in models for
+// clients that only need those
+type availableModelsResponse struct {
+	Models    []string               `json:"models"`
+	Catalog   []models.CatalogModel  `json:"catalog"`
+	Total     int                    `json:"total"`
+	CacheInfo map[string]interface{} `json:"cache_info,omitempty"`
+}
+
+// catalogResponse builds the response listing catalog models
+func catalogResponse(catalog []models.CatalogModel) availableModelsResponse {
+	names := make([]string, 0, len(catalog))
+	for _, entry := range catalog {
+		names = append(names, entry.Name)
+	}
+	return availableModelsResponse{
+		Models:  names,
+		Catalog: catalog,
+		Total:   len(catalog),
+	}
+}
+
 // GetModelDownloadStatus handles GET /v1/models/{id}/download-status
 func (h *ModelsHandler) GetModelDownloadStatus(w http.ResponseWriter, r *http.Request) {
 	logger := utils.FromContext(r.Context())
@@ -612,7 +675,7 @@ func (h *ModelsHandler) GetModelDownloadStatus(w http.ResponseWriter, r *http.Re
 
 	logger.Info().Str("model_id", modelID).Msg("Getting model download status")
 
-	model, err := h.modelManager.GetModelDownloadStatus(ctx, modelID)
+	model, download, err := h.modelManager.GetModelDownloadStatus(ctx, modelID)
 	if err != nil {
 		logger.Error().Err(err).Str("model_id", modelID).Msg("Failed to get model download status")
 		
@@ -628,21 +691,182 @@ func (h *ModelsHandler) GetModelDownloadStatus(w http.ResponseWriter, r *http.Re
 	}
 
 	response := struct {
-		ID       string  `json:"id"`
-		Name     string  `json:"name"`
-		Status   string  `json:"status"`
-		Progress float64 `json:"progress,omitempty"`
+		ID       string                `json:"id"`
+		Name     string                `json:"name"`
+		Status   string                `json:"status"`
+		Progress float64               `json:"progress,omitempty"`
+		Download *models.ModelDownload `json:"download,omitempty"` // Most recent download with per-layer progress
 	}{
 		ID:       model.ID,
 		Name:     model.Name,
 		Status:   model.Status,
 		Progress: model.Progress,
+		Download: download,
 	}
 
 	logger.Info().Str("model_id", modelID).Str("status", model.Status).Msg("Model download status retrieved successfully")
 	utils.WriteSuccess(w, response)
 }
 
+// CancelDownload handles DELETE /v1/models/{modelID}/download
+func (h *ModelsHandler) CancelDownload(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	modelID := chi.URLParam(r, "modelID")
+	if modelID == "" {
+		apiErr := utils.NewValidationError("Model ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	download, err := h.modelManager.CancelDownload(ctx, modelID)
+	if err != nil {
+		if err.Error() == "download not found" {
+			apiErr := utils.NewNotFoundError("No active download for this model", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+
+		logger.Error().Err(err).Str("model_id", modelID).Msg("Failed to cancel model download")
+		apiErr := utils.NewInternalError("Failed to cancel model download", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	logger.Info().Str("model_id", modelID).Str("download_id", download.ID).Msg("Model download cancelled")
+	utils.WriteSuccess(w, download)
+}
+
+// downloadStreamSnapshotInterval is how often the download stream resends the active
+// downloads. It keeps the connection alive and catches up on progress made by other
+// instances.
+const downloadStreamSnapshotInterval = 15 * time.Second
+
+// StreamDownloads handles GET /v1/models/downloads/stream. It sends a snapshot of the
+// active downloads, then pushes download events as Server-Sent Events until the client
+// disconnects.
+func (h *ModelsHandler) StreamDownloads(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	events, unsubscribe := h.modelManager.SubscribeModelEvents()
+	defer unsubscribe()
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debug().Err(err).Msg("Failed to clear write deadline for download stream")
+	}
+
+	utils.WriteSSEHeaders(w)
+	w.WriteHeader(http.StatusOK)
+
+	if err := h.writeDownloadSnapshot(w, r); err != nil {
+		logger.Debug().Err(err).Msg("Download stream closed")
+		return
+	}
+
+	ticker := time.NewTicker(downloadStreamSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Download == nil {
+				continue
+			}
+			if err := utils.WriteSSEEvent(w, event.Type, event); err != nil {
+				logger.Debug().Err(err).Msg("Download stream closed")
+				return
+			}
+		case <-ticker.C:
+			if err := h.writeDownloadSnapshot(w, r); err != nil {
+				logger.Debug().Err(err).Msg("Download stream closed")
+				return
+			}
+		}
+	}
+}
+
+// StreamModelEvents handles GET /v1/models/events. It pushes the model.added,
+// model.removed and model.updated events of model syncs as Server-Sent Events until the
+// client disconnects.
+func (h *ModelsHandler) StreamModelEvents(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	events, unsubscribe := h.modelManager.SubscribeModelEvents()
+	defer unsubscribe()
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debug().Err(err).Msg("Failed to clear write deadline for model event stream")
+	}
+
+	utils.WriteSSEHeaders(w)
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(downloadStreamSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Model == nil {
+				continue
+			}
+			if err := utils.WriteSSEEvent(w, event.Type, event); err != nil {
+				logger.Debug().Err(err).Msg("Model event stream closed")
+				return
+			}
+		case <-ticker.C:
+			if err := utils.WriteSSEComment(w, "keepalive"); err != nil {
+				logger.Debug().Err(err).Msg("Model event stream closed")
+				return
+			}
+		}
+	}
+}
+
+// writeDownloadSnapshot sends the active downloads as a snapshot event
+func (h *ModelsHandler) writeDownloadSnapshot(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	downloads, err := h.modelManager.ListActiveDownloads(ctx)
+	if err != nil {
+		h.logger.Warn().Err(err).Msg("Failed to list active downloads for stream")
+		return utils.WriteSSEComment(w, "keepalive")
+	}
+
+	return utils.WriteSSEEvent(w, "snapshot", struct {
+		Downloads []models.ModelDownload `json:"downloads"`
+		Timestamp time.Time              `json:"timestamp"`
+	}{
+		Downloads: downloads,
+		Timestamp: time.Now(),
+	})
+}
+
 // RefreshAvailableModels handles POST /v1/models/available/refresh
 func (h *ModelsHandler) RefreshAvailableModels(w http.ResponseWriter, r *http.Request) {
 	logger := utils.FromContext(r.Context())
@@ -663,7 +887,7 @@ func (h *ModelsHandler) RefreshAvailableModels(w http.ResponseWriter, r *http.Re
 	}
 
 	// Get the updated list
-	availableModels, err := h.modelManager.GetAvailableModelsFromOllama(ctx)
+	catalog, err := h.modelManager.GetCatalogModels(ctx, models.CatalogFilter{})
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to get available models after refresh")
 		apiErr := utils.NewInternalError("Failed to retrieve available models after refresh", r.URL.Path)
@@ -671,17 +895,10 @@ func (h *ModelsHandler) RefreshAvailableModels(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	response := struct {
-		Models    []string               `json:"models"`
-		Total     int                    `json:"total"`
-		CacheInfo map[string]interface{} `json:"cache_info"`
-	}{
-		Models:    availableModels,
-		Total:     len(availableModels),
-		CacheInfo: h.modelManager.GetCacheInfo(),
-	}
+	response := catalogResponse(catalog)
+	response.CacheInfo = h.modelManager.GetCacheInfo()
 
-	logger.Info().Int("model_count", len(availableModels)).Msg("Available models cache refreshed successfully")
+	logger.Info().Int("model_count", len(catalog)).Msg("Available models cache refreshed successfully")
 	utils.WriteSuccess(w, response)
 }
 
@@ -698,4 +915,670 @@ func (h *ModelsHandler) GetCacheInfo(w http.ResponseWriter, r *http.Request) {
 
 	logger.Info().Msg("Cache information retrieved successfully")
 	utils.WriteSuccess(w, cacheInfo)
-}
\ No newline at end of file
+}
+// modelLoadTimeout bounds loading a model into memory, which can take minutes for large
+// models on slow disks
+const modelLoadTimeout = 10 * time.Minute
+
+// ListRunningModels handles GET /v1/models/running
+func (h *ModelsHandler) ListRunningModels(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	running, err := h.modelManager.ListRunningModels(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list running models")
+		apiErr := utils.NewOllamaError("Failed to list running models", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	response := struct {
+		Models        []models.RunningModel `json:"models"`
+		Total         int                   `json:"total"`
+		TotalSize     int64                 `json:"total_size"`
+		TotalSizeVRAM int64                 `json:"total_size_vram"`
+	}{
+		Models: running,
+		Total:  len(running),
+	}
+	for _, model := range running {
+		response.TotalSize += model.Size
+		response.TotalSizeVRAM += model.SizeVRAM
+	}
+
+	utils.WriteSuccess(w, response)
+}
+
+// LoadModel handles POST /v1/models/{id}/load. The optional body sets keep_alive, which
+// defaults to the model's configuration.
+func (h *ModelsHandler) LoadModel(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	modelID := chi.URLParam(r, "modelID")
+	if modelID == "" {
+		apiErr := utils.NewValidationError("Model ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	var req models.ModelLoadRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+	if err := services.ValidateKeepAlive(req.KeepAlive); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelLoadTimeout)
+	defer cancel()
+
+	logger.Info().Str("model_id", modelID).Str("keep_alive", req.KeepAlive).Msg("Loading model")
+
+	running, err := h.modelManager.LoadModel(ctx, modelID, req.KeepAlive)
+	if err != nil {
+		h.writeModelRuntimeError(w, r, logger, modelID, err, "Failed to load model")
+		return
+	}
+
+	logger.Info().Str("model_id", modelID).Str("model", running.Name).Msg("Model loaded")
+	utils.WriteSuccess(w, running)
+}
+
+// UnloadModel handles POST /v1/models/{id}/unload
+func (h *ModelsHandler) UnloadModel(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	modelID := chi.URLParam(r, "modelID")
+	if modelID == "" {
+		apiErr := utils.NewValidationError("Model ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := h.modelManager.UnloadModel(ctx, modelID); err != nil {
+		h.writeModelRuntimeError(w, r, logger, modelID, err, "Failed to unload model")
+		return
+	}
+
+	logger.Info().Str("model_id", modelID).Msg("Model unloaded")
+	utils.WriteSuccess(w, map[string]string{
+		"message": "Model unloaded",
+	})
+}
+
+// writeModelRuntimeError maps an error from loading or unloading a model to an API error
+func (h *ModelsHandler) writeModelRuntimeError(w http.ResponseWriter, r *http.Request, logger *utils.Logger, modelID string, err error, message string) {
+	switch err.Error() {
+	case "model not found":
+		utils.WriteError(w, utils.NewNotFoundError("Model not found", r.URL.Path))
+		return
+	case "model is not available":
+		utils.WriteError(w, utils.NewValidationError("Model is not available", r.URL.Path))
+		return
+	case "model is not loaded":
+		utils.WriteError(w, utils.NewValidationError("Model is not loaded", r.URL.Path))
+		return
+	}
+
+	logger.Error().Err(err).Str("model_id", modelID).Msg(message)
+	utils.WriteError(w, utils.NewOllamaError(message, r.URL.Path))
+}
+
+// modelEvictionTimeout bounds an eviction, which deletes models on every Ollama host
+const modelEvictionTimeout = 60 * time.Second
+
+// PlanModelEviction handles GET /v1/models/storage/plan. It lists the models an eviction
+// down to target_bytes, or to the storage budget, would delete without deleting them.
+func (h *ModelsHandler) PlanModelEviction(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var targetBytes int64
+	if value := r.URL.Query().Get("target_bytes"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			apiErr := utils.NewValidationError("target_bytes must be a number of bytes", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		targetBytes = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	plan, err := h.modelManager.PlanEviction(ctx, targetBytes)
+	if err != nil {
+		h.writeModelEvictionError(w, r, logger, err, "Failed to plan model eviction")
+		return
+	}
+
+	utils.WriteSuccess(w, plan)
+}
+
+// EvictModels handles POST /v1/admin/models/storage/evict. The optional body sets target_bytes,
+// which defaults to the storage budget, and dry_run.
+func (h *ModelsHandler) EvictModels(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var req models.ModelEvictionRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelEvictionTimeout)
+	defer cancel()
+
+	var plan *models.ModelEvictionPlan
+	var err error
+	if req.DryRun {
+		plan, err = h.modelManager.PlanEviction(ctx, req.TargetBytes)
+	} else {
+		plan, err = h.modelManager.EvictModels(ctx, req.TargetBytes)
+	}
+	if err != nil {
+		h.writeModelEvictionError(w, r, logger, err, "Failed to evict models")
+		return
+	}
+
+	if !req.DryRun {
+		logger.Info().
+			Int("models", len(plan.Models)).
+			Int64("freed_bytes", plan.FreedBytes).
+			Int64("shortfall", plan.Shortfall).
+			Msg("Models evicted")
+	}
+	utils.WriteSuccess(w, plan)
+}
+
+// writeModelEvictionError maps an error from planning or running an eviction to an API error
+func (h *ModelsHandler) writeModelEvictionError(w http.ResponseWriter, r *http.Request, logger *utils.Logger, err error, message string) {
+	if strings.Contains(err.Error(), "target_bytes") {
+		utils.WriteError(w, utils.NewValidationError(err.Error(), r.URL.Path))
+		return
+	}
+
+	logger.Error().Err(err).Msg(message)
+	utils.WriteError(w, utils.NewInternalError(message, r.URL.Path))
+}
+
+// BenchmarkModel handles POST /v1/models/{id}/benchmark. The optional body sets the
+// prompts, which default to the configured suite, the runs and max_tokens. The benchmark
+// is queued; its results are read with GET /v1/models/benchmarks/{id}.
+func (h *ModelsHandler) BenchmarkModel(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	modelID := chi.URLParam(r, "modelID")
+	if modelID == "" {
+		apiErr := utils.NewValidationError("Model ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	var req models.ModelBenchmarkRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	benchmark, err := h.benchmarks.QueueBenchmark(ctx, modelID, req)
+	if err != nil {
+		h.writeBenchmarkError(w, r, logger, err, "Failed to queue model benchmark")
+		return
+	}
+
+	logger.Info().Str("model_id", modelID).Str("benchmark_id", benchmark.ID).Msg("Model benchmark queued")
+	utils.WriteCreated(w, benchmark)
+}
+
+// ListModelBenchmarks handles GET /v1/models/{id}/benchmarks
+func (h *ModelsHandler) ListModelBenchmarks(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	modelID := chi.URLParam(r, "modelID")
+	if modelID == "" {
+		apiErr := utils.NewValidationError("Model ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	limit := 20
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > 100 {
+			apiErr := utils.NewValidationError("limit must be between 1 and 100", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	benchmarks, err := h.benchmarks.ListBenchmarks(ctx, modelID, limit)
+	if err != nil {
+		h.writeBenchmarkError(w, r, logger, err, "Failed to list model benchmarks")
+		return
+	}
+
+	utils.WriteSuccess(w, map[string]interface{}{
+		"benchmarks": benchmarks,
+		"total":      len(benchmarks),
+	})
+}
+
+// GetBenchmark handles GET /v1/models/benchmarks/{benchmarkID}
+func (h *ModelsHandler) GetBenchmark(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	benchmarkID := chi.URLParam(r, "benchmarkID")
+	if benchmarkID == "" {
+		apiErr := utils.NewValidationError("Benchmark ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	benchmark, err := h.benchmarks.GetBenchmark(ctx, benchmarkID)
+	if err != nil {
+		h.writeBenchmarkError(w, r, logger, err, "Failed to get benchmark")
+		return
+	}
+
+	utils.WriteSuccess(w, benchmark)
+}
+
+// CompareBenchmarks handles GET /v1/models/benchmarks/compare. Models are ranked by their
+// latest completed benchmark on the sort metric; model_ids limits the comparison to a
+// comma-separated list of models.
+func (h *ModelsHandler) CompareBenchmarks(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var modelIDs []string
+	for _, id := range strings.Split(r.URL.Query().Get("model_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			modelIDs = append(modelIDs, id)
+		}
+	}
+	metric := r.URL.Query().Get("sort")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	rankings, err := h.benchmarks.CompareModels(ctx, metric, modelIDs)
+	if err != nil {
+		h.writeBenchmarkError(w, r, logger, err, "Failed to compare model benchmarks")
+		return
+	}
+
+	if metric == "" {
+		metric = models.BenchmarkMetricTokensPerSecond
+	}
+	utils.WriteSuccess(w, map[string]interface{}{
+		"sort":   metric,
+		"models": rankings,
+		"total":  len(rankings),
+	})
+}
+
+// writeBenchmarkError maps an error from queueing or reading benchmarks to an API error
+func (h *ModelsHandler) writeBenchmarkError(w http.ResponseWriter, r *http.Request, logger *utils.Logger, err error, message string) {
+	switch msg := err.Error(); {
+	case msg == "model not found":
+		utils.WriteError(w, utils.NewNotFoundError("Model not found", r.URL.Path))
+		return
+	case msg == "benchmark not found":
+		utils.WriteError(w, utils.NewNotFoundError("Benchmark not found", r.URL.Path))
+		return
+	case msg == "model is not available", msg == "model cannot generate text",
+		strings.HasPrefix(msg, "runs "), strings.HasPrefix(msg, "max_tokens "),
+		strings.HasPrefix(msg, "prompt"), strings.HasPrefix(msg, "sort "):
+		utils.WriteError(w, utils.NewValidationError(msg, r.URL.Path))
+		return
+	}
+
+	logger.Error().Err(err).Msg(message)
+	utils.WriteError(w, utils.NewInternalError(message, r.URL.Path))
+}
+
+// modelCreateTimeout bounds a model creation, which may convert or quantize weights
+const modelCreateTimeout = 60 * time.Minute
+
+// CreateModel handles POST /v1/models/create. Unless stream is false, Ollama's progress
+// is streamed as Server-Sent Events: progress events, then a done event with the model
+// or an error event.
+func (h *ModelsHandler) CreateModel(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var req models.ModelCreateRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse model create request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateModelCreateRequest(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelCreateTimeout)
+	defer cancel()
+
+	logger.Info().Str("model_name", req.Name).Msg("Creating model from Modelfile")
+
+	streamModelCreation(w, r, logger, req.Stream == nil || *req.Stream, func(progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+		return h.modelManager.CreateCustomModel(ctx, req, progressChan)
+	})
+}
+
+// streamModelCreation runs a model creation or import, streaming its progress as
+// Server-Sent Events (progress events, then a done event with the model or an error
+// event) or, without streaming, responding with the model once it is created. run must
+// close progressChan when it returns.
+func streamModelCreation(w http.ResponseWriter, r *http.Request, logger *utils.Logger, stream bool, run func(progressChan chan<- models.ModelCreateProgress) (*models.Model, error)) {
+	// Creation outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debug().Err(err).Msg("Failed to clear write deadline for model creation")
+	}
+
+	progressChan := make(chan models.ModelCreateProgress, 100)
+	type createResult struct {
+		model *models.Model
+		err   error
+	}
+	resultChan := make(chan createResult, 1)
+	go func() {
+		model, err := run(progressChan)
+		resultChan <- createResult{model: model, err: err}
+	}()
+
+	if stream {
+		utils.WriteSSEHeaders(w)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	// Drain progress until creation finishes, even once the client is gone
+	writeFailed := false
+	for progress := range progressChan {
+		if stream && !writeFailed {
+			writeFailed = utils.WriteSSEEvent(w, "progress", progress) != nil
+		}
+	}
+	result := <-resultChan
+
+	if result.err != nil {
+		logger.Error().Err(result.err).Msg("Failed to create model")
+	} else {
+		logger.Info().Str("model_name", result.model.Name).Str("model_id", result.model.ID).Msg("Model created successfully")
+	}
+
+	if stream {
+		if writeFailed {
+			return
+		}
+		if result.err != nil {
+			utils.WriteSSEEvent(w, "error", map[string]string{"error": result.err.Error()})
+			return
+		}
+		utils.WriteSSEEvent(w, "done", result.model)
+		return
+	}
+
+	if result.err != nil {
+		utils.WriteError(w, modelDerivationError(result.err, r.URL.Path))
+		return
+	}
+	utils.WriteCreated(w, result.model)
+}
+
+// CopyModel handles POST /v1/models/{modelID}/copy
+func (h *ModelsHandler) CopyModel(w http.ResponseWriter, r *http.Request) {
+	h.copyModel(w, r, false)
+}
+
+// AliasModel handles POST /v1/models/{modelID}/alias
+func (h *ModelsHandler) AliasModel(w http.ResponseWriter, r *http.Request) {
+	h.copyModel(w, r, true)
+}
+
+// copyModel copies a model under a new name, recorded as a copy or as an alias
+func (h *ModelsHandler) copyModel(w http.ResponseWriter, r *http.Request, alias bool) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	modelID := chi.URLParam(r, "modelID")
+	if modelID == "" {
+		apiErr := utils.NewValidationError("Model ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	var req models.ModelCopyRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse model copy request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateModelCopyRequest(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	model, err := h.modelManager.CopyModel(ctx, modelID, req, alias)
+	if err != nil {
+		logger.Error().Err(err).Str("model_id", modelID).Str("name", req.Name).Bool("alias", alias).Msg("Failed to copy model")
+		utils.WriteError(w, modelDerivationError(err, r.URL.Path))
+		return
+	}
+
+	logger.Info().Str("source_model_id", modelID).Str("model_id", model.ID).Str("name", model.Name).Bool("alias", alias).Msg("Model copied successfully")
+	utils.WriteCreated(w, model)
+}
+
+// modelDerivationError maps an error creating, importing or copying a model to an API error
+func modelDerivationError(err error, path string) utils.APIError {
+	switch {
+	case err.Error() == "model not found":
+		return utils.NewNotFoundError("Model not found", path)
+	case err.Error() == "import file not found":
+		return utils.NewNotFoundError("Import file not found", path)
+	case err.Error() == "model import directory not configured",
+		err.Error() == "file must be a .gguf file",
+		err.Error() == "file must be inside the import directory",
+		strings.HasPrefix(err.Error(), "invalid GGUF file"):
+		return utils.NewValidationError(err.Error(), path)
+	case err.Error() == "model is not available":
+		return utils.NewValidationError("Model is not available", path)
+	case strings.HasSuffix(err.Error(), "already exists"):
+		return utils.NewValidationError("A model with this name already exists", path)
+	case strings.HasPrefix(err.Error(), "failed to create model:"), strings.HasPrefix(err.Error(), "failed to copy model:"):
+		return utils.NewOllamaError(err.Error(), path)
+	}
+	return utils.NewInternalError("Failed to register model", path)
+}
+
+// ListImportFiles handles GET /v1/models/import/files
+func (h *ModelsHandler) ListImportFiles(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	files, err := h.imports.ListImportFiles(ctx)
+	if err != nil {
+		if err.Error() == "model import directory not configured" {
+			apiErr := utils.NewValidationError("Model import directory not configured", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+
+		logger.Error().Err(err).Msg("Failed to list import files")
+		apiErr := utils.NewInternalError("Failed to list import files", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	utils.WriteSuccess(w, struct {
+		Files []models.ModelImportFile `json:"files"`
+		Total int                      `json:"total"`
+	}{
+		Files: files,
+		Total: len(files),
+	})
+}
+
+// ImportModel handles POST /v1/models/import, importing a GGUF file from the server-side
+// import directory
+func (h *ModelsHandler) ImportModel(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var req models.ModelImportRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse model import request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateModelImportRequest(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+	if req.File == "" {
+		apiErr := utils.NewValidationError("file is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelCreateTimeout)
+	defer cancel()
+
+	logger.Info().Str("model_name", req.Name).Str("file", req.File).Msg("Importing GGUF file")
+
+	streamModelCreation(w, r, logger, req.Stream == nil || *req.Stream, func(progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+		return h.imports.ImportFile(ctx, req, progressChan)
+	})
+}
+
+// UploadModel handles POST /v1/models/import/upload. The request body is the GGUF file;
+// name, display_name, description, filename and stream are query parameters.
+func (h *ModelsHandler) UploadModel(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	query := r.URL.Query()
+	req := models.ModelImportRequest{
+		Name:        query.Get("name"),
+		DisplayName: query.Get("display_name"),
+		Description: query.Get("description"),
+	}
+	if stream := query.Get("stream"); stream != "" {
+		value := stream != "false"
+		req.Stream = &value
+	}
+
+	if err := services.ValidateModelImportRequest(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), modelCreateTimeout)
+	defer cancel()
+
+	// The upload outlives the server's read timeout
+	if err := http.NewResponseController(w).SetReadDeadline(time.Time{}); err != nil {
+		logger.Debug().Err(err).Msg("Failed to clear read deadline for model upload")
+	}
+
+	// Read the whole body before responding: the response may be streamed, and a
+	// response that has started can end the request body
+	path, digest, err := h.imports.SpoolUpload(r.Body, r.ContentLength)
+	if err != nil {
+		logger.Error().Err(err).Str("model_name", req.Name).Msg("Failed to receive model upload")
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+	defer os.Remove(path)
+
+	logger.Info().Str("model_name", req.Name).Str("digest", digest).Msg("Importing uploaded GGUF file")
+
+	streamModelCreation(w, r, logger, req.Stream == nil || *req.Stream, func(progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+		return h.imports.ImportUpload(ctx, req, path, digest, query.Get("filename"), progressChan)
+	})
+}
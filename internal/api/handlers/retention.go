@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chat_ollama/internal/api/middleware"
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// RetentionHandler handles memory retention policies, account purges and embedding maintenance
+type RetentionHandler struct {
+	retention      *services.RetentionService
+	semanticMemory *services.SemanticMemoryService
+	logger         *utils.Logger
+}
+
+// NewRetentionHandler creates a new retention handler
+func NewRetentionHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *RetentionHandler {
+	embeddingService := services.NewEmbeddingService(db, cfg, logger)
+
+	return &RetentionHandler{
+		retention:      services.NewRetentionService(db, cfg, logger),
+		semanticMemory: services.NewSemanticMemoryServiceWithModel(db, embeddingService, logger, cfg.EmbeddingModel),
+		logger:         logger.WithComponent("retention_handler"),
+	}
+}
+
+// authContext returns the authenticated user, falling back to the debug user
+func (h *RetentionHandler) authContext(r *http.Request, logger *utils.Logger) *models.AuthContext {
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for retention policy, using debug user")
+	}
+	return authContext
+}
+
+// GetPolicy handles GET /v1/memory/retention
+func (h *RetentionHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	policy, err := h.retention.GetUserPolicy(ctx, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to get retention policy")
+		utils.WriteError(w, h.retentionError(err, r.URL.Path, "Failed to retrieve retention policy"))
+		return
+	}
+
+	utils.WriteSuccess(w, policy)
+}
+
+// UpdatePolicy handles PUT /v1/memory/retention
+func (h *RetentionHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	var req models.RetentionPolicy
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse retention policy request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateRetentionPolicy(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	policy, err := h.retention.UpdateUserPolicy(ctx, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to update retention policy")
+		utils.WriteError(w, h.retentionError(err, r.URL.Path, "Failed to update retention policy"))
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Int("effective_days", policy.EffectiveDays).
+		Msg("Retention policy updated")
+
+	utils.WriteSuccess(w, policy)
+}
+
+// GetProjectPolicy handles GET /v1/projects/{projectID}/retention
+func (h *RetentionHandler) GetProjectPolicy(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	projectID := chi.URLParam(r, "projectID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	policy, err := h.retention.GetProjectPolicy(ctx, projectID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", projectID).Msg("Failed to get project retention policy")
+		utils.WriteError(w, h.retentionError(err, r.URL.Path, "Failed to retrieve retention policy"))
+		return
+	}
+
+	utils.WriteSuccess(w, policy)
+}
+
+// UpdateProjectPolicy handles PUT /v1/projects/{projectID}/retention
+func (h *RetentionHandler) UpdateProjectPolicy(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	projectID := chi.URLParam(r, "projectID")
+
+	var req models.RetentionPolicy
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse retention policy request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateRetentionPolicy(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	policy, err := h.retention.UpdateProjectPolicy(ctx, projectID, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", projectID).Msg("Failed to update project retention policy")
+		utils.WriteError(w, h.retentionError(err, r.URL.Path, "Failed to update retention policy"))
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Str("project_id", projectID).
+		Int("effective_days", policy.EffectiveDays).
+		Msg("Project retention policy updated")
+
+	utils.WriteSuccess(w, policy)
+}
+
+// PurgeAccount handles DELETE /v1/account
+func (h *RetentionHandler) PurgeAccount(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// No debug user fallback: purging has to be asked for by the account itself
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		apiErr := utils.NewUnauthorizedError("Authentication required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	var req models.PurgeAccountRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse account purge request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if req.Confirm != authContext.Username {
+		apiErr := utils.NewValidationError("confirm must match your username", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	result, err := h.retention.PurgeAccount(ctx, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to purge account")
+		utils.WriteError(w, h.retentionError(err, r.URL.Path, "Failed to purge account"))
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Int64("sessions", result.Sessions).
+		Msg("Account purged")
+
+	utils.WriteSuccess(w, result)
+}
+
+// CleanupEmbeddings handles POST /v1/admin/memory/cleanup
+func (h *RetentionHandler) CleanupEmbeddings(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var req models.EmbeddingCleanupRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse embedding cleanup request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if req.OlderThanDays < 0 {
+		apiErr := utils.NewValidationError("older_than_days cannot be negative", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+	if req.OlderThanDays == 0 && req.SessionID == "" && !req.Orphaned && len(req.ExcludeRoles) == 0 {
+		apiErr := utils.NewValidationError("at least one of older_than_days, session_id, exclude_roles or orphaned is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	result, err := h.semanticMemory.CleanupObsoleteEmbeddings(ctx, services.CleanupOptions{
+		OlderThanDays: req.OlderThanDays,
+		SessionID:     req.SessionID,
+		ExcludeRoles:  req.ExcludeRoles,
+		Orphaned:      req.Orphaned,
+		DryRun:        req.DryRun,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to clean up embeddings")
+		apiErr := utils.NewInternalError("Failed to clean up embeddings", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	logger.Info().
+		Int64("embeddings_deleted", result.EmbeddingsDeleted).
+		Bool("dry_run", result.DryRun).
+		Msg("Embedding cleanup completed")
+
+	utils.WriteSuccess(w, result)
+}
+
+// GetEmbeddingStats handles GET /v1/admin/memory/stats
+func (h *RetentionHandler) GetEmbeddingStats(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	stats, err := h.semanticMemory.GetEmbeddingStats(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get embedding statistics")
+		apiErr := utils.NewInternalError("Failed to retrieve embedding statistics", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	utils.WriteSuccess(w, stats)
+}
+
+// retentionError maps retention errors to API errors
+func (h *RetentionHandler) retentionError(err error, path, fallback string) utils.APIError {
+	switch err.Error() {
+	case "project not found":
+		return utils.NewNotFoundError("Project not found", path)
+	case "user not found":
+		return utils.NewNotFoundError("User not found", path)
+	case "access denied":
+		return utils.NewForbiddenError("Access denied", path)
+	default:
+		return utils.NewInternalError(fallback, path)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chat_ollama/internal/api/middleware"
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// RetrievalHandler handles retrieval settings, context templates and retrieval traces
+type RetrievalHandler struct {
+	settings *services.RetrievalSettingsService
+	renderer *services.ContextRenderer
+	traces   *services.RetrievalTraceService
+	logger   *utils.Logger
+}
+
+// NewRetrievalHandler creates a new retrieval handler
+func NewRetrievalHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *RetrievalHandler {
+	return &RetrievalHandler{
+		settings: services.NewRetrievalSettingsService(db, cfg, logger),
+		renderer: services.NewContextRenderer(db, cfg, logger),
+		traces:   services.NewRetrievalTraceService(db, logger),
+		logger:   logger.WithComponent("retrieval_handler"),
+	}
+}
+
+// authContext returns the authenticated user, falling back to the debug user
+func (h *RetrievalHandler) authContext(r *http.Request, logger *utils.Logger) *models.AuthContext {
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for retrieval settings, using debug user")
+	}
+	return authContext
+}
+
+// GetSettings handles GET /v1/memory/retrieval
+func (h *RetrievalHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	settings, err := h.settings.GetUserSettings(ctx, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to get retrieval settings")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to retrieve retrieval settings"))
+		return
+	}
+
+	utils.WriteSuccess(w, settings)
+}
+
+// UpdateSettings handles PUT /v1/memory/retrieval
+func (h *RetrievalHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	var req models.RetrievalOverrides
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse retrieval settings request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateRetrievalOverrides(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	settings, err := h.settings.UpdateUserSettings(ctx, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to update retrieval settings")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to update retrieval settings"))
+		return
+	}
+
+	logger.Info().Str("user_id", authContext.UserID).Msg("Retrieval settings updated")
+
+	utils.WriteSuccess(w, settings)
+}
+
+// GetProjectSettings handles GET /v1/projects/{projectID}/retrieval
+func (h *RetrievalHandler) GetProjectSettings(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	projectID := chi.URLParam(r, "projectID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	settings, err := h.settings.GetProjectSettings(ctx, projectID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", projectID).Msg("Failed to get project retrieval settings")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to retrieve retrieval settings"))
+		return
+	}
+
+	utils.WriteSuccess(w, settings)
+}
+
+// UpdateProjectSettings handles PUT /v1/projects/{projectID}/retrieval
+func (h *RetrievalHandler) UpdateProjectSettings(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	projectID := chi.URLParam(r, "projectID")
+
+	var req models.RetrievalOverrides
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse retrieval settings request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if err := services.ValidateRetrievalOverrides(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	settings, err := h.settings.UpdateProjectSettings(ctx, projectID, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", projectID).Msg("Failed to update project retrieval settings")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to update retrieval settings"))
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Str("project_id", projectID).
+		Msg("Project retrieval settings updated")
+
+	utils.WriteSuccess(w, settings)
+}
+
+// GetProjectContextTemplate handles GET /v1/projects/{projectID}/context-template
+func (h *RetrievalHandler) GetProjectContextTemplate(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	projectID := chi.URLParam(r, "projectID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	tmpl, err := h.renderer.GetProjectTemplate(ctx, projectID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", projectID).Msg("Failed to get project context template")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to retrieve context template"))
+		return
+	}
+
+	utils.WriteSuccess(w, tmpl)
+}
+
+// UpdateProjectContextTemplate handles PUT /v1/projects/{projectID}/context-template
+func (h *RetrievalHandler) UpdateProjectContextTemplate(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	projectID := chi.URLParam(r, "projectID")
+
+	var req models.UpdateContextTemplateRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse context template request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	// An empty template restores the default, anything else has to parse and execute
+	if strings.TrimSpace(req.Template) != "" {
+		if _, err := services.ParseContextTemplate(req.Template); err != nil {
+			apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	tmpl, err := h.renderer.UpdateProjectTemplate(ctx, projectID, authContext.UserID, req.Template)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", projectID).Msg("Failed to update project context template")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to update context template"))
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Str("project_id", projectID).
+		Bool("is_default", tmpl.IsDefault).
+		Msg("Project context template updated")
+
+	utils.WriteSuccess(w, tmpl)
+}
+
+// GetMessageTrace handles GET /v1/messages/{messageID}/trace
+func (h *RetrievalHandler) GetMessageTrace(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	messageID := chi.URLParam(r, "messageID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	trace, err := h.traces.GetTrace(ctx, messageID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("message_id", messageID).Msg("Failed to get retrieval trace")
+		utils.WriteError(w, h.retrievalError(err, r.URL.Path, "Failed to retrieve retrieval trace"))
+		return
+	}
+
+	utils.WriteSuccess(w, trace)
+}
+
+// retrievalError maps retrieval settings, context template and trace errors to API errors
+func (h *RetrievalHandler) retrievalError(err error, path, fallback string) utils.APIError {
+	switch err.Error() {
+	case "project not found":
+		return utils.NewNotFoundError("Project not found", path)
+	case "user not found":
+		return utils.NewNotFoundError("User not found", path)
+	case "trace not found":
+		return utils.NewNotFoundError("No retrieval trace for this message", path)
+	case "access denied":
+		return utils.NewForbiddenError("Access denied", path)
+	default:
+		return utils.NewInternalError(fallback, path)
+	}
+}
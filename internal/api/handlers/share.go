@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chat_ollama/internal/api/middleware"
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// ShareHandler handles session sharing requests
+type ShareHandler struct {
+	shareService *services.ShareService
+	logger       *utils.Logger
+}
+
+// NewShareHandler creates a new share handler
+func NewShareHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *ShareHandler {
+	return &ShareHandler{
+		shareService: services.NewShareService(db, logger),
+		logger:       logger.WithComponent("share_handler"),
+	}
+}
+
+// CreateShare handles POST /v1/sessions/{sessionID}/share
+func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for session share, using debug user")
+	}
+
+	sessionID := chi.URLParam(r, "sessionID")
+	if sessionID == "" {
+		apiErr := utils.NewValidationError("Session ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	var req models.CreateShareRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			logger.Error().Err(err).Msg("Failed to parse share request")
+			apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	logger.Info().
+		Str("session_id", sessionID).
+		Str("user_id", authContext.UserID).
+		Msg("Creating session share")
+
+	share, err := h.shareService.CreateShare(ctx, sessionID, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to create session share")
+		utils.WriteError(w, h.shareError(err, r.URL.Path, "Failed to create share link"))
+		return
+	}
+
+	logger.Info().
+		Str("session_id", sessionID).
+		Str("share_id", share.ID).
+		Msg("Session share created successfully")
+
+	utils.WriteCreated(w, share)
+}
+
+// ListShares handles GET /v1/sessions/{sessionID}/shares
+func (h *ShareHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for session shares, using debug user")
+	}
+
+	sessionID := chi.URLParam(r, "sessionID")
+	if sessionID == "" {
+		apiErr := utils.NewValidationError("Session ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	shares, err := h.shareService.ListShares(ctx, sessionID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to list session shares")
+		utils.WriteError(w, h.shareError(err, r.URL.Path, "Failed to retrieve share links"))
+		return
+	}
+
+	if shares == nil {
+		shares = []models.SessionShare{}
+	}
+
+	logger.Info().
+		Str("session_id", sessionID).
+		Int("share_count", len(shares)).
+		Msg("Session shares retrieved successfully")
+
+	utils.WriteSuccess(w, models.SharesResponse{Shares: shares})
+}
+
+// RevokeShare handles DELETE /v1/sessions/{sessionID}/shares/{shareID}
+func (h *ShareHandler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for share revocation, using debug user")
+	}
+
+	sessionID := chi.URLParam(r, "sessionID")
+	shareID := chi.URLParam(r, "shareID")
+	if sessionID == "" || shareID == "" {
+		apiErr := utils.NewValidationError("Session ID and share ID are required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := h.shareService.RevokeShare(ctx, sessionID, shareID, authContext.UserID); err != nil {
+		logger.Error().Err(err).Str("share_id", shareID).Msg("Failed to revoke session share")
+		utils.WriteError(w, h.shareError(err, r.URL.Path, "Failed to revoke share link"))
+		return
+	}
+
+	logger.Info().
+		Str("session_id", sessionID).
+		Str("share_id", shareID).
+		Msg("Session share revoked successfully")
+
+	utils.WriteSuccess(w, map[string]string{
+		"message":  "Share link revoked successfully",
+		"share_id": shareID,
+	})
+}
+
+// GetSharedSession handles GET /v1/shared/{token} (public)
+func (h *ShareHandler) GetSharedSession(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	token := chi.URLParam(r, "token")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	snapshot, err := h.shareService.GetSharedSnapshot(ctx, token)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to load shared session")
+		utils.WriteError(w, h.shareError(err, r.URL.Path, "Failed to retrieve shared session"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteSuccess(w, snapshot)
+}
+
+// ViewSharedSession handles GET /shared/{token} (public, server-rendered HTML)
+func (h *ShareHandler) ViewSharedSession(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	token := chi.URLParam(r, "token")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	snapshot, err := h.shareService.GetSharedSnapshot(ctx, token)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to load shared session for HTML view")
+		status := http.StatusInternalServerError
+		if err.Error() == "share not found" {
+			status = http.StatusNotFound
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	if err := sharedSessionTemplate.Execute(w, snapshot); err != nil {
+		logger.Error().Err(err).Msg("Failed to render shared session")
+	}
+}
+
+// ForkSharedSession handles POST /v1/shared/{token}/fork
+func (h *ShareHandler) ForkSharedSession(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for session fork, using debug user")
+	}
+
+	token := chi.URLParam(r, "token")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	session, err := h.shareService.ForkSharedSession(ctx, token, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to fork shared session")
+		utils.WriteError(w, h.shareError(err, r.URL.Path, "Failed to fork shared session"))
+		return
+	}
+
+	logger.Info().
+		Str("session_id", session.ID).
+		Str("user_id", authContext.UserID).
+		Msg("Shared session forked successfully")
+
+	utils.WriteCreated(w, session)
+}
+
+// shareError maps share service errors to API errors
+func (h *ShareHandler) shareError(err error, instance, fallback string) utils.APIError {
+	switch err.Error() {
+	case "session not found":
+		return utils.NewNotFoundError("Session not found", instance)
+	case "share not found":
+		return utils.NewNotFoundError("Share link not found or no longer active", instance)
+	case "access denied":
+		return utils.NewForbiddenError("Access denied to this session", instance)
+	case "invalid expiry duration":
+		return utils.NewValidationError("expires_in must be a positive duration such as \"72h\"", instance)
+	}
+	return utils.NewInternalError(fallback, instance)
+}
+
+// sharedSessionTemplate renders a read-only snapshot of a shared session
+var sharedSessionTemplate = template.Must(template.New("shared_session").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>{{.Title}} - Ollama Pilot</title>
+    <link rel="stylesheet" href="/style.css">
+    <style>
+        .shared-container { max-width: 820px; margin: 0 auto; padding: 32px 16px; }
+        .shared-meta { opacity: 0.7; font-size: 0.85em; margin-bottom: 24px; }
+        .shared-message { padding: 12px 16px; margin-bottom: 12px; border-radius: 8px; white-space: pre-wrap; }
+        .shared-role { font-weight: 600; text-transform: capitalize; margin-bottom: 4px; }
+        .shared-message.user { background: rgba(255, 255, 255, 0.06); }
+        .shared-message.system { border: 1px dashed rgba(255, 255, 255, 0.2); }
+    </style>
+</head>
+<body class="dark">
+    <div class="shared-container">
+        <h1>{{.Title}}</h1>
+        <div class="shared-meta">
+            Shared {{.SharedAt.Format "Jan 2, 2006 15:04 MST"}}{{if .ExpiresAt}} &middot; expires {{.ExpiresAt.Format "Jan 2, 2006 15:04 MST"}}{{end}}{{if .Redacted}} &middot; system messages hidden{{end}}
+        </div>
+        {{range .Messages}}
+        <div class="shared-message {{.Role}}">
+            <div class="shared-role">{{.Role}}{{if .Model}} &middot; {{.Model}}{{end}}</div>
+            <div>{{.Content}}</div>
+        </div>
+        {{else}}
+        <p>This conversation has no messages.</p>
+        {{end}}
+    </div>
+</body>
+</html>
+`))
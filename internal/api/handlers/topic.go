@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chat_ollama/internal/api/middleware"
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// TopicHandler handles semantic memory topic requests
+type TopicHandler struct {
+	topicService *services.TopicService
+	logger       *utils.Logger
+}
+
+// NewTopicHandler creates a new topic handler
+func NewTopicHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *TopicHandler {
+	ollamaClient := services.NewOllamaClient(cfg, logger)
+
+	return &TopicHandler{
+		topicService: services.NewTopicService(db, ollamaClient, cfg, logger),
+		logger:       logger.WithComponent("topic_handler"),
+	}
+}
+
+// GetTopics handles GET /v1/memory/topics
+func (h *TopicHandler) GetTopics(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for topics, using debug user")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	topics, err := h.topicService.GetTopicsByUser(ctx, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to get topics")
+		apiErr := utils.NewInternalError("Failed to retrieve topics", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Int("topic_count", len(topics)).
+		Msg("Topics retrieved")
+
+	utils.WriteSuccess(w, models.TopicsResponse{Topics: topics})
+}
+
+// GetTopicMessages handles GET /v1/memory/topics/{topicID}/messages
+func (h *TopicHandler) GetTopicMessages(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// Get authenticated user from context (optional for debugging)
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for topic messages, using debug user")
+	}
+
+	topicID := chi.URLParam(r, "topicID")
+	if topicID == "" {
+		apiErr := utils.NewValidationError("Topic ID is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	limit := 50
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > 200 {
+			apiErr := utils.NewValidationError("limit must be between 1 and 200", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
+		parsed, err := strconv.Atoi(offsetParam)
+		if err != nil || parsed < 0 {
+			apiErr := utils.NewValidationError("offset must be a non-negative integer", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		offset = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	topic, err := h.topicService.GetTopic(ctx, topicID, authContext.UserID)
+	if err != nil {
+		switch err.Error() {
+		case "topic not found":
+			utils.WriteError(w, utils.NewNotFoundError("Topic not found", r.URL.Path))
+		case "access denied":
+			utils.WriteError(w, utils.NewForbiddenError("Access denied to this topic", r.URL.Path))
+		default:
+			logger.Error().Err(err).Str("topic_id", topicID).Msg("Failed to get topic")
+			utils.WriteError(w, utils.NewInternalError("Failed to retrieve topic", r.URL.Path))
+		}
+		return
+	}
+
+	messages, err := h.topicService.GetTopicMessages(ctx, topicID, limit, offset)
+	if err != nil {
+		logger.Error().Err(err).Str("topic_id", topicID).Msg("Failed to get topic messages")
+		apiErr := utils.NewInternalError("Failed to retrieve topic messages", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	utils.WriteSuccess(w, models.TopicMessagesResponse{
+		Topic:    *topic,
+		Messages: messages,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
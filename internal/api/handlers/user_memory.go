@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"chat_ollama/internal/api/middleware"
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// UserMemoryHandler handles explicit user memory (fact) requests
+type UserMemoryHandler struct {
+	userMemory *services.UserMemoryService
+	config     *config.Config
+	logger     *utils.Logger
+}
+
+// NewUserMemoryHandler creates a new user memory handler
+func NewUserMemoryHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *UserMemoryHandler {
+	ollamaClient := services.NewOllamaClient(cfg, logger)
+	embeddingService := services.NewEmbeddingService(db, cfg, logger)
+
+	return &UserMemoryHandler{
+		userMemory: services.NewUserMemoryService(db, ollamaClient, embeddingService, cfg, logger),
+		config:     cfg,
+		logger:     logger.WithComponent("user_memory_handler"),
+	}
+}
+
+// authContext returns the authenticated user, falling back to the debug user
+func (h *UserMemoryHandler) authContext(r *http.Request, logger *utils.Logger) *models.AuthContext {
+	authContext, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		// For debugging: create a temporary auth context
+		authContext = &models.AuthContext{
+			UserID:   "debug-user-id",
+			Username: "debug-user",
+		}
+		logger.Warn().Msg("No authentication context found for user memory, using debug user")
+	}
+	return authContext
+}
+
+// GetFacts handles GET /v1/memory/facts
+func (h *UserMemoryHandler) GetFacts(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.UserMemoryStatusProposed, models.UserMemoryStatusConfirmed, models.UserMemoryStatusRejected:
+	default:
+		apiErr := utils.NewValidationError("status must be one of proposed, confirmed or rejected", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	facts, err := h.userMemory.GetFacts(ctx, authContext.UserID, status)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to get facts")
+		apiErr := utils.NewInternalError("Failed to retrieve facts", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	utils.WriteSuccess(w, models.UserMemoriesResponse{Facts: facts})
+}
+
+// CreateFact handles POST /v1/memory/facts
+func (h *UserMemoryHandler) CreateFact(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	var req models.CreateUserMemoryRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse fact request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	fact, err := h.userMemory.CreateFact(ctx, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("user_id", authContext.UserID).Msg("Failed to create fact")
+		utils.WriteError(w, h.factError(err, r.URL.Path, "Failed to create fact"))
+		return
+	}
+
+	logger.Info().
+		Str("user_id", authContext.UserID).
+		Str("fact_id", fact.ID).
+		Msg("Fact created successfully")
+
+	utils.WriteCreated(w, fact)
+}
+
+// GetFact handles GET /v1/memory/facts/{factID}
+func (h *UserMemoryHandler) GetFact(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	factID := chi.URLParam(r, "factID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	fact, err := h.userMemory.GetFact(ctx, factID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("fact_id", factID).Msg("Failed to get fact")
+		utils.WriteError(w, h.factError(err, r.URL.Path, "Failed to retrieve fact"))
+		return
+	}
+
+	utils.WriteSuccess(w, fact)
+}
+
+// UpdateFact handles PUT /v1/memory/facts/{factID}
+func (h *UserMemoryHandler) UpdateFact(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	factID := chi.URLParam(r, "factID")
+
+	var req models.UpdateUserMemoryRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse fact update request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	fact, err := h.userMemory.UpdateFact(ctx, factID, authContext.UserID, req)
+	if err != nil {
+		logger.Error().Err(err).Str("fact_id", factID).Msg("Failed to update fact")
+		utils.WriteError(w, h.factError(err, r.URL.Path, "Failed to update fact"))
+		return
+	}
+
+	logger.Info().
+		Str("fact_id", factID).
+		Str("status", fact.Status).
+		Msg("Fact updated successfully")
+
+	utils.WriteSuccess(w, fact)
+}
+
+// ConfirmFact handles POST /v1/memory/facts/{factID}/confirm
+func (h *UserMemoryHandler) ConfirmFact(w http.ResponseWriter, r *http.Request) {
+	h.setFactStatus(w, r, models.UserMemoryStatusConfirmed)
+}
+
+// RejectFact handles POST /v1/memory/facts/{factID}/reject
+func (h *UserMemoryHandler) RejectFact(w http.ResponseWriter, r *http.Request) {
+	h.setFactStatus(w, r, models.UserMemoryStatusRejected)
+}
+
+// setFactStatus confirms or rejects a proposed fact
+func (h *UserMemoryHandler) setFactStatus(w http.ResponseWriter, r *http.Request, status string) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	factID := chi.URLParam(r, "factID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	fact, err := h.userMemory.SetFactStatus(ctx, factID, authContext.UserID, status)
+	if err != nil {
+		logger.Error().Err(err).Str("fact_id", factID).Str("status", status).Msg("Failed to set fact status")
+		utils.WriteError(w, h.factError(err, r.URL.Path, "Failed to update fact"))
+		return
+	}
+
+	logger.Info().
+		Str("fact_id", factID).
+		Str("status", status).
+		Msg("Fact status updated")
+
+	utils.WriteSuccess(w, fact)
+}
+
+// DeleteFact handles DELETE /v1/memory/facts/{factID}
+func (h *UserMemoryHandler) DeleteFact(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+	factID := chi.URLParam(r, "factID")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := h.userMemory.DeleteFact(ctx, factID, authContext.UserID); err != nil {
+		logger.Error().Err(err).Str("fact_id", factID).Msg("Failed to delete fact")
+		utils.WriteError(w, h.factError(err, r.URL.Path, "Failed to delete fact"))
+		return
+	}
+
+	logger.Info().Str("fact_id", factID).Msg("Fact deleted successfully")
+
+	utils.WriteSuccess(w, map[string]string{
+		"message": "Fact deleted successfully",
+		"fact_id": factID,
+	})
+}
+
+// ExtractFacts handles POST /v1/memory/facts/extract
+func (h *UserMemoryHandler) ExtractFacts(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	authContext := h.authContext(r, logger)
+
+	var req models.ExtractUserMemoriesRequest
+	if err := utils.ParseJSON(r, &req); err != nil {
+		logger.Error().Err(err).Msg("Failed to parse fact extraction request")
+		apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	if req.SessionID == "" {
+		apiErr := utils.NewValidationError("session_id is required", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	// Extraction calls the LLM, so allow it the full Ollama timeout
+	ctx, cancel := context.WithTimeout(r.Context(), h.config.OllamaTimeout)
+	defer cancel()
+
+	facts, err := h.userMemory.ExtractFactsForUser(ctx, req.SessionID, authContext.UserID)
+	if err != nil {
+		logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Failed to extract facts")
+		utils.WriteError(w, h.factError(err, r.URL.Path, "Failed to extract facts"))
+		return
+	}
+
+	logger.Info().
+		Str("session_id", req.SessionID).
+		Int("facts_proposed", len(facts)).
+		Msg("Facts extracted from session")
+
+	utils.WriteSuccess(w, models.UserMemoriesResponse{Facts: facts})
+}
+
+// factError maps user memory service errors to API errors
+func (h *UserMemoryHandler) factError(err error, path, fallback string) utils.APIError {
+	switch err.Error() {
+	case "fact not found":
+		return utils.NewNotFoundError("Fact not found", path)
+	case "session not found":
+		return utils.NewNotFoundError("Session not found", path)
+	case "access denied":
+		return utils.NewForbiddenError("Access denied", path)
+	case "content is required", "invalid status":
+		return utils.NewValidationError(err.Error(), path)
+	default:
+		return utils.NewInternalError(fallback, path)
+	}
+}
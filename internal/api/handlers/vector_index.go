@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/services"
+	"chat_ollama/internal/utils"
+)
+
+// VectorIndexHandler handles vector index diagnostics and rebuilds
+type VectorIndexHandler struct {
+	indexes *services.VectorIndexService
+	jobs    *services.JobQueue
+	logger  *utils.Logger
+}
+
+// NewVectorIndexHandler creates a new vector index handler
+func NewVectorIndexHandler(db database.Database, cfg *config.Config, logger *utils.Logger) *VectorIndexHandler {
+	return &VectorIndexHandler{
+		indexes: services.NewVectorIndexService(db, cfg, logger),
+		jobs:    services.NewJobQueue(db, cfg, logger),
+		logger:  logger.WithComponent("vector_index_handler"),
+	}
+}
+
+// GetDiagnostics handles GET /v1/admin/memory/indexes
+func (h *VectorIndexHandler) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	var tuning database.SearchTuning
+	for param, value := range map[string]*int{"ef_search": &tuning.EfSearch, "probes": &tuning.Probes} {
+		raw := r.URL.Query().Get(param)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			apiErr := utils.NewValidationError(param+" must be a positive integer", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+		*value = parsed
+	}
+	if err := services.ValidateSearchTuning(tuning); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	// Estimating recall runs two searches per sampled vector
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	report, err := h.indexes.Diagnostics(ctx, tuning)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get vector index diagnostics")
+		apiErr := utils.NewInternalError("Failed to retrieve vector index diagnostics", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	utils.WriteSuccess(w, report)
+}
+
+// Reindex handles POST /v1/admin/memory/indexes/reindex
+func (h *VectorIndexHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	logger := utils.FromContext(r.Context())
+	if logger == nil {
+		logger = h.logger
+	}
+
+	// The body is optional; without it every index is rebuilt
+	var req models.ReindexRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			logger.Error().Err(err).Msg("Failed to parse reindex request")
+			apiErr := utils.NewValidationError("Invalid JSON in request body", r.URL.Path)
+			utils.WriteError(w, apiErr)
+			return
+		}
+	}
+
+	if err := services.ValidateReindexRequest(req); err != nil {
+		apiErr := utils.NewValidationError(err.Error(), r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	jobID, err := h.jobs.Enqueue(ctx, services.JobTypeReindexVectors, req, services.JobOptions{
+		DedupeKey: services.JobTypeReindexVectors,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to queue vector reindex")
+		apiErr := utils.NewInternalError("Failed to queue vector reindex", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+	if jobID == "" {
+		apiErr := utils.NewValidationError("a vector reindex is already queued", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	job, err := h.jobs.GetJob(ctx, jobID)
+	if err != nil {
+		logger.Error().Err(err).Str("job_id", jobID).Msg("Failed to get job")
+		apiErr := utils.NewInternalError("Failed to retrieve job", r.URL.Path)
+		utils.WriteError(w, apiErr)
+		return
+	}
+
+	logger.Info().
+		Str("job_id", jobID).
+		Str("model", req.Model).
+		Str("table", req.Table).
+		Msg("Vector reindex queued")
+
+	utils.WriteCreated(w, job)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"chat_ollama/internal/config"
 	"chat_ollama/internal/models"
 	"chat_ollama/internal/services"
 	"chat_ollama/internal/utils"
@@ -102,6 +103,29 @@ func OptionalAuthMiddleware(authService *services.AuthService) func(http.Handler
 	}
 }
 
+// AdminMiddleware creates middleware restricting routes to the configured administrators.
+// It must run after AuthMiddleware.
+func AdminMiddleware(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authContext, ok := GetUserFromContext(r)
+			if !ok {
+				apiErr := utils.NewUnauthorizedError("Authentication required", "authentication")
+				utils.WriteError(w, apiErr)
+				return
+			}
+
+			if !cfg.IsAdmin(authContext.Username) {
+				apiErr := utils.NewForbiddenError("Administrator access required", r.URL.Path)
+				utils.WriteError(w, apiErr)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserFromContext extracts the authenticated user from request context
 func GetUserFromContext(r *http.Request) (*models.AuthContext, bool) {
 	authContext, ok := r.Context().Value(UserContextKey).(*models.AuthContext)
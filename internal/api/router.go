@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"path/filepath"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -11,25 +13,81 @@ import (
 	apiMiddleware "chat_ollama/internal/api/middleware"
 	"chat_ollama/internal/config"
 	"chat_ollama/internal/database"
+	"chat_ollama/internal/services"
 	"chat_ollama/internal/utils"
 )
 
 // Router holds the router configuration and dependencies
 type Router struct {
-	db     database.Database
-	cfg    *config.Config
-	logger *utils.Logger
+	db      database.Database
+	cfg     *config.Config
+	logger  *utils.Logger
+	workers []services.Worker
+	wg      sync.WaitGroup
 }
 
 // NewRouter creates a new router instance
 func NewRouter(db database.Database, cfg *config.Config, logger *utils.Logger) *Router {
+	// Background workers share an Ollama client and embedding service
+	ollamaClient := services.NewOllamaClient(cfg, logger)
+	embeddingService := services.NewEmbeddingService(db, cfg, logger)
+
+	// The job queue runs work queued by request handlers
+	jobQueue := services.NewJobQueue(db, cfg, logger)
+	semanticMemory := services.NewSemanticMemoryServiceWithModel(db, embeddingService, logger, cfg.EmbeddingModel)
+	semanticMemory.SetGapDetection(cfg.MemoryGapThreshold, cfg.TopicDriftThreshold)
+	semanticMemory.RegisterJobs(jobQueue)
+	services.NewTitleService(db, ollamaClient, cfg, logger).RegisterJobs(jobQueue)
+	modelManager := services.NewModelManager(db, ollamaClient, logger)
+	modelManager.SetStorageBudget(cfg.ModelStorageBudget, cfg.EmbeddingModel, cfg.TitleModel, cfg.SummaryModel, cfg.FactModel)
+	modelManager.RegisterJobs(jobQueue)
+	services.NewModelBenchmarkService(db, modelManager, ollamaClient, cfg, logger).RegisterJobs(jobQueue)
+	retention := services.NewRetentionService(db, cfg, logger)
+	retention.RegisterJobs(jobQueue)
+	services.NewVectorIndexService(db, cfg, logger).RegisterJobs(jobQueue)
+	summarizer := services.NewSummarizerService(db, ollamaClient, embeddingService, cfg, logger)
+	summarizer.RegisterJobs(jobQueue)
+	topics := services.NewTopicService(db, ollamaClient, cfg, logger)
+	topics.RegisterJobs(jobQueue)
+	facts := services.NewUserMemoryService(db, ollamaClient, embeddingService, cfg, logger)
+	facts.RegisterJobs(jobQueue)
+
 	return &Router{
 		db:     db,
 		cfg:    cfg,
 		logger: logger,
+		workers: []services.Worker{
+			jobQueue,
+			ollamaClient.Pool(),
+			services.NewChatActivity(db, ollamaClient, logger),
+			services.NewModelReconciler(jobQueue, cfg, logger),
+			summarizer,
+			topics,
+			facts,
+			services.NewReembedService(db, embeddingService, cfg, logger),
+			retention,
+		},
 	}
 }
 
+// StartWorkers starts all background workers; they stop when ctx is cancelled
+func (rt *Router) StartWorkers(ctx context.Context) {
+	for _, worker := range rt.workers {
+		rt.wg.Add(1)
+		go func(worker services.Worker) {
+			defer rt.wg.Done()
+			rt.logger.Info().Str("worker", worker.Name()).Msg("Starting background worker")
+			worker.Run(ctx)
+			rt.logger.Info().Str("worker", worker.Name()).Msg("Background worker stopped")
+		}(worker)
+	}
+}
+
+// WaitForWorkers blocks until all background workers have returned
+func (rt *Router) WaitForWorkers() {
+	rt.wg.Wait()
+}
+
 // SetupRoutes configures all routes and middleware
 func (rt *Router) SetupRoutes() http.Handler {
 	r := chi.NewRouter()
@@ -71,6 +129,10 @@ func (rt *Router) SetupRoutes() http.Handler {
 	r.Get("/ready", healthHandler.ReadinessCheck)
 	r.Get("/live", healthHandler.LivenessCheck)
 
+	// Public read-only view of shared sessions
+	shareHandler := handlers.NewShareHandler(rt.db, rt.cfg, rt.logger)
+	r.Get("/shared/{token}", shareHandler.ViewSharedSession)
+
 	// API v1 routes
 	r.Route("/v1", func(r chi.Router) {
 		// Authentication handlers
@@ -85,6 +147,9 @@ func (rt *Router) SetupRoutes() http.Handler {
 		r.Post("/auth/login", authHandler.Login)
 		rt.logger.Info().Msg("Auth routes registered successfully")
 		
+		// Public shared session snapshot (no auth required)
+		r.Get("/shared/{token}", shareHandler.GetSharedSession)
+		
 		// Protected authentication endpoints (auth required)
 		r.Group(func(r chi.Router) {
 			r.Use(apiMiddleware.AuthMiddleware(authHandler.GetAuthService()))
@@ -104,6 +169,9 @@ func (rt *Router) SetupRoutes() http.Handler {
 		// Chat handlers
 		chatHandler := handlers.NewChatHandler(rt.db, rt.cfg, rt.logger)
 		
+		// Model management handlers (can be public or protected based on requirements)
+		modelsHandler := handlers.NewModelsHandler(rt.db, rt.cfg, rt.logger)
+		
 		// Protected routes (require authentication)
 		r.Group(func(r chi.Router) {
 			r.Use(apiMiddleware.AuthMiddleware(authHandler.GetAuthService()))
@@ -115,6 +183,13 @@ func (rt *Router) SetupRoutes() http.Handler {
 			r.Get("/sessions", chatHandler.GetSessions)
 			r.Get("/sessions/{sessionID}/messages", chatHandler.GetSessionMessages)
 			r.Delete("/sessions/{sessionID}", chatHandler.DeleteSession)
+			r.Post("/sessions/{sessionID}/title", chatHandler.RegenerateTitle)
+			
+			// Session sharing endpoints
+			r.Post("/sessions/{sessionID}/share", shareHandler.CreateShare)
+			r.Get("/sessions/{sessionID}/shares", shareHandler.ListShares)
+			r.Delete("/sessions/{sessionID}/shares/{shareID}", shareHandler.RevokeShare)
+			r.Post("/shared/{token}/fork", shareHandler.ForkSharedSession)
 			
 			// Project handlers
 			projectHandler := handlers.NewProjectHandler(rt.db, rt.cfg, rt.logger)
@@ -126,17 +201,76 @@ func (rt *Router) SetupRoutes() http.Handler {
 			r.Put("/projects/{projectID}", projectHandler.UpdateProject)
 			r.Delete("/projects/{projectID}", projectHandler.DeleteProject)
 			r.Get("/projects/{projectID}/sessions", projectHandler.GetProjectSessions)
+
+			// Retrieval settings, context template and trace endpoints
+			retrievalHandler := handlers.NewRetrievalHandler(rt.db, rt.cfg, rt.logger)
+			r.Get("/memory/retrieval", retrievalHandler.GetSettings)
+			r.Put("/memory/retrieval", retrievalHandler.UpdateSettings)
+			r.Get("/projects/{projectID}/retrieval", retrievalHandler.GetProjectSettings)
+			r.Put("/projects/{projectID}/retrieval", retrievalHandler.UpdateProjectSettings)
+			r.Get("/projects/{projectID}/context-template", retrievalHandler.GetProjectContextTemplate)
+			r.Put("/projects/{projectID}/context-template", retrievalHandler.UpdateProjectContextTemplate)
+			r.Get("/messages/{messageID}/trace", retrievalHandler.GetMessageTrace)
+
+			// Memory retention and account purge endpoints
+			retentionHandler := handlers.NewRetentionHandler(rt.db, rt.cfg, rt.logger)
+			r.Get("/memory/retention", retentionHandler.GetPolicy)
+			r.Put("/memory/retention", retentionHandler.UpdatePolicy)
+			r.Get("/projects/{projectID}/retention", retentionHandler.GetProjectPolicy)
+			r.Put("/projects/{projectID}/retention", retentionHandler.UpdateProjectPolicy)
+			r.Delete("/account", retentionHandler.PurgeAccount)
 			
 			// Semantic memory endpoints
 			r.Post("/memory/search", chatHandler.SearchMemory)
 			r.Get("/memory/summaries", chatHandler.GetMemorySummaries)
 			r.Post("/memory/summaries", chatHandler.CreateMemorySummary)
+			r.Post("/memory/summaries/generate", chatHandler.GenerateMemorySummary)
 			r.Get("/memory/gaps/{sessionID}", chatHandler.GetMemoryGaps)
+
+			// Memory topic endpoints
+			topicHandler := handlers.NewTopicHandler(rt.db, rt.cfg, rt.logger)
+			r.Get("/memory/topics", topicHandler.GetTopics)
+			r.Get("/memory/topics/{topicID}/messages", topicHandler.GetTopicMessages)
+
+			// User memory (fact) endpoints
+			userMemoryHandler := handlers.NewUserMemoryHandler(rt.db, rt.cfg, rt.logger)
+			r.Get("/memory/facts", userMemoryHandler.GetFacts)
+			r.Post("/memory/facts", userMemoryHandler.CreateFact)
+			r.Post("/memory/facts/extract", userMemoryHandler.ExtractFacts)
+			r.Get("/memory/facts/{factID}", userMemoryHandler.GetFact)
+			r.Put("/memory/facts/{factID}", userMemoryHandler.UpdateFact)
+			r.Delete("/memory/facts/{factID}", userMemoryHandler.DeleteFact)
+			r.Post("/memory/facts/{factID}/confirm", userMemoryHandler.ConfirmFact)
+			r.Post("/memory/facts/{factID}/reject", userMemoryHandler.RejectFact)
+
+			// Embedding generation and model migration endpoints
+			embeddingHandler := handlers.NewEmbeddingHandler(rt.db, rt.cfg, rt.logger)
+			r.Post("/embed", embeddingHandler.Embed)
+			r.Post("/memory/reembed", embeddingHandler.CreateReembedJob)
+			r.Get("/memory/reembed", embeddingHandler.ListReembedJobs)
+			r.Get("/memory/reembed/{jobID}", embeddingHandler.GetReembedJob)
+			r.Delete("/memory/reembed/{jobID}", embeddingHandler.CancelReembedJob)
+
+			// Administration endpoints
+			jobsHandler := handlers.NewJobsHandler(rt.db, rt.cfg, rt.logger)
+			vectorIndexHandler := handlers.NewVectorIndexHandler(rt.db, rt.cfg, rt.logger)
+			r.Group(func(r chi.Router) {
+				r.Use(apiMiddleware.AdminMiddleware(rt.cfg))
+				r.Get("/admin/jobs", jobsHandler.ListJobs)
+				r.Get("/admin/jobs/{jobID}", jobsHandler.GetJob)
+				r.Post("/admin/jobs/{jobID}/retry", jobsHandler.RetryJob)
+				r.Post("/admin/embeddings/backfill", jobsHandler.BackfillEmbeddings)
+				r.Post("/admin/memory/cleanup", retentionHandler.CleanupEmbeddings)
+				r.Get("/admin/memory/stats", retentionHandler.GetEmbeddingStats)
+				r.Get("/admin/memory/indexes", vectorIndexHandler.GetDiagnostics)
+				r.Post("/admin/memory/indexes/reindex", vectorIndexHandler.Reindex)
+				r.Post("/admin/models/storage/evict", modelsHandler.EvictModels)
+				r.Get("/admin/models/import/files", modelsHandler.ListImportFiles)
+				r.Post("/admin/models/import", modelsHandler.ImportModel)
+				r.Post("/admin/models/import/upload", modelsHandler.UploadModel)
+			})
 		})
 		
-		// Model management handlers (can be public or protected based on requirements)
-		modelsHandler := handlers.NewModelsHandler(rt.db, rt.cfg, rt.logger)
-		
 		// Model endpoints (keeping these public for now, but can be moved to protected group)
 		r.Get("/models", modelsHandler.GetModels)
 		r.Get("/models/{modelID}", modelsHandler.GetModel)
@@ -144,12 +278,34 @@ func (rt *Router) SetupRoutes() http.Handler {
 		r.Delete("/models/{modelID}", modelsHandler.DeleteModel) // Soft delete
 		r.Post("/models/sync", modelsHandler.SyncModels)
 		
+		// Custom model endpoints
+		r.Post("/models/create", modelsHandler.CreateModel)
+		r.Post("/models/{modelID}/copy", modelsHandler.CopyModel)
+		r.Post("/models/{modelID}/alias", modelsHandler.AliasModel)
+		
 		// Model download endpoints
 		r.Post("/models/download", modelsHandler.DownloadModel)
 		r.Get("/models/available", modelsHandler.GetAvailableModels)
 		r.Post("/models/available/refresh", modelsHandler.RefreshAvailableModels)
 		r.Get("/models/cache-info", modelsHandler.GetCacheInfo)
 		r.Get("/models/{modelID}/download-status", modelsHandler.GetModelDownloadStatus)
+		r.Delete("/models/{modelID}/download", modelsHandler.CancelDownload)
+		r.Get("/models/downloads/stream", modelsHandler.StreamDownloads)
+		r.Get("/models/events", modelsHandler.StreamModelEvents)
+		
+		// Running model endpoints
+		r.Get("/models/running", modelsHandler.ListRunningModels)
+		r.Post("/models/{modelID}/load", modelsHandler.LoadModel)
+		r.Post("/models/{modelID}/unload", modelsHandler.UnloadModel)
+		
+		// Model storage endpoints
+		r.Get("/models/storage/plan", modelsHandler.PlanModelEviction)
+		
+		// Model benchmark endpoints
+		r.Post("/models/{modelID}/benchmark", modelsHandler.BenchmarkModel)
+		r.Get("/models/{modelID}/benchmarks", modelsHandler.ListModelBenchmarks)
+		r.Get("/models/benchmarks/compare", modelsHandler.CompareBenchmarks)
+		r.Get("/models/benchmarks/{benchmarkID}", modelsHandler.GetBenchmark)
 		
 		// Model configuration endpoints
 		r.Get("/models/{modelID}/config", modelsHandler.GetModelConfig)
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v10"
@@ -23,8 +24,30 @@ type Config struct {
 	DBSSLMode  string `env:"DB_SSL_MODE" envDefault:"disable"`
 
 	// Ollama configuration
-	OllamaHost    string        `env:"OLLAMA_HOST" envDefault:"localhost:11434"`
-	OllamaTimeout time.Duration `env:"OLLAMA_TIMEOUT" envDefault:"300s"`
+	OllamaHost           string        `env:"OLLAMA_HOST" envDefault:"localhost:11434"` // One host, or a comma-separated pool of [name=]host:port
+	OllamaTimeout        time.Duration `env:"OLLAMA_TIMEOUT" envDefault:"300s"`
+	OllamaHealthInterval time.Duration `env:"OLLAMA_HEALTH_INTERVAL" envDefault:"15s"` // How often the hosts of the pool are checked
+	OllamaPlacement      string        `env:"OLLAMA_PLACEMENT" envDefault:""`          // pattern=host,host rules separated by semicolons; empty places models on every host
+
+	// Model download and import configuration
+	MaxConcurrentDownloads int    `env:"MAX_CONCURRENT_DOWNLOADS" envDefault:"2"`        // Further downloads wait in the queue
+	ModelImportDir         string `env:"MODEL_IMPORT_DIR" envDefault:""`                 // Server-side directory of GGUF files to import; empty disables it
+	ModelUploadMaxSize     int64  `env:"MODEL_UPLOAD_MAX_SIZE" envDefault:"17179869184"` // Largest GGUF upload in bytes (16 GiB)
+	ModelStorageBudget     int64  `env:"MODEL_STORAGE_BUDGET" envDefault:"0"`            // Bytes installed models may take before the least recently used are evicted; 0 means no limit
+
+	// Model reconciler configuration (syncs the models table with Ollama)
+	EnableModelReconciler  bool          `env:"ENABLE_MODEL_RECONCILER" envDefault:"true"`
+	ModelReconcileInterval time.Duration `env:"MODEL_RECONCILE_INTERVAL" envDefault:"5m"`
+
+	// Model benchmark configuration
+	BenchmarkSuiteFile string `env:"BENCHMARK_SUITE_FILE" envDefault:""`    // Prompt suite in JSON or YAML; empty uses the built-in suite
+	BenchmarkMaxTokens int    `env:"BENCHMARK_MAX_TOKENS" envDefault:"256"` // Tokens generated per prompt
+
+	// Model catalog configuration
+	ModelCatalogFile            string `env:"MODEL_CATALOG_FILE" envDefault:""`                 // Curated catalog in JSON or YAML
+	ModelCatalogURL             string `env:"MODEL_CATALOG_URL" envDefault:""`                  // Mirror serving the catalog as JSON
+	ModelCatalogToken           string `env:"MODEL_CATALOG_TOKEN" envDefault:""`                // Bearer token for the mirror
+	ModelCatalogLibraryFallback bool   `env:"MODEL_CATALOG_LIBRARY_FALLBACK" envDefault:"true"` // Scrape ollama.com when the catalogs above fail
 
 	// Logging configuration
 	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`
@@ -39,7 +62,88 @@ type Config struct {
 	EnableSemanticMemory bool   `env:"ENABLE_SEMANTIC_MEMORY" envDefault:"true"`
 	EmbeddingModel       string `env:"EMBEDDING_MODEL" envDefault:"nomic-embed-text"`
 	MaxContextResults    int    `env:"MAX_CONTEXT_RESULTS" envDefault:"5"`
+	VectorStore          string `env:"VECTOR_STORE" envDefault:"pgvector"` // pgvector, or memory to keep message embeddings in process
+
+	// Vector index configuration
+	VectorIndexType        string `env:"VECTOR_INDEX_TYPE" envDefault:"hnsw"`  // hnsw or ivfflat
+	HNSWM                  int    `env:"HNSW_M" envDefault:"16"`               // Connections per HNSW graph node
+	HNSWEfConstruction     int    `env:"HNSW_EF_CONSTRUCTION" envDefault:"64"` // Candidate list size while building HNSW indexes
+	IVFFlatLists           int    `env:"IVFFLAT_LISTS" envDefault:"0"`         // 0 sizes the lists from the row count at build time
+	VectorSearchEfSearch   int    `env:"VECTOR_SEARCH_EF_SEARCH" envDefault:"40"`
+	VectorSearchProbes     int    `env:"VECTOR_SEARCH_PROBES" envDefault:"10"`
+	VectorRecallSampleSize int    `env:"VECTOR_RECALL_SAMPLE_SIZE" envDefault:"20"` // Queries sampled to estimate index recall
+
+	// Retrieval configuration (defaults; users and projects can override them)
+	RetrievalMinSimilarity   float64       `env:"RETRIEVAL_MIN_SIMILARITY" envDefault:"0.35"`
+	RetrievalMMRLambda       float64       `env:"RETRIEVAL_MMR_LAMBDA" envDefault:"0.7"` // 1 ranks purely by relevance, 0 purely by diversity
+	RetrievalRecencyWeight   float64       `env:"RETRIEVAL_RECENCY_WEIGHT" envDefault:"0.2"`
+	RetrievalRecencyHalfLife time.Duration `env:"RETRIEVAL_RECENCY_HALF_LIFE" envDefault:"720h"`
+	RetrievalExcludeRecent   int           `env:"RETRIEVAL_EXCLUDE_RECENT" envDefault:"10"` // Most recent messages of the current session that are never retrieved
+	RetrievalDedupThreshold  float64       `env:"RETRIEVAL_DEDUP_THRESHOLD" envDefault:"0.95"`
+	RetrievalScope           string        `env:"RETRIEVAL_SCOPE" envDefault:"user"` // user, project or session
+	ContextTemplateFile      string        `env:"CONTEXT_TEMPLATE_FILE" envDefault:""` // Go text/template for retrieved context; empty uses the built-in template
+
+	// Embedding generation configuration
+	EmbeddingBatchSize       int           `env:"EMBEDDING_BATCH_SIZE" envDefault:"64"` // Texts sent to Ollama per /api/embed call
+	EmbeddingKeepAlive       string        `env:"EMBEDDING_KEEP_ALIVE" envDefault:"5m"` // How long Ollama keeps the embedding model loaded
+	EmbeddingTruncate        bool          `env:"EMBEDDING_TRUNCATE" envDefault:"true"` // Truncate inputs longer than the model's context
+	EnableEmbeddingCache     bool          `env:"ENABLE_EMBEDDING_CACHE" envDefault:"true"`
+	EmbeddingCacheTTL        time.Duration `env:"EMBEDDING_CACHE_TTL" envDefault:"720h"`           // Cached embeddings unused for this long are deleted; 0 keeps them
+	EmbeddingCacheMaxEntries int           `env:"EMBEDDING_CACHE_MAX_ENTRIES" envDefault:"100000"` // Least recently used embeddings beyond this are deleted; 0 means no limit
+
+	// Session title and follow-up suggestion configuration
+	TitleModel                string        `env:"TITLE_MODEL" envDefault:""` // Empty means use the chat model
+	EnableFollowUpSuggestions bool          `env:"ENABLE_FOLLOW_UP_SUGGESTIONS" envDefault:"true"`
+	SessionMetadataTimeout    time.Duration `env:"SESSION_METADATA_TIMEOUT" envDefault:"20s"` // How long a chat stream stays open after done for the metadata event
+
+	// Memory summarization configuration
+	EnableSummarization bool          `env:"ENABLE_SUMMARIZATION" envDefault:"true"`
+	SummaryModel        string        `env:"SUMMARY_MODEL" envDefault:""` // Empty means use the default model
+	SessionIdleTimeout  time.Duration `env:"SESSION_IDLE_TIMEOUT" envDefault:"30m"`
+	SummarizerInterval  time.Duration `env:"SUMMARIZER_INTERVAL" envDefault:"10m"`
+
+	// Memory gap detection configuration
+	MemoryGapThreshold  time.Duration `env:"MEMORY_GAP_THRESHOLD" envDefault:"1h"`
+	TopicDriftThreshold float64       `env:"TOPIC_DRIFT_THRESHOLD" envDefault:"0.35"` // Consecutive user turns less similar than this start a topical gap
+	EnableResumeBridge  bool          `env:"ENABLE_RESUME_BRIDGE" envDefault:"true"`
+	ResumeBridgeTimeout time.Duration `env:"RESUME_BRIDGE_TIMEOUT" envDefault:"15s"`
+
+	// Topic clustering configuration
+	EnableTopicClustering    bool          `env:"ENABLE_TOPIC_CLUSTERING" envDefault:"true"`
+	TopicClusteringInterval  time.Duration `env:"TOPIC_CLUSTERING_INTERVAL" envDefault:"15m"`
+	TopicSimilarityThreshold float64       `env:"TOPIC_SIMILARITY_THRESHOLD" envDefault:"0.6"` // Minimum similarity to join an existing topic
+	MaxTopicsPerUser         int           `env:"MAX_TOPICS_PER_USER" envDefault:"50"`
+
+	// Re-embedding configuration
+	ReembedBatchSize    int           `env:"REEMBED_BATCH_SIZE" envDefault:"50"`
+	ReembedPollInterval time.Duration `env:"REEMBED_POLL_INTERVAL" envDefault:"30s"`
+
+	// User memory (fact) configuration
+	EnableFactExtraction   bool          `env:"ENABLE_FACT_EXTRACTION" envDefault:"true"`
+	FactModel              string        `env:"FACT_MODEL" envDefault:""` // Empty means use the summary model
+	FactExtractionInterval time.Duration `env:"FACT_EXTRACTION_INTERVAL" envDefault:"10m"`
+	MinFactConfidence      float64       `env:"MIN_FACT_CONFIDENCE" envDefault:"0.6"` // Proposals below this confidence are discarded
+	MaxInjectedFacts       int           `env:"MAX_INJECTED_FACTS" envDefault:"5"`
+	FactRelevanceThreshold float64       `env:"FACT_RELEVANCE_THRESHOLD" envDefault:"0.3"` // Minimum similarity to the message for a fact to be injected
 	
+	// Memory retention configuration (users and projects can set their own policy)
+	EnableMemoryRetention   bool          `env:"ENABLE_MEMORY_RETENTION" envDefault:"true"`
+	MemoryRetentionDays     int           `env:"MEMORY_RETENTION_DAYS" envDefault:"0"` // 0 keeps memory forever unless a user or project sets a policy
+	MemoryRetentionInterval time.Duration `env:"MEMORY_RETENTION_INTERVAL" envDefault:"6h"`
+
+	// Job queue configuration
+	JobWorkers        int           `env:"JOB_WORKERS" envDefault:"4"`
+	JobPollInterval   time.Duration `env:"JOB_POLL_INTERVAL" envDefault:"1s"`
+	JobMaxAttempts    int           `env:"JOB_MAX_ATTEMPTS" envDefault:"5"`
+	JobRetryBaseDelay time.Duration `env:"JOB_RETRY_BASE_DELAY" envDefault:"10s"` // Doubled after every failed attempt
+	JobRetryMaxDelay  time.Duration `env:"JOB_RETRY_MAX_DELAY" envDefault:"10m"`
+	JobLockTimeout    time.Duration `env:"JOB_LOCK_TIMEOUT" envDefault:"2m"` // Running jobs without a heartbeat for this long are picked up again
+	JobDrainTimeout   time.Duration `env:"JOB_DRAIN_TIMEOUT" envDefault:"30s"` // How long shutdown waits for running jobs
+	JobRetention      time.Duration `env:"JOB_RETENTION" envDefault:"24h"` // Completed jobs are deleted after this long
+
+	// Administration configuration
+	AdminUsernames []string `env:"ADMIN_USERNAMES" envSeparator:","`
+
 	// Authentication configuration
 	JWTSecret     string        `env:"JWT_SECRET" envDefault:"your-secret-key-change-in-production"`
 	JWTExpiration time.Duration `env:"JWT_EXPIRATION" envDefault:"24h"`
@@ -84,13 +188,215 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("OLLAMA_HOST cannot be empty")
 	}
 
+	hosts := make(map[string]bool)
+	for _, host := range SplitOllamaHosts(c.OllamaHost) {
+		if host.Name == "" || host.Address == "" {
+			return fmt.Errorf("OLLAMA_HOST entries must be host:port or name=host:port")
+		}
+		if hosts[host.Name] {
+			return fmt.Errorf("OLLAMA_HOST has more than one host named %s", host.Name)
+		}
+		hosts[host.Name] = true
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("OLLAMA_HOST cannot be empty")
+	}
+
+	if c.OllamaHealthInterval <= 0 {
+		return fmt.Errorf("OLLAMA_HEALTH_INTERVAL must be positive")
+	}
+
+	for _, rule := range strings.Split(c.OllamaPlacement, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		pattern, targets, ok := strings.Cut(rule, "=")
+		if !ok || strings.TrimSpace(pattern) == "" || strings.TrimSpace(targets) == "" {
+			return fmt.Errorf("OLLAMA_PLACEMENT rules must be pattern=host,host")
+		}
+		for _, target := range strings.Split(targets, ",") {
+			target = strings.TrimSpace(target)
+			if target != "all" && !hosts[target] {
+				return fmt.Errorf("OLLAMA_PLACEMENT names unknown host %s", target)
+			}
+		}
+	}
+
+	if c.MaxConcurrentDownloads <= 0 {
+		return fmt.Errorf("MAX_CONCURRENT_DOWNLOADS must be positive")
+	}
+
+	if c.ModelUploadMaxSize <= 0 {
+		return fmt.Errorf("MODEL_UPLOAD_MAX_SIZE must be positive")
+	}
+
+	if c.ModelStorageBudget < 0 {
+		return fmt.Errorf("MODEL_STORAGE_BUDGET must not be negative")
+	}
+
+	if c.BenchmarkMaxTokens <= 0 {
+		return fmt.Errorf("BENCHMARK_MAX_TOKENS must be positive")
+	}
+
+	if c.ModelReconcileInterval <= 0 {
+		return fmt.Errorf("MODEL_RECONCILE_INTERVAL must be positive")
+	}
+
+	if c.ModelCatalogURL != "" && !strings.HasPrefix(c.ModelCatalogURL, "http://") && !strings.HasPrefix(c.ModelCatalogURL, "https://") {
+		return fmt.Errorf("MODEL_CATALOG_URL must be an http or https URL")
+	}
+
 	if c.MaxConcurrentChats <= 0 {
 		return fmt.Errorf("MAX_CONCURRENT_CHATS must be positive")
 	}
 
+	if c.MaxContextResults <= 0 {
+		return fmt.Errorf("MAX_CONTEXT_RESULTS must be positive")
+	}
+
+	if c.RetrievalMMRLambda < 0 || c.RetrievalMMRLambda > 1 {
+		return fmt.Errorf("RETRIEVAL_MMR_LAMBDA must be between 0 and 1")
+	}
+
+	if c.RetrievalRecencyWeight < 0 || c.RetrievalRecencyWeight > 1 {
+		return fmt.Errorf("RETRIEVAL_RECENCY_WEIGHT must be between 0 and 1")
+	}
+
+	if c.RetrievalRecencyHalfLife <= 0 {
+		return fmt.Errorf("RETRIEVAL_RECENCY_HALF_LIFE must be positive")
+	}
+
+	switch c.RetrievalScope {
+	case "user", "project", "session":
+	default:
+		return fmt.Errorf("RETRIEVAL_SCOPE must be one of user, project or session")
+	}
+
+	switch c.VectorStore {
+	case "pgvector", "memory":
+	default:
+		return fmt.Errorf("VECTOR_STORE must be pgvector or memory")
+	}
+
+	switch c.VectorIndexType {
+	case "hnsw", "ivfflat":
+	default:
+		return fmt.Errorf("VECTOR_INDEX_TYPE must be hnsw or ivfflat")
+	}
+
+	if c.HNSWM < 2 || c.HNSWM > 100 {
+		return fmt.Errorf("HNSW_M must be between 2 and 100")
+	}
+
+	if c.HNSWEfConstruction < 2*c.HNSWM || c.HNSWEfConstruction > 1000 {
+		return fmt.Errorf("HNSW_EF_CONSTRUCTION must be between twice HNSW_M and 1000")
+	}
+
+	if c.IVFFlatLists < 0 || c.IVFFlatLists > 32768 {
+		return fmt.Errorf("IVFFLAT_LISTS must be between 0 and 32768")
+	}
+
+	if c.VectorSearchEfSearch < 1 || c.VectorSearchEfSearch > 1000 {
+		return fmt.Errorf("VECTOR_SEARCH_EF_SEARCH must be between 1 and 1000")
+	}
+
+	if c.VectorSearchProbes < 1 {
+		return fmt.Errorf("VECTOR_SEARCH_PROBES must be positive")
+	}
+
+	if c.VectorRecallSampleSize <= 0 {
+		return fmt.Errorf("VECTOR_RECALL_SAMPLE_SIZE must be positive")
+	}
+
+	if c.EmbeddingBatchSize <= 0 {
+		return fmt.Errorf("EMBEDDING_BATCH_SIZE must be positive")
+	}
+
+	if c.EmbeddingCacheTTL < 0 {
+		return fmt.Errorf("EMBEDDING_CACHE_TTL cannot be negative")
+	}
+
+	if c.EmbeddingCacheMaxEntries < 0 {
+		return fmt.Errorf("EMBEDDING_CACHE_MAX_ENTRIES cannot be negative")
+	}
+
+	if c.SummarizerInterval <= 0 {
+		return fmt.Errorf("SUMMARIZER_INTERVAL must be positive")
+	}
+
+	if c.TopicClusteringInterval <= 0 {
+		return fmt.Errorf("TOPIC_CLUSTERING_INTERVAL must be positive")
+	}
+
+	if c.MaxTopicsPerUser <= 0 {
+		return fmt.Errorf("MAX_TOPICS_PER_USER must be positive")
+	}
+
+	if c.ReembedBatchSize <= 0 {
+		return fmt.Errorf("REEMBED_BATCH_SIZE must be positive")
+	}
+
+	if c.ReembedPollInterval <= 0 {
+		return fmt.Errorf("REEMBED_POLL_INTERVAL must be positive")
+	}
+
+	if c.FactExtractionInterval <= 0 {
+		return fmt.Errorf("FACT_EXTRACTION_INTERVAL must be positive")
+	}
+
+	if c.MaxInjectedFacts < 0 {
+		return fmt.Errorf("MAX_INJECTED_FACTS cannot be negative")
+	}
+
+	if c.MemoryRetentionDays < 0 {
+		return fmt.Errorf("MEMORY_RETENTION_DAYS cannot be negative")
+	}
+
+	if c.MemoryRetentionInterval <= 0 {
+		return fmt.Errorf("MEMORY_RETENTION_INTERVAL must be positive")
+	}
+
+	if c.JobWorkers <= 0 {
+		return fmt.Errorf("JOB_WORKERS must be positive")
+	}
+
+	if c.JobPollInterval <= 0 {
+		return fmt.Errorf("JOB_POLL_INTERVAL must be positive")
+	}
+
+	if c.JobMaxAttempts <= 0 {
+		return fmt.Errorf("JOB_MAX_ATTEMPTS must be positive")
+	}
+
+	if c.JobRetryBaseDelay <= 0 || c.JobRetryMaxDelay < c.JobRetryBaseDelay {
+		return fmt.Errorf("JOB_RETRY_BASE_DELAY must be positive and no greater than JOB_RETRY_MAX_DELAY")
+	}
+
+	if c.JobLockTimeout <= 0 {
+		return fmt.Errorf("JOB_LOCK_TIMEOUT must be positive")
+	}
+
+	if c.JobDrainTimeout <= 0 {
+		return fmt.Errorf("JOB_DRAIN_TIMEOUT must be positive")
+	}
+
+	if c.JobRetention <= 0 {
+		return fmt.Errorf("JOB_RETENTION must be positive")
+	}
+
 	return nil
 }
 
+// IsAdmin reports whether the user may use the administration endpoints
+func (c *Config) IsAdmin(username string) bool {
+	for _, admin := range c.AdminUsernames {
+		if strings.TrimSpace(admin) == username && username != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // IsDevelopment returns true if running in development mode
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
@@ -105,4 +411,29 @@ func (c *Config) IsProduction() bool {
 func (c *Config) GetDatabaseDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
-}
\ No newline at end of file
+}
+
+// OllamaHostSpec is one host of OLLAMA_HOST
+type OllamaHostSpec struct {
+	Name    string // Name used in placement rules and status; defaults to the address
+	Address string
+}
+
+// SplitOllamaHosts splits OLLAMA_HOST into its hosts, each host:port or name=host:port
+func SplitOllamaHosts(value string) []OllamaHostSpec {
+	var hosts []OllamaHostSpec
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, address, ok := strings.Cut(entry, "=")
+		if !ok {
+			address = entry
+			name = strings.TrimPrefix(strings.TrimPrefix(entry, "https://"), "http://")
+			name = strings.TrimRight(name, "/")
+		}
+		hosts = append(hosts, OllamaHostSpec{Name: strings.TrimSpace(name), Address: strings.TrimSpace(address)})
+	}
+	return hosts
+}
@@ -25,8 +25,7 @@ type Database interface {
 	// Migration operations
 	RunMigrations(migrationsPath string) error
 
-	// Vector operations (PostgreSQL specific)
-	VectorSimilaritySearch(table, vectorColumn string, queryVector []float32, limit int) (*sql.Rows, error)
-	InsertVector(table string, columns []string, values []interface{}) error
+	// Vector operations
+	Vectors() VectorStore
 	CheckPgvectorExtension() error
 }
\ No newline at end of file
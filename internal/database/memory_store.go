@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryVectorStore keeps vectors in process memory and searches them exactly. It suits
+// tests and small single-user deployments; nothing survives a restart. Summaries and facts
+// are still searched in PostgreSQL, so the pgvector extension remains required.
+type MemoryVectorStore struct {
+	mu      sync.RWMutex
+	records map[memoryKey]VectorRecord
+	topics  TopicMembership
+}
+
+// TopicMembership returns which of the given messages are assigned to a topic. The
+// memory store uses it for the Unclustered filter, since topics are kept in the database.
+type TopicMembership func(ctx context.Context, messageIDs []string) (map[string]bool, error)
+
+// memoryKey identifies the vector of a message for a model
+type memoryKey struct {
+	messageID string
+	model     string
+}
+
+// NewMemoryVectorStore creates an empty in-memory vector store
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{records: make(map[memoryKey]VectorRecord)}
+}
+
+// SetTopicMembership sets the lookup the Unclustered filter is answered with
+func (m *MemoryVectorStore) SetTopicMembership(topics TopicMembership) {
+	m.topics = topics
+}
+
+// matching returns the keys and records passing the filter, looking up topic membership
+// when the filter asks for unclustered messages only. The caller holds the lock.
+func (m *MemoryVectorStore) matching(ctx context.Context, filter VectorFilter) ([]memoryKey, []VectorRecord, error) {
+	var keys []memoryKey
+	var records []VectorRecord
+	for key, record := range m.records {
+		if filter.matches(record) {
+			keys = append(keys, key)
+			records = append(records, record)
+		}
+	}
+	if !filter.Unclustered || len(records) == 0 {
+		return keys, records, nil
+	}
+
+	if m.topics == nil {
+		return nil, nil, fmt.Errorf("memory vector store has no topic membership lookup")
+	}
+	messageIDs := make([]string, len(records))
+	for i, record := range records {
+		messageIDs[i] = record.MessageID
+	}
+	clustered, err := m.topics(ctx, messageIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up message topics: %w", err)
+	}
+
+	n := 0
+	for i, record := range records {
+		if !clustered[record.MessageID] {
+			keys[n], records[n] = keys[i], record
+			n++
+		}
+	}
+	return keys[:n], records[:n], nil
+}
+
+// Upsert stores the records, replacing the vector a message already has for the model
+func (m *MemoryVectorStore) Upsert(ctx context.Context, records ...VectorRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, record := range records {
+		key := memoryKey{messageID: record.MessageID, model: record.Model}
+		if existing, ok := m.records[key]; ok {
+			record.ID = existing.ID
+			if record.UserID == "" {
+				record.UserID = existing.UserID
+			}
+		}
+		if record.ID == "" {
+			record.ID = uuid.New().String()
+		}
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = time.Now()
+		}
+		record.Embedding = append([]float32(nil), record.Embedding...)
+		m.records[key] = record
+	}
+	return nil
+}
+
+// Delete removes the records matching the filter
+func (m *MemoryVectorStore) Delete(ctx context.Context, filter VectorFilter) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys, _, err := m.matching(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		delete(m.records, key)
+	}
+	return int64(len(keys)), nil
+}
+
+// Search compares the query with every matching record of the same dimension. The search is
+// exact, so tuning is ignored.
+func (m *MemoryVectorStore) Search(ctx context.Context, query []float32, filter VectorFilter, k int, _ SearchTuning) ([]VectorMatch, error) {
+	m.mu.RLock()
+	_, records, err := m.matching(ctx, filter)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []VectorMatch
+	for _, record := range records {
+		if len(record.Embedding) != len(query) {
+			continue
+		}
+		matches = append(matches, VectorMatch{VectorRecord: record, Similarity: cosineSimilarity(query, record.Embedding)})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].MessageID < matches[j].MessageID
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// List returns the matching records, oldest message first
+func (m *MemoryVectorStore) List(ctx context.Context, filter VectorFilter, limit int) ([]VectorRecord, error) {
+	m.mu.RLock()
+	_, records, err := m.matching(ctx, filter)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].MessageCreatedAt.Equal(records[j].MessageCreatedAt) {
+			return records[i].MessageCreatedAt.Before(records[j].MessageCreatedAt)
+		}
+		return records[i].MessageID < records[j].MessageID
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// Count returns the number of matching records
+func (m *MemoryVectorStore) Count(ctx context.Context, filter VectorFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys, _, err := m.matching(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(keys)), nil
+}
+
+// Stats summarizes the stored records
+func (m *MemoryVectorStore) Stats(ctx context.Context) (*VectorStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := &VectorStats{
+		ByRole:  make(map[string]int64),
+		ByModel: make(map[string]int64),
+	}
+	sessions := make(map[string]bool)
+	for _, record := range m.records {
+		stats.Total++
+		stats.ByRole[record.Role]++
+		stats.ByModel[record.Model]++
+		sessions[record.SessionID] = true
+
+		createdAt := record.MessageCreatedAt
+		if stats.Oldest == nil || createdAt.Before(*stats.Oldest) {
+			stats.Oldest = &createdAt
+		}
+		if stats.Newest == nil || createdAt.After(*stats.Newest) {
+			stats.Newest = &createdAt
+		}
+	}
+	stats.Sessions = int64(len(sessions))
+	return stats, nil
+}
+
+// matches reports whether a record passes the filter
+func (f VectorFilter) matches(record VectorRecord) bool {
+	if f.Model != "" && record.Model != f.Model {
+		return false
+	}
+	if f.UserID != "" && record.UserID != f.UserID {
+		return false
+	}
+	if f.ExcludeUserIDs != nil && record.UserID != "" && containsString(f.ExcludeUserIDs, record.UserID) {
+		return false
+	}
+	if f.SessionIDs != nil && !containsString(f.SessionIDs, record.SessionID) {
+		return false
+	}
+	if containsString(f.ExcludeSessionIDs, record.SessionID) {
+		return false
+	}
+	if f.MessageIDs != nil && !containsString(f.MessageIDs, record.MessageID) {
+		return false
+	}
+	if containsString(f.ExcludeMessageIDs, record.MessageID) {
+		return false
+	}
+	if f.Roles != nil && !containsString(f.Roles, record.Role) {
+		return false
+	}
+	if containsString(f.ExcludeRoles, record.Role) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !record.MessageCreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && record.MessageCreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
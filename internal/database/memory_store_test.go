@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var memoryStoreEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedMemoryStore returns a store holding four messages of two users in three sessions
+func seedMemoryStore(t *testing.T) *MemoryVectorStore {
+	t.Helper()
+	store := NewMemoryVectorStore()
+	err := store.Upsert(context.Background(),
+		VectorRecord{MessageID: "m1", SessionID: "s1", UserID: "u1", Role: "user", Model: "a", Embedding: []float32{1, 0}, MessageCreatedAt: memoryStoreEpoch},
+		VectorRecord{MessageID: "m2", SessionID: "s1", UserID: "u1", Role: "assistant", Model: "a", Embedding: []float32{0, 1}, MessageCreatedAt: memoryStoreEpoch.Add(time.Hour)},
+		VectorRecord{MessageID: "m3", SessionID: "s2", UserID: "u1", Role: "user", Model: "b", Embedding: []float32{1, 1}, MessageCreatedAt: memoryStoreEpoch.Add(2 * time.Hour)},
+		VectorRecord{MessageID: "m4", SessionID: "s3", UserID: "u2", Role: "user", Model: "a", Embedding: []float32{1, 0, 0}, MessageCreatedAt: memoryStoreEpoch.Add(3 * time.Hour)},
+	)
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	return store
+}
+
+// messageIDs returns the sorted message IDs of the records
+func messageIDs(records []VectorRecord) []string {
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.MessageID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestMemoryVectorStoreFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter VectorFilter
+		want   []string
+	}{
+		{"empty filter matches everything", VectorFilter{}, []string{"m1", "m2", "m3", "m4"}},
+		{"model", VectorFilter{Model: "a"}, []string{"m1", "m2", "m4"}},
+		{"user", VectorFilter{UserID: "u2"}, []string{"m4"}},
+		{"exclude users", VectorFilter{ExcludeUserIDs: []string{"u1"}}, []string{"m4"}},
+		{"nil session list does not filter", VectorFilter{SessionIDs: nil}, []string{"m1", "m2", "m3", "m4"}},
+		{"empty session list matches nothing", VectorFilter{SessionIDs: []string{}}, []string{}},
+		{"sessions", VectorFilter{SessionIDs: []string{"s1", "s3"}}, []string{"m1", "m2", "m4"}},
+		{"exclude sessions", VectorFilter{ExcludeSessionIDs: []string{"s1"}}, []string{"m3", "m4"}},
+		{"empty exclude list excludes nothing", VectorFilter{ExcludeSessionIDs: []string{}}, []string{"m1", "m2", "m3", "m4"}},
+		{"empty message list matches nothing", VectorFilter{MessageIDs: []string{}}, []string{}},
+		{"messages", VectorFilter{MessageIDs: []string{"m2", "m3"}}, []string{"m2", "m3"}},
+		{"exclude messages", VectorFilter{ExcludeMessageIDs: []string{"m1", "m4"}}, []string{"m2", "m3"}},
+		{"empty role list matches nothing", VectorFilter{Roles: []string{}}, []string{}},
+		{"roles", VectorFilter{Roles: []string{"assistant"}}, []string{"m2"}},
+		{"exclude roles", VectorFilter{ExcludeRoles: []string{"user"}}, []string{"m2"}},
+		{"created before is exclusive", VectorFilter{CreatedBefore: memoryStoreEpoch.Add(time.Hour)}, []string{"m1"}},
+		{"created after is inclusive", VectorFilter{CreatedAfter: memoryStoreEpoch.Add(2 * time.Hour)}, []string{"m3", "m4"}},
+		{"combined", VectorFilter{Model: "a", UserID: "u1", Roles: []string{"user"}}, []string{"m1"}},
+	}
+
+	store := seedMemoryStore(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.List(context.Background(), tt.filter, 0)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if got := messageIDs(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+
+			count, err := store.Count(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Errorf("Count = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMemoryVectorStoreUnclustered(t *testing.T) {
+	store := seedMemoryStore(t)
+	if _, err := store.List(context.Background(), VectorFilter{Unclustered: true}, 0); err == nil {
+		t.Fatal("List without a topic membership lookup succeeded, want an error")
+	}
+
+	var asked []string
+	store.SetTopicMembership(func(ctx context.Context, ids []string) (map[string]bool, error) {
+		asked = append(asked, ids...)
+		return map[string]bool{"m1": true, "m4": true}, nil
+	})
+	records, err := store.List(context.Background(), VectorFilter{Model: "a", Unclustered: true}, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := messageIDs(records), []string{"m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	// Only the records passing the rest of the filter are looked up
+	sort.Strings(asked)
+	if want := []string{"m1", "m2", "m4"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("looked up %v, want %v", asked, want)
+	}
+
+	store.SetTopicMembership(func(ctx context.Context, ids []string) (map[string]bool, error) {
+		return nil, errors.New("unavailable")
+	})
+	if _, err := store.Count(context.Background(), VectorFilter{Unclustered: true}); err == nil {
+		t.Error("Count with a failing lookup succeeded, want an error")
+	}
+}
+
+func TestMemoryVectorStoreSearch(t *testing.T) {
+	store := NewMemoryVectorStore()
+	err := store.Upsert(context.Background(),
+		VectorRecord{MessageID: "far", Model: "a", Embedding: []float32{0, 1}},
+		VectorRecord{MessageID: "near", Model: "a", Embedding: []float32{1, 0.1}},
+		VectorRecord{MessageID: "tie-b", Model: "a", Embedding: []float32{1, 1}},
+		VectorRecord{MessageID: "tie-a", Model: "a", Embedding: []float32{2, 2}},
+		VectorRecord{MessageID: "other-dimension", Model: "a", Embedding: []float32{1, 0, 0}},
+	)
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		k    int
+		want []string
+	}{
+		{"all by similarity, ties by message ID", 0, []string{"near", "tie-a", "tie-b", "far"}},
+		{"top k", 2, []string{"near", "tie-a"}},
+		{"k above the matches", 10, []string{"near", "tie-a", "tie-b", "far"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := store.Search(context.Background(), []float32{1, 0}, VectorFilter{}, tt.k, SearchTuning{})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			got := make([]string, len(matches))
+			for i, match := range matches {
+				got[i] = match.MessageID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Vectors of another dimension are not comparable and are skipped
+	matches, err := store.Search(context.Background(), []float32{1, 0, 0}, VectorFilter{}, 0, SearchTuning{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].MessageID != "other-dimension" || matches[0].Similarity != 1 {
+		t.Errorf("Search with a 3-dimensional query = %+v, want only other-dimension with similarity 1", matches)
+	}
+}
+
+func TestMemoryVectorStoreUpsert(t *testing.T) {
+	store := seedMemoryStore(t)
+	before, err := store.List(context.Background(), VectorFilter{MessageIDs: []string{"m1"}}, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	// Replacing a vector keeps its ID and owner
+	if err := store.Upsert(context.Background(), VectorRecord{MessageID: "m1", SessionID: "s1", Model: "a", Embedding: []float32{0, 1}}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	after, err := store.List(context.Background(), VectorFilter{MessageIDs: []string{"m1"}}, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(after) != 1 {
+		t.Fatalf("List = %d records, want 1", len(after))
+	}
+	if after[0].ID != before[0].ID || after[0].UserID != "u1" {
+		t.Errorf("replaced record has ID %q and user %q, want %q and u1", after[0].ID, after[0].UserID, before[0].ID)
+	}
+	if !reflect.DeepEqual(after[0].Embedding, []float32{0, 1}) {
+		t.Errorf("replaced record has embedding %v, want [0 1]", after[0].Embedding)
+	}
+}
+
+func TestMemoryVectorStoreDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  VectorFilter
+		deleted int64
+		left    []string
+	}{
+		{"session", VectorFilter{SessionIDs: []string{"s1"}}, 2, []string{"m3", "m4"}},
+		{"model of a user", VectorFilter{Model: "a", UserID: "u1"}, 2, []string{"m3", "m4"}},
+		{"empty list deletes nothing", VectorFilter{MessageIDs: []string{}}, 0, []string{"m1", "m2", "m3", "m4"}},
+		{"no match", VectorFilter{SessionIDs: []string{"missing"}}, 0, []string{"m1", "m2", "m3", "m4"}},
+		{"everything", VectorFilter{}, 4, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := seedMemoryStore(t)
+			deleted, err := store.Delete(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if deleted != tt.deleted {
+				t.Errorf("Delete = %d, want %d", deleted, tt.deleted)
+			}
+			records, err := store.List(context.Background(), VectorFilter{}, 0)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if got := messageIDs(records); !reflect.DeepEqual(got, tt.left) {
+				t.Errorf("left %v, want %v", got, tt.left)
+			}
+		})
+	}
+}
+
+func TestMemoryVectorStoreList(t *testing.T) {
+	store := seedMemoryStore(t)
+	records, err := store.List(context.Background(), VectorFilter{}, 3)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	got := make([]string, len(records))
+	for i, record := range records {
+		got[i] = record.MessageID
+	}
+	if want := []string{"m1", "m2", "m3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want the oldest three %v", got, want)
+	}
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// pgVectorColumns are the message_embeddings columns a VectorRecord is scanned from
+const pgVectorColumns = "id, message_id, session_id, user_id, role, content, model_used, embedding, message_created_at, created_at"
+
+// PgVectorStore keeps vectors in the message_embeddings table and searches them with pgvector
+type PgVectorStore struct {
+	db     *sql.DB
+	tuning SearchTuning
+}
+
+// NewPgVectorStore creates a vector store over the message_embeddings table that searches
+// with the given tuning unless a query overrides it
+func NewPgVectorStore(db *sql.DB, tuning SearchTuning) *PgVectorStore {
+	return &PgVectorStore{db: db, tuning: tuning}
+}
+
+// Upsert stores the records, replacing the vector a message already has for the model
+func (p *PgVectorStore) Upsert(ctx context.Context, records ...VectorRecord) error {
+	for _, record := range records {
+		id := record.ID
+		if id == "" {
+			id = uuid.New().String()
+		}
+		createdAt := record.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		var userID sql.NullString
+		if record.UserID != "" {
+			userID = sql.NullString{String: record.UserID, Valid: true}
+		}
+
+		_, err := p.db.ExecContext(ctx, `
+			INSERT INTO message_embeddings (id, message_id, session_id, user_id, role, content, embedding, model_used, message_created_at, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (message_id, model_used) DO UPDATE
+			SET session_id = EXCLUDED.session_id,
+				user_id = COALESCE(EXCLUDED.user_id, message_embeddings.user_id),
+				role = EXCLUDED.role,
+				content = EXCLUDED.content,
+				embedding = EXCLUDED.embedding,
+				message_created_at = EXCLUDED.message_created_at,
+				created_at = EXCLUDED.created_at
+		`, id, record.MessageID, record.SessionID, userID, record.Role, record.Content,
+			pgvector.NewVector(record.Embedding), record.Model, record.MessageCreatedAt, createdAt)
+		if err != nil {
+			return fmt.Errorf("failed to upsert embedding: %w", err)
+		}
+	}
+	return nil
+}
+
+// Delete removes the records matching the filter
+func (p *PgVectorStore) Delete(ctx context.Context, filter VectorFilter) (int64, error) {
+	where, args := filter.sql(1)
+	result, err := p.db.ExecContext(ctx, "DELETE FROM message_embeddings WHERE "+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete embeddings: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Search orders the matching vectors of the query's dimension by cosine distance. Vectors
+// are cast to that dimension so the per-model indexes can be used.
+func (p *PgVectorStore) Search(ctx context.Context, query []float32, filter VectorFilter, k int, tuning SearchTuning) ([]VectorMatch, error) {
+	// Index scan settings only last for the transaction the query runs in
+	tx, err := p.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tuning.WithDefaults(p.tuning).Apply(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	where, args := filter.sql(3)
+	distance := fmt.Sprintf("embedding::vector(%d) <=> $1::vector(%d)", len(query), len(query))
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+pgVectorColumns+`, (`+distance+`) AS distance
+		FROM message_embeddings
+		WHERE vector_dims(embedding) = `+fmt.Sprint(len(query))+` AND `+where+`
+		ORDER BY `+distance+`
+		LIMIT $2
+	`, append([]interface{}{pgvector.NewVector(query), k}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute similarity search: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []VectorMatch
+	for rows.Next() {
+		var match VectorMatch
+		var distance float64
+		if err := scanVectorRecord(rows, &match.VectorRecord, &distance); err != nil {
+			return nil, err
+		}
+		match.Similarity = 1.0 - distance
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// List returns the matching records, oldest message first
+func (p *PgVectorStore) List(ctx context.Context, filter VectorFilter, limit int) ([]VectorRecord, error) {
+	where, args := filter.sql(1)
+	query := "SELECT " + pgVectorColumns + " FROM message_embeddings WHERE " + where + " ORDER BY message_created_at, message_id"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var records []VectorRecord
+	for rows.Next() {
+		var record VectorRecord
+		if err := scanVectorRecord(rows, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// Count returns the number of matching records
+func (p *PgVectorStore) Count(ctx context.Context, filter VectorFilter) (int64, error) {
+	where, args := filter.sql(1)
+	var count int64
+	if err := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM message_embeddings WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count embeddings: %w", err)
+	}
+	return count, nil
+}
+
+// Stats summarizes the stored records
+func (p *PgVectorStore) Stats(ctx context.Context) (*VectorStats, error) {
+	stats := &VectorStats{
+		ByRole:  make(map[string]int64),
+		ByModel: make(map[string]int64),
+	}
+
+	var oldest, newest sql.NullTime
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT session_id), MIN(message_created_at), MAX(message_created_at)
+		FROM message_embeddings
+	`).Scan(&stats.Total, &stats.Sessions, &oldest, &newest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding totals: %w", err)
+	}
+	if oldest.Valid {
+		stats.Oldest = &oldest.Time
+		stats.Newest = &newest.Time
+	}
+
+	for column, counts := range map[string]map[string]int64{"role": stats.ByRole, "model_used": stats.ByModel} {
+		rows, err := p.db.QueryContext(ctx, fmt.Sprintf("SELECT %s, COUNT(*) FROM message_embeddings GROUP BY %s", column, column))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get embeddings by %s: %w", column, err)
+		}
+		for rows.Next() {
+			var key string
+			var count int64
+			if err := rows.Scan(&key, &count); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan embeddings by %s: %w", column, err)
+			}
+			counts[key] = count
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating embeddings by %s: %w", column, err)
+		}
+	}
+
+	return stats, nil
+}
+
+// scanVectorRecord scans the pgVectorColumns of a row, followed by any extra columns
+func scanVectorRecord(rows *sql.Rows, record *VectorRecord, extra ...interface{}) error {
+	var userID sql.NullString
+	var embedding pgvector.Vector
+	dest := []interface{}{
+		&record.ID, &record.MessageID, &record.SessionID, &userID, &record.Role, &record.Content,
+		&record.Model, &embedding, &record.MessageCreatedAt, &record.CreatedAt,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return fmt.Errorf("failed to scan embedding: %w", err)
+	}
+	record.UserID = userID.String
+	record.Embedding = embedding.Slice()
+	return nil
+}
+
+// sql renders the filter as a WHERE condition whose placeholders start at $first
+func (f VectorFilter) sql(first int) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", first+len(args)-1)))
+	}
+
+	if f.Model != "" {
+		add("model_used = ?", f.Model)
+	}
+	if f.UserID != "" {
+		add("user_id = ?", f.UserID)
+	}
+	if f.ExcludeUserIDs != nil {
+		add("(user_id IS NULL OR NOT user_id = ANY(?))", pq.Array(f.ExcludeUserIDs))
+	}
+	if f.SessionIDs != nil {
+		add("session_id = ANY(?)", pq.Array(f.SessionIDs))
+	}
+	if len(f.ExcludeSessionIDs) > 0 {
+		add("NOT session_id = ANY(?)", pq.Array(f.ExcludeSessionIDs))
+	}
+	if f.MessageIDs != nil {
+		add("message_id = ANY(?)", pq.Array(f.MessageIDs))
+	}
+	if len(f.ExcludeMessageIDs) > 0 {
+		add("NOT message_id = ANY(?)", pq.Array(f.ExcludeMessageIDs))
+	}
+	if f.Roles != nil {
+		add("role = ANY(?)", pq.Array(f.Roles))
+	}
+	if len(f.ExcludeRoles) > 0 {
+		add("NOT role = ANY(?)", pq.Array(f.ExcludeRoles))
+	}
+	if !f.CreatedBefore.IsZero() {
+		add("message_created_at < ?", f.CreatedBefore)
+	}
+	if !f.CreatedAfter.IsZero() {
+		add("message_created_at >= ?", f.CreatedAfter)
+	}
+	if f.Unclustered {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM message_topics mt WHERE mt.message_id = message_embeddings.message_id)")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
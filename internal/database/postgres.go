@@ -11,13 +11,13 @@ import (
 	"chat_ollama/internal/config"
 
 	_ "github.com/lib/pq"
-	"github.com/pgvector/pgvector-go"
 )
 
 // PostgresDB wraps the sql.DB connection for PostgreSQL
 type PostgresDB struct {
 	*sql.DB
-	config *config.Config
+	config  *config.Config
+	vectors VectorStore
 }
 
 // NewPostgresDB creates a new PostgreSQL database connection
@@ -39,10 +39,23 @@ func NewPostgresDB(cfg *config.Config) (*PostgresDB, error) {
 		return nil, fmt.Errorf("failed to ping PostgreSQL database: %w", err)
 	}
 
-	return &PostgresDB{
+	pgDB := &PostgresDB{
 		DB:     db,
 		config: cfg,
-	}, nil
+	}
+
+	pgDB.vectors, err = NewVectorStore(pgDB, cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return pgDB, nil
+}
+
+// Vectors returns the store holding message embeddings
+func (db *PostgresDB) Vectors() VectorStore {
+	return db.vectors
 }
 
 // Close closes the database connection
@@ -176,33 +189,3 @@ func getMigrationFiles(migrationsPath string) ([]string, error) {
 	sort.Strings(files)
 	return files, nil
 }
-
-// VectorSimilaritySearch performs vector similarity search
-func (db *PostgresDB) VectorSimilaritySearch(table, vectorColumn string, queryVector []float32, limit int) (*sql.Rows, error) {
-	query := fmt.Sprintf(`
-		SELECT *, (%s <=> $1) as distance 
-		FROM %s 
-		ORDER BY %s <=> $1 
-		LIMIT $2
-	`, vectorColumn, table, vectorColumn)
-	
-	return db.Query(query, pgvector.NewVector(queryVector), limit)
-}
-
-// InsertVector inserts a vector into the specified table
-func (db *PostgresDB) InsertVector(table string, columns []string, values []interface{}) error {
-	placeholders := make([]string, len(values))
-	for i := range placeholders {
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
-	}
-	
-	query := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s)",
-		table,
-		strings.Join(columns, ", "),
-		strings.Join(placeholders, ", "),
-	)
-	
-	_, err := db.Exec(query, values...)
-	return err
-}
\ No newline at end of file
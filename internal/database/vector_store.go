@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"chat_ollama/internal/config"
+
+	"github.com/lib/pq"
+)
+
+// VectorStore stores message embeddings and answers similarity queries over them.
+// A message has at most one vector per embedding model. Only message vectors go through
+// the store; memory summaries and user facts keep their embeddings in their own pgvector
+// columns whichever store is configured.
+type VectorStore interface {
+	// Upsert stores the records, replacing the vector a message already has for the model
+	Upsert(ctx context.Context, records ...VectorRecord) error
+	// Delete removes the records matching the filter and returns how many were removed
+	Delete(ctx context.Context, filter VectorFilter) (int64, error)
+	// Search returns the k records matching the filter most similar to the query vector,
+	// most similar first. Only vectors of the query's dimension are compared. Zero tuning
+	// values use the store's defaults; exact stores ignore them.
+	Search(ctx context.Context, query []float32, filter VectorFilter, k int, tuning SearchTuning) ([]VectorMatch, error)
+	// List returns the records matching the filter, oldest message first; limit 0 returns all
+	List(ctx context.Context, filter VectorFilter, limit int) ([]VectorRecord, error)
+	// Count returns the number of records matching the filter
+	Count(ctx context.Context, filter VectorFilter) (int64, error)
+	// Stats summarizes the stored records
+	Stats(ctx context.Context) (*VectorStats, error)
+}
+
+// VectorRecord is the embedding of a message together with the message data it is searched by
+type VectorRecord struct {
+	ID               string
+	MessageID        string
+	SessionID        string
+	UserID           string // Owner of the session, kept when the session is deleted
+	Role             string
+	Content          string
+	Model            string
+	Embedding        []float32
+	MessageCreatedAt time.Time
+	CreatedAt        time.Time
+}
+
+// VectorMatch is a record found by a similarity search
+type VectorMatch struct {
+	VectorRecord
+	Similarity float64 // Cosine similarity to the query
+}
+
+// VectorFilter restricts the records an operation applies to. Empty fields do not filter;
+// a non-nil but empty ID list matches nothing.
+type VectorFilter struct {
+	Model             string
+	UserID            string
+	ExcludeUserIDs    []string
+	SessionIDs        []string
+	ExcludeSessionIDs []string
+	MessageIDs        []string
+	ExcludeMessageIDs []string
+	Roles             []string
+	ExcludeRoles      []string
+	CreatedBefore     time.Time // Message created before this time
+	CreatedAfter      time.Time // Message created at or after this time
+	Unclustered       bool      // Message not assigned to a topic in message_topics
+}
+
+// SearchTuning trades speed for recall on approximate nearest neighbour indexes
+type SearchTuning struct {
+	EfSearch int `json:"ef_search,omitempty"` // Candidate list size of HNSW scans (hnsw.ef_search)
+	Probes   int `json:"probes,omitempty"`    // Lists visited by IVFFlat scans (ivfflat.probes)
+}
+
+// WithDefaults fills the unset values of the tuning from defaults
+func (t SearchTuning) WithDefaults(defaults SearchTuning) SearchTuning {
+	if t.EfSearch <= 0 {
+		t.EfSearch = defaults.EfSearch
+	}
+	if t.Probes <= 0 {
+		t.Probes = defaults.Probes
+	}
+	return t
+}
+
+// Apply sets the tuning for the rest of the transaction
+func (t SearchTuning) Apply(ctx context.Context, tx *sql.Tx) error {
+	if t.EfSearch > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", t.EfSearch)); err != nil {
+			return fmt.Errorf("failed to set hnsw.ef_search: %w", err)
+		}
+	}
+	if t.Probes > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", t.Probes)); err != nil {
+			return fmt.Errorf("failed to set ivfflat.probes: %w", err)
+		}
+	}
+	return nil
+}
+
+// VectorStats summarizes the records in a vector store
+type VectorStats struct {
+	Total    int64
+	ByRole   map[string]int64
+	ByModel  map[string]int64
+	Sessions int64 // Distinct sessions with records
+	Oldest   *time.Time
+	Newest   *time.Time
+}
+
+// NewVectorStore creates the vector store selected by VECTOR_STORE
+func NewVectorStore(db *PostgresDB, cfg *config.Config) (VectorStore, error) {
+	switch cfg.VectorStore {
+	case "", "pgvector":
+		return NewPgVectorStore(db.DB, SearchTuning{
+			EfSearch: cfg.VectorSearchEfSearch,
+			Probes:   cfg.VectorSearchProbes,
+		}), nil
+	case "memory":
+		store := NewMemoryVectorStore()
+		store.SetTopicMembership(func(ctx context.Context, messageIDs []string) (map[string]bool, error) {
+			rows, err := db.QueryContext(ctx, "SELECT DISTINCT message_id FROM message_topics WHERE message_id = ANY($1)", pq.Array(messageIDs))
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			clustered := make(map[string]bool)
+			for rows.Next() {
+				var messageID string
+				if err := rows.Scan(&messageID); err != nil {
+					return nil, err
+				}
+				clustered[messageID] = true
+			}
+			return clustered, rows.Err()
+		})
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown vector store: %s", cfg.VectorStore)
+	}
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors of the same dimension
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
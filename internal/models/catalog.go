@@ -0,0 +1,28 @@
+package models
+
+// CatalogModel is a model that can be downloaded, as listed by a model catalog
+type CatalogModel struct {
+	Name         string   `json:"name" yaml:"name"` // Model name with tag, e.g. llama3.1:8b
+	Family       string   `json:"family" yaml:"family"`
+	Description  string   `json:"description,omitempty" yaml:"description"`
+	Parameters   string   `json:"parameters,omitempty" yaml:"parameters"`     // Parameter size, e.g. 8B
+	Size         int64    `json:"size,omitempty" yaml:"size"`                 // Download size in bytes
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities"` // e.g. completion, tools, vision or embedding
+	Tags         []string `json:"tags,omitempty" yaml:"tags"`                 // Labels such as recommended or coding
+}
+
+// CatalogFile is the format of a catalog file and of a catalog mirror's response
+type CatalogFile struct {
+	Models []CatalogModel `json:"models" yaml:"models"`
+}
+
+// CatalogFilter narrows the models listed from the catalog. Empty fields match
+// everything.
+type CatalogFilter struct {
+	Query      string // Matches the name, family, description or a tag
+	Family     string
+	Size       string // Parameter size, e.g. 8b
+	MaxSize    int64  // Largest download size in bytes
+	Capability string
+	Tag        string
+}
@@ -22,16 +22,22 @@ type ChatRequest struct {
 	Model     string                 `json:"model"`
 	Stream    bool                   `json:"stream"`
 	Options   map[string]interface{} `json:"options,omitempty"`
+	Images    []string               `json:"images,omitempty"` // Base64-encoded images sent with the message to a vision model
+	KeepAlive string                 `json:"keep_alive,omitempty"` // How long the model stays loaded; defaults to the model's configuration
 }
 
 // ChatResponse represents a non-streaming chat response
 type ChatResponse struct {
-	ID         string    `json:"id"`
-	SessionID  string    `json:"session_id"`
-	Content    string    `json:"content"`
-	Model      string    `json:"model"`
-	CreatedAt  time.Time `json:"created_at"`
-	TokensUsed int       `json:"tokens_used"`
+	ID          string        `json:"id"`
+	SessionID   string        `json:"session_id"`
+	Content     string        `json:"content"`
+	Model       string        `json:"model"`
+	CreatedAt   time.Time     `json:"created_at"`
+	TokensUsed  int           `json:"tokens_used"`
+	Title       string        `json:"title,omitempty"`
+	Suggestions []string      `json:"suggestions,omitempty"`
+	Context     []ContextItem `json:"context,omitempty"`   // Semantic memory the response was given
+	Citations   []Citation    `json:"citations,omitempty"` // Sources of the context by citation marker
 }
 
 // StreamResponse represents a streaming chat response
@@ -45,8 +51,9 @@ type StreamResponse struct {
 
 // MessagesResponse represents the response for getting conversation history
 type MessagesResponse struct {
-	SessionID string    `json:"session_id"`
-	Messages  []Message `json:"messages"`
+	SessionID   string    `json:"session_id"`
+	Messages    []Message `json:"messages"`
+	Suggestions []string  `json:"suggestions,omitempty"`
 }
 
 // ValidateRole validates if the role is valid
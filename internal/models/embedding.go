@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Re-embedding job statuses
+const (
+	ReembedStatusPending   = "pending"
+	ReembedStatusRunning   = "running"
+	ReembedStatusCompleted = "completed"
+	ReembedStatusFailed    = "failed"
+	ReembedStatusCancelled = "cancelled"
+)
+
+// Re-embedding job phases, processed in this order
+const (
+	ReembedPhaseMessages  = "messages"
+	ReembedPhaseSummaries = "summaries"
+	ReembedPhaseFacts     = "facts"
+	ReembedPhaseSwitch    = "switch"
+)
+
+// ReembedJob represents a background job migrating a user's memory to another embedding model
+type ReembedJob struct {
+	ID               string     `json:"id" db:"id"`
+	UserID           string     `json:"user_id" db:"user_id"`
+	SourceModel      string     `json:"source_model" db:"source_model"`
+	TargetModel      string     `json:"target_model" db:"target_model"`
+	TargetDimensions int        `json:"target_dimensions" db:"target_dimensions"`
+	Status           string     `json:"status" db:"status"`
+	Phase            string     `json:"phase" db:"phase"`
+	LastItemID       string     `json:"-" db:"last_item_id"`
+	TotalItems       int        `json:"total_items" db:"total_items"`
+	ProcessedItems   int        `json:"processed_items" db:"processed_items"`
+	FailedItems      int        `json:"failed_items" db:"failed_items"`
+	Progress         float64    `json:"progress"`
+	Error            string     `json:"error,omitempty" db:"error"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	StartedAt        *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// CreateReembedJobRequest represents a request to migrate memory to another embedding model
+type CreateReembedJobRequest struct {
+	TargetModel string `json:"target_model"`
+}
+
+// ReembedJobsResponse represents the response for listing re-embedding jobs
+type ReembedJobsResponse struct {
+	CurrentModel string       `json:"current_model"`
+	Jobs         []ReembedJob `json:"jobs"`
+}
+
+// EmbedInput is the text to embed, given either as a single string or an array of strings
+type EmbedInput []string
+
+// UnmarshalJSON accepts a string or an array of strings
+func (in *EmbedInput) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*in = EmbedInput{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("input must be a string or an array of strings")
+	}
+	*in = many
+	return nil
+}
+
+// EmbedRequest represents a request to embed text
+type EmbedRequest struct {
+	Model     string     `json:"model,omitempty"` // Defaults to the configured embedding model
+	Input     EmbedInput `json:"input"`
+	Truncate  *bool      `json:"truncate,omitempty"`
+	KeepAlive string     `json:"keep_alive,omitempty"`
+}
+
+// EmbedResponse represents the embeddings of a request, in input order
+type EmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Dimensions int         `json:"dimensions"`
+	Cached     int         `json:"cached"` // Number of inputs served from the embedding cache
+}
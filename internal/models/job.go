@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Background job statuses. Failed jobs that will be retried go back to pending;
+// jobs that have used up their attempts are dead-lettered.
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusDead      = "dead"
+)
+
+// Job represents a unit of background work in the job queue
+type Job struct {
+	ID          string          `json:"id" db:"id"`
+	Type        string          `json:"type" db:"type"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	Priority    int             `json:"priority" db:"priority"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LockedBy    string          `json:"locked_by,omitempty" db:"locked_by"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty" db:"locked_at"`
+	LastError   string          `json:"last_error,omitempty" db:"last_error"`
+	DedupeKey   string          `json:"dedupe_key,omitempty" db:"dedupe_key"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// JobsResponse represents the response for listing background jobs
+type JobsResponse struct {
+	Jobs   []Job          `json:"jobs"`
+	Counts map[string]int `json:"counts"` // Number of jobs per status
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
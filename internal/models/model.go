@@ -19,8 +19,20 @@ type Model struct {
 	Progress             float64   `json:"progress,omitempty" db:"-"` // Download progress percentage (0-100)
 	IsDefault            bool      `json:"is_default" db:"is_default"`
 	IsEnabled            bool      `json:"is_enabled" db:"is_enabled"`
+	IsPinned             bool      `json:"is_pinned" db:"is_pinned"` // Never evicted to stay within the storage budget
 	SupportsEmbeddings   bool      `json:"supports_embeddings" db:"supports_embeddings"`
 	EmbeddingDimensions  int       `json:"embedding_dimensions" db:"embedding_dimensions"`
+	ContextLength        int       `json:"context_length,omitempty" db:"context_length"` // Trained context length, when known
+	Source               string    `json:"source" db:"source"` // library, created, copied, alias or imported
+	BaseModel            string    `json:"base_model,omitempty" db:"base_model"` // FROM of a created model, source of a copy
+	ParentModelID        string    `json:"parent_model_id,omitempty" db:"parent_model_id"`
+	Modelfile            string    `json:"modelfile,omitempty" db:"modelfile"` // Modelfile a created model was built from
+	Digest               string    `json:"digest,omitempty" db:"digest"`
+	Capabilities         []string  `json:"capabilities" db:"capabilities"` // completion, embedding, vision, tools, insert or thinking
+	SupportsVision       bool      `json:"supports_vision" db:"supports_vision"`
+	SupportsTools        bool      `json:"supports_tools" db:"supports_tools"`
+	Template             string    `json:"template,omitempty" db:"template"` // Prompt template
+	License              string    `json:"license,omitempty" db:"license"`
 	CreatedAt            time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
 	LastUsedAt           *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
@@ -37,6 +49,7 @@ type ModelConfig struct {
 	ContextLength    *int                   `json:"context_length,omitempty" db:"context_length"`
 	MaxTokens        *int                   `json:"max_tokens,omitempty" db:"max_tokens"`
 	SystemPrompt     string                 `json:"system_prompt" db:"system_prompt"`
+	KeepAlive        string                 `json:"keep_alive" db:"keep_alive"` // How long Ollama keeps the model loaded after a chat, e.g. 30m; empty uses Ollama's default
 	CustomOptions    map[string]interface{} `json:"custom_options,omitempty"`
 	CreatedAt        time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time              `json:"updated_at" db:"updated_at"`
@@ -84,6 +97,7 @@ type ModelUpdateRequest struct {
 	Description *string `json:"description,omitempty"`
 	IsDefault   *bool   `json:"is_default,omitempty"`
 	IsEnabled   *bool   `json:"is_enabled,omitempty"`
+	IsPinned    *bool   `json:"is_pinned,omitempty"`
 }
 
 // ModelConfigUpdateRequest represents a request to update model configuration
@@ -95,6 +109,7 @@ type ModelConfigUpdateRequest struct {
 	ContextLength *int                   `json:"context_length,omitempty"`
 	MaxTokens     *int                   `json:"max_tokens,omitempty"`
 	SystemPrompt  *string                `json:"system_prompt,omitempty"`
+	KeepAlive     *string                `json:"keep_alive,omitempty"`
 	CustomOptions map[string]interface{} `json:"custom_options,omitempty"`
 }
 
@@ -150,17 +165,20 @@ type ModelDownloadProgress struct {
 	Completed  int64   `json:"completed,omitempty"`
 	Percentage float64 `json:"percentage,omitempty"`
 	Error      string  `json:"error,omitempty"`
+	Host       string  `json:"host,omitempty"` // Ollama host the model is being pulled to
 }
 
 // OllamaModelInfo represents detailed model information from Ollama
 type OllamaModelInfo struct {
-	License    string                 `json:"license,omitempty"`
-	Modelfile  string                 `json:"modelfile,omitempty"`
-	Parameters string                 `json:"parameters,omitempty"`
-	Template   string                 `json:"template,omitempty"`
-	System     string                 `json:"system,omitempty"`
-	Details    OllamaModelDetails     `json:"details,omitempty"`
-	ModelInfo  map[string]interface{} `json:"model_info,omitempty"`
+	License       string                 `json:"license,omitempty"`
+	Modelfile     string                 `json:"modelfile,omitempty"`
+	Parameters    string                 `json:"parameters,omitempty"`
+	Template      string                 `json:"template,omitempty"`
+	System        string                 `json:"system,omitempty"`
+	Details       OllamaModelDetails     `json:"details,omitempty"`
+	ModelInfo     map[string]interface{} `json:"model_info,omitempty"`
+	ProjectorInfo map[string]interface{} `json:"projector_info,omitempty"` // Vision projector of multimodal models
+	Capabilities  []string               `json:"capabilities,omitempty"`   // Reported by Ollama 0.6.4 and later
 }
 
 // OllamaModelDetails represents model details from Ollama
@@ -181,18 +199,41 @@ type ModelDownloadRequest struct {
 
 // ModelDownloadResponse represents the response for model download initiation
 type ModelDownloadResponse struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DownloadID string `json:"download_id"`
+	Message    string `json:"message"`
 }
 
 // OllamaModelDetailedInfo represents detailed model information from Ollama with size
 type OllamaModelDetailedInfo struct {
-	Name          string `json:"name"`
-	Size          int64  `json:"size"`
-	Family        string `json:"family"`
-	Format        string `json:"format"`
-	Parameters    string `json:"parameters"`
-	Quantization  string `json:"quantization"`
-}
\ No newline at end of file
+	Name          string   `json:"name"`
+	Size          int64    `json:"size"`
+	Family        string   `json:"family"`
+	Format        string   `json:"format"`
+	Parameters    string   `json:"parameters"`
+	Quantization  string   `json:"quantization"`
+	Digest        string   `json:"digest"`
+	Hosts         []string `json:"hosts,omitempty"` // Ollama hosts the model is installed on
+}
+// Model capabilities, as Ollama's /api/show reports them
+const (
+	ModelCapabilityCompletion = "completion"
+	ModelCapabilityEmbedding  = "embedding"
+	ModelCapabilityVision     = "vision"
+	ModelCapabilityTools      = "tools"
+	ModelCapabilityInsert     = "insert"
+	ModelCapabilityThinking   = "thinking"
+)
+
+// HasCapability reports whether a model has a capability. Models whose capabilities
+// have not been discovered yet report none.
+func (m Model) HasCapability(capability string) bool {
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// Model benchmark statuses
+const (
+	BenchmarkStatusQueued    = "queued"
+	BenchmarkStatusRunning   = "running"
+	BenchmarkStatusCompleted = "completed"
+	BenchmarkStatusFailed    = "failed"
+)
+
+// Benchmark metrics models can be compared on
+const (
+	BenchmarkMetricTokensPerSecond       = "tokens_per_second"
+	BenchmarkMetricPromptTokensPerSecond = "prompt_tokens_per_second"
+	BenchmarkMetricTimeToFirstToken      = "time_to_first_token"
+	BenchmarkMetricLoadTime              = "load_time"
+)
+
+// BenchmarkPrompt is one prompt of a benchmark suite
+type BenchmarkPrompt struct {
+	Name   string `json:"name" yaml:"name"`
+	Prompt string `json:"prompt" yaml:"prompt"`
+	System string `json:"system,omitempty" yaml:"system"`
+}
+
+// ModelBenchmarkRequest represents a request to benchmark a model
+type ModelBenchmarkRequest struct {
+	Prompts   []BenchmarkPrompt `json:"prompts,omitempty"`    // Defaults to the configured prompt suite
+	Runs      int               `json:"runs,omitempty"`       // Times each prompt is run; defaults to 1
+	MaxTokens int               `json:"max_tokens,omitempty"` // Tokens generated per prompt; defaults to BENCHMARK_MAX_TOKENS
+}
+
+// BenchmarkPromptResult is the outcome of one run of one prompt
+type BenchmarkPromptResult struct {
+	Prompt                string  `json:"prompt"` // Name of the prompt
+	Run                   int     `json:"run"`
+	PromptTokens          int     `json:"prompt_tokens"`
+	OutputTokens          int     `json:"output_tokens"`
+	TokensPerSecond       float64 `json:"tokens_per_second"`
+	PromptTokensPerSecond float64 `json:"prompt_tokens_per_second"`
+	TimeToFirstTokenMs    float64 `json:"time_to_first_token_ms"`
+	TotalMs               float64 `json:"total_ms"`
+}
+
+// ModelBenchmark is a queued, running or finished benchmark of a model. The metrics are
+// averaged over the results once it completed.
+type ModelBenchmark struct {
+	ID                    string                  `json:"id"`
+	ModelID               string                  `json:"model_id"`
+	ModelName             string                  `json:"model_name"`
+	JobID                 string                  `json:"job_id,omitempty"`
+	Host                  string                  `json:"host,omitempty"` // Ollama host the benchmark ran on
+	Status                string                  `json:"status"`
+	Prompts               []BenchmarkPrompt       `json:"prompts"`
+	Runs                  int                     `json:"runs"`
+	MaxTokens             int                     `json:"max_tokens"`
+	Results               []BenchmarkPromptResult `json:"results"`
+	TokensPerSecond       float64                 `json:"tokens_per_second"`        // Generation speed
+	PromptTokensPerSecond float64                 `json:"prompt_tokens_per_second"` // Prompt processing speed
+	TimeToFirstTokenMs    float64                 `json:"time_to_first_token_ms"`
+	LoadMs                float64                 `json:"load_ms"` // Time to load the model into memory from cold
+	Error                 string                  `json:"error,omitempty"`
+	CreatedAt             time.Time               `json:"created_at"`
+	StartedAt             *time.Time              `json:"started_at,omitempty"`
+	CompletedAt           *time.Time              `json:"completed_at,omitempty"`
+	UpdatedAt             time.Time               `json:"updated_at"`
+}
+
+// ModelBenchmarkRanking is a model's latest completed benchmark with its rank on every
+// metric, 1 being the best
+type ModelBenchmarkRanking struct {
+	Rank                  int            `json:"rank"` // Rank on the metric the comparison is sorted by
+	ModelID               string         `json:"model_id"`
+	ModelName             string         `json:"model_name"`
+	Quantization          string         `json:"quantization,omitempty"`
+	Parameters            string         `json:"parameters,omitempty"`
+	BenchmarkID           string         `json:"benchmark_id"`
+	Host                  string         `json:"host,omitempty"`
+	TokensPerSecond       float64        `json:"tokens_per_second"`
+	PromptTokensPerSecond float64        `json:"prompt_tokens_per_second"`
+	TimeToFirstTokenMs    float64        `json:"time_to_first_token_ms"`
+	LoadMs                float64        `json:"load_ms"`
+	Ranks                 map[string]int `json:"ranks"`
+	CompletedAt           *time.Time     `json:"completed_at,omitempty"`
+}
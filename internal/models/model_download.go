@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Model download statuses
+const (
+	DownloadStatusQueued      = "queued"
+	DownloadStatusDownloading = "downloading"
+	DownloadStatusCompleted   = "completed"
+	DownloadStatusFailed      = "failed"
+	DownloadStatusCancelled   = "cancelled"
+)
+
+// Model event types pushed to stream subscribers
+const (
+	ModelEventDownloadQueued    = "download_queued"
+	ModelEventDownloadProgress  = "download_progress"
+	ModelEventDownloadCompleted = "download_completed"
+	ModelEventDownloadFailed    = "download_failed"
+	ModelEventDownloadCancelled = "download_cancelled"
+
+	// Changes to the installed models, found by model sync
+	ModelEventAdded   = "model.added"
+	ModelEventRemoved = "model.removed"
+	ModelEventUpdated = "model.updated"
+)
+
+// ModelDownloadLayer is the progress of one layer (blob) of a model being pulled
+type ModelDownloadLayer struct {
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+}
+
+// ModelDownload represents a persisted model download
+type ModelDownload struct {
+	ID             string               `json:"id"`
+	ModelID        string               `json:"model_id"`
+	ModelName      string               `json:"model_name"`
+	JobID          string               `json:"job_id,omitempty"`
+	Status         string               `json:"status"`
+	Phase          string               `json:"phase,omitempty"` // Last status reported by Ollama, e.g. "pulling manifest"
+	Layers         []ModelDownloadLayer `json:"layers"`
+	TotalBytes     int64                `json:"total_bytes"`
+	CompletedBytes int64                `json:"completed_bytes"`
+	Progress       float64              `json:"progress"` // Percentage (0-100) over all layers seen so far
+	Error          string               `json:"error,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	StartedAt      *time.Time           `json:"started_at,omitempty"`
+	CompletedAt    *time.Time           `json:"completed_at,omitempty"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
+
+// IsActive reports whether the download is still queued or running
+func (d *ModelDownload) IsActive() bool {
+	return d.Status == DownloadStatusQueued || d.Status == DownloadStatusDownloading
+}
+
+// ModelEvent is a model lifecycle event pushed to stream subscribers
+type ModelEvent struct {
+	Type      string         `json:"type"`
+	Download  *ModelDownload `json:"download,omitempty"`
+	Model     *Model         `json:"model,omitempty"`   // The model as it is after the change
+	Changes   []string       `json:"changes,omitempty"` // What changed for model.updated: status, size, metadata, digest or default
+	Timestamp time.Time      `json:"timestamp"`
+}
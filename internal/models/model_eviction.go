@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ModelEvictionRequest represents a request to evict models to free storage
+type ModelEvictionRequest struct {
+	TargetBytes int64 `json:"target_bytes,omitempty"` // Storage to get down to; defaults to the storage budget
+	DryRun      bool  `json:"dry_run,omitempty"`
+}
+
+// ModelEvictionCandidate is an installed model chosen for eviction
+type ModelEvictionCandidate struct {
+	ModelID    string     `json:"model_id"`
+	Name       string     `json:"name"`
+	Digest     string     `json:"digest,omitempty"`
+	Size       int64      `json:"size"`
+	FreedBytes int64      `json:"freed_bytes"` // 0 for copies and aliases whose blobs another candidate frees
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Error      string     `json:"error,omitempty"` // Why the model could not be evicted
+}
+
+// ModelEvictionPlan lists the least recently used models evicted, or that would be, to
+// bring model storage down to a target
+type ModelEvictionPlan struct {
+	BudgetBytes int64                    `json:"budget_bytes"` // 0 when no storage budget is configured
+	TargetBytes int64                    `json:"target_bytes"`
+	UsedBytes   int64                    `json:"used_bytes"` // Storage used before the eviction, counting shared blobs once
+	FreedBytes  int64                    `json:"freed_bytes"`
+	Shortfall   int64                    `json:"shortfall"` // Bytes still over the target once only protected models are left
+	Models      []ModelEvictionCandidate `json:"models"`
+	DryRun      bool                     `json:"dry_run"`
+}
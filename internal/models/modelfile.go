@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// Model sources recorded as a model's lineage
+const (
+	ModelSourceLibrary  = "library"  // Pulled from the Ollama library or found in Ollama
+	ModelSourceCreated  = "created"  // Created from a Modelfile
+	ModelSourceCopied   = "copied"   // Copied from another model
+	ModelSourceAlias    = "alias"    // Another name for an existing model
+	ModelSourceImported = "imported" // Imported from a GGUF file
+)
+
+// Modelfile is the parsed form of an Ollama Modelfile
+type Modelfile struct {
+	From       string               `json:"from"` // Base model name, or the digest of an uploaded blob
+	System     string               `json:"system,omitempty"`
+	Template   string               `json:"template,omitempty"`
+	Parameters []ModelfileParameter `json:"parameters,omitempty"` // In order; a parameter such as stop may repeat
+	Adapters   []string             `json:"adapters,omitempty"`   // Digests of uploaded LoRA adapter blobs
+	Messages   []ModelfileMessage   `json:"messages,omitempty"`
+}
+
+// ModelfileParameter is a PARAMETER instruction
+type ModelfileParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ModelfileMessage is a MESSAGE instruction seeding the conversation history
+type ModelfileMessage struct {
+	Role    string `json:"role"` // system, user or assistant
+	Content string `json:"content"`
+}
+
+// ModelCreateRequest represents a request to create a model from a Modelfile. The
+// Modelfile is given either as text or as its parsed definition.
+type ModelCreateRequest struct {
+	Name        string     `json:"name"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Modelfile   string     `json:"modelfile,omitempty"`
+	Definition  *Modelfile `json:"definition,omitempty"`
+	Stream      *bool      `json:"stream,omitempty"` // Defaults to true: progress is streamed as Server-Sent Events
+}
+
+// ModelCreateProgress represents a status update while a model is imported or created
+type ModelCreateProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ModelCopyRequest represents a request to copy a model or give it an alias
+type ModelCopyRequest struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ModelImportRequest represents a request to import a GGUF file as a model
+type ModelImportRequest struct {
+	Name        string               `json:"name"`
+	File        string               `json:"file,omitempty"` // Path relative to the import directory; empty for uploads
+	DisplayName string               `json:"display_name,omitempty"`
+	Description string               `json:"description,omitempty"`
+	System      string               `json:"system,omitempty"`
+	Template    string               `json:"template,omitempty"` // Empty lets Ollama use the chat template in the file
+	Parameters  []ModelfileParameter `json:"parameters,omitempty"`
+	Stream      *bool                `json:"stream,omitempty"` // Defaults to true: progress is streamed as Server-Sent Events
+}
+
+// ModelImportFile is a GGUF file in the import directory
+type ModelImportFile struct {
+	Path       string    `json:"path"` // Relative to the import directory
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OllamaHostStatus is the state of one Ollama host of the pool, as the last health check saw it
+type OllamaHostStatus struct {
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	Status    string     `json:"status"` // healthy, unhealthy, or unknown before the first check
+	Error     string     `json:"error,omitempty"`
+	LatencyMs int64      `json:"latency_ms"`
+	InFlight  int64      `json:"in_flight"` // Requests currently sent to the host
+	Models    []string   `json:"models"`    // Installed models
+	Loaded    []string   `json:"loaded"`    // Models loaded into memory
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+// Retention policy sources
+const (
+	RetentionSourceDefault = "default" // Server-wide MEMORY_RETENTION_DAYS
+	RetentionSourceUser    = "user"
+	RetentionSourceProject = "project"
+)
+
+// RetentionPolicy represents a request to set a user's or project's memory retention
+type RetentionPolicy struct {
+	RetentionDays *int `json:"retention_days"` // Null inherits the user's or the server's policy
+}
+
+// RetentionPolicyResponse represents a stored retention policy and the policy in effect
+type RetentionPolicyResponse struct {
+	RetentionDays *int   `json:"retention_days"`
+	EffectiveDays int    `json:"effective_days"` // 0 keeps memory forever
+	Source        string `json:"source"`         // Where the effective policy comes from
+}
+
+// PurgeResult counts the rows removed by a retention run, a forgotten session or an account purge
+type PurgeResult struct {
+	Embeddings       int64 `json:"embeddings"`
+	CachedEmbeddings int64 `json:"cached_embeddings,omitempty"` // Embedding cache entries of forgotten text
+	Summaries        int64 `json:"summaries"`
+	Gaps             int64 `json:"gaps"`
+	Traces           int64 `json:"traces"`
+	Facts            int64 `json:"facts,omitempty"`
+	Messages         int64 `json:"messages,omitempty"`
+	Sessions         int64 `json:"sessions,omitempty"`
+}
+
+// PurgeAccountRequest represents a request to delete the caller's account and all of its data
+type PurgeAccountRequest struct {
+	Confirm string `json:"confirm"` // Must repeat the account's username
+}
+
+// EmbeddingCleanupRequest represents an administrative embedding cleanup
+type EmbeddingCleanupRequest struct {
+	OlderThanDays int      `json:"older_than_days,omitempty"`
+	SessionID     string   `json:"session_id,omitempty"`
+	ExcludeRoles  []string `json:"exclude_roles,omitempty"`
+	Orphaned      bool     `json:"orphaned,omitempty"` // Only embeddings whose session has been deleted
+	DryRun        bool     `json:"dry_run"`
+}
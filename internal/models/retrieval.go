@@ -0,0 +1,157 @@
+package models
+
+import (
+	"time"
+)
+
+// Retrieval scopes
+const (
+	RetrievalScopeUser    = "user"    // All of the user's sessions
+	RetrievalScopeProject = "project" // Sessions of the current session's project
+	RetrievalScopeSession = "session" // The current session only
+)
+
+// RetrievalSettings controls how semantic memory is selected for a chat
+type RetrievalSettings struct {
+	MaxResults          int     `json:"max_results"`
+	MinSimilarity       float64 `json:"min_similarity"`         // Candidates less similar to the message are dropped
+	MMRLambda           float64 `json:"mmr_lambda"`             // 1 ranks purely by relevance, 0 purely by diversity
+	RecencyWeight       float64 `json:"recency_weight"`         // Share of the score that decays with age
+	RecencyHalfLifeDays float64 `json:"recency_half_life_days"` // Age at which the decaying share is halved
+	ExcludeRecent       int     `json:"exclude_recent"`         // Most recent messages of the current session that are never retrieved
+	DedupThreshold      float64 `json:"dedup_threshold"`        // Candidates at least this similar to a better one are collapsed into it
+	Scope               string  `json:"scope"`
+}
+
+// RetrievalOverrides holds the retrieval settings a user or project overrides; nil fields are inherited
+type RetrievalOverrides struct {
+	MaxResults          *int     `json:"max_results,omitempty"`
+	MinSimilarity       *float64 `json:"min_similarity,omitempty"`
+	MMRLambda           *float64 `json:"mmr_lambda,omitempty"`
+	RecencyWeight       *float64 `json:"recency_weight,omitempty"`
+	RecencyHalfLifeDays *float64 `json:"recency_half_life_days,omitempty"`
+	ExcludeRecent       *int     `json:"exclude_recent,omitempty"`
+	DedupThreshold      *float64 `json:"dedup_threshold,omitempty"`
+	Scope               *string  `json:"scope,omitempty"`
+}
+
+// Apply returns the settings with the overrides applied
+func (o RetrievalOverrides) Apply(settings RetrievalSettings) RetrievalSettings {
+	if o.MaxResults != nil {
+		settings.MaxResults = *o.MaxResults
+	}
+	if o.MinSimilarity != nil {
+		settings.MinSimilarity = *o.MinSimilarity
+	}
+	if o.MMRLambda != nil {
+		settings.MMRLambda = *o.MMRLambda
+	}
+	if o.RecencyWeight != nil {
+		settings.RecencyWeight = *o.RecencyWeight
+	}
+	if o.RecencyHalfLifeDays != nil {
+		settings.RecencyHalfLifeDays = *o.RecencyHalfLifeDays
+	}
+	if o.ExcludeRecent != nil {
+		settings.ExcludeRecent = *o.ExcludeRecent
+	}
+	if o.DedupThreshold != nil {
+		settings.DedupThreshold = *o.DedupThreshold
+	}
+	if o.Scope != nil {
+		settings.Scope = *o.Scope
+	}
+	return settings
+}
+
+// RetrievalSettingsResponse represents the stored overrides and the settings in effect
+type RetrievalSettingsResponse struct {
+	Overrides RetrievalOverrides `json:"overrides"`
+	Effective RetrievalSettings  `json:"effective"`
+}
+
+// ContextItem is a piece of semantic memory selected as context for a chat
+type ContextItem struct {
+	Type       string    `json:"type"` // "message" or "summary"
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Role       string    `json:"role,omitempty"`
+	Marker     string    `json:"marker,omitempty"` // Citation marker the item is presented with, e.g. "[1]"
+	Title      string    `json:"title,omitempty"`  // Label of a summary
+	Content    string    `json:"content"`
+	Similarity float64   `json:"similarity"`
+	Score      float64   `json:"score"`                // Similarity weighted by recency
+	Duplicates int       `json:"duplicates,omitempty"` // Near-duplicate candidates collapsed into this item
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ContextTemplateResponse represents the context template used for a project's sessions
+type ContextTemplateResponse struct {
+	Template  string `json:"template"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// UpdateContextTemplateRequest represents a request to set a project's context template
+type UpdateContextTemplateRequest struct {
+	Template string `json:"template"` // Empty restores the default template
+}
+
+// Retrieval trace candidate outcomes
+const (
+	TraceOutcomeSelected       = "selected"
+	TraceOutcomeBelowThreshold = "below_threshold"
+	TraceOutcomeRepeatsQuery   = "repeats_query"
+	TraceOutcomeDuplicate      = "duplicate"
+	TraceOutcomeNotSelected    = "not_selected" // Lost out in the MMR re-ranking
+)
+
+// TraceCandidate is an item considered for the context of a chat turn and what became of it
+type TraceCandidate struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Role       string    `json:"role,omitempty"`
+	Similarity float64   `json:"similarity"`
+	Score      float64   `json:"score"`
+	Outcome    string    `json:"outcome"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RetrievalTrace records how the memory injected into a chat turn was selected
+type RetrievalTrace struct {
+	ID              string            `json:"id"`
+	MessageID       string            `json:"message_id"`      // Assistant message of the turn
+	UserMessageID   string            `json:"user_message_id"` // User message the memory was retrieved for
+	SessionID       string            `json:"session_id"`
+	Query           string            `json:"query"`
+	EmbeddingModel  string            `json:"embedding_model"`
+	Settings        RetrievalSettings `json:"settings"`
+	Candidates      []TraceCandidate  `json:"candidates"`
+	Selected        []ContextItem     `json:"selected"`
+	InjectedContext string            `json:"injected_context"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// Citation points to a piece of memory a response was given, by the marker it was presented with
+type Citation struct {
+	Marker    string `json:"marker"`
+	Type      string `json:"type"` // "message" or "summary"
+	SessionID string `json:"session_id,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+	SummaryID string `json:"summary_id,omitempty"`
+}
+
+// CitationsFor returns the citations of the context items
+func CitationsFor(items []ContextItem) []Citation {
+	var citations []Citation
+	for _, item := range items {
+		citation := Citation{Marker: item.Marker, Type: item.Type, SessionID: item.SessionID}
+		if item.Type == "summary" {
+			citation.SummaryID = item.ID
+		} else {
+			citation.MessageID = item.ID
+		}
+		citations = append(citations, citation)
+	}
+	return citations
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RunningModel is a model Ollama has loaded into memory, as /api/ps reports it
+type RunningModel struct {
+	ModelID       string    `json:"model_id,omitempty"` // ID of the registered model, if there is one
+	Name          string    `json:"name"`
+	Host          string    `json:"host,omitempty"` // Ollama host the model is loaded on
+	Digest        string    `json:"digest"`
+	Parameters    string    `json:"parameters,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	Size          int64     `json:"size"`                     // Memory in use, in bytes
+	SizeVRAM      int64     `json:"size_vram"`                // Part of the memory in use on the GPU
+	ContextLength int       `json:"context_length,omitempty"` // Context the model was loaded with
+	ExpiresAt     time.Time `json:"expires_at"`               // When Ollama unloads the model unless it is used again
+}
+
+// ModelLoadRequest represents a request to preload a model
+type ModelLoadRequest struct {
+	KeepAlive string `json:"keep_alive,omitempty"` // Defaults to the model's configured keep_alive
+}
@@ -18,4 +18,19 @@ type Session struct {
 // SessionsResponse represents the response for listing sessions
 type SessionsResponse struct {
 	Sessions []Session `json:"sessions"`
-}
\ No newline at end of file
+}
+
+// SessionMetadata holds LLM-generated session metadata
+type SessionMetadata struct {
+	SessionID    string   `json:"session_id"`
+	Title        string   `json:"title"`
+	TitleUpdated bool     `json:"title_updated"`
+	Suggestions  []string `json:"suggestions"`
+}
+
+// Session title sources
+const (
+	TitleSourceDefault   = "default"
+	TitleSourceHeuristic = "heuristic"
+	TitleSourceGenerated = "generated"
+)
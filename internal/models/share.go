@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// SessionShare represents a read-only share link for a session
+type SessionShare struct {
+	ID                   string     `json:"id" db:"id"`
+	SessionID            string     `json:"session_id" db:"session_id"`
+	UserID               string     `json:"user_id" db:"user_id"`
+	Token                string     `json:"token" db:"token"`
+	RedactSystemMessages bool       `json:"redact_system_messages" db:"redact_system_messages"`
+	ExpiresAt            *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt            *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	ViewCount            int        `json:"view_count" db:"view_count"`
+	LastViewedAt         *time.Time `json:"last_viewed_at,omitempty" db:"last_viewed_at"`
+	CreatedAt            time.Time  `json:"created_at" db:"created_at"`
+}
+
+// IsActive reports whether the share link can still be used
+func (s *SessionShare) IsActive(now time.Time) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+	if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// CreateShareRequest represents a request to share a session
+type CreateShareRequest struct {
+	ExpiresIn            string `json:"expires_in,omitempty"` // Go duration, e.g. "72h"; empty means no expiry
+	RedactSystemMessages bool   `json:"redact_system_messages,omitempty"`
+}
+
+// SharesResponse represents the response for listing share links
+type SharesResponse struct {
+	Shares []SessionShare `json:"shares"`
+}
+
+// SharedSessionSnapshot is the public, read-only view of a shared session
+type SharedSessionSnapshot struct {
+	Token     string     `json:"token"`
+	Title     string     `json:"title"`
+	SharedAt  time.Time  `json:"shared_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Redacted  bool       `json:"redacted"`
+	Messages  []Message  `json:"messages"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Topic represents a cluster of semantically related messages
+type Topic struct {
+	ID           string    `json:"id" db:"id"`
+	UserID       string    `json:"user_id" db:"user_id"`
+	Name         string    `json:"name" db:"name"`
+	Description  string    `json:"description,omitempty" db:"description"`
+	MessageCount int       `json:"message_count" db:"message_count"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TopicMessage represents a message assigned to a topic
+type TopicMessage struct {
+	MessageID      string    `json:"message_id"`
+	SessionID      string    `json:"session_id"`
+	SessionTitle   string    `json:"session_title"`
+	Role           string    `json:"role"`
+	Content        string    `json:"content"`
+	RelevanceScore float64   `json:"relevance_score"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TopicsResponse represents the response for listing topics
+type TopicsResponse struct {
+	Topics []Topic `json:"topics"`
+}
+
+// TopicMessagesResponse represents the response for listing the messages in a topic
+type TopicMessagesResponse struct {
+	Topic    Topic          `json:"topic"`
+	Messages []TopicMessage `json:"messages"`
+	Limit    int            `json:"limit"`
+	Offset   int            `json:"offset"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// User memory statuses
+const (
+	UserMemoryStatusProposed  = "proposed"
+	UserMemoryStatusConfirmed = "confirmed"
+	UserMemoryStatusRejected  = "rejected"
+)
+
+// User memory sources
+const (
+	UserMemorySourceManual    = "manual"
+	UserMemorySourceExtracted = "extracted"
+)
+
+// UserMemory represents an explicit long-term fact about a user
+type UserMemory struct {
+	ID              string    `json:"id" db:"id"`
+	UserID          string    `json:"user_id" db:"user_id"`
+	Content         string    `json:"content" db:"content"`
+	Category        string    `json:"category,omitempty" db:"category"`
+	Status          string    `json:"status" db:"status"`
+	Source          string    `json:"source" db:"source"`
+	Confidence      float64   `json:"confidence" db:"confidence"`
+	SourceSessionID string    `json:"source_session_id,omitempty" db:"source_session_id"`
+	SourceMessageID string    `json:"source_message_id,omitempty" db:"source_message_id"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateUserMemoryRequest represents a request to store a fact
+type CreateUserMemoryRequest struct {
+	Content  string `json:"content"`
+	Category string `json:"category,omitempty"`
+}
+
+// UpdateUserMemoryRequest represents a request to update a fact
+type UpdateUserMemoryRequest struct {
+	Content  *string `json:"content,omitempty"`
+	Category *string `json:"category,omitempty"`
+	Status   *string `json:"status,omitempty"`
+}
+
+// ExtractUserMemoriesRequest represents a request to propose facts from a session
+type ExtractUserMemoriesRequest struct {
+	SessionID string `json:"session_id"`
+}
+
+// UserMemoriesResponse represents the response for listing facts
+type UserMemoriesResponse struct {
+	Facts []UserMemory `json:"facts"`
+}
@@ -0,0 +1,40 @@
+package models
+
+// Vector index types
+const (
+	VectorIndexHNSW    = "hnsw"
+	VectorIndexIVFFlat = "ivfflat"
+)
+
+// VectorIndexInfo describes the vector index of one embedding model on one table
+type VectorIndexInfo struct {
+	Name           string   `json:"name"`
+	Table          string   `json:"table"`
+	Model          string   `json:"model"`
+	Dimensions     int      `json:"dimensions"`
+	Exists         bool     `json:"exists"`
+	Type           string   `json:"type,omitempty"`       // hnsw or ivfflat
+	Parameters     []string `json:"parameters,omitempty"` // Storage parameters the index was built with, e.g. "m=16"
+	Valid          bool     `json:"valid"`                // False while a concurrent build is running or after it failed
+	MatchesConfig  bool     `json:"matches_config"`       // False when a reindex would change the index type
+	SizeBytes      int64    `json:"size_bytes"`
+	Rows           int64    `json:"rows"`
+	Recall         *float64 `json:"recall,omitempty"` // Share of the exact nearest neighbours the index finds
+	SampledQueries int      `json:"sampled_queries,omitempty"`
+}
+
+// VectorIndexReport represents the vector index diagnostics
+type VectorIndexReport struct {
+	VectorStore string            `json:"vector_store"`
+	IndexType   string            `json:"index_type"` // Type new and rebuilt indexes get
+	EfSearch    int               `json:"ef_search"`
+	Probes      int               `json:"probes"`
+	RecallAtK   int               `json:"recall_at_k"`
+	Indexes     []VectorIndexInfo `json:"indexes"`
+}
+
+// ReindexRequest represents a request to rebuild vector indexes with the configured type
+type ReindexRequest struct {
+	Model string `json:"model,omitempty"` // Empty rebuilds the indexes of every model
+	Table string `json:"table,omitempty"` // Empty rebuilds the indexes of every table
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+// BridgeService writes "welcome back" recaps for sessions resumed after a gap
+type BridgeService struct {
+	db           database.Database
+	ollamaClient *OllamaClient
+	logger       *utils.Logger
+	config       *config.Config
+}
+
+// NewBridgeService creates a new bridge service
+func NewBridgeService(db database.Database, ollamaClient *OllamaClient, cfg *config.Config, logger *utils.Logger) *BridgeService {
+	return &BridgeService{
+		db:           db,
+		ollamaClient: ollamaClient,
+		logger:       logger.WithComponent("bridge_service"),
+		config:       cfg,
+	}
+}
+
+// bridgeRecentMessages is how many messages before the gap are shown to the recap model
+const bridgeRecentMessages = 8
+
+const resumeBridgePrompt = `The user is returning to the conversation below after a break of %s.
+Write a short "welcome back" recap in 2 or 3 sentences: what was being discussed, what was concluded,
+and what was still open. Address the situation neutrally; do not greet the user or ask questions.
+%s
+Most recent messages:
+%s`
+
+// ResumeGapStart returns the time of the last non-system message when the session is
+// being resumed after more than the configured gap threshold
+func ResumeGapStart(history []models.Message, now time.Time, threshold time.Duration) (time.Time, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "system" {
+			continue
+		}
+		last := history[i].CreatedAt
+		return last, now.Sub(last) > threshold
+	}
+	return time.Time{}, false
+}
+
+// GenerateBridge writes a recap of the session up to gapStart
+func (s *BridgeService) GenerateBridge(ctx context.Context, sessionID, model string, history []models.Message, gapStart, now time.Time) (string, error) {
+	var lines []string
+	for _, msg := range history {
+		if msg.Role == "system" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", msg.Role, truncateText(msg.Content, maxSummaryMessageChars)))
+	}
+	if len(lines) > bridgeRecentMessages {
+		lines = lines[len(lines)-bridgeRecentMessages:]
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	// Reuse the conversation summary when the summarizer has already covered the session
+	var summary sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT content FROM memory_summaries
+		WHERE session_id = $1 AND source = 'generated' AND summary_type = 'conversation'
+	`, sessionID).Scan(&summary)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get conversation summary: %w", err)
+	}
+
+	earlier := ""
+	if summary.Valid && summary.String != "" {
+		earlier = fmt.Sprintf("\nSummary of the conversation so far:\n%s\n", summary.String)
+	}
+
+	resp, err := s.ollamaClient.Generate(ctx, OllamaGenerateRequest{
+		Model:   model,
+		Prompt:  fmt.Sprintf(resumeBridgePrompt, humanizeDuration(now.Sub(gapStart)), earlier, joinWithinBudget(lines, maxSummaryInputChars)),
+		Options: map[string]interface{}{"temperature": 0.2},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate bridge content: %w", err)
+	}
+
+	bridge := strings.TrimSpace(resp.Response)
+
+	s.logger.Info().
+		Str("session_id", sessionID).
+		Str("model", model).
+		Dur("gap", now.Sub(gapStart)).
+		Int("bridge_length", len(bridge)).
+		Msg("Resume bridge generated")
+
+	return bridge, nil
+}
+
+// humanizeDuration renders a gap length the way a person would describe it
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	case d >= 24*time.Hour:
+		return "a day"
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	case d >= time.Hour:
+		return "an hour"
+	default:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// catalogMaxResponseSize bounds the catalog read from a mirror
+const catalogMaxResponseSize = 16 << 20
+
+// parameterTagPattern matches a tag that starts with a parameter size, e.g. 8b or 1.5b-instruct
+var parameterTagPattern = regexp.MustCompile(`^(?i)(\d+(\.\d+)?[kmbt])([-_].*)?$`)
+
+// CatalogSource lists the models that can be downloaded
+type CatalogSource interface {
+	// Name identifies the source in logs and cache information
+	Name() string
+	// Models returns the models in the catalog
+	Models(ctx context.Context) ([]models.CatalogModel, error)
+}
+
+// NewCatalogSources returns the configured catalog sources in the order they are
+// tried: the catalog file, the mirror, then the ollama.com library unless it is
+// disabled. The library is always used when nothing else is configured.
+func NewCatalogSources(cfg *config.Config, ollamaClient *OllamaClient) []CatalogSource {
+	var sources []CatalogSource
+	if cfg.ModelCatalogFile != "" {
+		sources = append(sources, NewFileCatalogSource(cfg.ModelCatalogFile))
+	}
+	if cfg.ModelCatalogURL != "" {
+		sources = append(sources, NewHTTPCatalogSource(cfg.ModelCatalogURL, cfg.ModelCatalogToken, cfg.OllamaTimeout))
+	}
+	if cfg.ModelCatalogLibraryFallback || len(sources) == 0 {
+		sources = append(sources, NewLibraryCatalogSource(ollamaClient))
+	}
+	return sources
+}
+
+// FileCatalogSource reads a curated catalog from a JSON or YAML file
+type FileCatalogSource struct {
+	path string
+}
+
+// NewFileCatalogSource creates a catalog source for a file. Files ending in .yaml or
+// .yml are read as YAML, anything else as JSON.
+func NewFileCatalogSource(path string) *FileCatalogSource {
+	return &FileCatalogSource{path: path}
+}
+
+// Name identifies the source
+func (s *FileCatalogSource) Name() string {
+	return "file:" + s.path
+}
+
+// Models reads the catalog file
+func (s *FileCatalogSource) Models(ctx context.Context) ([]models.CatalogModel, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(s.path)) {
+	case ".yaml", ".yml":
+		return decodeCatalog(data, yaml.Unmarshal)
+	}
+	return decodeCatalog(data, json.Unmarshal)
+}
+
+// HTTPCatalogSource fetches a catalog in the catalog file's JSON format from a mirror
+type HTTPCatalogSource struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// NewHTTPCatalogSource creates a catalog source for a mirror. A non-empty token is sent
+// as a bearer token.
+func NewHTTPCatalogSource(url, token string, timeout time.Duration) *HTTPCatalogSource {
+	return &HTTPCatalogSource{
+		url:        url,
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name identifies the source
+func (s *HTTPCatalogSource) Name() string {
+	return "http:" + s.url
+}
+
+// Models fetches the catalog from the mirror
+func (s *HTTPCatalogSource) Models(ctx context.Context) ([]models.CatalogModel, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create catalog request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog mirror returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, catalogMaxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	if len(data) > catalogMaxResponseSize {
+		return nil, fmt.Errorf("catalog exceeds %d bytes", catalogMaxResponseSize)
+	}
+
+	return decodeCatalog(data, json.Unmarshal)
+}
+
+// LibraryCatalogSource lists the models on ollama.com by scraping its library pages.
+// It only knows model names, and breaks when the pages change, so it is the fallback
+// for when no catalog is configured or the configured ones fail.
+type LibraryCatalogSource struct {
+	ollamaClient *OllamaClient
+}
+
+// NewLibraryCatalogSource creates a catalog source for the ollama.com library
+func NewLibraryCatalogSource(ollamaClient *OllamaClient) *LibraryCatalogSource {
+	return &LibraryCatalogSource{ollamaClient: ollamaClient}
+}
+
+// Name identifies the source
+func (s *LibraryCatalogSource) Name() string {
+	return "library"
+}
+
+// Models scrapes the library
+func (s *LibraryCatalogSource) Models(ctx context.Context) ([]models.CatalogModel, error) {
+	names, err := s.ollamaClient.GetLibraryModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := make([]models.CatalogModel, 0, len(names))
+	for _, name := range names {
+		catalog = append(catalog, models.CatalogModel{Name: name})
+	}
+	return normalizeCatalog(catalog)
+}
+
+// decodeCatalog decodes a catalog given either as a catalog file or as a bare list of
+// models
+func decodeCatalog(data []byte, unmarshal func([]byte, interface{}) error) ([]models.CatalogModel, error) {
+	var catalog []models.CatalogModel
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("-")) {
+		if err := unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("invalid catalog: %w", err)
+		}
+	} else {
+		var file models.CatalogFile
+		if err := unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("invalid catalog: %w", err)
+		}
+		catalog = file.Models
+	}
+
+	return normalizeCatalog(catalog)
+}
+
+// normalizeCatalog checks the model names in a catalog and fills in what can be derived
+// from them: the latest tag, the family and the parameter size. Labels and capabilities
+// are lowercased so filters match them regardless of case. Later duplicates of a name
+// are dropped.
+func normalizeCatalog(catalog []models.CatalogModel) ([]models.CatalogModel, error) {
+	normalized := make([]models.CatalogModel, 0, len(catalog))
+	seen := make(map[string]bool, len(catalog))
+
+	for i, entry := range catalog {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("catalog entry %d has no name", i+1)
+		}
+		if !modelNamePattern.MatchString(entry.Name) {
+			return nil, fmt.Errorf("catalog entry %d has an invalid model name %q", i+1, entry.Name)
+		}
+		if entry.Size < 0 {
+			return nil, fmt.Errorf("catalog entry %s has a negative size", entry.Name)
+		}
+
+		entry.Name = normalizeModelName(entry.Name)
+		if seen[entry.Name] {
+			continue
+		}
+		seen[entry.Name] = true
+
+		family, tag, _ := strings.Cut(entry.Name, ":")
+		if entry.Family == "" {
+			entry.Family = family
+		}
+		if entry.Parameters == "" {
+			if match := parameterTagPattern.FindStringSubmatch(tag); match != nil {
+				entry.Parameters = strings.ToUpper(match[1])
+			}
+		}
+		entry.Capabilities = lowerStrings(entry.Capabilities)
+		entry.Tags = lowerStrings(entry.Tags)
+
+		normalized = append(normalized, entry)
+	}
+
+	return normalized, nil
+}
+
+// lowerStrings lowercases and trims a list, dropping empty values
+func lowerStrings(values []string) []string {
+	var lowered []string
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowered = append(lowered, value)
+		}
+	}
+	return lowered
+}
+
+// FilterCatalog returns the catalog models that match a filter
+func FilterCatalog(catalog []models.CatalogModel, filter models.CatalogFilter) []models.CatalogModel {
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+
+	matches := []models.CatalogModel{}
+	for _, entry := range catalog {
+		if filter.Family != "" && !strings.EqualFold(entry.Family, filter.Family) {
+			continue
+		}
+		if filter.Size != "" && !strings.EqualFold(entry.Parameters, filter.Size) {
+			continue
+		}
+		if filter.MaxSize > 0 && (entry.Size == 0 || entry.Size > filter.MaxSize) {
+			continue
+		}
+		if filter.Capability != "" && !containsFold(entry.Capabilities, filter.Capability) {
+			continue
+		}
+		if filter.Tag != "" && !containsFold(entry.Tags, filter.Tag) {
+			continue
+		}
+		if query != "" && !catalogEntryMatches(entry, query) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+
+	return matches
+}
+
+// catalogEntryMatches reports whether a lowercase search query appears in a catalog
+// model's name, family, description or tags
+func catalogEntryMatches(entry models.CatalogModel, query string) bool {
+	fields := append([]string{entry.Name, entry.Family, entry.Description}, entry.Tags...)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether a list contains a value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -20,6 +21,13 @@ type ChatService struct {
 	ollamaClient   *OllamaClient
 	modelManager   *ModelManager
 	semanticMemory *SemanticMemoryService
+	retrieval      *RetrievalSettingsService
+	renderer       *ContextRenderer
+	traces         *RetrievalTraceService
+	titleService   *TitleService
+	bridgeService  *BridgeService
+	userMemory     *UserMemoryService
+	jobs           *JobQueue
 	logger         *utils.Logger
 	config         *config.Config
 }
@@ -29,12 +37,20 @@ func NewChatService(db database.Database, ollamaClient *OllamaClient, embeddingS
 	// Create model manager
 	modelManager := NewModelManager(db, ollamaClient, logger)
 	semanticMemory := NewSemanticMemoryServiceWithModel(db, embeddingService, logger, cfg.EmbeddingModel)
+	semanticMemory.SetGapDetection(cfg.MemoryGapThreshold, cfg.TopicDriftThreshold)
 	
 	return &ChatService{
 		db:             db,
 		ollamaClient:   ollamaClient,
 		modelManager:   modelManager,
 		semanticMemory: semanticMemory,
+		retrieval:      NewRetrievalSettingsService(db, cfg, logger),
+		renderer:       NewContextRenderer(db, cfg, logger),
+		traces:         NewRetrievalTraceService(db, logger),
+		titleService:   NewTitleService(db, ollamaClient, cfg, logger),
+		bridgeService:  NewBridgeService(db, ollamaClient, cfg, logger),
+		userMemory:     NewUserMemoryService(db, ollamaClient, embeddingService, cfg, logger),
+		jobs:           NewJobQueue(db, cfg, logger),
 		logger:         logger.WithComponent("chat_service"),
 		config:         cfg,
 	}
@@ -63,6 +79,16 @@ func (s *ChatService) ProcessChat(ctx context.Context, req models.ChatRequest) (
 		}
 	}
 
+	// Check that the model can handle the request, e.g. that it accepts images
+	if err := s.validateChatCapabilities(ctx, req); err != nil {
+		return nil, fmt.Errorf("invalid model: %w", err)
+	}
+
+	// Keep the model loaded as long as it is configured to be
+	if req.KeepAlive == "" && s.modelManager != nil {
+		req.KeepAlive = s.modelManager.KeepAliveFor(ctx, req.Model)
+	}
+
 	// Ensure session exists - use ensureSessionWithUser for proper session creation
 	if err := s.ensureSessionWithUser(ctx, req.SessionID, "debug-user-id"); err != nil {
 		return nil, fmt.Errorf("failed to ensure session: %w", err)
@@ -75,26 +101,24 @@ func (s *ChatService) ProcessChat(ctx context.Context, req models.ChatRequest) (
 	}
 
 	// Get relevant context from semantic memory if enabled
-	var relevantContext string
-	if s.config.EnableSemanticMemory && s.semanticMemory != nil {
-		context, err := s.semanticMemory.GetRelevantContext(ctx, req.Message, "", s.config.MaxContextResults)
-		if err != nil {
-			s.logger.Warn().Err(err).Msg("Failed to retrieve semantic context, continuing without it")
-		} else if context != "" {
-			relevantContext = context
-			s.logger.Info().
-				Str("session_id", req.SessionID).
-				Int("context_length", len(context)).
-				Int("max_results", s.config.MaxContextResults).
-				Msg("Retrieved relevant context from semantic memory")
-		}
+	retrieved := s.retrieveContext(ctx, req)
+
+	// Recap the conversation if the user is resuming it after a gap
+	bridge := s.buildResumeBridge(ctx, req, messages)
+	if bridge != nil {
+		messages = bridge.inject(messages)
 	}
 
+	// Remind the model of what the user has asked it to remember
+	messages, factCount := s.injectUserFacts(ctx, req, messages)
+
 	s.logger.Info().
 		Str("session_id", req.SessionID).
 		Str("model", req.Model).
 		Int("history_count", len(messages)).
-		Bool("has_semantic_context", relevantContext != "").
+		Int("context_items", len(retrieved.Items)).
+		Bool("resumed_after_gap", bridge != nil).
+		Int("user_facts", factCount).
 		Msg("Processing chat request")
 
 	// Save user message
@@ -110,15 +134,17 @@ func (s *ChatService) ProcessChat(ctx context.Context, req models.ChatRequest) (
 		return nil, fmt.Errorf("failed to save user message: %w", err)
 	}
 
+	if bridge != nil {
+		s.saveResumeBridge(ctx, req.SessionID, bridge, userMessage.CreatedAt)
+	}
+
 	// Update session title if this is the first user message
-	go func() {
-		if err := s.updateSessionTitleFromFirstMessage(context.Background(), req.SessionID, req.Message); err != nil {
-			s.logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Failed to update session title")
-		}
-	}()
+	if err := s.updateSessionTitleFromFirstMessage(ctx, req.SessionID, req.Message); err != nil {
+		s.logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Failed to update session title")
+	}
 
 	// Send to Ollama with semantic context
-	ollamaResp, err := s.ollamaClient.ChatWithContext(ctx, req, messages, relevantContext)
+	ollamaResp, err := s.ollamaClient.ChatWithContext(ctx, req, messages, retrieved.Text)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get response from Ollama: %w", err)
 	}
@@ -138,37 +164,10 @@ func (s *ChatService) ProcessChat(ctx context.Context, req models.ChatRequest) (
 		return nil, fmt.Errorf("failed to save assistant message: %w", err)
 	}
 
-	// Process messages for semantic memory (async)
-	if s.semanticMemory != nil {
-		// Create copies of messages to avoid race conditions in goroutine
-		userMsgCopy := models.Message{
-			ID:         userMessage.ID,
-			SessionID:  userMessage.SessionID,
-			Role:       userMessage.Role,
-			Content:    userMessage.Content,
-			Model:      userMessage.Model,
-			TokensUsed: userMessage.TokensUsed,
-			CreatedAt:  userMessage.CreatedAt,
-		}
-		assistantMsgCopy := models.Message{
-			ID:         assistantMessage.ID,
-			SessionID:  assistantMessage.SessionID,
-			Role:       assistantMessage.Role,
-			Content:    assistantMessage.Content,
-			Model:      assistantMessage.Model,
-			TokensUsed: assistantMessage.TokensUsed,
-			CreatedAt:  assistantMessage.CreatedAt,
-		}
-		
-		go func() {
-			if err := s.semanticMemory.ProcessMessageForSemanticMemory(context.Background(), userMsgCopy); err != nil {
-				s.logger.Error().Err(err).Str("message_id", userMsgCopy.ID).Msg("Failed to process user message for semantic memory")
-			}
-			if err := s.semanticMemory.ProcessMessageForSemanticMemory(context.Background(), assistantMsgCopy); err != nil {
-				s.logger.Error().Err(err).Str("message_id", assistantMsgCopy.ID).Msg("Failed to process assistant message for semantic memory")
-			}
-		}()
-	}
+	s.saveRetrievalTrace(ctx, assistantMessage, userMessage, retrieved)
+
+	// Queue the messages for semantic memory
+	s.enqueueEmbeddings(ctx, userMessage, assistantMessage)
 
 	s.logger.Info().
 		Str("session_id", req.SessionID).
@@ -176,14 +175,75 @@ func (s *ChatService) ProcessChat(ctx context.Context, req models.ChatRequest) (
 		Int("tokens_used", assistantMessage.TokensUsed).
 		Msg("Chat request completed")
 
-	return &models.ChatResponse{
+	response := &models.ChatResponse{
 		ID:         assistantMessage.ID,
 		SessionID:  req.SessionID,
 		Content:    assistantMessage.Content,
 		Model:      assistantMessage.Model,
 		CreatedAt:  assistantMessage.CreatedAt,
 		TokensUsed: assistantMessage.TokensUsed,
-	}, nil
+		Context:    retrieved.Items,
+		Citations:  models.CitationsFor(retrieved.Items),
+	}
+
+	// The title and suggestions are generated in the background, so the response carries
+	// the ones stored so far and later requests pick up the new ones
+	s.queueSessionMetadata(ctx, req.SessionID, req.Model)
+	if metadata, err := s.titleService.GetSessionMetadata(ctx, req.SessionID); err == nil {
+		response.Title = metadata.Title
+		response.Suggestions = metadata.Suggestions
+	}
+
+	return response, nil
+}
+
+// ValidateChatRequest checks that the model of a chat request is usable and can handle
+// the request
+func (s *ChatService) ValidateChatRequest(ctx context.Context, req models.ChatRequest) error {
+	if s.modelManager != nil && req.Model != "" {
+		if err := s.modelManager.ValidateModel(ctx, req.Model); err != nil {
+			return err
+		}
+	}
+	return s.validateChatCapabilities(ctx, req)
+}
+
+// validateChatCapabilities checks a chat request's images and keep_alive and that its
+// model supports what the request asks of it
+func (s *ChatService) validateChatCapabilities(ctx context.Context, req models.ChatRequest) error {
+	if err := ValidateChatImages(req.Images); err != nil {
+		return err
+	}
+	if err := ValidateKeepAlive(req.KeepAlive); err != nil {
+		return err
+	}
+	if s.modelManager == nil || req.Model == "" {
+		return nil
+	}
+	return s.modelManager.ValidateChatCapabilities(ctx, req)
+}
+
+// notifyModelLoading sends a loading_model event when the chat's model is not loaded, so
+// the client can explain the wait for the first token
+func (s *ChatService) notifyModelLoading(ctx context.Context, req models.ChatRequest, responseChan chan<- models.StreamResponse) {
+	if s.modelManager == nil {
+		return
+	}
+
+	loaded, err := s.modelManager.IsModelLoaded(ctx, req.Model)
+	if err != nil {
+		s.logger.Debug().Err(err).Str("model", req.Model).Msg("Failed to check whether the model is loaded")
+		return
+	}
+	if loaded {
+		return
+	}
+
+	responseChan <- models.StreamResponse{
+		Type:      "loading_model",
+		SessionID: req.SessionID,
+		Metadata:  map[string]interface{}{"model": req.Model},
+	}
 }
 
 // ProcessStreamingChat handles a streaming chat request
@@ -226,6 +286,21 @@ func (s *ChatService) ProcessStreamingChat(ctx context.Context, req models.ChatR
 		}
 	}
 
+	// Check that the model can handle the request, e.g. that it accepts images
+	if err := s.validateChatCapabilities(ctx, req); err != nil {
+		responseChan <- models.StreamResponse{
+			Type:      "error",
+			SessionID: req.SessionID,
+			Error:     fmt.Sprintf("Invalid model: %v", err),
+		}
+		return err
+	}
+
+	// Keep the model loaded as long as it is configured to be
+	if req.KeepAlive == "" && s.modelManager != nil {
+		req.KeepAlive = s.modelManager.KeepAliveFor(ctx, req.Model)
+	}
+
 	// Ensure session exists - use ensureSessionWithUser for proper session creation
 	if err := s.ensureSessionWithUser(ctx, req.SessionID, "debug-user-id"); err != nil {
 		responseChan <- models.StreamResponse{
@@ -247,10 +322,21 @@ func (s *ChatService) ProcessStreamingChat(ctx context.Context, req models.ChatR
 		return err
 	}
 
+	// Recap the conversation if the user is resuming it after a gap
+	bridge := s.buildResumeBridge(ctx, req, messages)
+	if bridge != nil {
+		messages = bridge.inject(messages)
+	}
+
+	// Remind the model of what the user has asked it to remember
+	messages, factCount := s.injectUserFacts(ctx, req, messages)
+
 	s.logger.Info().
 		Str("session_id", req.SessionID).
 		Str("model", req.Model).
 		Int("history_count", len(messages)).
+		Bool("resumed_after_gap", bridge != nil).
+		Int("user_facts", factCount).
 		Msg("Processing streaming chat request")
 
 	// Save user message
@@ -271,30 +357,27 @@ func (s *ChatService) ProcessStreamingChat(ctx context.Context, req models.ChatR
 		return err
 	}
 
+	if bridge != nil {
+		s.saveResumeBridge(ctx, req.SessionID, bridge, userMessage.CreatedAt)
+	}
+
 	// Update session title if this is the first user message
-	go func() {
-		if err := s.updateSessionTitleFromFirstMessage(context.Background(), req.SessionID, req.Message); err != nil {
-			s.logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Failed to update session title")
-		}
-	}()
+	if err := s.updateSessionTitleFromFirstMessage(ctx, req.SessionID, req.Message); err != nil {
+		s.logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Failed to update session title")
+	}
+
+	// Get relevant context from semantic memory if enabled
+	retrieved := s.retrieveContext(ctx, req)
+
+	// Let the client know when the model has to be loaded first, which can take a while
+	s.notifyModelLoading(ctx, req, responseChan)
 
 	// Create channel for Ollama responses
 	ollamaResponseChan := make(chan models.StreamResponse, 100)
 	
 	// Start streaming from Ollama with semantic context
 	go func() {
-		// Get relevant context for streaming as well if enabled
-		var streamingContext string
-		if s.config.EnableSemanticMemory && s.semanticMemory != nil {
-			context, err := s.semanticMemory.GetRelevantContext(ctx, req.Message, "", s.config.MaxContextResults)
-			if err != nil {
-				s.logger.Warn().Err(err).Msg("Failed to retrieve semantic context for streaming, continuing without it")
-			} else {
-				streamingContext = context
-			}
-		}
-		
-		if err := s.ollamaClient.ChatStreamWithContext(ctx, req, messages, streamingContext, ollamaResponseChan); err != nil {
+		if err := s.ollamaClient.ChatStreamWithContext(ctx, req, messages, retrieved.Text, ollamaResponseChan); err != nil {
 			s.logger.Error().Err(err).Str("session_id", req.SessionID).Msg("Ollama streaming failed")
 		}
 	}()
@@ -303,6 +386,11 @@ func (s *ChatService) ProcessStreamingChat(ctx context.Context, req models.ChatR
 	var responseContent string
 	var totalTokens int
 
+	// The done event is held back until the assistant message is saved so
+	// the message ID can be attached to it
+	var doneResp *models.StreamResponse
+	var metadataJob *sessionMetadataJob
+
 	// Forward responses and collect content
 	for ollamaResp := range ollamaResponseChan {
 		if ollamaResp.Type == "token" {
@@ -311,13 +399,15 @@ func (s *ChatService) ProcessStreamingChat(ctx context.Context, req models.ChatR
 			if tokens, ok := ollamaResp.Metadata["total_tokens"].(int); ok {
 				totalTokens = tokens
 			}
+			doneResp = &ollamaResp
+			break
 		}
 
 		// Forward to client
 		responseChan <- ollamaResp
 
-		// If error or done, break
-		if ollamaResp.Type == "error" || ollamaResp.Type == "done" {
+		// If error, break
+		if ollamaResp.Type == "error" {
 			break
 		}
 	}
@@ -349,50 +439,310 @@ func (s *ChatService) ProcessStreamingChat(ctx context.Context, req models.ChatR
 				Str("message_id", assistantMessage.ID).
 				Int("tokens_used", totalTokens).
 				Msg("Streaming chat request completed")
-		}
 
-		// Process messages for semantic memory (async) with separate context
-		if s.semanticMemory != nil {
-			// Create copies of messages to avoid race conditions
-			userMsgCopy := models.Message{
-				ID:         userMessage.ID,
-				SessionID:  userMessage.SessionID,
-				Role:       userMessage.Role,
-				Content:    userMessage.Content,
-				Model:      userMessage.Model,
-				TokensUsed: userMessage.TokensUsed,
-				CreatedAt:  userMessage.CreatedAt,
-			}
-			assistantMsgCopy := models.Message{
-				ID:         assistantMessage.ID,
-				SessionID:  assistantMessage.SessionID,
-				Role:       assistantMessage.Role,
-				Content:    assistantMessage.Content,
-				Model:      assistantMessage.Model,
-				TokensUsed: assistantMessage.TokensUsed,
-				CreatedAt:  assistantMessage.CreatedAt,
-			}
-			
-			go func() {
-				memoryCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-				defer cancel()
-				
-				// Process user message first
-				if err := s.semanticMemory.ProcessMessageForSemanticMemory(memoryCtx, userMsgCopy); err != nil {
-					s.logger.Error().Err(err).Str("message_id", userMsgCopy.ID).Msg("Failed to process user message for semantic memory")
+			if doneResp != nil {
+				if doneResp.Metadata == nil {
+					doneResp.Metadata = make(map[string]interface{})
 				}
-				
-				// Then process assistant message
-				if err := s.semanticMemory.ProcessMessageForSemanticMemory(memoryCtx, assistantMsgCopy); err != nil {
-					s.logger.Error().Err(err).Str("message_id", assistantMsgCopy.ID).Msg("Failed to process assistant message for semantic memory")
+				doneResp.Metadata["message_id"] = assistantMessage.ID
+				if len(retrieved.Items) > 0 {
+					doneResp.Metadata["context"] = retrieved.Items
+					doneResp.Metadata["citations"] = models.CitationsFor(retrieved.Items)
 				}
-			}()
+			}
+			metadataJob = s.queueSessionMetadata(ctx, req.SessionID, req.Model)
+		}
+
+		s.saveRetrievalTrace(saveCtx, assistantMessage, userMessage, retrieved)
+
+		// Queue the messages for semantic memory with the separate context
+		s.enqueueEmbeddings(saveCtx, userMessage, assistantMessage)
+	}
+
+	if doneResp != nil {
+		responseChan <- *doneResp
+	}
+
+	// The title and suggestions follow the done event in a metadata event once the
+	// titling job finishes
+	if metadata := s.awaitSessionMetadata(ctx, metadataJob); metadata != nil {
+		event := map[string]interface{}{"suggestions": metadata.Suggestions}
+		if metadata.TitleUpdated {
+			event["title"] = metadata.Title
+		}
+		responseChan <- models.StreamResponse{
+			Type:      "metadata",
+			SessionID: req.SessionID,
+			Metadata:  event,
 		}
 	}
 
 	return nil
 }
 
+// retrieveContext selects semantic memory for the message with the settings in effect for the
+// session and renders it with the session's context template. Failures are logged and leave
+// the chat without retrieved context.
+func (s *ChatService) retrieveContext(ctx context.Context, req models.ChatRequest) *RetrievedContext {
+	if !s.config.EnableSemanticMemory || s.semanticMemory == nil {
+		return &RetrievedContext{}
+	}
+
+	userID := s.sessionUserID(ctx, req.SessionID)
+	settings := s.retrieval.EffectiveForSession(ctx, userID, req.SessionID)
+
+	retrieved, err := s.semanticMemory.RetrieveContext(ctx, req.Message, req.SessionID, userID, settings)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("session_id", req.SessionID).Msg("Failed to retrieve semantic context, continuing without it")
+		return &RetrievedContext{}
+	}
+
+	if len(retrieved.Items) > 0 {
+		retrieved.Text = s.renderer.Render(ctx, req.SessionID, retrieved.Items)
+		s.logger.Info().
+			Str("session_id", req.SessionID).
+			Int("context_items", len(retrieved.Items)).
+			Int("max_results", settings.MaxResults).
+			Msg("Retrieved relevant context from semantic memory")
+	}
+
+	return retrieved
+}
+
+// saveRetrievalTrace records how the memory of a turn was selected. Turns that ran no
+// retrieval have no trace.
+func (s *ChatService) saveRetrievalTrace(ctx context.Context, assistantMessage, userMessage models.Message, retrieved *RetrievedContext) {
+	if retrieved.Model == "" {
+		return
+	}
+	if err := s.traces.SaveTrace(ctx, assistantMessage.ID, userMessage.ID, assistantMessage.SessionID, userMessage.Content, retrieved); err != nil {
+		s.logger.Error().Err(err).Str("message_id", assistantMessage.ID).Msg("Failed to save retrieval trace")
+	}
+}
+
+// resumeBridge is a recap of a session injected when the user returns after a gap
+type resumeBridge struct {
+	gapStart time.Time
+	content  string
+}
+
+// inject appends the recap to the conversation history as a system message
+func (b *resumeBridge) inject(history []models.Message) []models.Message {
+	withBridge := make([]models.Message, 0, len(history)+1)
+	withBridge = append(withBridge, history...)
+	return append(withBridge, models.Message{
+		Role:    "system",
+		Content: fmt.Sprintf("The user is returning to this conversation after a break. Recap of where it left off: %s", b.content),
+	})
+}
+
+// buildResumeBridge generates a recap when the session is resumed after the memory gap threshold
+func (s *ChatService) buildResumeBridge(ctx context.Context, req models.ChatRequest, history []models.Message) *resumeBridge {
+	if !s.config.EnableResumeBridge || s.bridgeService == nil {
+		return nil
+	}
+
+	now := time.Now()
+	gapStart, resumed := ResumeGapStart(history, now, s.config.MemoryGapThreshold)
+	if !resumed {
+		return nil
+	}
+
+	model := s.config.SummaryModel
+	if model == "" {
+		model = req.Model
+	}
+
+	bridgeCtx, cancel := context.WithTimeout(ctx, s.config.ResumeBridgeTimeout)
+	defer cancel()
+
+	content, err := s.bridgeService.GenerateBridge(bridgeCtx, req.SessionID, model, history, gapStart, now)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("session_id", req.SessionID).Msg("Failed to generate resume bridge, continuing without it")
+		return nil
+	}
+	if content == "" {
+		return nil
+	}
+
+	return &resumeBridge{gapStart: gapStart, content: content}
+}
+
+// saveResumeBridge stores the recap as the bridge content of the gap ending at the new user message
+func (s *ChatService) saveResumeBridge(ctx context.Context, sessionID string, bridge *resumeBridge, gapEnd time.Time) {
+	if s.semanticMemory == nil {
+		return
+	}
+	if err := s.semanticMemory.SaveBridgeContent(ctx, sessionID, bridge.gapStart, gapEnd, bridge.content); err != nil {
+		s.logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to save resume bridge")
+	}
+}
+
+// injectUserFacts prepends the user's confirmed facts that are relevant to the message as a system
+// message, returning the new history and the number of facts injected
+func (s *ChatService) injectUserFacts(ctx context.Context, req models.ChatRequest, history []models.Message) ([]models.Message, int) {
+	if s.userMemory == nil || s.config.MaxInjectedFacts == 0 {
+		return history, 0
+	}
+
+	facts, err := s.userMemory.GetRelevantFacts(ctx, s.sessionUserID(ctx, req.SessionID), req.Message, s.config.MaxInjectedFacts)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("session_id", req.SessionID).Msg("Failed to retrieve user facts, continuing without them")
+		return history, 0
+	}
+	if len(facts) == 0 {
+		return history, 0
+	}
+
+	withFacts := make([]models.Message, 0, len(history)+1)
+	withFacts = append(withFacts, models.Message{
+		Role:    "system",
+		Content: FormatFactsPrompt(facts),
+	})
+	return append(withFacts, history...), len(facts)
+}
+
+// sessionMetadataJob is a queued titling job and the session title it started from
+type sessionMetadataJob struct {
+	sessionID string
+	jobID     string
+	title     string
+}
+
+// queueSessionMetadata queues the job generating the session title and follow-up
+// suggestions. It returns nil when nothing was queued: the title is already generated
+// and suggestions are disabled, or a job for the session is already waiting to run.
+func (s *ChatService) queueSessionMetadata(ctx context.Context, sessionID, model string) *sessionMetadataJob {
+	if s.titleService == nil || s.jobs == nil {
+		return nil
+	}
+
+	needed, err := s.titleService.NeedsSessionMetadata(ctx, sessionID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to get session metadata")
+		return nil
+	}
+	if !needed {
+		return nil
+	}
+
+	before, err := s.titleService.GetSessionMetadata(ctx, sessionID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to get session metadata")
+		return nil
+	}
+
+	// Titling runs ahead of embeddings since its result is shown right after the reply
+	jobID, err := s.jobs.Enqueue(ctx, JobTypeSessionMetadata, sessionMetadataPayload{SessionID: sessionID, Model: model}, JobOptions{
+		DedupeKey: JobTypeSessionMetadata + ":" + sessionID,
+		Priority:  10,
+	})
+	if err != nil {
+		s.logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to queue session metadata job")
+		return nil
+	}
+	if jobID == "" {
+		// A titling job for this session is already waiting to run
+		return nil
+	}
+
+	return &sessionMetadataJob{sessionID: sessionID, jobID: jobID, title: before.Title}
+}
+
+// awaitSessionMetadata waits up to SessionMetadataTimeout for a queued titling job and
+// returns the metadata it stored. The job keeps running after a timeout so the title and
+// suggestions are still stored for later requests.
+func (s *ChatService) awaitSessionMetadata(ctx context.Context, job *sessionMetadataJob) *models.SessionMetadata {
+	if job == nil {
+		return nil
+	}
+
+	status, err := s.jobs.Wait(ctx, job.jobID, s.config.SessionMetadataTimeout)
+	if err != nil {
+		return nil
+	}
+	if status != models.JobStatusCompleted {
+		s.logger.Warn().
+			Str("session_id", job.sessionID).
+			Str("job_status", status).
+			Dur("timeout", s.config.SessionMetadataTimeout).
+			Msg("Session metadata not ready in time, closing the stream without it")
+		return nil
+	}
+
+	metadata, err := s.titleService.GetSessionMetadata(ctx, job.sessionID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("session_id", job.sessionID).Msg("Failed to get session metadata")
+		return nil
+	}
+	metadata.TitleUpdated = metadata.Title != job.title
+	return metadata
+}
+
+// enqueueEmbeddings queues messages for embedding and gap detection on the job queue
+func (s *ChatService) enqueueEmbeddings(ctx context.Context, messages ...models.Message) {
+	if s.semanticMemory == nil || s.jobs == nil {
+		return
+	}
+
+	for _, message := range messages {
+		if err := EnqueueMessageEmbedding(ctx, s.jobs, message.ID, 0); err != nil {
+			s.logger.Error().Err(err).Str("message_id", message.ID).Msg("Failed to queue message for semantic memory")
+		}
+	}
+}
+
+// sessionUserID returns the owner of a session, or an empty string if it cannot be determined
+func (s *ChatService) sessionUserID(ctx context.Context, sessionID string) string {
+	var userID sql.NullString
+	if err := s.db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = $1", sessionID).Scan(&userID); err != nil {
+		s.logger.Warn().Err(err).Str("session_id", sessionID).Msg("Failed to look up session owner")
+		return ""
+	}
+	return userID.String
+}
+
+// RegenerateSessionTitle forces a new LLM-generated title and suggestions for a session
+func (s *ChatService) RegenerateSessionTitle(ctx context.Context, sessionID string) (*models.SessionMetadata, error) {
+	model, err := s.lastSessionModel(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.titleService.GenerateSessionMetadata(ctx, sessionID, model, true)
+}
+
+// GetSessionSuggestions returns the stored follow-up suggestions for a session
+func (s *ChatService) GetSessionSuggestions(ctx context.Context, sessionID string) ([]string, error) {
+	return s.titleService.GetSessionSuggestions(ctx, sessionID)
+}
+
+// lastSessionModel returns the model of the latest assistant message, falling back to the default model
+func (s *ChatService) lastSessionModel(ctx context.Context, sessionID string) (string, error) {
+	var model sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT model FROM messages
+		WHERE session_id = $1 AND role = 'assistant' AND model IS NOT NULL AND model <> ''
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, sessionID).Scan(&model)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get session model: %w", err)
+	}
+	if model.Valid {
+		return model.String, nil
+	}
+
+	if s.modelManager == nil {
+		return "", fmt.Errorf("no model specified and model manager not available")
+	}
+	defaultModel, err := s.modelManager.GetDefaultModel(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no default model available: %w", err)
+	}
+	return defaultModel.Name, nil
+}
+
 // SaveMessage saves a message to the database
 func (s *ChatService) SaveMessage(ctx context.Context, message models.Message) error {
 	query := `
@@ -817,7 +1167,14 @@ func (s *ChatService) updateSessionTitleFromFirstMessage(ctx context.Context, se
 	// Only update if it's still the default title
 	if currentTitle == "New Chat" {
 		newTitle := s.generateTitleFromMessage(message)
-		if err := s.UpdateSessionTitle(ctx, sessionID, newTitle); err != nil {
+
+		// Never overwrite a title the titling job has already produced
+		query := `
+			UPDATE sessions
+			SET title = $1, title_source = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $3 AND title_source = $4
+		`
+		if _, err := s.db.ExecContext(ctx, query, newTitle, models.TitleSourceHeuristic, sessionID, models.TitleSourceDefault); err != nil {
 			return fmt.Errorf("failed to update session title: %w", err)
 		}
 		
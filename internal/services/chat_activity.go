@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// chatActivityInterval is how often an instance refreshes its chat count when it
+	// does not change
+	chatActivityInterval = 5 * time.Second
+	// chatActivityStale is how old the chat count of an instance may be before the
+	// instance is considered stopped
+	chatActivityStale = 3 * chatActivityInterval
+	// chatActivityExpiry is how long the rows of stopped instances are kept
+	chatActivityExpiry = time.Hour
+)
+
+// chatActivityInstance identifies this process in chat_activity
+var chatActivityInstance = func() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8])
+}()
+
+// ChatActivity shares the number of chats in progress between instances. Each instance
+// publishes its own count to the chat_activity table as it changes, so work that must
+// not run during chats, such as model benchmarks, waits for the chats of every instance.
+type ChatActivity struct {
+	db           database.Database
+	ollamaClient *OllamaClient
+	logger       *utils.Logger
+}
+
+// NewChatActivity creates a new chat activity tracker
+func NewChatActivity(db database.Database, ollamaClient *OllamaClient, logger *utils.Logger) *ChatActivity {
+	return &ChatActivity{
+		db:           db,
+		ollamaClient: ollamaClient,
+		logger:       logger.WithComponent("chat_activity"),
+	}
+}
+
+// Name returns the worker name
+func (a *ChatActivity) Name() string {
+	return "chat_activity"
+}
+
+// Run publishes the chat count of this instance whenever it changes and at least every
+// chatActivityInterval until ctx is cancelled, then removes it
+func (a *ChatActivity) Run(ctx context.Context) {
+	ticker := time.NewTicker(chatActivityInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.publish(ctx); err != nil && ctx.Err() == nil {
+			a.logger.Error().Err(err).Msg("Failed to publish chat activity")
+		}
+
+		select {
+		case <-ctx.Done():
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := a.db.ExecContext(cleanupCtx, "DELETE FROM chat_activity WHERE instance_id = $1", chatActivityInstance); err != nil {
+				a.logger.Warn().Err(err).Msg("Failed to remove chat activity")
+			}
+			return
+		case <-ticker.C:
+		case <-a.ollamaClient.ChatsChanged():
+		}
+	}
+}
+
+// publish stores the chat count of this instance and drops the rows of instances that
+// stopped long ago
+func (a *ChatActivity) publish(ctx context.Context) error {
+	if _, err := a.db.ExecContext(ctx, `
+		INSERT INTO chat_activity (instance_id, active_chats, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (instance_id) DO UPDATE SET active_chats = EXCLUDED.active_chats, updated_at = NOW()
+	`, chatActivityInstance, a.ollamaClient.ActiveChats()); err != nil {
+		return fmt.Errorf("failed to store chat activity: %w", err)
+	}
+
+	if _, err := a.db.ExecContext(ctx, `
+		DELETE FROM chat_activity WHERE updated_at < NOW() - $1 * INTERVAL '1 second'
+	`, chatActivityExpiry.Seconds()); err != nil {
+		return fmt.Errorf("failed to expire chat activity: %w", err)
+	}
+	return nil
+}
+
+// ActiveChats returns the number of chats in progress on all instances: those of this
+// process and those other running instances published
+func (a *ChatActivity) ActiveChats(ctx context.Context) (int64, error) {
+	var others int64
+	err := a.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(active_chats), 0) FROM chat_activity
+		WHERE instance_id <> $1 AND updated_at > NOW() - $2 * INTERVAL '1 second'
+	`, chatActivityInstance, chatActivityStale.Seconds()).Scan(&others)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active chats: %w", err)
+	}
+	return a.ollamaClient.ActiveChats() + others, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package services
+
+import "errors"
+
+// freeDiskSpace is not supported on this platform, so uploads are not checked against it
+func freeDiskSpace(dir string) (int64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
@@ -1,76 +1,201 @@
 package services
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strings"
 
 	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
 	"chat_ollama/internal/utils"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
 )
 
 // EmbeddingService handles text embedding generation using Ollama
 type EmbeddingService struct {
-	client     *http.Client
-	ollamaHost string
-	logger     *utils.Logger
+	db        database.Database
+	client    *http.Client
+	pool      *OllamaPool
+	keepAlive string
+	truncate  bool
+	batchSize int
+	useCache  bool
+	logger    *utils.Logger
 }
 
-// EmbeddingRequest represents a request to generate embeddings
+// EmbeddingRequest represents a request to the Ollama /api/embed endpoint
 type EmbeddingRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
+	Model     string   `json:"model"`
+	Input     []string `json:"input"`
+	Truncate  *bool    `json:"truncate,omitempty"`
+	KeepAlive string   `json:"keep_alive,omitempty"`
 }
 
-// EmbeddingResponse represents the response from Ollama embedding API
+// EmbeddingResponse represents the response from the Ollama /api/embed endpoint
 type EmbeddingResponse struct {
-	Embedding []float32 `json:"embedding"`
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// EmbeddingOptions overrides the configured truncation and keep-alive for a request
+type EmbeddingOptions struct {
+	Truncate  *bool
+	KeepAlive string
+}
+
+// EmbeddingResult holds the embeddings of a batch in input order
+type EmbeddingResult struct {
+	Embeddings [][]float32
+	Cached     int // Number of inputs served from the cache
 }
 
 // NewEmbeddingService creates a new embedding service
-func NewEmbeddingService(cfg *config.Config, logger *utils.Logger) *EmbeddingService {
+func NewEmbeddingService(db database.Database, cfg *config.Config, logger *utils.Logger) *EmbeddingService {
 	return &EmbeddingService{
+		db: db,
 		client: &http.Client{
 			Timeout: cfg.OllamaTimeout,
 		},
-		ollamaHost: cfg.OllamaHost,
-		logger:     logger.WithComponent("embedding_service"),
+		pool:      sharedOllamaPool(cfg, logger),
+		keepAlive: cfg.EmbeddingKeepAlive,
+		truncate:  cfg.EmbeddingTruncate,
+		batchSize: cfg.EmbeddingBatchSize,
+		useCache:  cfg.EnableEmbeddingCache && db != nil,
+		logger:    logger.WithComponent("embedding_service"),
 	}
 }
 
 // GenerateEmbedding generates an embedding for the given text using the specified model
 func (s *EmbeddingService) GenerateEmbedding(ctx context.Context, text, model string) ([]float32, error) {
+	result, err := s.Embed(ctx, []string{text}, model, EmbeddingOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Embeddings[0], nil
+}
+
+// GenerateEmbeddingBatch generates embeddings for multiple texts
+func (s *EmbeddingService) GenerateEmbeddingBatch(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	result, err := s.Embed(ctx, texts, model, EmbeddingOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Embeddings, nil
+}
+
+// Embed generates embeddings for texts in input order. Texts already embedded with the
+// model are served from the cache; identical texts are embedded once; the rest are sent
+// to Ollama in batches of EMBEDDING_BATCH_SIZE.
+func (s *EmbeddingService) Embed(ctx context.Context, texts []string, model string, opts EmbeddingOptions) (*EmbeddingResult, error) {
 	if model == "" {
 		model = "nomic-embed-text" // Default embedding model
 	}
 
-	req := EmbeddingRequest{
-		Model:  model,
-		Prompt: text,
+	result := &EmbeddingResult{Embeddings: make([][]float32, len(texts))}
+	if len(texts) == 0 {
+		return result, nil
 	}
 
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	// Group inputs by content so identical text is embedded once
+	positions := make(map[string][]int, len(texts))
+	var hashes []string
+	for i, text := range texts {
+		hash := contentHash(text)
+		if _, seen := positions[hash]; !seen {
+			hashes = append(hashes, hash)
+		}
+		positions[hash] = append(positions[hash], i)
 	}
 
-	url := fmt.Sprintf("http://%s/api/embeddings", s.ollamaHost)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	digest := s.modelDigest(ctx, model)
+	cached := s.lookupCache(ctx, model, digest, hashes)
+
+	var missing []string
+	for _, hash := range hashes {
+		if embedding, ok := cached[hash]; ok {
+			for _, i := range positions[hash] {
+				result.Embeddings[i] = embedding
+			}
+			result.Cached += len(positions[hash])
+			continue
+		}
+		missing = append(missing, hash)
+	}
+
+	for start := 0; start < len(missing); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := missing[start:end]
+
+		inputs := make([]string, len(batch))
+		for i, hash := range batch {
+			inputs[i] = texts[positions[hash][0]]
+		}
+
+		embeddings, err := s.requestEmbeddings(ctx, inputs, model, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		fresh := make(map[string][]float32, len(batch))
+		for i, hash := range batch {
+			fresh[hash] = embeddings[i]
+			for _, pos := range positions[hash] {
+				result.Embeddings[pos] = embeddings[i]
+			}
+		}
+		s.storeCache(ctx, model, digest, fresh)
+	}
+
+	s.logger.Debug().
+		Str("model", model).
+		Int("inputs", len(texts)).
+		Int("cached", result.Cached).
+		Int("embedded", len(missing)).
+		Msg("Embeddings generated")
+
+	return result, nil
+}
+
+// requestEmbeddings sends one batch to the Ollama /api/embed endpoint
+func (s *EmbeddingService) requestEmbeddings(ctx context.Context, inputs []string, model string, opts EmbeddingOptions) ([][]float32, error) {
+	truncate := s.truncate
+	if opts.Truncate != nil {
+		truncate = *opts.Truncate
+	}
+	keepAlive := s.keepAlive
+	if opts.KeepAlive != "" {
+		keepAlive = opts.KeepAlive
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	jsonData, err := json.Marshal(EmbeddingRequest{
+		Model:     model,
+		Input:     inputs,
+		Truncate:  &truncate,
+		KeepAlive: keepAlive,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
 
 	s.logger.Debug().
 		Str("model", model).
-		Str("text_preview", truncateText(text, 100)).
-		Msg("Generating embedding")
+		Int("inputs", len(inputs)).
+		Str("text_preview", truncateText(inputs[0], 100)).
+		Msg("Generating embeddings")
 
-	resp, err := s.client.Do(httpReq)
+	resp, err := s.pool.send(ctx, s.client, model, "POST", "/api/embed", jsonData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send embedding request: %w", err)
 	}
@@ -86,27 +211,87 @@ func (s *EmbeddingService) GenerateEmbedding(ctx context.Context, text, model st
 		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
 	}
 
-	s.logger.Debug().
-		Str("model", model).
-		Int("embedding_dimensions", len(embeddingResp.Embedding)).
-		Msg("Embedding generated successfully")
+	if len(embeddingResp.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("embedding response has %d embeddings for %d inputs", len(embeddingResp.Embeddings), len(inputs))
+	}
 
-	return embeddingResp.Embedding, nil
+	return embeddingResp.Embeddings, nil
 }
 
-// GenerateEmbeddingBatch generates embeddings for multiple texts
-func (s *EmbeddingService) GenerateEmbeddingBatch(ctx context.Context, texts []string, model string) ([][]float32, error) {
-	embeddings := make([][]float32, len(texts))
-	
-	for i, text := range texts {
-		embedding, err := s.GenerateEmbedding(ctx, text, model)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate embedding for text %d: %w", i, err)
+// modelDigest returns the digest of the installed model the cache is keyed by, or an
+// empty string when the model is not known yet
+func (s *EmbeddingService) modelDigest(ctx context.Context, model string) string {
+	if !s.useCache {
+		return ""
+	}
+
+	var digest string
+	err := s.db.QueryRowContext(ctx, "SELECT digest FROM models WHERE name = $1", normalizeModelName(model)).Scan(&digest)
+	if err != nil && err != sql.ErrNoRows {
+		s.logger.Warn().Err(err).Str("model", model).Msg("Failed to get embedding model digest")
+	}
+	return digest
+}
+
+// lookupCache returns the cached embeddings of the given content hashes. Cache failures
+// are logged and treated as misses.
+func (s *EmbeddingService) lookupCache(ctx context.Context, model, digest string, hashes []string) map[string][]float32 {
+	found := make(map[string][]float32)
+	if !s.useCache {
+		return found
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE embedding_cache SET last_used_at = NOW()
+		WHERE model = $1 AND model_digest = $2 AND content_hash = ANY($3)
+		RETURNING content_hash, embedding
+	`, model, digest, pq.Array(hashes))
+	if err != nil {
+		s.logger.Warn().Err(err).Str("model", model).Msg("Failed to read embedding cache")
+		return found
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var embedding pgvector.Vector
+		if err := rows.Scan(&hash, &embedding); err != nil {
+			s.logger.Warn().Err(err).Str("model", model).Msg("Failed to scan cached embedding")
+			return map[string][]float32{}
 		}
-		embeddings[i] = embedding
+		found[hash] = embedding.Slice()
 	}
 
-	return embeddings, nil
+	return found
+}
+
+// storeCache saves freshly generated embeddings, keyed by model digest and content hash
+func (s *EmbeddingService) storeCache(ctx context.Context, model, digest string, embeddings map[string][]float32) {
+	if !s.useCache || len(embeddings) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(embeddings))
+	args := []interface{}{model, digest}
+	for hash, embedding := range embeddings {
+		values = append(values, fmt.Sprintf("($1, $2, $%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, hash, pgvector.NewVector(embedding))
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO embedding_cache (model, model_digest, content_hash, embedding)
+		VALUES %s
+		ON CONFLICT (model, model_digest, content_hash) DO NOTHING
+	`, strings.Join(values, ", "))
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		s.logger.Warn().Err(err).Str("model", model).Msg("Failed to write embedding cache")
+	}
+}
+
+// contentHash returns the SHA-256 hex digest of text
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
 }
 
 // GetEmbeddingDimensions returns the dimensions of embeddings for a given model
@@ -158,7 +343,7 @@ func CosineSimilarity(a, b []float32) float64 {
 		return 0
 	}
 
-	return dotProduct / (normA * normB)
+	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
 // truncateText truncates text to a specified length for logging
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/utils"
+)
+
+// EmbeddingRegistry tracks the embedding models in use, their dimensions and the
+// model each user's memory is stored under
+type EmbeddingRegistry struct {
+	db               database.Database
+	embeddingService *EmbeddingService
+	indexes          *VectorIndexService
+	logger           *utils.Logger
+	defaultModel     string
+
+	mu         sync.RWMutex
+	dimensions map[string]int
+}
+
+// NewEmbeddingRegistry creates a new embedding registry
+func NewEmbeddingRegistry(db database.Database, embeddingService *EmbeddingService, defaultModel string, logger *utils.Logger) *EmbeddingRegistry {
+	return &EmbeddingRegistry{
+		db:               db,
+		embeddingService: embeddingService,
+		logger:           logger.WithComponent("embedding_registry"),
+		defaultModel:     defaultModel,
+		dimensions:       make(map[string]int),
+	}
+}
+
+// SetIndexes sets the service that builds the vector indexes of registered models.
+// Without it registering a model creates no indexes.
+func (r *EmbeddingRegistry) SetIndexes(indexes *VectorIndexService) {
+	r.indexes = indexes
+}
+
+// DefaultModel returns the configured embedding model
+func (r *EmbeddingRegistry) DefaultModel() string {
+	return r.defaultModel
+}
+
+// ModelForUser returns the embedding model the user's memory is stored under
+func (r *EmbeddingRegistry) ModelForUser(ctx context.Context, userID string) string {
+	if userID == "" {
+		return r.defaultModel
+	}
+
+	var model sql.NullString
+	err := r.db.QueryRowContext(ctx, "SELECT embedding_model FROM users WHERE id = $1", userID).Scan(&model)
+	if err != nil && err != sql.ErrNoRows {
+		r.logger.Warn().Err(err).Str("user_id", userID).Msg("Failed to look up user embedding model, using default")
+	}
+	if model.Valid && model.String != "" {
+		return model.String
+	}
+	return r.defaultModel
+}
+
+// ModelForSession returns the embedding model of the session owner's memory
+func (r *EmbeddingRegistry) ModelForSession(ctx context.Context, sessionID string) string {
+	var model sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.embedding_model
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.id = $1
+	`, sessionID).Scan(&model)
+	if err != nil && err != sql.ErrNoRows {
+		r.logger.Warn().Err(err).Str("session_id", sessionID).Msg("Failed to look up session embedding model, using default")
+	}
+	if model.Valid && model.String != "" {
+		return model.String
+	}
+	return r.defaultModel
+}
+
+// Dimensions returns the registered dimension of a model, or zero if it is unknown
+func (r *EmbeddingRegistry) Dimensions(ctx context.Context, model string) int {
+	r.mu.RLock()
+	dims, ok := r.dimensions[model]
+	r.mu.RUnlock()
+	if ok {
+		return dims
+	}
+
+	if err := r.db.QueryRowContext(ctx, "SELECT dimensions FROM embedding_models WHERE name = $1", model).Scan(&dims); err != nil {
+		return 0
+	}
+
+	r.mu.Lock()
+	r.dimensions[model] = dims
+	r.mu.Unlock()
+	return dims
+}
+
+// RegisterModel asks Ollama for the model's embedding dimension, checks it against the
+// dimension stored for the model and makes sure its vector indexes exist
+func (r *EmbeddingRegistry) RegisterModel(ctx context.Context, model string) (int, error) {
+	dims, err := r.embeddingService.GetEmbeddingDimensions(ctx, model)
+	if err != nil {
+		return 0, err
+	}
+
+	return dims, r.register(ctx, model, dims)
+}
+
+// register records a model's dimension after checking it against the stored vectors
+func (r *EmbeddingRegistry) register(ctx context.Context, model string, dims int) error {
+	stored, err := r.storedDimensions(ctx, model)
+	if err != nil {
+		return err
+	}
+	if stored != 0 && stored != dims {
+		return fmt.Errorf("embedding model %s returns %d dimensions but %d-dimensional vectors are stored for it; "+
+			"re-embed memory with another model or remove the stored vectors", model, dims, stored)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO embedding_models (name, dimensions) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET dimensions = EXCLUDED.dimensions
+	`, model, dims)
+	if err != nil {
+		return fmt.Errorf("failed to register embedding model: %w", err)
+	}
+
+	if r.indexes != nil {
+		if err := r.indexes.EnsureIndexes(ctx, model, dims); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	r.dimensions[model] = dims
+	r.mu.Unlock()
+
+	return nil
+}
+
+// CheckSchema verifies at startup that the configured embedding model matches the stored
+// vectors. An unreachable Ollama is only logged; a dimension mismatch is returned as an error.
+func (r *EmbeddingRegistry) CheckSchema(ctx context.Context) error {
+	dims, err := r.embeddingService.GetEmbeddingDimensions(ctx, r.defaultModel)
+	if err != nil {
+		r.logger.Warn().Err(err).Str("model", r.defaultModel).Msg("Could not reach embedding model, skipping embedding schema check")
+		return nil
+	}
+
+	if err := r.register(ctx, r.defaultModel, dims); err != nil {
+		return err
+	}
+
+	// Vectors written before models were tracked belong to the default model when their dimension matches
+	for _, table := range []string{"memory_summaries", "semantic_topics"} {
+		result, err := r.db.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s SET embedding_model = $1
+			WHERE embedding_model IS NULL AND embedding IS NOT NULL AND vector_dims(embedding) = $2
+		`, table), r.defaultModel, dims)
+		if err != nil {
+			return fmt.Errorf("failed to backfill embedding model of %s: %w", table, err)
+		}
+		if backfilled, err := result.RowsAffected(); err == nil && backfilled > 0 {
+			r.logger.Info().Str("table", table).Int64("rows", backfilled).Msg("Backfilled embedding model")
+		}
+	}
+
+	r.logger.Info().
+		Str("model", r.defaultModel).
+		Int("dimensions", dims).
+		Msg("Embedding schema check passed")
+
+	return nil
+}
+
+// storedDimensions returns the dimension stored for a model, from the registry or, for
+// vectors written before the registry existed, from the vectors themselves
+func (r *EmbeddingRegistry) storedDimensions(ctx context.Context, model string) (int, error) {
+	var dims int
+	err := r.db.QueryRowContext(ctx, "SELECT dimensions FROM embedding_models WHERE name = $1", model).Scan(&dims)
+	if err == nil {
+		return dims, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get registered embedding model: %w", err)
+	}
+
+	records, err := r.db.Vectors().List(ctx, database.VectorFilter{Model: model}, 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect stored embeddings: %w", err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records[0].Embedding), nil
+}
+
+// vectorOf casts a vector expression to a fixed dimension so per-model indexes can be used
+func vectorOf(expr string, dims int) string {
+	if dims <= 0 {
+		return expr
+	}
+	return fmt.Sprintf("%s::vector(%d)", expr, dims)
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ggufMagic is "GGUF" read as a little-endian uint32
+const ggufMagic = 0x46554747
+
+// Limits that keep a corrupt header from making the reader allocate or loop without end
+const (
+	ggufMaxStringLength = 64 << 20
+	ggufMaxCount        = 1 << 32
+	ggufMaxDimensions   = 8
+)
+
+// GGUF metadata value types
+const (
+	ggufTypeUint8 uint32 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+// ggufFileTypes names the general.file_type values llama.cpp writes
+var ggufFileTypes = map[uint64]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16",
+}
+
+// GGUFInfo is the model metadata read from a GGUF file header
+type GGUFInfo struct {
+	Version        uint32
+	Name           string // general.name
+	Architecture   string // general.architecture, e.g. llama or qwen2
+	FileType       string // Quantization, e.g. Q4_K_M
+	ParameterCount uint64 // Elements of all tensors
+	ContextLength  int    // <architecture>.context_length
+	TensorCount    uint64
+}
+
+// Parameters formats the parameter count the way Ollama reports it, e.g. 7.6B
+func (i GGUFInfo) Parameters() string {
+	count := float64(i.ParameterCount)
+	switch {
+	case count >= 1e9:
+		return fmt.Sprintf("%.1fB", count/1e9)
+	case count >= 1e6:
+		return fmt.Sprintf("%.1fM", count/1e6)
+	case count >= 1e3:
+		return fmt.Sprintf("%.1fK", count/1e3)
+	case count > 0:
+		return fmt.Sprintf("%d", i.ParameterCount)
+	}
+	return ""
+}
+
+// ReadGGUFInfo reads the header of a GGUF file: its metadata and the tensor infos that
+// give the parameter count. The tensor data itself is not read.
+func ReadGGUFInfo(path string) (*GGUFInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseGGUF(bufio.NewReaderSize(file, 1<<20))
+}
+
+// ggufReader decodes the little-endian values of a GGUF header
+type ggufReader struct {
+	r   io.Reader
+	err error
+}
+
+func (g *ggufReader) read(v interface{}) {
+	if g.err == nil {
+		g.err = binary.Read(g.r, binary.LittleEndian, v)
+	}
+}
+
+func (g *ggufReader) uint32() uint32 {
+	var v uint32
+	g.read(&v)
+	return v
+}
+
+func (g *ggufReader) uint64() uint64 {
+	var v uint64
+	g.read(&v)
+	return v
+}
+
+func (g *ggufReader) string() string {
+	length := g.uint64()
+	if g.err != nil {
+		return ""
+	}
+	if length > ggufMaxStringLength {
+		g.err = fmt.Errorf("string of %d bytes exceeds the header limit", length)
+		return ""
+	}
+	buf := make([]byte, length)
+	_, g.err = io.ReadFull(g.r, buf)
+	return string(buf)
+}
+
+func (g *ggufReader) skip(n int64) {
+	if g.err == nil {
+		_, g.err = io.CopyN(io.Discard, g.r, n)
+	}
+}
+
+// value reads a metadata value, returning scalars as uint64, int64, float64, bool or
+// string. Arrays are skipped and returned as nil.
+func (g *ggufReader) value(valueType uint32) interface{} {
+	switch valueType {
+	case ggufTypeUint8:
+		var v uint8
+		g.read(&v)
+		return uint64(v)
+	case ggufTypeInt8:
+		var v int8
+		g.read(&v)
+		return int64(v)
+	case ggufTypeUint16:
+		var v uint16
+		g.read(&v)
+		return uint64(v)
+	case ggufTypeInt16:
+		var v int16
+		g.read(&v)
+		return int64(v)
+	case ggufTypeUint32:
+		return uint64(g.uint32())
+	case ggufTypeInt32:
+		var v int32
+		g.read(&v)
+		return int64(v)
+	case ggufTypeFloat32:
+		var v float32
+		g.read(&v)
+		return float64(v)
+	case ggufTypeBool:
+		var v uint8
+		g.read(&v)
+		return v != 0
+	case ggufTypeString:
+		return g.string()
+	case ggufTypeUint64:
+		return g.uint64()
+	case ggufTypeInt64:
+		var v int64
+		g.read(&v)
+		return v
+	case ggufTypeFloat64:
+		var v float64
+		g.read(&v)
+		return v
+	case ggufTypeArray:
+		g.skipArray()
+		return nil
+	}
+	if g.err == nil {
+		g.err = fmt.Errorf("unknown metadata value type %d", valueType)
+	}
+	return nil
+}
+
+// skipArray skips an array value, e.g. the tokenizer vocabulary
+func (g *ggufReader) skipArray() {
+	elementType := g.uint32()
+	count := g.uint64()
+	if g.err != nil {
+		return
+	}
+	if count > ggufMaxCount {
+		g.err = fmt.Errorf("array of %d elements exceeds the header limit", count)
+		return
+	}
+
+	if size := ggufScalarSize(elementType); size > 0 {
+		g.skip(int64(count) * size)
+		return
+	}
+	for i := uint64(0); i < count && g.err == nil; i++ {
+		g.value(elementType)
+	}
+}
+
+// ggufScalarSize returns the encoded size of a fixed-size value type, or 0
+func ggufScalarSize(valueType uint32) int64 {
+	switch valueType {
+	case ggufTypeUint8, ggufTypeInt8, ggufTypeBool:
+		return 1
+	case ggufTypeUint16, ggufTypeInt16:
+		return 2
+	case ggufTypeUint32, ggufTypeInt32, ggufTypeFloat32:
+		return 4
+	case ggufTypeUint64, ggufTypeInt64, ggufTypeFloat64:
+		return 8
+	}
+	return 0
+}
+
+// parseGGUF parses a GGUF header (versions 2 and 3)
+func parseGGUF(r io.Reader) (*GGUFInfo, error) {
+	g := &ggufReader{r: r}
+
+	if magic := g.uint32(); g.err != nil || magic != ggufMagic {
+		return nil, fmt.Errorf("not a GGUF file")
+	}
+
+	info := &GGUFInfo{Version: g.uint32()}
+	if g.err == nil && (info.Version < 2 || info.Version > 3) {
+		return nil, fmt.Errorf("unsupported GGUF version %d", info.Version)
+	}
+
+	info.TensorCount = g.uint64()
+	kvCount := g.uint64()
+	if g.err == nil && (info.TensorCount > ggufMaxCount || kvCount > ggufMaxCount) {
+		return nil, fmt.Errorf("invalid GGUF header counts")
+	}
+
+	metadata := make(map[string]interface{})
+	for i := uint64(0); i < kvCount && g.err == nil; i++ {
+		key := g.string()
+		valueType := g.uint32()
+		if value := g.value(valueType); value != nil {
+			metadata[key] = value
+		}
+	}
+
+	for i := uint64(0); i < info.TensorCount && g.err == nil; i++ {
+		g.string() // Tensor name
+		dimensions := g.uint32()
+		if g.err == nil && dimensions > ggufMaxDimensions {
+			return nil, fmt.Errorf("tensor with %d dimensions exceeds the header limit", dimensions)
+		}
+		elements := uint64(1)
+		for d := uint32(0); d < dimensions; d++ {
+			elements *= g.uint64()
+		}
+		g.uint32() // Tensor type
+		g.uint64() // Data offset
+		if elements <= math.MaxUint64-info.ParameterCount {
+			info.ParameterCount += elements
+		}
+	}
+
+	if g.err != nil {
+		return nil, fmt.Errorf("failed to read GGUF header: %w", g.err)
+	}
+
+	info.Name, _ = metadata["general.name"].(string)
+	info.Architecture, _ = metadata["general.architecture"].(string)
+	if fileType, ok := metadata["general.file_type"].(uint64); ok {
+		if name, known := ggufFileTypes[fileType]; known {
+			info.FileType = name
+		}
+	}
+	switch contextLength := metadata[info.Architecture+".context_length"].(type) {
+	case uint64:
+		info.ContextLength = int(contextLength)
+	case int64:
+		info.ContextLength = int(contextLength)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Job types handled by the job queue
+const (
+	JobTypeEmbedMessage        = "embed_message"
+	JobTypeDetectGaps          = "detect_gaps"
+	JobTypeSessionMetadata     = "session_metadata"
+	JobTypeModelDownload       = "model_download"
+	JobTypeEmbeddingBackfill   = "embedding_backfill"
+	JobTypeEnforceRetention    = "enforce_retention"
+	JobTypePruneEmbeddingCache = "prune_embedding_cache"
+	JobTypeReindexVectors      = "reindex_vectors"
+	JobTypeReconcileModels     = "reconcile_models"
+	JobTypeModelBenchmark      = "model_benchmark"
+	JobTypeSummarize           = "summarize"
+	JobTypeClusterTopics       = "cluster_topics"
+	JobTypeExtractFacts        = "extract_facts"
+)
+
+// JobHandler processes the payload of a job. Returning an error schedules a retry.
+type JobHandler func(ctx context.Context, payload json.RawMessage) error
+
+// JobOptions controls how a job is queued
+type JobOptions struct {
+	// DedupeKey skips queueing when a pending job with the same key exists
+	DedupeKey string
+	// Priority orders runnable jobs; higher runs first
+	Priority int
+	// Delay postpones the first attempt
+	Delay time.Duration
+}
+
+// jobDeferral is returned by a handler that cannot run yet, e.g. because a concurrency
+// limit is reached. The job runs again after the delay without counting the attempt.
+type jobDeferral struct {
+	delay  time.Duration
+	reason string
+}
+
+func (d *jobDeferral) Error() string {
+	return "job deferred: " + d.reason
+}
+
+// DeferJob returns an error that makes the queue run the job again after delay
+// without counting the attempt
+func DeferJob(delay time.Duration, reason string) error {
+	return &jobDeferral{delay: delay, reason: reason}
+}
+
+// jobDefinition is a registered job type
+type jobDefinition struct {
+	handler JobHandler
+	timeout time.Duration // Zero means the job may run until shutdown
+}
+
+// claimedJob is a job a worker has locked for execution
+type claimedJob struct {
+	id       string
+	jobType  string
+	payload  json.RawMessage
+	attempts int
+}
+
+// JobQueue is a Postgres-backed queue of background work. Any instance can queue jobs;
+// the instance running as a worker executes them with the handlers registered on it.
+type JobQueue struct {
+	db       database.Database
+	logger   *utils.Logger
+	config   *config.Config
+	workerID string
+
+	mu       sync.RWMutex
+	handlers map[string]jobDefinition
+}
+
+// NewJobQueue creates a new job queue
+func NewJobQueue(db database.Database, cfg *config.Config, logger *utils.Logger) *JobQueue {
+	hostname, _ := os.Hostname()
+
+	return &JobQueue{
+		db:       db,
+		logger:   logger.WithComponent("job_queue"),
+		config:   cfg,
+		workerID: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8]),
+		handlers: make(map[string]jobDefinition),
+	}
+}
+
+const jobColumns = `id, type, payload, status, priority, attempts, run_at, locked_by, locked_at, last_error,
+	dedupe_key, created_at, started_at, completed_at, updated_at`
+
+// Register sets the handler for a job type
+func (q *JobQueue) Register(jobType string, timeout time.Duration, handler JobHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = jobDefinition{handler: handler, timeout: timeout}
+}
+
+// Enqueue adds a job to the queue and returns its ID. With a dedupe key, an empty ID is
+// returned when an equivalent job is already waiting.
+func (q *JobQueue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts JobOptions) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job payload: %w", err)
+	}
+	if payload == nil {
+		data = []byte("{}")
+	}
+
+	jobID := uuid.New().String()
+	result, err := q.db.ExecContext(ctx, `
+		INSERT INTO jobs (id, type, payload, priority, run_at, dedupe_key)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second', $6)
+		ON CONFLICT (dedupe_key) WHERE status = 'pending' DO NOTHING
+	`, jobID, jobType, string(data), opts.Priority, opts.Delay.Seconds(), nullableString(opts.DedupeKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue %s job: %w", jobType, err)
+	}
+
+	if inserted, err := result.RowsAffected(); err == nil && inserted == 0 {
+		return "", nil
+	}
+
+	return jobID, nil
+}
+
+// Wait polls until the job has finished or timeout elapses and returns its last known status
+func (q *JobQueue) Wait(ctx context.Context, jobID string, timeout time.Duration) (string, error) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	for {
+		var status string
+		if err := q.db.QueryRowContext(ctx, "SELECT status FROM jobs WHERE id = $1", jobID).Scan(&status); err != nil {
+			if err == sql.ErrNoRows {
+				return "", fmt.Errorf("job not found")
+			}
+			return "", err
+		}
+		if status == models.JobStatusCompleted || status == models.JobStatusDead {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-deadline:
+			return status, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Name returns the worker name
+func (q *JobQueue) Name() string {
+	return "job_queue"
+}
+
+// Run executes queued jobs until ctx is cancelled. Jobs already running are then
+// given JobDrainTimeout to finish; jobs still running after that are released so
+// another worker picks them up without counting the interrupted attempt.
+func (q *JobQueue) Run(ctx context.Context) {
+	drainCtx, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
+
+	go func() {
+		<-ctx.Done()
+		select {
+		case <-time.After(q.config.JobDrainTimeout):
+			cancelDrain()
+		case <-drainCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.config.JobWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, drainCtx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runPeriodically(ctx, time.Hour, q.logger, q.purgeCompleted)
+	}()
+
+	wg.Wait()
+}
+
+// work claims and executes jobs one at a time until ctx is cancelled
+func (q *JobQueue) work(ctx, drainCtx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.claim(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				q.logger.Error().Err(err).Msg("Failed to claim job")
+			}
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(q.config.JobPollInterval):
+			}
+			continue
+		}
+
+		q.execute(drainCtx, job)
+	}
+}
+
+// claim locks the most urgent runnable job, including running jobs whose worker stopped
+// sending heartbeats
+func (q *JobQueue) claim(ctx context.Context) (*claimedJob, error) {
+	var job claimedJob
+	var payload []byte
+	err := q.db.QueryRowContext(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_by = $1, locked_at = NOW(),
+		    started_at = COALESCE(started_at, NOW())
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+			   OR (status = 'running' AND locked_at < NOW() - $2 * INTERVAL '1 second')
+			ORDER BY priority DESC, run_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, attempts
+	`, q.workerID, q.config.JobLockTimeout.Seconds()).Scan(&job.id, &job.jobType, &payload, &job.attempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job.payload = payload
+	return &job, nil
+}
+
+// execute runs a claimed job and records the outcome
+func (q *JobQueue) execute(drainCtx context.Context, job *claimedJob) {
+	logger := q.logger.With().Str("job_id", job.id).Str("job_type", job.jobType).Int("attempt", job.attempts).Logger()
+
+	q.mu.RLock()
+	definition, ok := q.handlers[job.jobType]
+	q.mu.RUnlock()
+	if !ok {
+		q.finish(job, fmt.Errorf("no handler registered for job type %s", job.jobType), true)
+		return
+	}
+
+	var jobCtx context.Context
+	var cancel context.CancelFunc
+	if definition.timeout > 0 {
+		jobCtx, cancel = context.WithTimeout(drainCtx, definition.timeout)
+	} else {
+		jobCtx, cancel = context.WithCancel(drainCtx)
+	}
+	defer cancel()
+
+	stopHeartbeat := q.heartbeat(job)
+	started := time.Now()
+	err := q.runHandler(jobCtx, definition.handler, job.payload)
+	stopHeartbeat()
+
+	if err != nil && drainCtx.Err() != nil {
+		// Interrupted by shutdown rather than failed: hand the job back untouched
+		q.release(job, 0)
+		logger.Warn().Msg("Job interrupted by shutdown, released for another worker")
+		return
+	}
+
+	var deferral *jobDeferral
+	if errors.As(err, &deferral) {
+		q.release(job, deferral.delay)
+		logger.Debug().Str("reason", deferral.reason).Dur("delay", deferral.delay).Msg("Job deferred")
+		return
+	}
+
+	if err != nil {
+		logger.Warn().Err(err).Dur("duration", time.Since(started)).Msg("Job failed")
+	} else {
+		logger.Debug().Dur("duration", time.Since(started)).Msg("Job completed")
+	}
+	q.finish(job, err, false)
+}
+
+// runHandler calls the handler, turning a panic into a job failure
+func (q *JobQueue) runHandler(ctx context.Context, handler JobHandler, payload json.RawMessage) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+// heartbeat keeps the job's lock fresh while it runs and returns a function stopping it
+func (q *JobQueue) heartbeat(job *claimedJob) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(q.config.JobLockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				_, err := q.db.ExecContext(ctx,
+					"UPDATE jobs SET locked_at = NOW() WHERE id = $1 AND locked_by = $2 AND status = 'running'",
+					job.id, q.workerID,
+				)
+				cancel()
+				if err != nil {
+					q.logger.Warn().Err(err).Str("job_id", job.id).Msg("Failed to refresh job lock")
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// finish marks a job completed, schedules a retry with exponential backoff or dead-letters it
+func (q *JobQueue) finish(job *claimedJob, jobErr error, permanent bool) {
+	// Use a fresh context so the outcome is recorded even while shutting down
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var err error
+	switch {
+	case jobErr == nil:
+		_, err = q.db.ExecContext(ctx, `
+			UPDATE jobs SET status = 'completed', locked_by = NULL, locked_at = NULL, completed_at = NOW()
+			WHERE id = $1 AND locked_by = $2
+		`, job.id, q.workerID)
+	case permanent || job.attempts >= q.config.JobMaxAttempts:
+		_, err = q.db.ExecContext(ctx, `
+			UPDATE jobs SET status = 'dead', locked_by = NULL, locked_at = NULL, last_error = $1, completed_at = NOW()
+			WHERE id = $2 AND locked_by = $3
+		`, jobErr.Error(), job.id, q.workerID)
+		q.logger.Error().Err(jobErr).
+			Str("job_id", job.id).
+			Str("job_type", job.jobType).
+			Int("attempts", job.attempts).
+			Msg("Job dead-lettered")
+	default:
+		delay := q.retryDelay(job.attempts)
+		_, err = q.db.ExecContext(ctx, `
+			UPDATE jobs
+			SET status = 'pending', locked_by = NULL, locked_at = NULL, last_error = $1,
+			    run_at = NOW() + $2 * INTERVAL '1 second'
+			WHERE id = $3 AND locked_by = $4
+		`, jobErr.Error(), delay.Seconds(), job.id, q.workerID)
+		if isUniqueViolation(err) {
+			err = q.supersede(ctx, job)
+		}
+	}
+	if err != nil {
+		q.logger.Error().Err(err).Str("job_id", job.id).Msg("Failed to record job outcome")
+	}
+}
+
+// supersede drops a job that cannot go back to pending because an equivalent job
+// with the same dedupe key was queued while it ran; that job does the work instead
+func (q *JobQueue) supersede(ctx context.Context, job *claimedJob) error {
+	_, err := q.db.ExecContext(ctx, "DELETE FROM jobs WHERE id = $1 AND locked_by = $2", job.id, q.workerID)
+	if err == nil {
+		q.logger.Info().Str("job_id", job.id).Str("job_type", job.jobType).Msg("Job superseded by an equivalent pending job")
+	}
+	return err
+}
+
+// release hands a job interrupted by shutdown or deferred by its handler back to the
+// queue without counting the attempt
+func (q *JobQueue) release(job *claimedJob, delay time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'pending', attempts = GREATEST(attempts - 1, 0), locked_by = NULL, locked_at = NULL,
+		    run_at = NOW() + $3 * INTERVAL '1 second'
+		WHERE id = $1 AND locked_by = $2
+	`, job.id, q.workerID, delay.Seconds())
+	if isUniqueViolation(err) {
+		err = q.supersede(ctx, job)
+	}
+	if err != nil {
+		q.logger.Error().Err(err).Str("job_id", job.id).Msg("Failed to release job")
+	}
+}
+
+// retryDelay returns the backoff before the next attempt: the base delay doubled
+// for every failed attempt, capped at the maximum delay
+func (q *JobQueue) retryDelay(attempts int) time.Duration {
+	delay := q.config.JobRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= q.config.JobRetryMaxDelay {
+			return q.config.JobRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// purgeCompleted deletes completed jobs older than the retention period
+func (q *JobQueue) purgeCompleted(ctx context.Context) error {
+	result, err := q.db.ExecContext(ctx,
+		"DELETE FROM jobs WHERE status = 'completed' AND completed_at < NOW() - $1 * INTERVAL '1 second'",
+		q.config.JobRetention.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to purge completed jobs: %w", err)
+	}
+	if purged, err := result.RowsAffected(); err == nil && purged > 0 {
+		q.logger.Info().Int64("jobs", purged).Msg("Purged completed jobs")
+	}
+	return nil
+}
+
+// ListJobs retrieves jobs, optionally filtered by status and type, newest first
+func (q *JobQueue) ListJobs(ctx context.Context, status, jobType string, limit, offset int) ([]models.Job, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT `+jobColumns+`
+		FROM jobs
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR type = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4
+	`, status, jobType, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// CountJobs returns the number of jobs per status
+func (q *JobQueue) CountJobs(ctx context.Context) (map[string]int, error) {
+	counts := map[string]int{
+		models.JobStatusPending:   0,
+		models.JobStatusRunning:   0,
+		models.JobStatusCompleted: 0,
+		models.JobStatusDead:      0,
+	}
+
+	rows, err := q.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM jobs GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan job count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetJob retrieves a job by ID
+func (q *JobQueue) GetJob(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := scanJob(q.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", jobID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("job not found")
+	}
+	return job, err
+}
+
+// RetryJob runs a dead-lettered or waiting job again as soon as possible. Dead jobs get
+// a fresh set of attempts.
+func (q *JobQueue) RetryJob(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := q.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobStatusDead && job.Status != models.JobStatusPending {
+		return nil, fmt.Errorf("job cannot be retried")
+	}
+
+	_, err = q.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'pending', run_at = NOW(), completed_at = NULL,
+		    attempts = CASE WHEN status = 'dead' THEN 0 ELSE attempts END
+		WHERE id = $1 AND status IN ('pending', 'dead')
+	`, jobID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("an equivalent job is already pending")
+		}
+		return nil, fmt.Errorf("failed to retry job: %w", err)
+	}
+
+	q.logger.Info().Str("job_id", jobID).Str("job_type", job.Type).Msg("Job queued for retry")
+
+	return q.GetJob(ctx, jobID)
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// scanJob scans a jobs row
+func scanJob(row rowScanner) (*models.Job, error) {
+	var job models.Job
+	var payload []byte
+	var lockedBy, lastError, dedupeKey sql.NullString
+	var lockedAt, startedAt, completedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Type, &payload, &job.Status, &job.Priority, &job.Attempts, &job.RunAt, &lockedBy,
+		&lockedAt, &lastError, &dedupeKey, &job.CreatedAt, &startedAt, &completedAt, &job.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan job: %w", err)
+	}
+
+	job.Payload = payload
+	job.LockedBy = lockedBy.String
+	job.LastError = lastError.String
+	job.DedupeKey = dedupeKey.String
+	if lockedAt.Valid {
+		job.LockedAt = &lockedAt.Time
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+
+	return &job, nil
+}
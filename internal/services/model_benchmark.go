@@ -0,0 +1,757 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// benchmarkChatRetry is how long a benchmark waits for chats to finish before trying again
+	benchmarkChatRetry = 30 * time.Second
+	// benchmarkSlotRetry is how long a benchmark waits for another benchmark to finish
+	benchmarkSlotRetry = time.Minute
+	// modelBenchmarkTimeout bounds a whole benchmark, loading the model included
+	modelBenchmarkTimeout = time.Hour
+	// maxBenchmarkRuns limits how many times each prompt of a benchmark runs
+	maxBenchmarkRuns = 10
+	// maxBenchmarkPrompts limits the size of a prompt suite
+	maxBenchmarkPrompts = 50
+)
+
+// errBenchmarkInterrupted stops a benchmark when a chat starts while it runs, so the
+// chat does not skew the measurements
+var errBenchmarkInterrupted = errors.New("benchmark interrupted by a chat")
+
+// DefaultBenchmarkSuite is the prompt suite used when BENCHMARK_SUITE_FILE is not set: a
+// short answer, longer generations and a prompt heavy on input for prompt processing
+var DefaultBenchmarkSuite = []models.BenchmarkPrompt{
+	{
+		Name:   "short-answer",
+		Prompt: "What is the capital of Australia? Answer in one sentence.",
+	},
+	{
+		Name:   "explanation",
+		Prompt: "Explain how a hash map works, including how it handles collisions and when it resizes.",
+	},
+	{
+		Name:   "code",
+		System: "You are a senior Go developer. Answer with code and brief comments.",
+		Prompt: "Write a Go function that merges two sorted slices of integers into one sorted slice.",
+	},
+	{
+		Name: "summary",
+		Prompt: "Summarize the following text in three bullet points.\n\n" +
+			"The city council met on Tuesday to discuss the proposed expansion of the public library. " +
+			"Supporters argued that the current building, opened more than forty years ago, no longer " +
+			"has room for the growing collection, the after-school programs or the study spaces students " +
+			"ask for during exams. They pointed to a survey in which most residents said they would use " +
+			"the library more often if it opened in the evenings and on Sundays. Opponents did not dispute " +
+			"the need but questioned the cost, which the latest estimate puts at twice the figure given " +
+			"when the project was first announced, and asked whether renovating two smaller branch " +
+			"libraries would serve outlying neighbourhoods better. The council's finance committee noted " +
+			"that a state grant could cover a third of the cost if the application is filed before the " +
+			"end of the year. After three hours of debate the council voted to commission an independent " +
+			"review of both options, to be presented at the first meeting of the next quarter, and asked " +
+			"the library board to prepare the grant application in the meantime so the deadline is not missed.",
+	},
+}
+
+// modelBenchmarkPayload is the payload of a model benchmark job
+type modelBenchmarkPayload struct {
+	BenchmarkID string `json:"benchmark_id"`
+}
+
+const modelBenchmarkColumns = `id, model_id, model_name, job_id, host, status, prompts, runs, max_tokens,
+	results, tokens_per_second, prompt_tokens_per_second, time_to_first_token_ms, load_ms, error,
+	created_at, started_at, completed_at, updated_at`
+
+// ModelBenchmarkService measures how fast models run on the Ollama hosts: a prompt suite
+// is run against a model and the generation speed, time to first token, load time and
+// prompt processing speed are recorded, so quantizations and models can be compared.
+// Benchmarks run on the job queue one at a time and wait while chats are in progress on
+// any instance.
+type ModelBenchmarkService struct {
+	db           database.Database
+	models       *ModelManager
+	ollamaClient *OllamaClient
+	activity     *ChatActivity
+	jobs         *JobQueue
+	config       *config.Config
+	suite        []models.BenchmarkPrompt
+	logger       *utils.Logger
+}
+
+// NewModelBenchmarkService creates a new model benchmark service. The prompt suite is read
+// from BENCHMARK_SUITE_FILE when set, falling back to the built-in suite if it is invalid.
+func NewModelBenchmarkService(db database.Database, modelManager *ModelManager, ollamaClient *OllamaClient, cfg *config.Config, logger *utils.Logger) *ModelBenchmarkService {
+	s := &ModelBenchmarkService{
+		db:           db,
+		models:       modelManager,
+		ollamaClient: ollamaClient,
+		activity:     NewChatActivity(db, ollamaClient, logger),
+		config:       cfg,
+		suite:        DefaultBenchmarkSuite,
+		logger:       logger.WithComponent("model_benchmark"),
+	}
+
+	if cfg.BenchmarkSuiteFile != "" {
+		suite, err := LoadBenchmarkSuite(cfg.BenchmarkSuiteFile)
+		if err != nil {
+			s.logger.Error().Err(err).Str("file", cfg.BenchmarkSuiteFile).Msg("Invalid benchmark suite, using the built-in suite")
+		} else {
+			s.suite = suite
+		}
+	}
+
+	return s
+}
+
+// SetJobQueue sets the queue benchmarks are started on
+func (s *ModelBenchmarkService) SetJobQueue(queue *JobQueue) {
+	s.jobs = queue
+}
+
+// RegisterJobs registers the model benchmark job handler on the queue
+func (s *ModelBenchmarkService) RegisterJobs(queue *JobQueue) {
+	s.jobs = queue
+	queue.Register(JobTypeModelBenchmark, modelBenchmarkTimeout, s.handleModelBenchmark)
+}
+
+// LoadBenchmarkSuite reads a prompt suite from a file holding a list of prompts. Files
+// ending in .yaml or .yml are read as YAML, others as JSON.
+func LoadBenchmarkSuite(path string) ([]models.BenchmarkPrompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark suite: %w", err)
+	}
+
+	var suite []models.BenchmarkPrompt
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &suite)
+	default:
+		err = json.Unmarshal(data, &suite)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid benchmark suite: %w", err)
+	}
+
+	return normalizeBenchmarkPrompts(suite)
+}
+
+// normalizeBenchmarkPrompts checks a prompt suite and names the prompts without a name
+// after their position
+func normalizeBenchmarkPrompts(prompts []models.BenchmarkPrompt) ([]models.BenchmarkPrompt, error) {
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("prompts must not be empty")
+	}
+	if len(prompts) > maxBenchmarkPrompts {
+		return nil, fmt.Errorf("prompts must not have more than %d entries", maxBenchmarkPrompts)
+	}
+
+	normalized := make([]models.BenchmarkPrompt, 0, len(prompts))
+	seen := make(map[string]bool, len(prompts))
+	for i, prompt := range prompts {
+		prompt.Name = strings.TrimSpace(prompt.Name)
+		if prompt.Name == "" {
+			prompt.Name = fmt.Sprintf("prompt-%d", i+1)
+		}
+		if strings.TrimSpace(prompt.Prompt) == "" {
+			return nil, fmt.Errorf("prompt %s has no text", prompt.Name)
+		}
+		if seen[prompt.Name] {
+			return nil, fmt.Errorf("prompt %s appears more than once", prompt.Name)
+		}
+		seen[prompt.Name] = true
+		normalized = append(normalized, prompt)
+	}
+
+	return normalized, nil
+}
+
+// QueueBenchmark records a benchmark of a model and queues the job running it
+func (s *ModelBenchmarkService) QueueBenchmark(ctx context.Context, modelID string, req models.ModelBenchmarkRequest) (*models.ModelBenchmark, error) {
+	model, err := s.models.GetModelByID(ctx, modelID)
+	if err != nil {
+		return nil, err
+	}
+	if model.Status != "available" {
+		return nil, fmt.Errorf("model is not available")
+	}
+	if len(model.Capabilities) > 0 && !model.HasCapability(models.ModelCapabilityCompletion) {
+		return nil, fmt.Errorf("model cannot generate text")
+	}
+
+	if req.Runs == 0 {
+		req.Runs = 1
+	}
+	if req.Runs < 0 || req.Runs > maxBenchmarkRuns {
+		return nil, fmt.Errorf("runs must be between 1 and %d", maxBenchmarkRuns)
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = s.config.BenchmarkMaxTokens
+	}
+	if req.MaxTokens < 0 {
+		return nil, fmt.Errorf("max_tokens must be positive")
+	}
+
+	prompts := s.suite
+	if len(req.Prompts) > 0 {
+		if prompts, err = normalizeBenchmarkPrompts(req.Prompts); err != nil {
+			return nil, err
+		}
+	}
+	promptsJSON, err := json.Marshal(prompts)
+	if err != nil {
+		return nil, err
+	}
+
+	benchmark, err := scanModelBenchmark(s.db.QueryRowContext(ctx, `
+		INSERT INTO model_benchmarks (id, model_id, model_name, prompts, runs, max_tokens)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+modelBenchmarkColumns,
+		uuid.New().String(), model.ID, model.Name, promptsJSON, req.Runs, req.MaxTokens))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record benchmark: %w", err)
+	}
+
+	jobID, err := s.jobs.Enqueue(ctx, JobTypeModelBenchmark, modelBenchmarkPayload{BenchmarkID: benchmark.ID}, JobOptions{
+		DedupeKey: JobTypeModelBenchmark + ":" + benchmark.ID,
+	})
+	if err != nil {
+		s.finishBenchmark(ctx, benchmark, models.BenchmarkStatusFailed, "failed to queue benchmark")
+		return nil, fmt.Errorf("failed to queue benchmark: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "UPDATE model_benchmarks SET job_id = $1 WHERE id = $2", jobID, benchmark.ID); err != nil {
+		return nil, fmt.Errorf("failed to record benchmark job: %w", err)
+	}
+	benchmark.JobID = jobID
+
+	s.logger.Info().
+		Str("model", model.Name).
+		Str("benchmark_id", benchmark.ID).
+		Int("prompts", len(prompts)).
+		Int("runs", req.Runs).
+		Msg("Model benchmark queued")
+
+	return benchmark, nil
+}
+
+// GetBenchmark returns a benchmark by ID
+func (s *ModelBenchmarkService) GetBenchmark(ctx context.Context, id string) (*models.ModelBenchmark, error) {
+	benchmark, err := scanModelBenchmark(s.db.QueryRowContext(ctx,
+		"SELECT "+modelBenchmarkColumns+" FROM model_benchmarks WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("benchmark not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get benchmark: %w", err)
+	}
+	return benchmark, nil
+}
+
+// ListBenchmarks returns the benchmarks of a model, newest first
+func (s *ModelBenchmarkService) ListBenchmarks(ctx context.Context, modelID string, limit int) ([]models.ModelBenchmark, error) {
+	if _, err := s.models.GetModelByID(ctx, modelID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+modelBenchmarkColumns+`
+		FROM model_benchmarks
+		WHERE model_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, modelID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query benchmarks: %w", err)
+	}
+	defer rows.Close()
+
+	benchmarks := []models.ModelBenchmark{}
+	for rows.Next() {
+		benchmark, err := scanModelBenchmark(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan benchmark: %w", err)
+		}
+		benchmarks = append(benchmarks, *benchmark)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query benchmarks: %w", err)
+	}
+
+	return benchmarks, nil
+}
+
+// benchmarkMetrics lists the metrics models are ranked on, with whether higher is better
+var benchmarkMetrics = []struct {
+	name           string
+	higherIsBetter bool
+	value          func(r *models.ModelBenchmarkRanking) float64
+}{
+	{models.BenchmarkMetricTokensPerSecond, true, func(r *models.ModelBenchmarkRanking) float64 { return r.TokensPerSecond }},
+	{models.BenchmarkMetricPromptTokensPerSecond, true, func(r *models.ModelBenchmarkRanking) float64 { return r.PromptTokensPerSecond }},
+	{models.BenchmarkMetricTimeToFirstToken, false, func(r *models.ModelBenchmarkRanking) float64 { return r.TimeToFirstTokenMs }},
+	{models.BenchmarkMetricLoadTime, false, func(r *models.ModelBenchmarkRanking) float64 { return r.LoadMs }},
+}
+
+// CompareModels ranks models by their latest completed benchmark on every metric and
+// orders them by their rank on metric. Without model IDs every benchmarked model is ranked.
+func (s *ModelBenchmarkService) CompareModels(ctx context.Context, metric string, modelIDs []string) ([]models.ModelBenchmarkRanking, error) {
+	if metric == "" {
+		metric = models.BenchmarkMetricTokensPerSecond
+	}
+	known := false
+	for _, m := range benchmarkMetrics {
+		known = known || m.name == metric
+	}
+	if !known {
+		return nil, fmt.Errorf("sort must be one of tokens_per_second, prompt_tokens_per_second, time_to_first_token or load_time")
+	}
+	if modelIDs == nil {
+		modelIDs = []string{}
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (b.model_id)
+		       b.id, b.model_id, m.name, m.quantization, m.parameters, b.host,
+		       b.tokens_per_second, b.prompt_tokens_per_second, b.time_to_first_token_ms, b.load_ms,
+		       b.completed_at
+		FROM model_benchmarks b
+		JOIN models m ON m.id = b.model_id
+		WHERE b.status = 'completed'
+		  AND (cardinality($1::text[]) = 0 OR b.model_id = ANY($1::text[]))
+		ORDER BY b.model_id, b.completed_at DESC
+	`, pq.Array(modelIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query benchmarks: %w", err)
+	}
+	defer rows.Close()
+
+	rankings := []models.ModelBenchmarkRanking{}
+	for rows.Next() {
+		var ranking models.ModelBenchmarkRanking
+		var tokensPerSecond, promptTokensPerSecond, timeToFirstToken, load sql.NullFloat64
+		var completedAt sql.NullTime
+		err := rows.Scan(
+			&ranking.BenchmarkID, &ranking.ModelID, &ranking.ModelName, &ranking.Quantization,
+			&ranking.Parameters, &ranking.Host, &tokensPerSecond, &promptTokensPerSecond,
+			&timeToFirstToken, &load, &completedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan benchmark: %w", err)
+		}
+		ranking.TokensPerSecond = tokensPerSecond.Float64
+		ranking.PromptTokensPerSecond = promptTokensPerSecond.Float64
+		ranking.TimeToFirstTokenMs = timeToFirstToken.Float64
+		ranking.LoadMs = load.Float64
+		if completedAt.Valid {
+			ranking.CompletedAt = &completedAt.Time
+		}
+		ranking.Ranks = make(map[string]int, len(benchmarkMetrics))
+		rankings = append(rankings, ranking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query benchmarks: %w", err)
+	}
+
+	// Rank on every metric; models with equal values share a rank
+	for _, m := range benchmarkMetrics {
+		better := func(i, j int) bool {
+			if m.higherIsBetter {
+				return m.value(&rankings[i]) > m.value(&rankings[j])
+			}
+			return m.value(&rankings[i]) < m.value(&rankings[j])
+		}
+		sort.SliceStable(rankings, better)
+		for i := range rankings {
+			rank := i + 1
+			if i > 0 && m.value(&rankings[i]) == m.value(&rankings[i-1]) {
+				rank = rankings[i-1].Ranks[m.name]
+			}
+			rankings[i].Ranks[m.name] = rank
+		}
+	}
+
+	sort.SliceStable(rankings, func(i, j int) bool {
+		if rankings[i].Ranks[metric] != rankings[j].Ranks[metric] {
+			return rankings[i].Ranks[metric] < rankings[j].Ranks[metric]
+		}
+		return rankings[i].ModelName < rankings[j].ModelName
+	})
+	for i := range rankings {
+		rankings[i].Rank = rankings[i].Ranks[metric]
+	}
+
+	return rankings, nil
+}
+
+// handleModelBenchmark runs a queued benchmark once no chat is in progress and no other
+// benchmark runs. A benchmark interrupted by a chat starts over later; a failed one is
+// recorded and not retried.
+func (s *ModelBenchmarkService) handleModelBenchmark(ctx context.Context, raw json.RawMessage) error {
+	var payload modelBenchmarkPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Errorf("invalid model benchmark payload: %w", err)
+	}
+
+	benchmark, err := s.GetBenchmark(ctx, payload.BenchmarkID)
+	if err != nil {
+		if err.Error() == "benchmark not found" {
+			// The model was deleted with its benchmarks
+			return nil
+		}
+		return err
+	}
+	if benchmark.Status == models.BenchmarkStatusCompleted || benchmark.Status == models.BenchmarkStatusFailed {
+		return nil
+	}
+
+	if chats, err := s.activity.ActiveChats(ctx); err != nil {
+		return err
+	} else if chats > 0 {
+		return DeferJob(benchmarkChatRetry, "chats in progress")
+	}
+	claimed, err := s.claimBenchmarkSlot(ctx, benchmark.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return DeferJob(benchmarkSlotRetry, "another benchmark is running")
+	}
+
+	err = s.runBenchmark(ctx, benchmark)
+	switch {
+	case errors.Is(err, errBenchmarkInterrupted):
+		s.requeueBenchmark(benchmark.ID)
+		return DeferJob(benchmarkChatRetry, "chat started during the benchmark")
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		// Shutting down: the queue hands the job to another worker, which starts over
+		s.requeueBenchmark(benchmark.ID)
+		return err
+	case err != nil:
+		s.logger.Warn().Err(err).Str("benchmark_id", benchmark.ID).Str("model", benchmark.ModelName).Msg("Model benchmark failed")
+		s.finishBenchmark(context.Background(), benchmark, models.BenchmarkStatusFailed, err.Error())
+		return nil
+	}
+
+	s.finishBenchmark(ctx, benchmark, models.BenchmarkStatusCompleted, "")
+	s.logger.Info().
+		Str("benchmark_id", benchmark.ID).
+		Str("model", benchmark.ModelName).
+		Str("host", benchmark.Host).
+		Float64("tokens_per_second", benchmark.TokensPerSecond).
+		Float64("time_to_first_token_ms", benchmark.TimeToFirstTokenMs).
+		Float64("load_ms", benchmark.LoadMs).
+		Msg("Model benchmark completed")
+	return nil
+}
+
+// runBenchmark loads the model from cold on one host, then runs every prompt of the suite
+// the requested number of times, saving the results as they come in
+func (s *ModelBenchmarkService) runBenchmark(ctx context.Context, benchmark *models.ModelBenchmark) error {
+	model, err := s.models.GetModelByID(ctx, benchmark.ModelID)
+	if err != nil {
+		return err
+	}
+	if model.Status != "available" {
+		return fmt.Errorf("model is not available")
+	}
+
+	// Every request goes to the same host, so the results describe one machine
+	ctx, host := s.ollamaClient.PinHost(ctx, model.Name)
+	benchmark.Host = host
+	benchmark.Results = []models.BenchmarkPromptResult{}
+
+	// Unload the model first so the load time is measured from cold. Chats may have
+	// started on any instance since the job checked, and unloading would stall them.
+	if err := s.checkNoChats(ctx); err != nil {
+		return err
+	}
+	if err := s.ollamaClient.UnloadModel(ctx, model.Name, false); err != nil {
+		s.logger.Warn().Err(err).Str("model", model.Name).Msg("Failed to unload model before benchmark")
+	}
+	started := time.Now()
+	if err := s.ollamaClient.LoadModel(ctx, model.Name, "", false); err != nil {
+		return err
+	}
+	benchmark.LoadMs = milliseconds(time.Since(started))
+
+	for run := 1; run <= benchmark.Runs; run++ {
+		for _, prompt := range benchmark.Prompts {
+			if err := s.checkNoChats(ctx); err != nil {
+				return err
+			}
+
+			result, err := s.runPrompt(ctx, model.Name, prompt, benchmark.MaxTokens)
+			if err != nil {
+				return fmt.Errorf("prompt %s failed: %w", prompt.Name, err)
+			}
+			result.Run = run
+			benchmark.Results = append(benchmark.Results, *result)
+
+			if err := s.saveBenchmarkProgress(ctx, benchmark); err != nil {
+				return err
+			}
+		}
+	}
+
+	summarizeBenchmark(benchmark)
+	return nil
+}
+
+// checkNoChats returns errBenchmarkInterrupted when chats are in progress on any instance
+func (s *ModelBenchmarkService) checkNoChats(ctx context.Context) error {
+	chats, err := s.activity.ActiveChats(ctx)
+	if err != nil {
+		return err
+	}
+	if chats > 0 {
+		return errBenchmarkInterrupted
+	}
+	return nil
+}
+
+// runPrompt generates a response to one prompt and measures it. Sampling is made
+// deterministic so runs of the same prompt generate comparable responses.
+func (s *ModelBenchmarkService) runPrompt(ctx context.Context, modelName string, prompt models.BenchmarkPrompt, maxTokens int) (*models.BenchmarkPromptResult, error) {
+	req := OllamaGenerateRequest{
+		Model:  modelName,
+		Prompt: prompt.Prompt,
+		System: prompt.System,
+		Options: map[string]interface{}{
+			"num_predict": maxTokens,
+			"temperature": 0,
+			"seed":        42,
+		},
+	}
+
+	var firstToken time.Duration
+	started := time.Now()
+	resp, err := s.ollamaClient.GenerateStream(ctx, req, func(string) {
+		if firstToken == 0 {
+			firstToken = time.Since(started)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.BenchmarkPromptResult{
+		Prompt:             prompt.Name,
+		PromptTokens:       resp.PromptEvalCount,
+		OutputTokens:       resp.EvalCount,
+		TimeToFirstTokenMs: milliseconds(firstToken),
+		TotalMs:            milliseconds(time.Since(started)),
+	}
+	if resp.EvalDuration > 0 {
+		result.TokensPerSecond = float64(resp.EvalCount) / time.Duration(resp.EvalDuration).Seconds()
+	}
+	if resp.PromptEvalDuration > 0 {
+		result.PromptTokensPerSecond = float64(resp.PromptEvalCount) / time.Duration(resp.PromptEvalDuration).Seconds()
+	}
+	return result, nil
+}
+
+// summarizeBenchmark averages the results of a benchmark into its metrics. Results
+// without a measurement, e.g. a prompt Ollama had cached, are left out of that average.
+func summarizeBenchmark(benchmark *models.ModelBenchmark) {
+	var tokensPerSecond, promptTokensPerSecond, timeToFirstToken []float64
+	for _, result := range benchmark.Results {
+		if result.TokensPerSecond > 0 {
+			tokensPerSecond = append(tokensPerSecond, result.TokensPerSecond)
+		}
+		if result.PromptTokensPerSecond > 0 {
+			promptTokensPerSecond = append(promptTokensPerSecond, result.PromptTokensPerSecond)
+		}
+		if result.TimeToFirstTokenMs > 0 {
+			timeToFirstToken = append(timeToFirstToken, result.TimeToFirstTokenMs)
+		}
+	}
+
+	benchmark.TokensPerSecond = mean(tokensPerSecond)
+	benchmark.PromptTokensPerSecond = mean(promptTokensPerSecond)
+	benchmark.TimeToFirstTokenMs = mean(timeToFirstToken)
+}
+
+// mean returns the average of values, or 0 when there are none
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// claimBenchmarkSlot marks a benchmark as running unless another benchmark runs. A
+// benchmark holds the slot while its job holds its lock, which the queue refreshes even
+// while a large model loads; benchmarks of crashed workers do not hold it.
+func (s *ModelBenchmarkService) claimBenchmarkSlot(ctx context.Context, benchmarkID string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise claims across instances
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('model_benchmarks'))"); err != nil {
+		return false, fmt.Errorf("failed to lock benchmark slot: %w", err)
+	}
+
+	var running int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM model_benchmarks b
+		JOIN jobs j ON j.id = b.job_id
+		WHERE b.status = 'running' AND b.id <> $1
+		  AND j.status = 'running' AND j.locked_at > NOW() - $2 * INTERVAL '1 second'
+	`, benchmarkID, s.config.JobLockTimeout.Seconds()).Scan(&running)
+	if err != nil {
+		return false, fmt.Errorf("failed to count running benchmarks: %w", err)
+	}
+	if running > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE model_benchmarks
+		SET status = 'running', results = '[]', error = NULL, started_at = NOW()
+		WHERE id = $1
+	`, benchmarkID); err != nil {
+		return false, fmt.Errorf("failed to start benchmark: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// saveBenchmarkProgress persists the results of a running benchmark so far
+func (s *ModelBenchmarkService) saveBenchmarkProgress(ctx context.Context, benchmark *models.ModelBenchmark) error {
+	results, err := json.Marshal(benchmark.Results)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE model_benchmarks SET host = $1, results = $2, load_ms = $3
+		WHERE id = $4 AND status = 'running'
+	`, benchmark.Host, results, benchmark.LoadMs, benchmark.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save benchmark progress: %w", err)
+	}
+	return nil
+}
+
+// requeueBenchmark puts a benchmark that was stopped before it finished back in the
+// queued state, discarding its partial results
+func (s *ModelBenchmarkService) requeueBenchmark(benchmarkID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE model_benchmarks SET status = 'queued', results = '[]', load_ms = NULL, started_at = NULL
+		WHERE id = $1 AND status = 'running'
+	`, benchmarkID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("benchmark_id", benchmarkID).Msg("Failed to requeue benchmark")
+	}
+}
+
+// finishBenchmark records the outcome of a benchmark
+func (s *ModelBenchmarkService) finishBenchmark(ctx context.Context, benchmark *models.ModelBenchmark, status, failure string) {
+	benchmark.Status = status
+	benchmark.Error = failure
+	now := time.Now()
+	benchmark.CompletedAt = &now
+
+	results, err := json.Marshal(benchmark.Results)
+	if err != nil {
+		s.logger.Error().Err(err).Str("benchmark_id", benchmark.ID).Msg("Failed to encode benchmark results")
+		return
+	}
+
+	// Metrics are only recorded for completed benchmarks, so failed ones never rank
+	var tokensPerSecond, promptTokensPerSecond, timeToFirstToken, load sql.NullFloat64
+	if status == models.BenchmarkStatusCompleted {
+		tokensPerSecond = sql.NullFloat64{Float64: benchmark.TokensPerSecond, Valid: true}
+		promptTokensPerSecond = sql.NullFloat64{Float64: benchmark.PromptTokensPerSecond, Valid: true}
+		timeToFirstToken = sql.NullFloat64{Float64: benchmark.TimeToFirstTokenMs, Valid: true}
+		load = sql.NullFloat64{Float64: benchmark.LoadMs, Valid: true}
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE model_benchmarks
+		SET status = $1, error = NULLIF($2, ''), host = $3, results = $4, tokens_per_second = $5,
+		    prompt_tokens_per_second = $6, time_to_first_token_ms = $7, load_ms = $8, completed_at = NOW()
+		WHERE id = $9
+	`, status, failure, benchmark.Host, results, tokensPerSecond, promptTokensPerSecond,
+		timeToFirstToken, load, benchmark.ID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("benchmark_id", benchmark.ID).Msg("Failed to record benchmark outcome")
+	}
+}
+
+// scanModelBenchmark scans a row of modelBenchmarkColumns
+func scanModelBenchmark(row rowScanner) (*models.ModelBenchmark, error) {
+	var benchmark models.ModelBenchmark
+	var jobID, failure sql.NullString
+	var prompts, results []byte
+	var tokensPerSecond, promptTokensPerSecond, timeToFirstToken, load sql.NullFloat64
+	var startedAt, completedAt sql.NullTime
+
+	err := row.Scan(
+		&benchmark.ID, &benchmark.ModelID, &benchmark.ModelName, &jobID, &benchmark.Host,
+		&benchmark.Status, &prompts, &benchmark.Runs, &benchmark.MaxTokens, &results,
+		&tokensPerSecond, &promptTokensPerSecond, &timeToFirstToken, &load, &failure,
+		&benchmark.CreatedAt, &startedAt, &completedAt, &benchmark.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	benchmark.JobID = jobID.String
+	benchmark.Error = failure.String
+	benchmark.TokensPerSecond = tokensPerSecond.Float64
+	benchmark.PromptTokensPerSecond = promptTokensPerSecond.Float64
+	benchmark.TimeToFirstTokenMs = timeToFirstToken.Float64
+	benchmark.LoadMs = load.Float64
+	if startedAt.Valid {
+		benchmark.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		benchmark.CompletedAt = &completedAt.Time
+	}
+	if err := json.Unmarshal(prompts, &benchmark.Prompts); err != nil {
+		return nil, fmt.Errorf("invalid benchmark prompts: %w", err)
+	}
+	if err := json.Unmarshal(results, &benchmark.Results); err != nil {
+		return nil, fmt.Errorf("invalid benchmark results: %w", err)
+	}
+	if benchmark.Results == nil {
+		benchmark.Results = []models.BenchmarkPromptResult{}
+	}
+	return &benchmark, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"chat_ollama/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// maxChatImages bounds the images sent with a single chat message
+const maxChatImages = 8
+
+// modelCapabilities is what a model can do, as read from Ollama's /api/show
+type modelCapabilities struct {
+	Capabilities        []string
+	ContextLength       int
+	EmbeddingDimensions int
+	Template            string
+	License             string
+}
+
+// parseModelCapabilities reads a model's capabilities from /api/show. Ollama lists them
+// since 0.6.4; for older versions they are inferred from the model info and template.
+func parseModelCapabilities(info *models.OllamaModelInfo) modelCapabilities {
+	architecture, _ := info.ModelInfo["general.architecture"].(string)
+	caps := modelCapabilities{
+		ContextLength: modelInfoInt(info.ModelInfo, architecture+".context_length"),
+		Template:      info.Template,
+		License:       info.License,
+	}
+
+	caps.Capabilities = lowerStrings(info.Capabilities)
+	if len(caps.Capabilities) == 0 {
+		caps.Capabilities = inferModelCapabilities(info, architecture)
+	}
+
+	for _, capability := range caps.Capabilities {
+		if capability == models.ModelCapabilityEmbedding {
+			caps.EmbeddingDimensions = modelInfoInt(info.ModelInfo, architecture+".embedding_length")
+		}
+	}
+
+	return caps
+}
+
+// inferModelCapabilities guesses the capabilities of a model from an Ollama version that
+// does not report them: embedding models pool their output, vision models have a
+// projector and tool and fill-in-the-middle support shows in the template
+func inferModelCapabilities(info *models.OllamaModelInfo, architecture string) []string {
+	if _, pooled := info.ModelInfo[architecture+".pooling_type"]; pooled || strings.Contains(architecture, "bert") {
+		return []string{models.ModelCapabilityEmbedding}
+	}
+
+	capabilities := []string{models.ModelCapabilityCompletion}
+	if len(info.ProjectorInfo) > 0 || containsFold(info.Details.Families, "clip") || modelInfoHasVision(info.ModelInfo) {
+		capabilities = append(capabilities, models.ModelCapabilityVision)
+	}
+	if strings.Contains(info.Template, ".Tools") {
+		capabilities = append(capabilities, models.ModelCapabilityTools)
+	}
+	if strings.Contains(info.Template, ".Suffix") {
+		capabilities = append(capabilities, models.ModelCapabilityInsert)
+	}
+	return capabilities
+}
+
+// modelInfoHasVision reports whether model info describes a built-in vision encoder
+func modelInfoHasVision(modelInfo map[string]interface{}) bool {
+	for key := range modelInfo {
+		if strings.Contains(key, ".vision.") {
+			return true
+		}
+	}
+	return false
+}
+
+// modelInfoInt reads a number from model info, which JSON decodes as float64
+func modelInfoInt(modelInfo map[string]interface{}, key string) int {
+	if value, ok := modelInfo[key].(float64); ok {
+		return int(value)
+	}
+	return 0
+}
+
+// refreshModelCapabilities reads a model's capabilities from Ollama and records them
+// along with the digest they were read for
+func (m *ModelManager) refreshModelCapabilities(ctx context.Context, id, name, digest string) error {
+	info, err := m.ollamaClient.GetModelInfo(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get model info: %w", err)
+	}
+
+	caps := parseModelCapabilities(info)
+	hasCapability := func(capability string) bool {
+		return containsFold(caps.Capabilities, capability)
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE models
+		SET digest = $1, capabilities = $2, supports_embeddings = $3, embedding_dimensions = $4,
+		    supports_vision = $5, supports_tools = $6, template = $7, license = $8,
+		    context_length = CASE WHEN $9::int > 0 THEN $9::int ELSE context_length END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $10
+	`, digest, pq.Array(caps.Capabilities), hasCapability(models.ModelCapabilityEmbedding), caps.EmbeddingDimensions,
+		hasCapability(models.ModelCapabilityVision), hasCapability(models.ModelCapabilityTools),
+		caps.Template, caps.License, caps.ContextLength, id)
+	if err != nil {
+		return fmt.Errorf("failed to update model capabilities: %w", err)
+	}
+
+	m.logger.Debug().
+		Str("model", name).
+		Strs("capabilities", caps.Capabilities).
+		Int("context_length", caps.ContextLength).
+		Msg("Model capabilities updated")
+	return nil
+}
+
+// ValidateChatCapabilities checks that a model can handle a chat request: that it is
+// not an embedding-only model, that it accepts images if the request has any and that
+// the context the request asks for fits the model. Models whose capabilities are not
+// known yet are not checked.
+func (m *ModelManager) ValidateChatCapabilities(ctx context.Context, req models.ChatRequest) error {
+	model, err := m.GetModelByName(ctx, req.Model)
+	if err != nil {
+		return err
+	}
+
+	if len(model.Capabilities) == 0 {
+		return nil
+	}
+	if !model.HasCapability(models.ModelCapabilityCompletion) {
+		return fmt.Errorf("model %s does not support chat", model.Name)
+	}
+	if len(req.Images) > 0 && !model.SupportsVision {
+		return fmt.Errorf("model %s does not support images", model.Name)
+	}
+	numCtx := 0
+	switch value := req.Options["num_ctx"].(type) {
+	case float64:
+		numCtx = int(value)
+	case int:
+		numCtx = value
+	}
+	if model.ContextLength > 0 && numCtx > model.ContextLength {
+		return fmt.Errorf("num_ctx %d exceeds the context length of model %s (%d)", numCtx, model.Name, model.ContextLength)
+	}
+
+	return nil
+}
+
+// ValidateChatImages checks the images of a chat request
+func ValidateChatImages(images []string) error {
+	if len(images) > maxChatImages {
+		return fmt.Errorf("at most %d images can be sent with a message", maxChatImages)
+	}
+	for i, image := range images {
+		if image == "" {
+			return fmt.Errorf("image %d is empty", i+1)
+		}
+		if _, err := base64.StdEncoding.DecodeString(image); err != nil {
+			return fmt.Errorf("image %d must be base64-encoded", i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CreateCustomModel creates a model in Ollama from a Modelfile and registers it with its
+// lineage: the base model it was built from and the Modelfile itself. Ollama's status
+// updates are sent on progressChan, which is closed when CreateCustomModel returns.
+func (m *ModelManager) CreateCustomModel(ctx context.Context, req models.ModelCreateRequest, progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+	defer close(progressChan)
+
+	modelfile, err := resolveModelfile(req)
+	if err != nil {
+		return nil, err
+	}
+	req.Name = normalizeModelName(req.Name)
+
+	lineage := models.Model{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+		Source:      models.ModelSourceCreated,
+		BaseModel:   modelfile.From,
+	}
+	if parent, err := m.GetModelByName(ctx, normalizeModelName(modelfile.From)); err == nil {
+		lineage.ParentModelID = parent.ID
+		if lineage.Description == "" {
+			lineage.Description = fmt.Sprintf("Model: %s (based on %s)", req.Name, parent.Name)
+		}
+	}
+
+	return m.createFromModelfile(ctx, lineage, *modelfile, progressChan)
+}
+
+// createFromModelfile registers a model with its lineage and creates it in Ollama from a
+// Modelfile, forwarding Ollama's status updates to progressChan
+func (m *ModelManager) createFromModelfile(ctx context.Context, lineage models.Model, modelfile models.Modelfile, progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+	createReq, err := ollamaCreateRequest(lineage.Name, modelfile)
+	if err != nil {
+		return nil, err
+	}
+
+	lineage.Modelfile = FormatModelfile(modelfile)
+	model, err := m.registerDerivedModel(ctx, lineage)
+	if err != nil {
+		return nil, err
+	}
+
+	statusChan := make(chan models.ModelCreateProgress, 100)
+	createErr := make(chan error, 1)
+	go func() {
+		// CreateModel closes statusChan when it returns
+		createErr <- m.ollamaClient.CreateModel(ctx, createReq, statusChan)
+	}()
+
+	for progress := range statusChan {
+		progressChan <- progress
+	}
+
+	if err := <-createErr; err != nil {
+		m.logger.Error().Err(err).Str("model", lineage.Name).Msg("Model creation failed")
+		// Record the failure even if the request was cancelled
+		statusCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		m.updateModelStatus(statusCtx, model.ID, "error")
+		return nil, fmt.Errorf("failed to create model: %w", err)
+	}
+
+	return m.finishDerivedModel(ctx, model)
+}
+
+// CopyModel copies a model in Ollama under a new name and registers the copy with the
+// model it came from. An alias is recorded the same way but marked as another name for
+// the source rather than an independent copy. The copy starts with the source's
+// configuration.
+func (m *ModelManager) CopyModel(ctx context.Context, sourceID string, req models.ModelCopyRequest, alias bool) (*models.Model, error) {
+	if err := ValidateModelCopyRequest(req); err != nil {
+		return nil, err
+	}
+	req.Name = normalizeModelName(req.Name)
+
+	sourceModel, err := m.GetModelByID(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if sourceModel.Status != "available" {
+		return nil, fmt.Errorf("model is not available")
+	}
+
+	lineage := models.Model{
+		Name:          req.Name,
+		DisplayName:   req.DisplayName,
+		Description:   req.Description,
+		Source:        models.ModelSourceCopied,
+		BaseModel:     sourceModel.Name,
+		ParentModelID: sourceModel.ID,
+		Modelfile:     sourceModel.Modelfile,
+	}
+	if alias {
+		lineage.Source = models.ModelSourceAlias
+		if lineage.Description == "" {
+			lineage.Description = fmt.Sprintf("Alias of %s", sourceModel.Name)
+		}
+	}
+
+	model, err := m.registerDerivedModel(ctx, lineage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ollamaClient.CopyModel(ctx, sourceModel.Name, req.Name); err != nil {
+		m.updateModelStatus(ctx, model.ID, "error")
+		return nil, fmt.Errorf("failed to copy model: %w", err)
+	}
+
+	if err := m.copyModelConfig(ctx, sourceModel.ID, model.ID); err != nil {
+		m.logger.Warn().Err(err).Str("model", req.Name).Msg("Failed to copy model configuration")
+	}
+
+	return m.finishDerivedModel(ctx, model)
+}
+
+// registerDerivedModel records a model that is being created or copied, with status
+// installing until Ollama has it. A model of the same name that is not available, e.g.
+// after a failed attempt, is reused.
+func (m *ModelManager) registerDerivedModel(ctx context.Context, lineage models.Model) (*models.Model, error) {
+	existing, err := m.GetModelByName(ctx, lineage.Name)
+	if err == nil {
+		if existing.Status == "available" {
+			return nil, fmt.Errorf("model %s already exists", lineage.Name)
+		}
+
+		_, err := m.db.ExecContext(ctx, `
+			UPDATE models
+			SET status = 'installing', source = $1, base_model = $2, parent_model_id = $3, modelfile = $4,
+			    display_name = COALESCE(NULLIF($5, ''), display_name),
+			    description = COALESCE(NULLIF($6, ''), description),
+			    updated_at = CURRENT_TIMESTAMP
+			WHERE id = $7
+		`, lineage.Source, lineage.BaseModel, sql.NullString{String: lineage.ParentModelID, Valid: lineage.ParentModelID != ""},
+			lineage.Modelfile, lineage.DisplayName, lineage.Description, existing.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update model record: %w", err)
+		}
+		return m.GetModelByID(ctx, existing.ID)
+	}
+
+	model := lineage
+	model.ID = uuid.New().String()
+	model.Status = "installing"
+	model.IsEnabled = true
+	model.CreatedAt = time.Now()
+	model.UpdatedAt = time.Now()
+	if model.DisplayName == "" {
+		model.DisplayName = m.generateDisplayName(model.Name)
+	}
+	if model.Description == "" {
+		model.Description = fmt.Sprintf("Model: %s", model.Name)
+	}
+
+	if err := m.CreateModel(ctx, model); err != nil {
+		return nil, fmt.Errorf("failed to create model record: %w", err)
+	}
+	return &model, nil
+}
+
+// finishDerivedModel marks a created or copied model available and fills in the details
+// Ollama reports for it
+func (m *ModelManager) finishDerivedModel(ctx context.Context, model *models.Model) (*models.Model, error) {
+	if err := m.updateModelStatus(ctx, model.ID, "available"); err != nil {
+		return nil, err
+	}
+
+	if err := m.SyncModels(ctx); err != nil {
+		m.logger.Warn().Err(err).Str("model", model.Name).Msg("Failed to sync models after creation")
+	}
+
+	m.logger.Info().Str("model_id", model.ID).Str("model", model.Name).Str("source", model.Source).Msg("Model registered")
+	return m.GetModelByID(ctx, model.ID)
+}
+
+// copyModelConfig overwrites a model's configuration with another model's
+func (m *ModelManager) copyModelConfig(ctx context.Context, fromID, toID string) error {
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE model_configs dst
+		SET temperature = src.temperature, top_p = src.top_p, top_k = src.top_k,
+		    repeat_penalty = src.repeat_penalty, context_length = src.context_length,
+		    max_tokens = src.max_tokens, system_prompt = src.system_prompt, keep_alive = src.keep_alive,
+		    updated_at = CURRENT_TIMESTAMP
+		FROM model_configs src
+		WHERE src.model_id = $1 AND dst.model_id = $2
+	`, fromID, toID)
+	return err
+}
+
+// ollamaCreateRequest turns a Modelfile into a structured /api/create request
+func ollamaCreateRequest(name string, modelfile models.Modelfile) (OllamaCreateRequest, error) {
+	parameters, err := modelfileParameters(modelfile.Parameters)
+	if err != nil {
+		return OllamaCreateRequest{}, err
+	}
+
+	createReq := OllamaCreateRequest{
+		Model:      name,
+		Template:   modelfile.Template,
+		System:     modelfile.System,
+		Parameters: parameters,
+	}
+
+	if blobDigestPattern.MatchString(modelfile.From) {
+		// Weights uploaded as a blob; the file name tells Ollama how to read them
+		createReq.Files = map[string]string{"model.gguf": normalizeBlobDigest(modelfile.From)}
+	} else {
+		createReq.From = modelfile.From
+	}
+
+	if len(modelfile.Adapters) > 0 {
+		createReq.Adapters = make(map[string]string, len(modelfile.Adapters))
+		for i, adapter := range modelfile.Adapters {
+			createReq.Adapters[fmt.Sprintf("adapter-%d.gguf", i+1)] = normalizeBlobDigest(adapter)
+		}
+	}
+
+	for _, message := range modelfile.Messages {
+		createReq.Messages = append(createReq.Messages, OllamaMessage{Role: message.Role, Content: message.Content})
+	}
+
+	return createReq, nil
+}
+
+// normalizeBlobDigest writes a blob digest in the sha256:<hex> form Ollama's API uses
+func normalizeBlobDigest(digest string) string {
+	return strings.Replace(digest, "sha256-", "sha256:", 1)
+}
+
+// updateGGUFMetadata records the details read from a GGUF header, keeping the current
+// value of anything the header does not give
+func (m *ModelManager) updateGGUFMetadata(ctx context.Context, id string, info *GGUFInfo) error {
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE models
+		SET format = 'gguf',
+		    family = COALESCE(NULLIF($1, ''), family),
+		    parameters = COALESCE(NULLIF($2, ''), parameters),
+		    quantization = COALESCE(NULLIF($3, ''), quantization),
+		    context_length = CASE WHEN $4::int > 0 THEN $4::int ELSE context_length END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+	`, info.Architecture, info.Parameters(), info.FileType, info.ContextLength, id)
+	if err != nil {
+		return fmt.Errorf("failed to update model metadata: %w", err)
+	}
+	return nil
+}
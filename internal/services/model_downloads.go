@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"chat_ollama/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// downloadSlotRetry is how long a download waits for a free slot before trying again
+	downloadSlotRetry = 15 * time.Second
+	// downloadProgressInterval throttles how often progress is persisted and published
+	downloadProgressInterval = time.Second
+)
+
+// errDownloadCancelled is the cancellation cause of a download cancelled by a user
+var errDownloadCancelled = errors.New("download cancelled")
+
+// runningDownloads holds the cancel functions of the downloads running in this process by
+// download ID, so a cancel request reaches the job pulling the model
+var runningDownloads = struct {
+	sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}{cancels: make(map[string]context.CancelCauseFunc)}
+
+const modelDownloadColumns = `id, model_id, model_name, job_id, status, phase, layers, total_bytes,
+	completed_bytes, error, created_at, started_at, completed_at, updated_at`
+
+// CancelDownload cancels the queued or running download of a model. The model goes back
+// to the removed state until it is downloaded again, unless an Ollama host still has it,
+// as when the download was adding it to another host.
+func (m *ModelManager) CancelDownload(ctx context.Context, modelID string) (*models.ModelDownload, error) {
+	download, err := scanModelDownload(m.db.QueryRowContext(ctx, `
+		UPDATE model_downloads SET status = 'cancelled', completed_at = NOW()
+		WHERE model_id = $1 AND status IN ('queued', 'downloading')
+		RETURNING `+modelDownloadColumns, modelID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("download not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel download: %w", err)
+	}
+
+	// Stop the pull if it runs here; a pull running on another instance notices the
+	// cancellation the next time it records progress
+	runningDownloads.Lock()
+	if cancel, ok := runningDownloads.cancels[download.ID]; ok {
+		cancel(errDownloadCancelled)
+	}
+	runningDownloads.Unlock()
+
+	if download.JobID != "" {
+		if _, err := m.db.ExecContext(ctx, "DELETE FROM jobs WHERE id = $1 AND status = 'pending'", download.JobID); err != nil {
+			m.logger.Warn().Err(err).Str("job_id", download.JobID).Msg("Failed to drop queued download job")
+		}
+	}
+
+	installed, err := m.installedOnAnyHost(ctx, download.ModelName)
+	if err != nil {
+		// The next model sync sets the status from the hosts' inventory
+		m.logger.Warn().Err(err).Str("model_id", modelID).Msg("Failed to check the hosts of cancelled model")
+	} else {
+		status := "removed"
+		if installed {
+			status = "available"
+		}
+		if err := m.updateModelStatus(ctx, modelID, status); err != nil {
+			m.logger.Warn().Err(err).Str("model_id", modelID).Msg("Failed to reset status of cancelled model")
+		}
+	}
+
+	m.logger.Info().Str("model_id", modelID).Str("download_id", download.ID).Msg("Model download cancelled")
+	modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadCancelled, Download: download})
+	return download, nil
+}
+
+// installedOnAnyHost reports whether any reachable Ollama host has a model installed
+func (m *ModelManager) installedOnAnyHost(ctx context.Context, name string) (bool, error) {
+	installed, err := m.ollamaClient.GetModelsWithInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+	name = normalizeModelName(name)
+	for _, model := range installed {
+		if normalizeModelName(model.Name) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListActiveDownloads returns the queued and running downloads, oldest first
+func (m *ModelManager) ListActiveDownloads(ctx context.Context) ([]models.ModelDownload, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT `+modelDownloadColumns+`
+		FROM model_downloads
+		WHERE status IN ('queued', 'downloading')
+		ORDER BY created_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query downloads: %w", err)
+	}
+	defer rows.Close()
+
+	downloads := []models.ModelDownload{}
+	for rows.Next() {
+		download, err := scanModelDownload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download: %w", err)
+		}
+		downloads = append(downloads, *download)
+	}
+	return downloads, rows.Err()
+}
+
+// RecoverDownloads queues the downloads interrupted by a restart again. Downloads whose
+// job is still pending, or running and waiting to be reclaimed by the queue, resume on
+// their own.
+func (m *ModelManager) RecoverDownloads(ctx context.Context) (int, error) {
+	if m.jobs == nil {
+		return 0, fmt.Errorf("job queue not configured")
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT d.id, d.model_id, d.model_name
+		FROM model_downloads d
+		WHERE d.status IN ('queued', 'downloading')
+		  AND NOT EXISTS (
+		      SELECT 1 FROM jobs j WHERE j.id = d.job_id AND j.status IN ('pending', 'running')
+		  )
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query interrupted downloads: %w", err)
+	}
+
+	var payloads []modelDownloadPayload
+	for rows.Next() {
+		var payload modelDownloadPayload
+		if err := rows.Scan(&payload.DownloadID, &payload.ModelID, &payload.Name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan download: %w", err)
+		}
+		payloads = append(payloads, payload)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, payload := range payloads {
+		if err := m.enqueueDownload(ctx, payload); err != nil {
+			m.logger.Error().Err(err).Str("download_id", payload.DownloadID).Msg("Failed to recover model download")
+			continue
+		}
+		recovered++
+	}
+
+	if recovered > 0 {
+		m.logger.Info().Int("downloads", recovered).Msg("Recovered interrupted model downloads")
+	}
+	return recovered, nil
+}
+
+// latestDownload returns the most recent download of a model
+func (m *ModelManager) latestDownload(ctx context.Context, modelID string) (*models.ModelDownload, error) {
+	return scanModelDownload(m.db.QueryRowContext(ctx, `
+		SELECT `+modelDownloadColumns+`
+		FROM model_downloads
+		WHERE model_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, modelID))
+}
+
+// createDownload records a new queued download of a model
+func (m *ModelManager) createDownload(ctx context.Context, modelID, name string) (*models.ModelDownload, error) {
+	download, err := scanModelDownload(m.db.QueryRowContext(ctx, `
+		INSERT INTO model_downloads (id, model_id, model_name)
+		VALUES ($1, $2, $3)
+		RETURNING `+modelDownloadColumns, uuid.New().String(), modelID, name))
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("model download already in progress")
+	}
+	return download, err
+}
+
+// enqueueDownload queues the job pulling a download and records it on the download
+func (m *ModelManager) enqueueDownload(ctx context.Context, payload modelDownloadPayload) error {
+	jobID, err := m.jobs.Enqueue(ctx, JobTypeModelDownload, payload, JobOptions{
+		DedupeKey: JobTypeModelDownload + ":" + payload.DownloadID,
+	})
+	if err != nil {
+		return err
+	}
+	if jobID == "" {
+		// A pending job already pulls this download
+		return nil
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE model_downloads SET job_id = $1, status = 'queued'
+		WHERE id = $2 AND status IN ('queued', 'downloading')
+	`, jobID, payload.DownloadID)
+	return err
+}
+
+// downloadForJob loads the download a job pulls. Jobs queued before downloads were
+// persisted carry no download ID and get a download recorded for them.
+func (m *ModelManager) downloadForJob(ctx context.Context, payload modelDownloadPayload) (*models.ModelDownload, error) {
+	if payload.DownloadID != "" {
+		download, err := scanModelDownload(m.db.QueryRowContext(ctx,
+			"SELECT "+modelDownloadColumns+" FROM model_downloads WHERE id = $1", payload.DownloadID))
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return download, err
+	}
+
+	download, err := m.createDownload(ctx, payload.ModelID, payload.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record download: %w", err)
+	}
+	return download, nil
+}
+
+// claimDownloadSlot marks a download as downloading when fewer than the configured
+// number of downloads run. Downloads that have not recorded progress within the job
+// lock timeout were interrupted and do not hold a slot. It returns false without error
+// when no slot is free and errDownloadCancelled when the download must not run anymore:
+// it was cancelled, completed, or failed and was superseded by a newer download.
+func (m *ModelManager) claimDownloadSlot(ctx context.Context, downloadID string) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise slot claims across instances
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('model_downloads'))"); err != nil {
+		return false, fmt.Errorf("failed to lock download slots: %w", err)
+	}
+
+	var runnable bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT status IN ('queued', 'downloading') OR (status = 'failed' AND NOT EXISTS (
+		    SELECT 1 FROM model_downloads n WHERE n.model_id = d.model_id AND n.created_at > d.created_at
+		))
+		FROM model_downloads d
+		WHERE id = $1
+	`, downloadID).Scan(&runnable)
+	if err == sql.ErrNoRows || (err == nil && !runnable) {
+		return false, errDownloadCancelled
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check download: %w", err)
+	}
+
+	var running int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM model_downloads
+		WHERE status = 'downloading' AND id <> $1 AND updated_at > NOW() - $2 * INTERVAL '1 second'
+	`, downloadID, m.jobs.config.JobLockTimeout.Seconds()).Scan(&running)
+	if err != nil {
+		return false, fmt.Errorf("failed to count running downloads: %w", err)
+	}
+	if running >= m.jobs.config.MaxConcurrentDownloads {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE model_downloads
+		SET status = 'downloading', error = NULL, completed_at = NULL, started_at = COALESCE(started_at, NOW())
+		WHERE id = $1
+	`, downloadID); err != nil {
+		return false, fmt.Errorf("failed to start download: %w", err)
+	}
+
+	return true, tx.Commit()
+}
+
+// saveDownloadProgress persists the progress of a running download. It returns false
+// when the download is no longer running, i.e. it was cancelled.
+func (m *ModelManager) saveDownloadProgress(ctx context.Context, download *models.ModelDownload) (bool, error) {
+	layers, err := json.Marshal(download.Layers)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := m.db.ExecContext(ctx, `
+		UPDATE model_downloads SET phase = $1, layers = $2, total_bytes = $3, completed_bytes = $4
+		WHERE id = $5 AND status = 'downloading'
+	`, download.Phase, layers, download.TotalBytes, download.CompletedBytes, download.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to save download progress: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// finishDownload records the outcome of a download that was not cancelled
+func (m *ModelManager) finishDownload(ctx context.Context, download *models.ModelDownload, status, failure string) error {
+	download.Status = status
+	download.Error = failure
+	now := time.Now()
+	download.CompletedAt = &now
+
+	layers, err := json.Marshal(download.Layers)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE model_downloads
+		SET status = $1, error = NULLIF($2, ''), phase = $3, layers = $4, total_bytes = $5,
+		    completed_bytes = $6, completed_at = NOW()
+		WHERE id = $7 AND status = 'downloading'
+	`, status, failure, download.Phase, layers, download.TotalBytes, download.CompletedBytes, download.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record download outcome: %w", err)
+	}
+	return nil
+}
+
+// applyDownloadProgress folds a progress update from Ollama into the download's
+// per-layer progress and totals
+func applyDownloadProgress(download *models.ModelDownload, progress models.ModelDownloadProgress) {
+	if progress.Status != "" && progress.Status != "error" {
+		download.Phase = progress.Status
+	}
+
+	if progress.Digest != "" && progress.Total > 0 {
+		found := false
+		for i := range download.Layers {
+			if download.Layers[i].Digest == progress.Digest {
+				download.Layers[i].Total = progress.Total
+				download.Layers[i].Completed = progress.Completed
+				found = true
+				break
+			}
+		}
+		if !found {
+			download.Layers = append(download.Layers, models.ModelDownloadLayer{
+				Digest:    progress.Digest,
+				Total:     progress.Total,
+				Completed: progress.Completed,
+			})
+		}
+	}
+
+	download.TotalBytes, download.CompletedBytes = 0, 0
+	for _, layer := range download.Layers {
+		download.TotalBytes += layer.Total
+		download.CompletedBytes += layer.Completed
+	}
+	download.Progress = downloadPercentage(download.TotalBytes, download.CompletedBytes)
+}
+
+// downloadPercentage returns the completed share of a download in percent
+func downloadPercentage(total, completed int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(completed) / float64(total) * 100
+}
+
+// scanModelDownload scans a row selected with modelDownloadColumns
+func scanModelDownload(row rowScanner) (*models.ModelDownload, error) {
+	var download models.ModelDownload
+	var jobID, failure sql.NullString
+	var layers []byte
+	var startedAt, completedAt sql.NullTime
+
+	err := row.Scan(
+		&download.ID, &download.ModelID, &download.ModelName, &jobID, &download.Status,
+		&download.Phase, &layers, &download.TotalBytes, &download.CompletedBytes, &failure,
+		&download.CreatedAt, &startedAt, &completedAt, &download.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	download.JobID = jobID.String
+	download.Error = failure.String
+	if startedAt.Valid {
+		download.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		download.CompletedAt = &completedAt.Time
+	}
+	if err := json.Unmarshal(layers, &download.Layers); err != nil {
+		return nil, fmt.Errorf("invalid download layers: %w", err)
+	}
+	if download.Layers == nil {
+		download.Layers = []models.ModelDownloadLayer{}
+	}
+
+	download.Progress = downloadPercentage(download.TotalBytes, download.CompletedBytes)
+	if download.Status == models.DownloadStatusCompleted {
+		download.Progress = 100
+	}
+	return &download, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"chat_ollama/internal/models"
+)
+
+// modelEventBuffer is how many events a subscriber may fall behind before events are dropped for it
+const modelEventBuffer = 64
+
+// modelEventHub fans model events out to stream subscribers in this process. Events
+// are best effort: a subscriber that does not keep up misses events rather than
+// blocking the publisher, and can resynchronise from the persisted state.
+type modelEventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan models.ModelEvent]struct{}
+}
+
+// modelEvents is shared by all model managers, since the job queue worker publishing an
+// event is usually not the manager of the handler streaming it
+var modelEvents = &modelEventHub{subscribers: make(map[chan models.ModelEvent]struct{})}
+
+// subscribe registers a subscriber and returns its channel and a function removing it
+func (h *modelEventHub) subscribe() (<-chan models.ModelEvent, func()) {
+	ch := make(chan models.ModelEvent, modelEventBuffer)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish sends an event to every subscriber without blocking
+func (h *modelEventHub) publish(event models.ModelEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeModelEvents streams model events published in this process until the
+// returned function is called
+func (m *ModelManager) SubscribeModelEvents() (<-chan models.ModelEvent, func()) {
+	return modelEvents.subscribe()
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+// importProgressInterval throttles progress updates while hashing and uploading files
+const importProgressInterval = 500 * time.Millisecond
+
+// ModelImportService imports GGUF files as models without the Ollama registry: the file
+// is pushed to Ollama as a blob and a model is created from a generated Modelfile
+type ModelImportService struct {
+	models       *ModelManager
+	ollamaClient *OllamaClient
+	config       *config.Config
+	logger       *utils.Logger
+}
+
+// NewModelImportService creates a new model import service
+func NewModelImportService(modelManager *ModelManager, ollamaClient *OllamaClient, cfg *config.Config, logger *utils.Logger) *ModelImportService {
+	return &ModelImportService{
+		models:       modelManager,
+		ollamaClient: ollamaClient,
+		config:       cfg,
+		logger:       logger.WithComponent("model_import"),
+	}
+}
+
+// ListImportFiles lists the GGUF files in the import directory
+func (s *ModelImportService) ListImportFiles(ctx context.Context) ([]models.ModelImportFile, error) {
+	if s.config.ModelImportDir == "" {
+		return nil, fmt.Errorf("model import directory not configured")
+	}
+
+	files := []models.ModelImportFile{}
+	err := filepath.WalkDir(s.config.ModelImportDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".gguf") {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.config.ModelImportDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, models.ModelImportFile{
+			Path:       filepath.ToSlash(rel),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list import directory: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// ImportFile imports a GGUF file from the import directory. Progress is sent on
+// progressChan, which is closed when ImportFile returns.
+func (s *ModelImportService) ImportFile(ctx context.Context, req models.ModelImportRequest, progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+	defer close(progressChan)
+
+	if err := ValidateModelImportRequest(req); err != nil {
+		return nil, err
+	}
+	if req.File == "" {
+		return nil, fmt.Errorf("file is required")
+	}
+
+	path, err := s.resolveImportPath(req.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.importGGUF(ctx, req, path, "", filepath.Base(path), progressChan)
+}
+
+// ImportUpload imports a GGUF file spooled by SpoolUpload. Progress is sent on
+// progressChan, which is closed when ImportUpload returns.
+func (s *ModelImportService) ImportUpload(ctx context.Context, req models.ModelImportRequest, path, digest, filename string, progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+	defer close(progressChan)
+
+	if err := ValidateModelImportRequest(req); err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		filename = "upload.gguf"
+	}
+
+	return s.importGGUF(ctx, req, path, digest, filename, progressChan)
+}
+
+// uploadReservations counts the bytes set aside on the temporary directory's file system
+// for uploads being received, so concurrent uploads cannot together fill the disk
+var uploadReservations = struct {
+	sync.Mutex
+	bytes int64
+}{}
+
+// reserveUploadSpace sets size bytes aside for an upload, failing when the temporary
+// directory does not have them free next to the uploads in progress. The returned
+// function releases the reservation.
+func (s *ModelImportService) reserveUploadSpace(size int64) (func(), error) {
+	uploadReservations.Lock()
+	defer uploadReservations.Unlock()
+
+	free, err := freeDiskSpace(os.TempDir())
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to check free disk space for model upload")
+	} else if free-uploadReservations.bytes < size {
+		return nil, fmt.Errorf("not enough disk space for the upload: %d bytes needed, %d bytes available",
+			size, max(free-uploadReservations.bytes, 0))
+	}
+
+	uploadReservations.bytes += size
+	return func() {
+		uploadReservations.Lock()
+		defer uploadReservations.Unlock()
+		uploadReservations.bytes -= size
+	}, nil
+}
+
+// SpoolUpload writes an uploaded file to a temporary file, computing its digest on the
+// way. size is the length of the upload, or -1 when unknown, in which case room for the
+// largest upload allowed is required. The caller removes the file once it is imported.
+func (s *ModelImportService) SpoolUpload(body io.Reader, size int64) (string, string, error) {
+	if size > s.config.ModelUploadMaxSize {
+		return "", "", fmt.Errorf("upload exceeds the maximum size of %d bytes", s.config.ModelUploadMaxSize)
+	}
+	if size < 0 {
+		size = s.config.ModelUploadMaxSize
+	}
+	release, err := s.reserveUploadSpace(size)
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+
+	file, err := os.CreateTemp("", "model-upload-*.gguf")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create upload file: %w", err)
+	}
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(body, s.config.ModelUploadMaxSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > s.config.ModelUploadMaxSize {
+		err = fmt.Errorf("upload exceeds the maximum size of %d bytes", s.config.ModelUploadMaxSize)
+	}
+	if err == nil && written == 0 {
+		err = fmt.Errorf("upload is empty")
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", "", err
+	}
+
+	return file.Name(), "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// importGGUF reads the header of a GGUF file, pushes the file to Ollama unless it
+// already has the blob, creates the model and records the metadata from the header
+func (s *ModelImportService) importGGUF(ctx context.Context, req models.ModelImportRequest, path, digest, filename string, progressChan chan<- models.ModelCreateProgress) (*models.Model, error) {
+	progressChan <- models.ModelCreateProgress{Status: "reading GGUF header"}
+	info, err := ReadGGUFInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GGUF file: %w", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat import file: %w", err)
+	}
+
+	if digest == "" {
+		if digest, err = s.hashFile(ctx, path, stat.Size(), progressChan); err != nil {
+			return nil, err
+		}
+	}
+
+	// The blob and the model created from it have to be on the same Ollama host
+	ctx, host := s.ollamaClient.PinHost(ctx, req.Name)
+	s.logger.Info().Str("model", req.Name).Str("host", host).Msg("Importing model to Ollama host")
+
+	exists, err := s.ollamaClient.HasBlob(ctx, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check blob: %w", err)
+	}
+	if !exists {
+		if err := s.pushFile(ctx, path, digest, stat.Size(), progressChan); err != nil {
+			return nil, err
+		}
+	} else {
+		progressChan <- models.ModelCreateProgress{Status: "blob already present", Digest: digest}
+	}
+
+	modelfile := models.Modelfile{
+		From:       digest,
+		System:     req.System,
+		Template:   req.Template,
+		Parameters: req.Parameters,
+	}
+	if err := ValidateModelfile(modelfile); err != nil {
+		return nil, err
+	}
+
+	lineage := models.Model{
+		Name:        normalizeModelName(req.Name),
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+		Source:      models.ModelSourceImported,
+		BaseModel:   filename,
+	}
+	if lineage.Description == "" {
+		lineage.Description = fmt.Sprintf("Imported from %s", filename)
+	}
+
+	model, err := s.models.createFromModelfile(ctx, lineage, modelfile, progressChan)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.models.updateGGUFMetadata(ctx, model.ID, info); err != nil {
+		s.logger.Warn().Err(err).Str("model", model.Name).Msg("Failed to record GGUF metadata")
+	}
+
+	s.logger.Info().
+		Str("model", model.Name).
+		Str("digest", digest).
+		Str("architecture", info.Architecture).
+		Str("quantization", info.FileType).
+		Msg("GGUF file imported")
+
+	return s.models.GetModelByID(ctx, model.ID)
+}
+
+// resolveImportPath turns a path relative to the import directory into a file path,
+// refusing paths that leave the directory
+func (s *ModelImportService) resolveImportPath(rel string) (string, error) {
+	if s.config.ModelImportDir == "" {
+		return "", fmt.Errorf("model import directory not configured")
+	}
+	if !strings.EqualFold(filepath.Ext(rel), ".gguf") {
+		return "", fmt.Errorf("file must be a .gguf file")
+	}
+
+	root, err := filepath.EvalSymlinks(s.config.ModelImportDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve import directory: %w", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", fmt.Errorf("import file not found")
+	}
+	if inside, err := filepath.Rel(root, path); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file must be inside the import directory")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("import file not found")
+	}
+	return path, nil
+}
+
+// hashFile computes the sha256:<hex> digest of a file
+func (s *ModelImportService) hashFile(ctx context.Context, path string, size int64, progressChan chan<- models.ModelCreateProgress) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	reader := &importProgressReader{ctx: ctx, r: file, status: "computing sha256", total: size, progressChan: progressChan}
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", fmt.Errorf("failed to hash import file: %w", err)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// pushFile uploads a file to Ollama as a blob
+func (s *ModelImportService) pushFile(ctx context.Context, path, digest string, size int64, progressChan chan<- models.ModelCreateProgress) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	reader := &importProgressReader{ctx: ctx, r: file, status: "uploading blob", digest: digest, total: size, progressChan: progressChan}
+	if err := s.ollamaClient.PushBlob(ctx, digest, reader, size); err != nil {
+		return err
+	}
+
+	progressChan <- models.ModelCreateProgress{Status: "blob uploaded", Digest: digest, Total: size, Completed: size}
+	return nil
+}
+
+// importProgressReader reports how much of a file has been read, at most every
+// importProgressInterval, and stops reading once the context is done
+type importProgressReader struct {
+	ctx          context.Context
+	r            io.Reader
+	status       string
+	digest       string
+	total        int64
+	completed    int64
+	lastReported time.Time
+	progressChan chan<- models.ModelCreateProgress
+}
+
+func (p *importProgressReader) Read(buf []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := p.r.Read(buf)
+	p.completed += int64(n)
+	if time.Since(p.lastReported) >= importProgressInterval || err == io.EOF {
+		p.lastReported = time.Now()
+		p.progressChan <- models.ModelCreateProgress{
+			Status:    p.status,
+			Digest:    p.digest,
+			Total:     p.total,
+			Completed: p.completed,
+		}
+	}
+	return n, err
+}
+
+// ValidateModelImportRequest checks a model import request
+func ValidateModelImportRequest(req models.ModelImportRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !modelNamePattern.MatchString(req.Name) {
+		return fmt.Errorf("name must be a valid model name such as team/reviewer:latest")
+	}
+
+	// The FROM of the generated Modelfile is a placeholder digest here
+	return ValidateModelfile(models.Modelfile{
+		From:       "sha256:" + strings.Repeat("0", 64),
+		System:     req.System,
+		Template:   req.Template,
+		Parameters: req.Parameters,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -14,6 +15,7 @@ import (
 	"chat_ollama/internal/utils"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // ModelManager handles model management operations
@@ -21,27 +23,60 @@ type ModelManager struct {
 	db           database.Database
 	ollamaClient *OllamaClient
 	logger       *utils.Logger
-	downloadProgress map[string]float64 // Track download progress by model ID
-	progressMutex    sync.RWMutex       // Protect progress map
+	jobs         *JobQueue
+
+	// Bytes the installed models may take before the least recently used are evicted,
+	// and the configured models that are never evicted
+	storageBudget   int64
+	protectedModels map[string]bool
 	
-	// Cache for available models from Ollama library
-	availableModelsCache []string
+	// Cache for the catalog of models that can be downloaded
+	catalogSources       []CatalogSource
+	availableModelsCache []models.CatalogModel
+	cacheSource          string
 	cacheLastUpdated     time.Time
 	cacheTTL             time.Duration
 	cacheMutex           sync.RWMutex
 }
 
+// modelDownloadPayload is the payload of a model download job
+type modelDownloadPayload struct {
+	DownloadID string `json:"download_id"`
+	ModelID    string `json:"model_id"`
+	Name       string `json:"name"`
+}
+
 // NewModelManager creates a new model manager
 func NewModelManager(db database.Database, ollamaClient *OllamaClient, logger *utils.Logger) *ModelManager {
 	return &ModelManager{
 		db:           db,
 		ollamaClient: ollamaClient,
 		logger:       logger.WithComponent("model_manager"),
-		downloadProgress: make(map[string]float64),
 		cacheTTL:     24 * time.Hour, // Cache for 24 hours
 	}
 }
 
+// SetJobQueue sets the queue downloads are started on
+func (m *ModelManager) SetJobQueue(queue *JobQueue) {
+	m.jobs = queue
+}
+
+// SetCatalogSources sets the sources the catalog of downloadable models is read from, in
+// the order they are tried. Without them the ollama.com library is scraped.
+func (m *ModelManager) SetCatalogSources(sources ...CatalogSource) {
+	m.cacheMutex.Lock()
+	defer m.cacheMutex.Unlock()
+	m.catalogSources = sources
+	m.cacheLastUpdated = time.Time{}
+}
+
+// RegisterJobs registers the model download job handler on the queue
+func (m *ModelManager) RegisterJobs(queue *JobQueue) {
+	m.jobs = queue
+	queue.Register(JobTypeModelDownload, 0, m.handleModelDownload)
+	queue.Register(JobTypeReconcileModels, 10*time.Minute, m.handleReconcileModels)
+}
+
 // SyncModels synchronizes local model database with Ollama
 func (m *ModelManager) SyncModels(ctx context.Context) error {
 	m.logger.Info().Msg("Starting model synchronization with Ollama")
@@ -69,11 +104,13 @@ func (m *ModelManager) SyncModels(ctx context.Context) error {
 		if existing, exists := existingMap[ollamaInfo.Name]; exists {
 			// Update existing model with size and status
 			needsUpdate := false
+			var changes []string
 
 			if existing.Status != "available" {
 				if err := m.updateModelStatus(ctx, existing.ID, "available"); err != nil {
 					m.logger.Error().Err(err).Str("model", ollamaInfo.Name).Msg("Failed to update model status")
 				}
+				changes = append(changes, "status")
 			}
 
 			// Update size if different
@@ -81,6 +118,7 @@ func (m *ModelManager) SyncModels(ctx context.Context) error {
 				if err := m.updateModelSize(ctx, existing.ID, ollamaInfo.Size); err != nil {
 					m.logger.Error().Err(err).Str("model", ollamaInfo.Name).Msg("Failed to update model size")
 				}
+				changes = append(changes, "size")
 			}
 
 			// Update other fields if we have the info
@@ -101,6 +139,25 @@ func (m *ModelManager) SyncModels(ctx context.Context) error {
 				if err := m.updateModelMetadata(ctx, existing.ID, ollamaInfo); err != nil {
 					m.logger.Error().Err(err).Str("model", ollamaInfo.Name).Msg("Failed to update model metadata")
 				}
+				changes = append(changes, "metadata")
+			}
+
+			// Capabilities are read again only when the model changed
+			if existing.Digest != ollamaInfo.Digest || ollamaInfo.Digest == "" {
+				if err := m.refreshModelCapabilities(ctx, existing.ID, ollamaInfo.Name, ollamaInfo.Digest); err != nil {
+					m.logger.Warn().Err(err).Str("model", ollamaInfo.Name).Msg("Failed to update model capabilities")
+				}
+				if existing.Digest != ollamaInfo.Digest {
+					changes = append(changes, "digest")
+				}
+			}
+
+			// A model that was removed and is back, e.g. pulled with the ollama CLI, is added again
+			switch {
+			case existing.Status == "removed":
+				m.publishModelChange(ctx, models.ModelEventAdded, existing.ID, nil)
+			case len(changes) > 0:
+				m.publishModelChange(ctx, models.ModelEventUpdated, existing.ID, changes)
 			}
 		} else {
 			// Add new model with full info
@@ -124,6 +181,10 @@ func (m *ModelManager) SyncModels(ctx context.Context) error {
 				m.logger.Error().Err(err).Str("model", ollamaInfo.Name).Msg("Failed to create model")
 			} else {
 				m.logger.Info().Str("model", ollamaInfo.Name).Msg("Added new model")
+				if err := m.refreshModelCapabilities(ctx, newModel.ID, ollamaInfo.Name, ollamaInfo.Digest); err != nil {
+					m.logger.Warn().Err(err).Str("model", ollamaInfo.Name).Msg("Failed to update model capabilities")
+				}
+				m.publishModelChange(ctx, models.ModelEventAdded, newModel.ID, nil)
 			}
 		}
 	}
@@ -140,20 +201,64 @@ func (m *ModelManager) SyncModels(ctx context.Context) error {
 				m.logger.Error().Err(err).Str("model", existing.Name).Msg("Failed to mark model as removed")
 			} else {
 				m.logger.Info().Str("model", existing.Name).Msg("Marked model as removed")
+				m.publishModelChange(ctx, models.ModelEventRemoved, existing.ID, nil)
 			}
 		}
 	}
 
+	if err := m.repairDefaultModel(ctx); err != nil {
+		m.logger.Error().Err(err).Msg("Failed to repair default model")
+	}
+
+	m.repairPlacement(ctx, ollamaModelsInfo)
+
 	m.logger.Info().Int("ollama_models", len(ollamaModelsInfo)).Msg("Model synchronization completed")
 	return nil
 }
 
+// repairPlacement queues a download of each model missing from a healthy host its
+// placement rule puts it on, such as a host that was down when the model was pulled
+func (m *ModelManager) repairPlacement(ctx context.Context, ollamaModelsInfo []models.OllamaModelDetailedInfo) {
+	if m.jobs == nil {
+		return
+	}
+
+	for _, ollamaInfo := range ollamaModelsInfo {
+		missing := m.ollamaClient.MissingHosts(ollamaInfo)
+		if len(missing) == 0 {
+			continue
+		}
+
+		// Created, copied and imported models cannot be pulled from the registry
+		model, err := m.GetModelByName(ctx, ollamaInfo.Name)
+		if err != nil || model.Source != "library" {
+			continue
+		}
+		if download, err := m.latestDownload(ctx, model.ID); err == nil && download.IsActive() {
+			continue
+		}
+
+		download, err := m.createDownload(ctx, model.ID, model.Name)
+		if err != nil {
+			m.logger.Warn().Err(err).Str("model", model.Name).Msg("Failed to record placement download")
+			continue
+		}
+		payload := modelDownloadPayload{DownloadID: download.ID, ModelID: model.ID, Name: model.Name}
+		if err := m.enqueueDownload(ctx, payload); err != nil {
+			m.db.ExecContext(ctx, "UPDATE model_downloads SET status = 'failed', error = $1, completed_at = NOW() WHERE id = $2", err.Error(), download.ID)
+			m.logger.Warn().Err(err).Str("model", model.Name).Msg("Failed to queue placement download")
+			continue
+		}
+
+		m.logger.Info().Str("model", model.Name).Strs("hosts", missing).Msg("Queued download of model missing from its hosts")
+		modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadQueued, Download: download})
+	}
+}
+
 // GetAllModels retrieves all models from the database
 func (m *ModelManager) GetAllModels(ctx context.Context) ([]models.Model, error) {
 	query := `
-		SELECT id, name, display_name, description, size, family, format,
-		       parameters, quantization, status, is_default, is_enabled,
-		       supports_embeddings, embedding_dimensions, created_at, updated_at, last_used_at
+		SELECT `+modelColumns+`
 		FROM models
 		ORDER BY is_default DESC, name ASC
 	`
@@ -166,24 +271,12 @@ func (m *ModelManager) GetAllModels(ctx context.Context) ([]models.Model, error)
 
 	var modelList []models.Model
 	for rows.Next() {
-		var model models.Model
-		var lastUsedAt sql.NullTime
-
-		err := rows.Scan(
-			&model.ID, &model.Name, &model.DisplayName, &model.Description,
-			&model.Size, &model.Family, &model.Format, &model.Parameters,
-			&model.Quantization, &model.Status, &model.IsDefault, &model.IsEnabled,
-			&model.SupportsEmbeddings, &model.EmbeddingDimensions, &model.CreatedAt, &model.UpdatedAt, &lastUsedAt,
-		)
+		model, err := scanModel(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan model: %w", err)
 		}
 
-		if lastUsedAt.Valid {
-			model.LastUsedAt = &lastUsedAt.Time
-		}
-
-		modelList = append(modelList, model)
+		modelList = append(modelList, *model)
 	}
 
 	return modelList, nil
@@ -192,9 +285,7 @@ func (m *ModelManager) GetAllModels(ctx context.Context) ([]models.Model, error)
 // GetAvailableModels retrieves only available and enabled models
 func (m *ModelManager) GetAvailableModels(ctx context.Context) ([]models.Model, error) {
 	query := `
-		SELECT id, name, display_name, description, size, family, format,
-		       parameters, quantization, status, is_default, is_enabled,
-		       supports_embeddings, embedding_dimensions, created_at, updated_at, last_used_at
+		SELECT `+modelColumns+`
 		FROM models
 		WHERE status = 'available' AND is_enabled = TRUE
 		ORDER BY is_default DESC, name ASC
@@ -208,24 +299,12 @@ func (m *ModelManager) GetAvailableModels(ctx context.Context) ([]models.Model,
 
 	var modelList []models.Model
 	for rows.Next() {
-		var model models.Model
-		var lastUsedAt sql.NullTime
-
-		err := rows.Scan(
-			&model.ID, &model.Name, &model.DisplayName, &model.Description,
-			&model.Size, &model.Family, &model.Format, &model.Parameters,
-			&model.Quantization, &model.Status, &model.IsDefault, &model.IsEnabled,
-			&model.SupportsEmbeddings, &model.EmbeddingDimensions, &model.CreatedAt, &model.UpdatedAt, &lastUsedAt,
-		)
+		model, err := scanModel(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan model: %w", err)
 		}
 
-		if lastUsedAt.Valid {
-			model.LastUsedAt = &lastUsedAt.Time
-		}
-
-		modelList = append(modelList, model)
+		modelList = append(modelList, *model)
 	}
 
 	return modelList, nil
@@ -234,23 +313,12 @@ func (m *ModelManager) GetAvailableModels(ctx context.Context) ([]models.Model,
 // GetModelByID retrieves a model by its ID
 func (m *ModelManager) GetModelByID(ctx context.Context, id string) (*models.Model, error) {
 	query := `
-		SELECT id, name, display_name, description, size, family, format,
-		       parameters, quantization, status, is_default, is_enabled,
-		       supports_embeddings, embedding_dimensions, created_at, updated_at, last_used_at
+		SELECT `+modelColumns+`
 		FROM models
 		WHERE id = $1
 	`
 
-	var model models.Model
-	var lastUsedAt sql.NullTime
-
-	err := m.db.QueryRowContext(ctx, query, id).Scan(
-		&model.ID, &model.Name, &model.DisplayName, &model.Description,
-		&model.Size, &model.Family, &model.Format, &model.Parameters,
-		&model.Quantization, &model.Status, &model.IsDefault, &model.IsEnabled,
-		&model.SupportsEmbeddings, &model.EmbeddingDimensions, &model.CreatedAt, &model.UpdatedAt, &lastUsedAt,
-	)
-
+	model, err := scanModel(m.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("model not found")
@@ -258,33 +326,18 @@ func (m *ModelManager) GetModelByID(ctx context.Context, id string) (*models.Mod
 		return nil, fmt.Errorf("failed to get model: %w", err)
 	}
 
-	if lastUsedAt.Valid {
-		model.LastUsedAt = &lastUsedAt.Time
-	}
-
-	return &model, nil
+	return model, nil
 }
 
 // GetModelByName retrieves a model by its name
 func (m *ModelManager) GetModelByName(ctx context.Context, name string) (*models.Model, error) {
 	query := `
-		SELECT id, name, display_name, description, size, family, format,
-		       parameters, quantization, status, is_default, is_enabled,
-		       supports_embeddings, embedding_dimensions, created_at, updated_at, last_used_at
+		SELECT `+modelColumns+`
 		FROM models
 		WHERE name = $1
 	`
 
-	var model models.Model
-	var lastUsedAt sql.NullTime
-
-	err := m.db.QueryRowContext(ctx, query, name).Scan(
-		&model.ID, &model.Name, &model.DisplayName, &model.Description,
-		&model.Size, &model.Family, &model.Format, &model.Parameters,
-		&model.Quantization, &model.Status, &model.IsDefault, &model.IsEnabled,
-		&model.SupportsEmbeddings, &model.EmbeddingDimensions, &model.CreatedAt, &model.UpdatedAt, &lastUsedAt,
-	)
-
+	model, err := scanModel(m.db.QueryRowContext(ctx, query, name))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("model not found")
@@ -292,11 +345,7 @@ func (m *ModelManager) GetModelByName(ctx context.Context, name string) (*models
 		return nil, fmt.Errorf("failed to get model: %w", err)
 	}
 
-	if lastUsedAt.Valid {
-		model.LastUsedAt = &lastUsedAt.Time
-	}
-
-	return &model, nil
+	return model, nil
 }
 
 // CreateModel creates a new model in the database
@@ -304,15 +353,22 @@ func (m *ModelManager) CreateModel(ctx context.Context, model models.Model) erro
 	query := `
 		INSERT INTO models (id, name, display_name, description, size, family, format,
 		                   parameters, quantization, status, is_default, is_enabled,
-		                   supports_embeddings, embedding_dimensions, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		                   supports_embeddings, embedding_dimensions, source, base_model,
+		                   parent_model_id, modelfile, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	`
 
+	if model.Source == "" {
+		model.Source = models.ModelSourceLibrary
+	}
+
 	_, err := m.db.ExecContext(ctx, query,
 		model.ID, model.Name, model.DisplayName, model.Description,
 		model.Size, model.Family, model.Format, model.Parameters,
 		model.Quantization, model.Status, model.IsDefault, model.IsEnabled,
-		model.SupportsEmbeddings, model.EmbeddingDimensions, model.CreatedAt, model.UpdatedAt,
+		model.SupportsEmbeddings, model.EmbeddingDimensions, model.Source, model.BaseModel,
+		sql.NullString{String: model.ParentModelID, Valid: model.ParentModelID != ""}, model.Modelfile,
+		model.CreatedAt, model.UpdatedAt,
 	)
 
 	if err != nil {
@@ -361,6 +417,11 @@ func (m *ModelManager) UpdateModel(ctx context.Context, id string, req models.Mo
 		args = append(args, *req.IsEnabled)
 		argIndex++
 	}
+	if req.IsPinned != nil {
+		setParts = append(setParts, fmt.Sprintf("is_pinned = $%d", argIndex))
+		args = append(args, *req.IsPinned)
+		argIndex++
+	}
 
 	if len(setParts) == 0 {
 		return fmt.Errorf("no fields to update")
@@ -499,7 +560,7 @@ func (m *ModelManager) RestoreModel(ctx context.Context, id string) error {
 func (m *ModelManager) GetModelConfig(ctx context.Context, modelID string) (*models.ModelConfig, error) {
 	query := `
 		SELECT id, model_id, temperature, top_p, top_k, repeat_penalty,
-		       context_length, max_tokens, system_prompt, created_at, updated_at
+		       context_length, max_tokens, system_prompt, keep_alive, created_at, updated_at
 		FROM model_configs
 		WHERE model_id = $1
 	`
@@ -510,7 +571,7 @@ func (m *ModelManager) GetModelConfig(ctx context.Context, modelID string) (*mod
 
 	err := m.db.QueryRowContext(ctx, query, modelID).Scan(
 		&config.ID, &config.ModelID, &temperature, &topP, &topK, &repeatPenalty,
-		&contextLength, &maxTokens, &config.SystemPrompt, &config.CreatedAt, &config.UpdatedAt,
+		&contextLength, &maxTokens, &config.SystemPrompt, &config.KeepAlive, &config.CreatedAt, &config.UpdatedAt,
 	)
 
 	if err != nil {
@@ -550,13 +611,13 @@ func (m *ModelManager) GetModelConfig(ctx context.Context, modelID string) (*mod
 func (m *ModelManager) CreateModelConfig(ctx context.Context, config models.ModelConfig) error {
 	query := `
 		INSERT INTO model_configs (id, model_id, temperature, top_p, top_k, repeat_penalty,
-		                          context_length, max_tokens, system_prompt, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		                          context_length, max_tokens, system_prompt, keep_alive, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 
 	_, err := m.db.ExecContext(ctx, query,
 		config.ID, config.ModelID, config.Temperature, config.TopP, config.TopK,
-		config.RepeatPenalty, config.ContextLength, config.MaxTokens, config.SystemPrompt,
+		config.RepeatPenalty, config.ContextLength, config.MaxTokens, config.SystemPrompt, config.KeepAlive,
 		config.CreatedAt, config.UpdatedAt,
 	)
 
@@ -609,6 +670,14 @@ func (m *ModelManager) UpdateModelConfig(ctx context.Context, modelID string, re
 		args = append(args, *req.SystemPrompt)
 		argIndex++
 	}
+	if req.KeepAlive != nil {
+		if err := ValidateKeepAlive(*req.KeepAlive); err != nil {
+			return err
+		}
+		setParts = append(setParts, fmt.Sprintf("keep_alive = $%d", argIndex))
+		args = append(args, *req.KeepAlive)
+		argIndex++
+	}
 
 	if len(setParts) == 0 {
 		return fmt.Errorf("no fields to update")
@@ -684,24 +753,13 @@ func (m *ModelManager) GetModelUsageStats(ctx context.Context, modelID string) (
 // GetDefaultModel retrieves the default model
 func (m *ModelManager) GetDefaultModel(ctx context.Context) (*models.Model, error) {
 	query := `
-		SELECT id, name, display_name, description, size, family, format,
-		       parameters, quantization, status, is_default, is_enabled,
-		       supports_embeddings, embedding_dimensions, created_at, updated_at, last_used_at
+		SELECT `+modelColumns+`
 		FROM models
 		WHERE is_default = TRUE AND is_enabled = TRUE AND status = 'available'
 		LIMIT 1
 	`
 
-	var model models.Model
-	var lastUsedAt sql.NullTime
-
-	err := m.db.QueryRowContext(ctx, query).Scan(
-		&model.ID, &model.Name, &model.DisplayName, &model.Description,
-		&model.Size, &model.Family, &model.Format, &model.Parameters,
-		&model.Quantization, &model.Status, &model.IsDefault, &model.IsEnabled,
-		&model.SupportsEmbeddings, &model.EmbeddingDimensions, &model.CreatedAt, &model.UpdatedAt, &lastUsedAt,
-	)
-
+	model, err := scanModel(m.db.QueryRowContext(ctx, query))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("no default model found")
@@ -709,11 +767,7 @@ func (m *ModelManager) GetDefaultModel(ctx context.Context) (*models.Model, erro
 		return nil, fmt.Errorf("failed to get default model: %w", err)
 	}
 
-	if lastUsedAt.Valid {
-		model.LastUsedAt = &lastUsedAt.Time
-	}
-
-	return &model, nil
+	return model, nil
 }
 
 // SetDefaultModel sets a model as the default
@@ -767,6 +821,39 @@ func (m *ModelManager) ValidateModel(ctx context.Context, modelName string) erro
 	return nil
 }
 
+// modelColumns are the columns scanModel reads, in order
+const modelColumns = `id, name, display_name, description, size, family, format,
+		       parameters, quantization, status, is_default, is_enabled, is_pinned,
+		       supports_embeddings, embedding_dimensions, context_length, source, base_model,
+		       parent_model_id, modelfile, digest, capabilities, supports_vision, supports_tools,
+		       template, license, created_at, updated_at, last_used_at`
+
+// scanModel scans a row selected with modelColumns
+func scanModel(row rowScanner) (*models.Model, error) {
+	var model models.Model
+	var parentModelID sql.NullString
+	var lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&model.ID, &model.Name, &model.DisplayName, &model.Description,
+		&model.Size, &model.Family, &model.Format, &model.Parameters,
+		&model.Quantization, &model.Status, &model.IsDefault, &model.IsEnabled, &model.IsPinned,
+		&model.SupportsEmbeddings, &model.EmbeddingDimensions, &model.ContextLength, &model.Source, &model.BaseModel,
+		&parentModelID, &model.Modelfile, &model.Digest, pq.Array(&model.Capabilities), &model.SupportsVision, &model.SupportsTools,
+		&model.Template, &model.License, &model.CreatedAt, &model.UpdatedAt, &lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	model.ParentModelID = parentModelID.String
+	if lastUsedAt.Valid {
+		model.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return &model, nil
+}
+
 // updateModelStatus updates the status of a model
 func (m *ModelManager) updateModelStatus(ctx context.Context, id, status string) error {
 	if !models.ValidateModelStatus(status) {
@@ -860,12 +947,29 @@ func (m *ModelManager) GetModelDetails(ctx context.Context, modelID string) (*mo
 
 // DownloadModel initiates a model download
 func (m *ModelManager) DownloadModel(ctx context.Context, req models.ModelDownloadRequest) (*models.ModelDownloadResponse, error) {
+	if m.jobs == nil {
+		return nil, fmt.Errorf("job queue not configured")
+	}
+
 	// Check if model already exists
 	existingModel, err := m.GetModelByName(ctx, req.Name)
 	if err == nil && existingModel.Status == "available" {
 		return nil, fmt.Errorf("model %s already exists and is available", req.Name)
 	}
 
+	// A model is downloaded once at a time
+	if existingModel != nil {
+		if download, err := m.latestDownload(ctx, existingModel.ID); err == nil && download.IsActive() {
+			return &models.ModelDownloadResponse{
+				ID:         existingModel.ID,
+				Name:       req.Name,
+				Status:     "downloading",
+				DownloadID: download.ID,
+				Message:    "Model download already in progress",
+			}, nil
+		}
+	}
+
 	// Create or update model record
 	var modelID string
 	if existingModel != nil {
@@ -900,68 +1004,181 @@ func (m *ModelManager) DownloadModel(ctx context.Context, req models.ModelDownlo
 		modelID = newModel.ID
 	}
 
-	// Start download in background
+	download, err := m.createDownload(ctx, modelID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download in the background on the job queue
+	payload := modelDownloadPayload{DownloadID: download.ID, ModelID: modelID, Name: req.Name}
+	if err := m.enqueueDownload(ctx, payload); err != nil {
+		m.db.ExecContext(ctx, "UPDATE model_downloads SET status = 'failed', error = $1, completed_at = NOW() WHERE id = $2", err.Error(), download.ID)
+		m.updateModelStatus(ctx, modelID, "error")
+		return nil, fmt.Errorf("failed to queue model download: %w", err)
+	}
+
+	modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadQueued, Download: download})
+
+	return &models.ModelDownloadResponse{
+		ID:         modelID,
+		Name:       req.Name,
+		Status:     "downloading",
+		DownloadID: download.ID,
+		Message:    "Model download queued",
+	}, nil
+}
+
+// handleModelDownload pulls a model from Ollama once a download slot is free, persisting
+// and publishing its progress. A failed pull marks the model as errored and is retried by
+// the queue; a cancelled download stops without retry.
+func (m *ModelManager) handleModelDownload(ctx context.Context, raw json.RawMessage) error {
+	var payload modelDownloadPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Errorf("invalid model download payload: %w", err)
+	}
+
+	download, err := m.downloadForJob(ctx, payload)
+	if err != nil {
+		return err
+	}
+	if download == nil {
+		return nil
+	}
+
+	claimed, err := m.claimDownloadSlot(ctx, download.ID)
+	if errors.Is(err, errDownloadCancelled) {
+		m.logger.Debug().Str("download_id", download.ID).Msg("Skipping model download that is no longer active")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return DeferJob(downloadSlotRetry, "download concurrency limit reached")
+	}
+
+	if err := m.updateModelStatus(ctx, payload.ModelID, "downloading"); err != nil {
+		return err
+	}
+	download.Status = models.DownloadStatusDownloading
+	download.Error = ""
+	modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadProgress, Download: download})
+
+	pullCtx, cancelPull := context.WithCancelCause(ctx)
+	defer cancelPull(nil)
+
+	runningDownloads.Lock()
+	runningDownloads.cancels[download.ID] = cancelPull
+	runningDownloads.Unlock()
+	defer func() {
+		runningDownloads.Lock()
+		delete(runningDownloads.cancels, download.ID)
+		runningDownloads.Unlock()
+	}()
+
+	progressChan := make(chan models.ModelDownloadProgress, 100)
+	pullErr := make(chan error, 1)
 	go func() {
-		progressChan := make(chan models.ModelDownloadProgress, 100)
-		
-		// Start the download
-		go func() {
-			defer close(progressChan)
-			if err := m.ollamaClient.PullModel(context.Background(), req.Name, progressChan); err != nil {
-				m.logger.Error().Err(err).Str("model", req.Name).Msg("Model download failed")
-				m.updateModelStatus(context.Background(), modelID, "error")
-				return
-			}
-		}()
+		// PullModel closes progressChan when it returns
+		pullErr <- m.ollamaClient.PullModel(pullCtx, payload.Name, progressChan)
+	}()
 
-		// Process progress updates
-		for progress := range progressChan {
-			m.progressMutex.Lock()
-			m.downloadProgress[modelID] = progress.Percentage
-			m.progressMutex.Unlock()
+	var failure string
+	var lastSaved time.Time
+	for progress := range progressChan {
+		phase := download.Phase
+		applyDownloadProgress(download, progress)
 
-			if progress.Status == "error" {
-				m.updateModelStatus(context.Background(), modelID, "error")
-				break
-			}
-			
-			// Check for completion
-			if progress.Percentage >= 100 || progress.Status == "success" {
-				m.updateModelStatus(context.Background(), modelID, "available")
-				
-				// Sync to get updated model info
-				m.SyncModels(context.Background())
-				break
-			}
+		if progress.Status == "error" && failure == "" {
+			failure = progress.Error
 		}
 
-		// Clean up progress tracking
-		m.progressMutex.Lock()
-		delete(m.downloadProgress, modelID)
-		m.progressMutex.Unlock()
-	}()
+		if download.Phase == phase && time.Since(lastSaved) < downloadProgressInterval {
+			continue
+		}
+		lastSaved = time.Now()
 
-	return &models.ModelDownloadResponse{
-		ID:      modelID,
-		Name:    req.Name,
-		Status:  "downloading",
-		Message: "Model download started",
-	}, nil
+		running, err := m.saveDownloadProgress(pullCtx, download)
+		if err != nil {
+			m.logger.Warn().Err(err).Str("download_id", download.ID).Msg("Failed to save download progress")
+		} else if !running {
+			// Cancelled on another instance
+			cancelPull(errDownloadCancelled)
+			continue
+		}
+		modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadProgress, Download: download})
+	}
+
+	err = <-pullErr
+	if err == nil && failure != "" {
+		err = fmt.Errorf("%s", failure)
+	}
+
+	// Record the outcome even if the job was interrupted
+	finishCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case errors.Is(context.Cause(pullCtx), errDownloadCancelled):
+		// CancelDownload recorded the cancellation
+		m.logger.Info().Str("model", payload.Name).Msg("Model download stopped after cancellation")
+		return nil
+	case err != nil && ctx.Err() != nil:
+		// Interrupted by shutdown: the download stays active and resumes with the released job
+		if _, saveErr := m.saveDownloadProgress(finishCtx, download); saveErr != nil {
+			m.logger.Warn().Err(saveErr).Str("download_id", download.ID).Msg("Failed to save download progress")
+		}
+		return err
+	case err != nil:
+		m.logger.Error().Err(err).Str("model", payload.Name).Msg("Model download failed")
+		if finishErr := m.finishDownload(finishCtx, download, models.DownloadStatusFailed, err.Error()); finishErr != nil {
+			m.logger.Error().Err(finishErr).Str("download_id", download.ID).Msg("Failed to record download failure")
+		}
+		m.updateModelStatus(finishCtx, payload.ModelID, "error")
+		modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadFailed, Download: download})
+		return err
+	}
+
+	download.Progress = 100
+	if err := m.finishDownload(finishCtx, download, models.DownloadStatusCompleted, ""); err != nil {
+		return err
+	}
+	if err := m.updateModelStatus(ctx, payload.ModelID, "available"); err != nil {
+		return err
+	}
+	modelEvents.publish(models.ModelEvent{Type: models.ModelEventDownloadCompleted, Download: download})
+
+	// Sync to get updated model info
+	if err := m.SyncModels(ctx); err != nil {
+		m.logger.Warn().Err(err).Str("model", payload.Name).Msg("Failed to sync models after download")
+	}
+
+	return nil
 }
 
-// GetAvailableModelsFromOllama retrieves available models from Ollama library
-func (m *ModelManager) GetAvailableModelsFromOllama(ctx context.Context) ([]string, error) {
+// GetCatalogModels lists the models in the catalog that match a filter
+func (m *ModelManager) GetCatalogModels(ctx context.Context, filter models.CatalogFilter) ([]models.CatalogModel, error) {
 	m.cacheMutex.RLock()
 	if time.Since(m.cacheLastUpdated) < m.cacheTTL && len(m.availableModelsCache) > 0 {
-		models := make([]string, len(m.availableModelsCache))
-		copy(models, m.availableModelsCache)
+		catalog := FilterCatalog(m.availableModelsCache, filter)
 		m.cacheMutex.RUnlock()
-		return models, nil
+		return catalog, nil
 	}
 	m.cacheMutex.RUnlock()
 
 	// Cache is stale or empty, refresh it
-	return m.refreshAvailableModelsCache(ctx)
+	catalog, err := m.refreshAvailableModelsCache(ctx)
+	if err != nil {
+		// Serve a stale catalog rather than none while every source is failing
+		m.cacheMutex.RLock()
+		defer m.cacheMutex.RUnlock()
+		if len(m.availableModelsCache) == 0 {
+			return nil, err
+		}
+		m.logger.Warn().Err(err).Msg("Serving stale model catalog")
+		return FilterCatalog(m.availableModelsCache, filter), nil
+	}
+	return FilterCatalog(catalog, filter), nil
 }
 
 // RefreshAvailableModelsCache refreshes the cache of available models
@@ -970,41 +1187,61 @@ func (m *ModelManager) RefreshAvailableModelsCache(ctx context.Context) error {
 	return err
 }
 
-// refreshAvailableModelsCache internal method to refresh cache
-func (m *ModelManager) refreshAvailableModelsCache(ctx context.Context) ([]string, error) {
+// refreshAvailableModelsCache reads the catalog from the first source that returns one
+func (m *ModelManager) refreshAvailableModelsCache(ctx context.Context) ([]models.CatalogModel, error) {
 	m.logger.Info().Msg("Refreshing available models cache")
 
-	models, err := m.ollamaClient.GetLibraryModels(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get library models: %w", err)
+	m.cacheMutex.RLock()
+	sources := m.catalogSources
+	m.cacheMutex.RUnlock()
+	if len(sources) == 0 {
+		sources = []CatalogSource{NewLibraryCatalogSource(m.ollamaClient)}
 	}
 
-	m.cacheMutex.Lock()
-	m.availableModelsCache = models
-	m.cacheLastUpdated = time.Now()
-	m.cacheMutex.Unlock()
+	var lastErr error
+	for _, source := range sources {
+		catalog, err := source.Models(ctx)
+		if err != nil {
+			m.logger.Warn().Err(err).Str("source", source.Name()).Msg("Model catalog source failed")
+			lastErr = err
+			continue
+		}
+
+		m.cacheMutex.Lock()
+		m.availableModelsCache = catalog
+		m.cacheSource = source.Name()
+		m.cacheLastUpdated = time.Now()
+		m.cacheMutex.Unlock()
+
+		m.logger.Info().Int("model_count", len(catalog)).Str("source", source.Name()).Msg("Available models cache refreshed")
+		return catalog, nil
+	}
 
-	m.logger.Info().Int("model_count", len(models)).Msg("Available models cache refreshed")
-	return models, nil
+	return nil, fmt.Errorf("failed to get model catalog: %w", lastErr)
 }
 
-// GetModelDownloadStatus retrieves download status for a model
-func (m *ModelManager) GetModelDownloadStatus(ctx context.Context, modelID string) (*models.Model, error) {
+// GetModelDownloadStatus retrieves download status for a model along with its most
+// recent download, if any
+func (m *ModelManager) GetModelDownloadStatus(ctx context.Context, modelID string) (*models.Model, *models.ModelDownload, error) {
 	model, err := m.GetModelByID(ctx, modelID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	download, err := m.latestDownload(ctx, modelID)
+	if err == sql.ErrNoRows {
+		return model, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get model download: %w", err)
 	}
 
 	// Add progress information if downloading
 	if model.Status == "downloading" {
-		m.progressMutex.RLock()
-		if progress, exists := m.downloadProgress[modelID]; exists {
-			model.Progress = progress
-		}
-		m.progressMutex.RUnlock()
+		model.Progress = download.Progress
 	}
 
-	return model, nil
+	return model, download, nil
 }
 
 // GetCacheInfo returns information about the models cache
@@ -1014,6 +1251,7 @@ func (m *ModelManager) GetCacheInfo() map[string]interface{} {
 
 	return map[string]interface{}{
 		"cache_size":        len(m.availableModelsCache),
+		"source":            m.cacheSource,
 		"last_updated":      m.cacheLastUpdated,
 		"cache_ttl_hours":   m.cacheTTL.Hours(),
 		"cache_valid":       time.Since(m.cacheLastUpdated) < m.cacheTTL,
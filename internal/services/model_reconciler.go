@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+// ModelReconciler keeps the models table in sync with the models installed in Ollama,
+// so that models pulled or deleted with the ollama CLI show up without a manual sync
+type ModelReconciler struct {
+	config *config.Config
+	jobs   *JobQueue
+	logger *utils.Logger
+}
+
+// NewModelReconciler creates a reconciler queuing syncs on the queue the model manager
+// registered its jobs on
+func NewModelReconciler(jobs *JobQueue, cfg *config.Config, logger *utils.Logger) *ModelReconciler {
+	return &ModelReconciler{
+		config: cfg,
+		jobs:   jobs,
+		logger: logger.WithComponent("model_reconciler"),
+	}
+}
+
+// Name returns the worker name
+func (r *ModelReconciler) Name() string {
+	return "model_reconciler"
+}
+
+// Run periodically queues a model sync until ctx is cancelled
+func (r *ModelReconciler) Run(ctx context.Context) {
+	if !r.config.EnableModelReconciler {
+		r.logger.Info().Msg("Model reconciler disabled")
+		return
+	}
+
+	r.logger.Info().Dur("interval", r.config.ModelReconcileInterval).Msg("Starting model reconciler")
+
+	runPeriodically(ctx, r.config.ModelReconcileInterval, r.logger, func(ctx context.Context) error {
+		// Deduplicated, so several instances still only run it once
+		_, err := r.jobs.Enqueue(ctx, JobTypeReconcileModels, nil, JobOptions{DedupeKey: JobTypeReconcileModels})
+		return err
+	})
+}
+
+// handleReconcileModels runs a queued model sync. Models over the storage budget are
+// evicted first, so the sync lists Ollama's models after the eviction and does not queue
+// placement downloads of evicted models.
+func (m *ModelManager) handleReconcileModels(ctx context.Context, _ json.RawMessage) error {
+	if err := m.enforceStorageBudget(ctx); err != nil {
+		m.logger.Error().Err(err).Msg("Failed to enforce model storage budget")
+	}
+	return m.SyncModels(ctx)
+}
+
+// publishModelChange publishes a model event with the model as it is now
+func (m *ModelManager) publishModelChange(ctx context.Context, eventType, id string, changes []string) {
+	model, err := m.GetModelByID(ctx, id)
+	if err != nil {
+		m.logger.Warn().Err(err).Str("model_id", id).Msg("Failed to load model for event")
+		return
+	}
+	modelEvents.publish(models.ModelEvent{Type: eventType, Model: model, Changes: changes})
+}
+
+// repairDefaultModel keeps exactly one default model, and one that is available and
+// enabled. When the default was removed or disabled, the most recently used chat model
+// becomes the default; when no model can be, no model is.
+func (m *ModelManager) repairDefaultModel(ctx context.Context) error {
+	rows, err := m.db.QueryContext(ctx, "SELECT id FROM models WHERE is_default = TRUE")
+	if err != nil {
+		return fmt.Errorf("failed to query default models: %w", err)
+	}
+	var defaults []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan default model: %w", err)
+		}
+		defaults = append(defaults, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query default models: %w", err)
+	}
+
+	keep := ""
+	if current, err := m.GetDefaultModel(ctx); err == nil {
+		keep = current.ID
+	} else {
+		err := m.db.QueryRowContext(ctx, `
+			SELECT id FROM models
+			WHERE status = 'available' AND is_enabled = TRUE
+			  AND (capabilities = '{}' OR 'completion' = ANY(capabilities))
+			ORDER BY last_used_at DESC NULLS LAST, name ASC
+			LIMIT 1
+		`).Scan(&keep)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to find a default model: %w", err)
+		}
+	}
+
+	if (len(defaults) == 1 && defaults[0] == keep) || (len(defaults) == 0 && keep == "") {
+		return nil
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE models SET is_default = (id = $1), updated_at = CURRENT_TIMESTAMP
+		WHERE is_default = TRUE OR id = $1
+	`, keep)
+	if err != nil {
+		return fmt.Errorf("failed to repair default model: %w", err)
+	}
+
+	if keep == "" {
+		m.logger.Warn().Msg("No available model can be the default model")
+	} else {
+		m.logger.Info().Str("model_id", keep).Msg("Default model repaired")
+	}
+
+	// Publish the models whose default flag changed
+	changed := make(map[string]bool)
+	if keep != "" {
+		changed[keep] = true
+	}
+	for _, id := range defaults {
+		changed[id] = !changed[id]
+	}
+	for id, flipped := range changed {
+		if flipped {
+			m.publishModelChange(ctx, models.ModelEventUpdated, id, []string{"default"})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"chat_ollama/internal/models"
+)
+
+// ValidateKeepAlive checks a keep_alive value: a duration such as 30m, 0 to unload the
+// model right away, a negative duration to keep it loaded, or empty for the default
+func ValidateKeepAlive(keepAlive string) error {
+	if keepAlive == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(keepAlive); err != nil {
+		return fmt.Errorf("keep_alive must be a duration such as 30m, 0 or -1m")
+	}
+	return nil
+}
+
+// ListRunningModels lists the models Ollama has loaded, with the IDs of the registered
+// models they are
+func (m *ModelManager) ListRunningModels(ctx context.Context) ([]models.RunningModel, error) {
+	running, err := m.ollamaClient.ListRunningModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range running {
+		if model, err := m.GetModelByName(ctx, running[i].Name); err == nil {
+			running[i].ModelID = model.ID
+		}
+	}
+
+	return running, nil
+}
+
+// IsModelLoaded reports whether Ollama has a model loaded
+func (m *ModelManager) IsModelLoaded(ctx context.Context, modelName string) (bool, error) {
+	running, err := m.ollamaClient.ListRunningModels(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	name := normalizeModelName(modelName)
+	for _, model := range running {
+		if model.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// LoadModel preloads a model so the first chat does not wait for it to load. An empty
+// keepAlive uses the model's configured keep_alive.
+func (m *ModelManager) LoadModel(ctx context.Context, modelID, keepAlive string) (*models.RunningModel, error) {
+	if err := ValidateKeepAlive(keepAlive); err != nil {
+		return nil, err
+	}
+
+	model, err := m.GetModelByID(ctx, modelID)
+	if err != nil {
+		return nil, err
+	}
+	if model.Status != "available" {
+		return nil, fmt.Errorf("model is not available")
+	}
+
+	if keepAlive == "" {
+		keepAlive = m.KeepAliveFor(ctx, model.Name)
+	}
+
+	if err := m.ollamaClient.LoadModel(ctx, model.Name, keepAlive, isEmbeddingOnly(model)); err != nil {
+		return nil, err
+	}
+
+	running, err := m.ListRunningModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range running {
+		if entry.ModelID == model.ID {
+			return &entry, nil
+		}
+	}
+
+	// A keep_alive of 0 unloads the model as soon as it is loaded
+	return nil, fmt.Errorf("model is not loaded")
+}
+
+// UnloadModel unloads a model to free the memory it uses
+func (m *ModelManager) UnloadModel(ctx context.Context, modelID string) error {
+	model, err := m.GetModelByID(ctx, modelID)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := m.IsModelLoaded(ctx, model.Name)
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		return fmt.Errorf("model is not loaded")
+	}
+
+	return m.ollamaClient.UnloadModel(ctx, model.Name, isEmbeddingOnly(model))
+}
+
+// KeepAliveFor returns the keep_alive configured for a model, or an empty string to
+// leave it to Ollama
+func (m *ModelManager) KeepAliveFor(ctx context.Context, modelName string) string {
+	var keepAlive string
+	err := m.db.QueryRowContext(ctx, `
+		SELECT mc.keep_alive
+		FROM model_configs mc
+		JOIN models mo ON mo.id = mc.model_id
+		WHERE mo.name = $1
+	`, modelName).Scan(&keepAlive)
+	if err != nil {
+		return ""
+	}
+	return keepAlive
+}
+
+// isEmbeddingOnly reports whether a model can only embed, so it cannot be loaded with a
+// generate request
+func isEmbeddingOnly(model *models.Model) bool {
+	return model.HasCapability(models.ModelCapabilityEmbedding) && !model.HasCapability(models.ModelCapabilityCompletion)
+}
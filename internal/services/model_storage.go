@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"chat_ollama/internal/models"
+)
+
+// SetStorageBudget sets how many bytes the installed models may take, 0 meaning no
+// limit, and the models that are never evicted to stay within it: the models the
+// services are configured to use, such as the embedding model
+func (m *ModelManager) SetStorageBudget(budget int64, protected ...string) {
+	m.storageBudget = budget
+	m.protectedModels = make(map[string]bool, len(protected))
+	for _, name := range protected {
+		if name != "" {
+			m.protectedModels[normalizeModelName(name)] = true
+		}
+	}
+}
+
+// evictionGroup is a set of installed models that share their blobs: a model with its
+// copies and aliases, which all have the same digest. Deleting the models of a group
+// frees its size only once the last of them is deleted.
+type evictionGroup struct {
+	models    []*models.Model
+	size      int64
+	protected bool
+	lastUsed  int // Position of the most recently used model of the group
+}
+
+// PlanEviction lists the models EvictModels would remove to bring model storage down to
+// targetBytes, or to the storage budget when targetBytes is 0
+func (m *ModelManager) PlanEviction(ctx context.Context, targetBytes int64) (*models.ModelEvictionPlan, error) {
+	if targetBytes < 0 {
+		return nil, fmt.Errorf("target_bytes must not be negative")
+	}
+	if targetBytes == 0 {
+		if m.storageBudget == 0 {
+			return nil, fmt.Errorf("target_bytes is required when no storage budget is configured")
+		}
+		targetBytes = m.storageBudget
+	}
+
+	// Least recently used first, where a model counts as used when it was added or
+	// last downloaded, so a model just downloaded again is not evicted right away
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT `+modelColumns+`
+		FROM models
+		WHERE status = 'available'
+		ORDER BY GREATEST(last_used_at, created_at, (
+			SELECT MAX(d.completed_at) FROM model_downloads d
+			WHERE d.model_id = models.id AND d.status = 'completed'
+		)) ASC, size DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query models: %w", err)
+	}
+	defer rows.Close()
+
+	var installed []*models.Model
+	for rows.Next() {
+		model, err := scanModel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan model: %w", err)
+		}
+		installed = append(installed, model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query models: %w", err)
+	}
+
+	protected, err := m.evictionProtectedModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the models sharing blobs, in the order their most recently used model was used
+	groups := make(map[string]*evictionGroup)
+	var order []*evictionGroup
+	for i, model := range installed {
+		key := model.Digest
+		if key == "" {
+			key = "id:" + model.ID
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &evictionGroup{}
+			groups[key] = group
+			order = append(order, group)
+		}
+		group.models = append(group.models, model)
+		if model.Size > group.size {
+			group.size = model.Size
+		}
+		group.lastUsed = i
+		if model.IsPinned || model.IsDefault || protected[normalizeModelName(model.Name)] {
+			group.protected = true
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].lastUsed < order[j].lastUsed })
+
+	plan := &models.ModelEvictionPlan{
+		BudgetBytes: m.storageBudget,
+		TargetBytes: targetBytes,
+		Models:      []models.ModelEvictionCandidate{},
+		DryRun:      true,
+	}
+	for _, group := range order {
+		plan.UsedBytes += group.size
+	}
+
+	for _, group := range order {
+		if plan.UsedBytes-plan.FreedBytes <= targetBytes {
+			break
+		}
+		if group.protected {
+			continue
+		}
+		for i, model := range group.models {
+			candidate := models.ModelEvictionCandidate{
+				ModelID:    model.ID,
+				Name:       model.Name,
+				Digest:     model.Digest,
+				Size:       model.Size,
+				LastUsedAt: model.LastUsedAt,
+			}
+			// The blobs are freed with the last model referencing them
+			if i == len(group.models)-1 {
+				candidate.FreedBytes = group.size
+			}
+			plan.Models = append(plan.Models, candidate)
+		}
+		plan.FreedBytes += group.size
+	}
+
+	if over := plan.UsedBytes - plan.FreedBytes - targetBytes; over > 0 {
+		plan.Shortfall = over
+	}
+	return plan, nil
+}
+
+// evictionProtectedModels returns the normalized names of the models that must not be
+// evicted beyond pinned and default ones: the configured models, the embedding models
+// memory is stored under, the models Ollama has loaded and the models being downloaded
+func (m *ModelManager) evictionProtectedModels(ctx context.Context) (map[string]bool, error) {
+	protected := make(map[string]bool, len(m.protectedModels))
+	for name := range m.protectedModels {
+		protected[name] = true
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT name FROM embedding_models
+		UNION
+		SELECT DISTINCT embedding_model FROM users WHERE embedding_model IS NOT NULL AND embedding_model <> ''
+		UNION
+		SELECT model_name FROM model_downloads WHERE status IN ('queued', 'downloading')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query models in use: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan model in use: %w", err)
+		}
+		protected[normalizeModelName(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query models in use: %w", err)
+	}
+
+	// Models in memory are in use, so they are kept
+	if running, err := m.ollamaClient.ListRunningModels(ctx); err != nil {
+		m.logger.Warn().Err(err).Msg("Failed to list running models for eviction")
+	} else {
+		for _, model := range running {
+			protected[normalizeModelName(model.Name)] = true
+		}
+	}
+
+	return protected, nil
+}
+
+// EvictModels deletes the least recently used models that are not pinned, the default,
+// configured or loaded from Ollama until model storage is down to targetBytes, or to the
+// storage budget when targetBytes is 0. Evicted models are marked removed, so they can be
+// downloaded again later.
+func (m *ModelManager) EvictModels(ctx context.Context, targetBytes int64) (*models.ModelEvictionPlan, error) {
+	plan, err := m.PlanEviction(ctx, targetBytes)
+	if err != nil {
+		return nil, err
+	}
+	plan.DryRun = false
+
+	// The blobs of a group stay on disk when any of its models could not be deleted
+	failed := make(map[string]bool)
+	for i := range plan.Models {
+		candidate := &plan.Models[i]
+		if err := m.ollamaClient.DeleteModel(ctx, candidate.Name); err != nil {
+			m.logger.Error().Err(err).Str("model", candidate.Name).Msg("Failed to evict model")
+			candidate.Error = err.Error()
+			failed[evictionGroupKey(candidate)] = true
+			continue
+		}
+		if err := m.updateModelStatus(ctx, candidate.ModelID, "removed"); err != nil {
+			m.logger.Error().Err(err).Str("model", candidate.Name).Msg("Failed to mark evicted model as removed")
+		}
+
+		m.logger.Info().
+			Str("model", candidate.Name).
+			Int64("size", candidate.Size).
+			Int64("freed_bytes", candidate.FreedBytes).
+			Msg("Evicted least recently used model")
+		m.publishModelChange(ctx, models.ModelEventRemoved, candidate.ModelID, nil)
+	}
+
+	plan.FreedBytes = 0
+	for i := range plan.Models {
+		candidate := &plan.Models[i]
+		if failed[evictionGroupKey(candidate)] {
+			candidate.FreedBytes = 0
+		}
+		plan.FreedBytes += candidate.FreedBytes
+	}
+	plan.Shortfall = 0
+	if over := plan.UsedBytes - plan.FreedBytes - plan.TargetBytes; over > 0 {
+		plan.Shortfall = over
+	}
+	return plan, nil
+}
+
+// evictionGroupKey returns the key of the group of models sharing a candidate's blobs
+func evictionGroupKey(candidate *models.ModelEvictionCandidate) string {
+	if candidate.Digest == "" {
+		return "id:" + candidate.ModelID
+	}
+	return candidate.Digest
+}
+
+// enforceStorageBudget evicts models while the installed models exceed the storage budget
+func (m *ModelManager) enforceStorageBudget(ctx context.Context) error {
+	if m.storageBudget == 0 {
+		return nil
+	}
+
+	plan, err := m.EvictModels(ctx, 0)
+	if err != nil {
+		return err
+	}
+	if plan.Shortfall > 0 {
+		m.logger.Warn().
+			Int64("budget", m.storageBudget).
+			Int64("shortfall", plan.Shortfall).
+			Msg("Model storage exceeds the budget with only protected models left")
+	}
+	return nil
+}
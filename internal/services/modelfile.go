@@ -0,0 +1,303 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"chat_ollama/internal/models"
+)
+
+// blobDigestPattern matches the digest of a blob uploaded to Ollama
+var blobDigestPattern = regexp.MustCompile(`^sha256[:-][0-9a-f]{64}$`)
+
+// modelNamePattern matches an Ollama model name: [host/][namespace/]model[:tag]
+var modelNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*(/[a-zA-Z0-9][a-zA-Z0-9._-]*){0,2}(:[a-zA-Z0-9][a-zA-Z0-9._-]{0,127})?$`)
+
+// Types of the Modelfile parameters Ollama accepts
+var (
+	modelfileIntParameters = map[string]bool{
+		"num_ctx": true, "num_batch": true, "num_gpu": true, "main_gpu": true, "num_thread": true,
+		"num_keep": true, "num_predict": true, "top_k": true, "repeat_last_n": true, "mirostat": true,
+		"seed": true,
+	}
+	modelfileFloatParameters = map[string]bool{
+		"temperature": true, "top_p": true, "min_p": true, "typical_p": true, "repeat_penalty": true,
+		"presence_penalty": true, "frequency_penalty": true, "mirostat_tau": true, "mirostat_eta": true,
+		"tfs_z": true,
+	}
+	modelfileBoolParameters = map[string]bool{
+		"penalize_newline": true, "numa": true, "use_mmap": true, "use_mlock": true, "low_vram": true,
+		"vocab_only": true,
+	}
+	modelfileListParameters = map[string]bool{
+		"stop": true,
+	}
+)
+
+// ParseModelfile parses the text of a Modelfile. Instructions are case-insensitive,
+// lines starting with # are comments, and arguments may be quoted with " or, spanning
+// several lines, with """.
+func ParseModelfile(text string) (*models.Modelfile, error) {
+	var modelfile models.Modelfile
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		instruction, rest := splitModelfileField(line)
+		var err error
+		var arg string
+		switch strings.ToUpper(instruction) {
+		case "FROM":
+			modelfile.From = rest
+		case "ADAPTER":
+			modelfile.Adapters = append(modelfile.Adapters, rest)
+		case "SYSTEM":
+			arg, i, err = modelfileArgument(lines, i, rest)
+			modelfile.System = arg
+		case "TEMPLATE":
+			arg, i, err = modelfileArgument(lines, i, rest)
+			modelfile.Template = arg
+		case "PARAMETER":
+			name, value := splitModelfileField(rest)
+			value, i, err = modelfileArgument(lines, i, value)
+			modelfile.Parameters = append(modelfile.Parameters, models.ModelfileParameter{
+				Name:  strings.ToLower(name),
+				Value: value,
+			})
+		case "MESSAGE":
+			role, content := splitModelfileField(rest)
+			content, i, err = modelfileArgument(lines, i, content)
+			modelfile.Messages = append(modelfile.Messages, models.ModelfileMessage{
+				Role:    strings.ToLower(role),
+				Content: content,
+			})
+		default:
+			return nil, fmt.Errorf("line %d: unknown instruction %s", lineNumber, instruction)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+
+	if err := ValidateModelfile(modelfile); err != nil {
+		return nil, err
+	}
+	return &modelfile, nil
+}
+
+// splitModelfileField splits the first whitespace-separated field off a line
+func splitModelfileField(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if index := strings.IndexAny(line, " \t"); index >= 0 {
+		return line[:index], strings.TrimSpace(line[index+1:])
+	}
+	return line, ""
+}
+
+// modelfileArgument reads a possibly quoted argument starting at lines[index], returning
+// it with the index of the line it ends on
+func modelfileArgument(lines []string, index int, value string) (string, int, error) {
+	switch {
+	case strings.HasPrefix(value, `"""`):
+		value = value[3:]
+		if end := strings.Index(value, `"""`); end >= 0 {
+			return value[:end], index, nil
+		}
+		parts := []string{value}
+		for index++; index < len(lines); index++ {
+			if end := strings.Index(lines[index], `"""`); end >= 0 {
+				parts = append(parts, lines[index][:end])
+				return strings.Join(parts, "\n"), index, nil
+			}
+			parts = append(parts, lines[index])
+		}
+		return "", index, fmt.Errorf(`unterminated """ quote`)
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", index, fmt.Errorf("invalid quoted argument %s", value)
+		}
+		return unquoted, index, nil
+	}
+	return value, index, nil
+}
+
+// FormatModelfile renders a Modelfile as text that ParseModelfile reads back
+func FormatModelfile(modelfile models.Modelfile) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "FROM %s\n", modelfile.From)
+	for _, adapter := range modelfile.Adapters {
+		fmt.Fprintf(&b, "ADAPTER %s\n", adapter)
+	}
+	if modelfile.Template != "" {
+		fmt.Fprintf(&b, "TEMPLATE %s\n", quoteModelfileArgument(modelfile.Template))
+	}
+	if modelfile.System != "" {
+		fmt.Fprintf(&b, "SYSTEM %s\n", quoteModelfileArgument(modelfile.System))
+	}
+	for _, parameter := range modelfile.Parameters {
+		fmt.Fprintf(&b, "PARAMETER %s %s\n", parameter.Name, quoteModelfileArgument(parameter.Value))
+	}
+	for _, message := range modelfile.Messages {
+		fmt.Fprintf(&b, "MESSAGE %s %s\n", message.Role, quoteModelfileArgument(message.Content))
+	}
+
+	return b.String()
+}
+
+// quoteModelfileArgument quotes an argument when it would not survive parsing as is
+func quoteModelfileArgument(value string) string {
+	switch {
+	case strings.ContainsAny(value, "\"\n\\") || strings.HasPrefix(value, " ") || strings.HasSuffix(value, " "):
+		return `"""` + value + `"""`
+	case strings.ContainsAny(value, " \t"):
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// roundTripsQuoted reports whether a value survives quoting by quoteModelfileArgument.
+// A """ quote ends at the first """, so the value cannot contain one, nor end with a "
+// that would run into the closing quote.
+func roundTripsQuoted(value string) bool {
+	return !strings.Contains(value, `"""`) && !strings.HasSuffix(value, `"`)
+}
+
+// ValidateModelfile checks that a Modelfile can be sent to Ollama
+func ValidateModelfile(modelfile models.Modelfile) error {
+	if modelfile.From == "" {
+		return fmt.Errorf("FROM is required")
+	}
+	if !blobDigestPattern.MatchString(modelfile.From) && !modelNamePattern.MatchString(modelfile.From) {
+		return fmt.Errorf("FROM must be a model name or a blob digest")
+	}
+
+	for _, adapter := range modelfile.Adapters {
+		if !blobDigestPattern.MatchString(adapter) {
+			return fmt.Errorf("ADAPTER must be the digest of an uploaded blob, got %q", adapter)
+		}
+	}
+
+	for _, value := range []string{modelfile.System, modelfile.Template} {
+		if !roundTripsQuoted(value) {
+			return fmt.Errorf(`SYSTEM and TEMPLATE cannot contain """ or end with "`)
+		}
+	}
+
+	if _, err := modelfileParameters(modelfile.Parameters); err != nil {
+		return err
+	}
+	for _, parameter := range modelfile.Parameters {
+		if !roundTripsQuoted(parameter.Value) {
+			return fmt.Errorf(`PARAMETER %s cannot contain """ or end with "`, parameter.Name)
+		}
+	}
+
+	for _, message := range modelfile.Messages {
+		switch message.Role {
+		case "system", "user", "assistant":
+		default:
+			return fmt.Errorf("MESSAGE role must be system, user or assistant, got %q", message.Role)
+		}
+		if !roundTripsQuoted(message.Content) {
+			return fmt.Errorf(`MESSAGE content cannot contain """ or end with "`)
+		}
+	}
+
+	return nil
+}
+
+// modelfileParameters converts PARAMETER instructions into the typed options Ollama expects
+func modelfileParameters(parameters []models.ModelfileParameter) (map[string]interface{}, error) {
+	if len(parameters) == 0 {
+		return nil, nil
+	}
+
+	options := make(map[string]interface{}, len(parameters))
+	for _, parameter := range parameters {
+		name, value := parameter.Name, parameter.Value
+		switch {
+		case modelfileListParameters[name]:
+			list, _ := options[name].([]string)
+			options[name] = append(list, value)
+		case modelfileIntParameters[name]:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("PARAMETER %s must be an integer", name)
+			}
+			options[name] = n
+		case modelfileFloatParameters[name]:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("PARAMETER %s must be a number", name)
+			}
+			options[name] = f
+		case modelfileBoolParameters[name]:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("PARAMETER %s must be true or false", name)
+			}
+			options[name] = b
+		default:
+			return nil, fmt.Errorf("unknown PARAMETER %s", name)
+		}
+	}
+
+	return options, nil
+}
+
+// ValidateModelCreateRequest checks a model creation request
+func ValidateModelCreateRequest(req models.ModelCreateRequest) error {
+	_, err := resolveModelfile(req)
+	return err
+}
+
+// resolveModelfile validates a model creation request and returns its Modelfile
+func resolveModelfile(req models.ModelCreateRequest) (*models.Modelfile, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if !modelNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("name must be a valid model name such as team/reviewer:latest")
+	}
+
+	switch {
+	case req.Modelfile != "" && req.Definition != nil:
+		return nil, fmt.Errorf("give either modelfile or definition, not both")
+	case req.Modelfile != "":
+		return ParseModelfile(req.Modelfile)
+	case req.Definition != nil:
+		if err := ValidateModelfile(*req.Definition); err != nil {
+			return nil, err
+		}
+		return req.Definition, nil
+	}
+	return nil, fmt.Errorf("modelfile or definition is required")
+}
+
+// normalizeModelName adds the latest tag to an untagged model name, the way Ollama lists it
+func normalizeModelName(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// ValidateModelCopyRequest checks a model copy or alias request
+func ValidateModelCopyRequest(req models.ModelCopyRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !modelNamePattern.MatchString(req.Name) {
+		return fmt.Errorf("name must be a valid model name such as team/reviewer:latest")
+	}
+	return nil
+}
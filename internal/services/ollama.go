@@ -1,54 +1,68 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"chat_ollama/internal/config"
 	"chat_ollama/internal/models"
 	"chat_ollama/internal/utils"
 )
 
-// OllamaClient handles communication with Ollama API
+// OllamaClient handles communication with Ollama API. Requests go through the pool of
+// Ollama hosts configured in OLLAMA_HOST, which clients built from the same config share.
 type OllamaClient struct {
-	baseURL    string
+	pool       *OllamaPool
 	httpClient *http.Client
 	logger     *utils.Logger
 }
 
 // NewOllamaClient creates a new Ollama client
-func NewOllamaClient(host string, timeout time.Duration, logger *utils.Logger) *OllamaClient {
-	baseURL := fmt.Sprintf("http://%s", host)
-	if !strings.HasPrefix(host, "http") {
-		baseURL = fmt.Sprintf("http://%s", host)
-	}
-
+func NewOllamaClient(cfg *config.Config, logger *utils.Logger) *OllamaClient {
 	return &OllamaClient{
-		baseURL: baseURL,
+		pool: sharedOllamaPool(cfg, logger),
 		httpClient: &http.Client{
-			Timeout: timeout,
+			Timeout: cfg.OllamaTimeout,
 		},
 		logger: logger.WithComponent("ollama_client"),
 	}
 }
 
+// Pool returns the pool of Ollama hosts the client sends requests to
+func (c *OllamaClient) Pool() *OllamaPool {
+	return c.pool
+}
+
+// ollamaBaseURL turns OLLAMA_HOST into a base URL, keeping an explicit http:// or https://
+// scheme and defaulting to http:// for a bare host:port
+func ollamaBaseURL(host string) string {
+	host = strings.TrimRight(host, "/")
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return host
+	}
+	return "http://" + host
+}
+
 // OllamaMessage represents a message in Ollama format
 type OllamaMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // Base64-encoded images for vision models
 }
 
 // OllamaChatRequest represents a chat request to Ollama
 type OllamaChatRequest struct {
-	Model    string          `json:"model"`
-	Messages []OllamaMessage `json:"messages"`
-	Stream   bool            `json:"stream"`
-	Options  map[string]interface{} `json:"options,omitempty"`
+	Model     string          `json:"model"`
+	Messages  []OllamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
 }
 
 // OllamaChatResponse represents a chat response from Ollama
@@ -63,24 +77,45 @@ type OllamaChatResponse struct {
 	EvalCount     int       `json:"eval_count,omitempty"`
 }
 
-// HealthCheck checks if Ollama is available
+// OllamaGenerateRequest represents a completion request to Ollama
+type OllamaGenerateRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	System  string                 `json:"system,omitempty"`
+	Format  string                 `json:"format,omitempty"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// OllamaGenerateResponse represents a completion response from Ollama
+type OllamaGenerateResponse struct {
+	Model              string    `json:"model"`
+	CreatedAt          time.Time `json:"created_at"`
+	Response           string    `json:"response"`
+	Done               bool      `json:"done"`
+	TotalDuration      int64     `json:"total_duration,omitempty"`
+	LoadDuration       int64     `json:"load_duration,omitempty"`
+	PromptEvalCount    int       `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64     `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int       `json:"eval_count,omitempty"`
+	EvalDuration       int64     `json:"eval_duration,omitempty"`
+}
+
+// HealthCheck checks every Ollama host and fails if none of them is available
 func (c *OllamaClient) HealthCheck(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
-	if err != nil {
-		return fmt.Errorf("failed to create health check request: %w", err)
-	}
+	c.pool.CheckAll(ctx)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("ollama health check failed: %w", err)
+	var unhealthy []string
+	for _, host := range c.pool.Status() {
+		if host.Status != models.StatusHealthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s: %s", host.Name, host.Error))
+		}
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ollama health check returned status %d", resp.StatusCode)
+	if len(unhealthy) == len(c.pool.backends) {
+		return fmt.Errorf("ollama health check failed: %s", strings.Join(unhealthy, "; "))
 	}
 
-	c.logger.Debug().Msg("Ollama health check passed")
+	c.logger.Debug().Int("unhealthy_hosts", len(unhealthy)).Msg("Ollama health check passed")
 	return nil
 }
 
@@ -89,39 +124,41 @@ func (c *OllamaClient) Chat(ctx context.Context, req models.ChatRequest, message
 	return c.ChatWithContext(ctx, req, messages, "")
 }
 
-// ChatWithContext sends a chat request to Ollama with optional semantic context
+// ChatWithContext sends a chat request to Ollama with optional semantic context, a rendered
+// context message that is sent as a system message before the user's turn
 func (c *OllamaClient) ChatWithContext(ctx context.Context, req models.ChatRequest, messages []models.Message, semanticContext string) (*OllamaChatResponse, error) {
+	defer c.pool.beginChat()()
+
 	// Convert messages to Ollama format
 	ollamaMessages := c.convertMessages(messages)
 	
-	// Prepare the user message content
-	userContent := req.Message
-	
-	// If we have semantic context, inject it into the conversation
+	// Retrieved memory goes into its own system message right before the user's turn,
+	// so the user message reaches the model verbatim
 	if semanticContext != "" {
-		userContent = fmt.Sprintf(`Based on the following relevant context from our previous conversations:
-
-%s
-
-Current question: %s`, semanticContext, req.Message)
+		ollamaMessages = append(ollamaMessages, OllamaMessage{
+			Role:    "system",
+			Content: semanticContext,
+		})
 		
 		c.logger.Debug().
 			Str("session_id", req.SessionID).
 			Int("context_length", len(semanticContext)).
-			Msg("Injecting semantic context into user message")
+			Msg("Injecting semantic context into conversation")
 	}
 	
-	// Add the current user message (with context if available)
+	// Add the current user message
 	ollamaMessages = append(ollamaMessages, OllamaMessage{
 		Role:    "user",
-		Content: userContent,
+		Content: req.Message,
+		Images:  req.Images,
 	})
 
 	ollamaReq := OllamaChatRequest{
 		Model:    req.Model,
 		Messages: ollamaMessages,
-		Stream:   false,
-		Options:  req.Options,
+		Stream:    false,
+		Options:   req.Options,
+		KeepAlive: req.KeepAlive,
 	}
 
 	reqBody, err := json.Marshal(ollamaReq)
@@ -129,19 +166,12 @@ Current question: %s`, semanticContext, req.Message)
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
 	c.logger.Debug().
 		Str("model", req.Model).
 		Int("message_count", len(ollamaMessages)).
 		Msg("Sending chat request to Ollama")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.pool.send(ctx, c.httpClient, req.Model, "POST", "/api/chat", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
 	}
@@ -174,38 +204,38 @@ func (c *OllamaClient) ChatStream(ctx context.Context, req models.ChatRequest, m
 // ChatStreamWithContext sends a streaming chat request to Ollama with optional semantic context
 func (c *OllamaClient) ChatStreamWithContext(ctx context.Context, req models.ChatRequest, messages []models.Message, semanticContext string, responseChan chan<- models.StreamResponse) error {
 	defer close(responseChan)
+	defer c.pool.beginChat()()
 
 	// Convert messages to Ollama format
 	ollamaMessages := c.convertMessages(messages)
 	
-	// Prepare the user message content
-	userContent := req.Message
-	
-	// If we have semantic context, inject it into the conversation
+	// Retrieved memory goes into its own system message right before the user's turn,
+	// so the user message reaches the model verbatim
 	if semanticContext != "" {
-		userContent = fmt.Sprintf(`Based on the following relevant context from our previous conversations:
-
-%s
-
-Current question: %s`, semanticContext, req.Message)
+		ollamaMessages = append(ollamaMessages, OllamaMessage{
+			Role:    "system",
+			Content: semanticContext,
+		})
 		
 		c.logger.Debug().
 			Str("session_id", req.SessionID).
 			Int("context_length", len(semanticContext)).
-			Msg("Injecting semantic context into streaming user message")
+			Msg("Injecting semantic context into streaming conversation")
 	}
 	
-	// Add the current user message (with context if available)
+	// Add the current user message
 	ollamaMessages = append(ollamaMessages, OllamaMessage{
 		Role:    "user",
-		Content: userContent,
+		Content: req.Message,
+		Images:  req.Images,
 	})
 
 	ollamaReq := OllamaChatRequest{
 		Model:    req.Model,
 		Messages: ollamaMessages,
-		Stream:   true,
-		Options:  req.Options,
+		Stream:    true,
+		Options:   req.Options,
+		KeepAlive: req.KeepAlive,
 	}
 
 	reqBody, err := json.Marshal(ollamaReq)
@@ -218,25 +248,13 @@ Current question: %s`, semanticContext, req.Message)
 		return err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(reqBody))
-	if err != nil {
-		responseChan <- models.StreamResponse{
-			Type:      "error",
-			SessionID: req.SessionID,
-			Error:     fmt.Sprintf("Failed to create request: %v", err),
-		}
-		return err
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
 	c.logger.Debug().
 		Str("model", req.Model).
 		Str("session_id", req.SessionID).
 		Int("message_count", len(ollamaMessages)).
 		Msg("Starting streaming chat request to Ollama")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.pool.send(ctx, c.httpClient, req.Model, "POST", "/api/chat", reqBody)
 	if err != nil {
 		responseChan <- models.StreamResponse{
 			Type:      "error",
@@ -318,6 +336,94 @@ Current question: %s`, semanticContext, req.Message)
 	return nil
 }
 
+// Generate sends a single non-streaming completion request to Ollama
+func (c *OllamaClient) Generate(ctx context.Context, req OllamaGenerateRequest) (*OllamaGenerateResponse, error) {
+	req.Stream = false
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	c.logger.Debug().
+		Str("model", req.Model).
+		Int("prompt_length", len(req.Prompt)).
+		Msg("Sending generate request to Ollama")
+
+	resp, err := c.pool.send(ctx, c.httpClient, req.Model, "POST", "/api/generate", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var generateResp OllamaGenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&generateResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &generateResp, nil
+}
+
+// GenerateStream sends a streaming completion request to Ollama, calling onToken with each
+// piece of the response as it arrives, and returns the final response with the timings
+func (c *OllamaClient) GenerateStream(ctx context.Context, req OllamaGenerateRequest, onToken func(token string)) (*OllamaGenerateResponse, error) {
+	req.Stream = true
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	c.logger.Debug().
+		Str("model", req.Model).
+		Int("prompt_length", len(req.Prompt)).
+		Msg("Starting streaming generate request to Ollama")
+
+	resp, err := c.pool.send(ctx, c.httpClient, req.Model, "POST", "/api/generate", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk OllamaGenerateResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("ollama closed the stream before the response was done")
+			}
+			return nil, fmt.Errorf("failed to decode streaming response: %w", err)
+		}
+
+		if chunk.Response != "" && onToken != nil {
+			onToken(chunk.Response)
+		}
+		if chunk.Done {
+			return &chunk, nil
+		}
+	}
+}
+
+// ActiveChats returns the number of chats this process is sending to Ollama
+func (c *OllamaClient) ActiveChats() int64 {
+	return c.pool.ActiveChats()
+}
+
+// ChatsChanged is signalled when a chat of this process starts or ends
+func (c *OllamaClient) ChatsChanged() <-chan struct{} {
+	return c.pool.ChatsChanged()
+}
+
 // convertMessages converts internal message format to Ollama format
 func (c *OllamaClient) convertMessages(messages []models.Message) []OllamaMessage {
 	ollamaMessages := make([]OllamaMessage, len(messages))
@@ -332,47 +438,87 @@ func (c *OllamaClient) convertMessages(messages []models.Message) []OllamaMessag
 
 // GetModels retrieves available models from Ollama
 func (c *OllamaClient) GetModels(ctx context.Context) ([]string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
+	modelsInfo, err := c.GetModelsWithInfo(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get models: %w", err)
+	models := make([]string, len(modelsInfo))
+	for i, model := range modelsInfo {
+		models[i] = model.Name
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama returned status %d", resp.StatusCode)
-	}
+	return models, nil
+}
 
-	var result struct {
-		Models []struct {
-			Name string `json:"name"`
-		} `json:"models"`
-	}
+// GetModelsWithInfo retrieves the models installed on any Ollama host, with the hosts
+// that have each of them. Hosts that cannot be reached are left out; it fails only if
+// none can be.
+func (c *OllamaClient) GetModelsWithInfo(ctx context.Context) ([]models.OllamaModelDetailedInfo, error) {
+	var modelsInfo []models.OllamaModelDetailedInfo
+	index := make(map[string]int)
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	err := c.eachHost(ctx, func(ctx context.Context, host string) error {
+		hostModels, err := c.getModelsWithInfo(ctx)
+		if err != nil {
+			return err
+		}
+		for _, model := range hostModels {
+			if i, ok := index[model.Name]; ok {
+				modelsInfo[i].Hosts = append(modelsInfo[i].Hosts, host)
+				continue
+			}
+			model.Hosts = []string{host}
+			index[model.Name] = len(modelsInfo)
+			modelsInfo = append(modelsInfo, model)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	models := make([]string, len(result.Models))
-	for i, model := range result.Models {
-		models[i] = model.Name
-	}
+	return modelsInfo, nil
+}
 
-	return models, nil
+// MissingHosts returns the healthy Ollama hosts that the placement rules put a model on
+// but that do not have it installed
+func (c *OllamaClient) MissingHosts(model models.OllamaModelDetailedInfo) []string {
+	var missing []string
+	for _, b := range c.pool.placementHosts(model.Name) {
+		if !b.isHealthy() || containsFold(model.Hosts, b.name) {
+			continue
+		}
+		missing = append(missing, b.name)
+	}
+	return missing
 }
 
-// GetModelsWithInfo retrieves models with detailed information from Ollama
-func (c *OllamaClient) GetModelsWithInfo(ctx context.Context) ([]models.OllamaModelDetailedInfo, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// eachHost calls fn for every Ollama host with a context pinned to it. Hosts whose call
+// fails are logged and skipped; eachHost fails only if every call does.
+func (c *OllamaClient) eachHost(ctx context.Context, fn func(ctx context.Context, host string) error) error {
+	var lastErr error
+	succeeded := false
+	for _, b := range c.pool.backends {
+		if err := fn(withOllamaHost(ctx, b.name), b.name); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			c.logger.Warn().Err(err).Str("host", b.name).Msg("Ollama host skipped")
+			lastErr = err
+			continue
+		}
+		succeeded = true
+	}
+	if !succeeded {
+		return lastErr
 	}
+	return nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// getModelsWithInfo retrieves models with detailed information from one Ollama host
+func (c *OllamaClient) getModelsWithInfo(ctx context.Context) ([]models.OllamaModelDetailedInfo, error) {
+	resp, err := c.pool.send(ctx, c.httpClient, "", "GET", "/api/tags", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get models: %w", err)
 	}
@@ -386,6 +532,7 @@ func (c *OllamaClient) GetModelsWithInfo(ctx context.Context) ([]models.OllamaMo
 		Models []struct {
 			Name    string `json:"name"`
 			Size    int64  `json:"size"`
+			Digest  string `json:"digest"`
 			Details struct {
 				Family           string `json:"family"`
 				Format           string `json:"format"`
@@ -408,16 +555,58 @@ func (c *OllamaClient) GetModelsWithInfo(ctx context.Context) ([]models.OllamaMo
 			Format:       model.Details.Format,
 			Parameters:   model.Details.ParameterSize,
 			Quantization: model.Details.QuantizationLevel,
+			Digest:       model.Digest,
 		}
 	}
 
 	return modelsInfo, nil
 }
 
-// PullModel downloads a model from Ollama with improved error handling and timeout management
+// PullModel downloads a model to every healthy Ollama host its placement rule names, one
+// host after the other, tagging the progress with the host. Hosts that are down get the
+// model when a later sync finds it missing there. It closes progressChan when it returns.
 func (c *OllamaClient) PullModel(ctx context.Context, modelName string, progressChan chan<- models.ModelDownloadProgress) error {
 	defer close(progressChan)
 
+	var hosts []*ollamaBackend
+	for _, b := range c.pool.placementHosts(modelName) {
+		if b.isHealthy() {
+			hosts = append(hosts, b)
+		}
+	}
+	if len(hosts) == 0 {
+		err := fmt.Errorf("no healthy Ollama host to pull model %s to", modelName)
+		progressChan <- models.ModelDownloadProgress{ModelName: modelName, Status: "error", Error: err.Error()}
+		return err
+	}
+
+	for _, b := range hosts {
+		hostProgress := make(chan models.ModelDownloadProgress, 100)
+		forwarded := make(chan struct{})
+		go func(host string) {
+			defer close(forwarded)
+			for progress := range hostProgress {
+				progress.Host = host
+				progressChan <- progress
+			}
+		}(b.name)
+
+		err := c.pullModel(withOllamaHost(ctx, b.name), modelName, hostProgress)
+		<-forwarded
+		c.pool.refresh(b.name)
+		if err != nil {
+			return fmt.Errorf("failed to pull model to %s: %w", b.name, err)
+		}
+	}
+
+	return nil
+}
+
+// pullModel downloads a model from Ollama with improved error handling and timeout
+// management. It closes progressChan when it returns.
+func (c *OllamaClient) pullModel(ctx context.Context, modelName string, progressChan chan<- models.ModelDownloadProgress) error {
+	defer close(progressChan)
+
 	pullReq := struct {
 		Name   string `json:"name"`
 		Stream bool   `json:"stream"`
@@ -441,18 +630,6 @@ func (c *OllamaClient) PullModel(ctx context.Context, modelName string, progress
 		Timeout: 0, // No timeout - we'll handle it with context
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/pull", bytes.NewBuffer(reqBody))
-	if err != nil {
-		progressChan <- models.ModelDownloadProgress{
-			ModelName: modelName,
-			Status:    "error",
-			Error:     fmt.Sprintf("Failed to create request: %v", err),
-		}
-		return err
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
 	c.logger.Info().
 		Str("model", modelName).
 		Msg("Starting model download from Ollama")
@@ -464,7 +641,7 @@ func (c *OllamaClient) PullModel(ctx context.Context, modelName string, progress
 		Percentage: 0,
 	}
 
-	resp, err := downloadClient.Do(httpReq)
+	resp, err := c.pool.send(ctx, downloadClient, modelName, "POST", "/api/pull", reqBody)
 	if err != nil {
 		progressChan <- models.ModelDownloadProgress{
 			ModelName: modelName,
@@ -633,6 +810,14 @@ func (c *OllamaClient) PullModel(ctx context.Context, modelName string, progress
 	return nil
 }
 
+// PinHost pins the requests made with the returned context to the best host for a
+// model among those its placement rule names, for work spanning several requests that
+// has to stay on one host, such as uploading weights and creating a model from them
+func (c *OllamaClient) PinHost(ctx context.Context, modelName string) (context.Context, string) {
+	host := c.pool.pickPlaced(ctx, modelName)
+	return withOllamaHost(ctx, host.name), host.name
+}
+
 // timeoutReader wraps an io.Reader with a timeout
 type timeoutReader struct {
 	reader  io.ReadCloser
@@ -676,14 +861,7 @@ func (c *OllamaClient) GetModelInfo(ctx context.Context, modelName string) (*mod
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/show", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.pool.send(ctx, c.httpClient, modelName, "POST", "/api/show", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get model info: %w", err)
 	}
@@ -701,7 +879,8 @@ func (c *OllamaClient) GetModelInfo(ctx context.Context, modelName string) (*mod
 	return &modelInfo, nil
 }
 
-// GetLibraryModels fetches available models from Ollama's public library
+// GetLibraryModels fetches available models from Ollama's public library by scraping its
+// HTML pages. It backs LibraryCatalogSource, the fallback model catalog.
 func (c *OllamaClient) GetLibraryModels(ctx context.Context) ([]string, error) {
 	c.logger.Info().Msg("Fetching models from Ollama library")
 	
@@ -865,8 +1044,36 @@ func (c *OllamaClient) extractModelVariants(content, family string) []string {
 	return variants
 }
 
-// DeleteModel removes a model from Ollama
+// DeleteModel removes a model from every Ollama host that has it
 func (c *OllamaClient) DeleteModel(ctx context.Context, modelName string) error {
+	name := normalizeModelName(modelName)
+	deleted := false
+	for _, b := range c.pool.backends {
+		has, known := b.hasModel(name)
+		if known && !has {
+			continue
+		}
+
+		err := c.deleteModel(withOllamaHost(ctx, b.name), modelName)
+		c.pool.refresh(b.name)
+		if err != nil {
+			// A host whose inventory was unknown may not have had the model
+			if !known && strings.Contains(err.Error(), "status 404") {
+				continue
+			}
+			return fmt.Errorf("failed to delete model from %s: %w", b.name, err)
+		}
+		deleted = true
+	}
+
+	if !deleted {
+		return fmt.Errorf("model %s not found on any Ollama host", modelName)
+	}
+	return nil
+}
+
+// deleteModel removes a model from one Ollama host
+func (c *OllamaClient) deleteModel(ctx context.Context, modelName string) error {
 	deleteReq := struct {
 		Name string `json:"name"`
 	}{
@@ -878,18 +1085,11 @@ func (c *OllamaClient) DeleteModel(ctx context.Context, modelName string) error
 		return fmt.Errorf("failed to marshal delete request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", c.baseURL+"/api/delete", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return fmt.Errorf("failed to create delete request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
 	c.logger.Info().
 		Str("model", modelName).
 		Msg("Deleting model from Ollama")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.pool.send(ctx, c.httpClient, modelName, "DELETE", "/api/delete", reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to send delete request to Ollama: %w", err)
 	}
@@ -905,4 +1105,304 @@ func (c *OllamaClient) DeleteModel(ctx context.Context, modelName string) error
 		Msg("Model deleted from Ollama successfully")
 
 	return nil
-}
\ No newline at end of file
+}
+
+// OllamaCreateRequest represents a request to create a model
+type OllamaCreateRequest struct {
+	Model      string                 `json:"model"`
+	From       string                 `json:"from,omitempty"`
+	Files      map[string]string      `json:"files,omitempty"` // File name to blob digest, for models created from uploaded weights
+	Adapters   map[string]string      `json:"adapters,omitempty"`
+	Template   string                 `json:"template,omitempty"`
+	System     string                 `json:"system,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Messages   []OllamaMessage        `json:"messages,omitempty"`
+	Stream     bool                   `json:"stream"`
+}
+
+// CreateModel creates a model with Ollama's /api/create, sending status updates on
+// progressChan. It closes progressChan when it returns.
+func (c *OllamaClient) CreateModel(ctx context.Context, createReq OllamaCreateRequest, progressChan chan<- models.ModelCreateProgress) error {
+	defer close(progressChan)
+
+	createReq.Stream = true
+	reqBody, err := json.Marshal(createReq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal create request: %w", err)
+	}
+
+	c.logger.Info().
+		Str("model", createReq.Model).
+		Str("from", createReq.From).
+		Msg("Creating model in Ollama")
+
+	// Creating a model may convert or quantize weights, so only the context limits it
+	createClient := &http.Client{Timeout: 0}
+	resp, err := c.pool.send(ctx, createClient, createReq.From, "POST", "/api/create", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to send create request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var progress models.ModelCreateProgress
+		if err := decoder.Decode(&progress); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to decode create response: %w", err)
+		}
+
+		if progress.Error != "" {
+			progressChan <- progress
+			return fmt.Errorf("ollama error: %s", progress.Error)
+		}
+
+		progressChan <- progress
+		if progress.Status == "success" {
+			break
+		}
+	}
+
+	c.logger.Info().Str("model", createReq.Model).Msg("Model created in Ollama")
+	return nil
+}
+
+// CopyModel copies a model in Ollama under a new name. The copy shares the blobs of
+// the source, so it takes no extra disk space.
+func (c *OllamaClient) CopyModel(ctx context.Context, source, destination string) error {
+	copyReq := struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	}{
+		Source:      source,
+		Destination: destination,
+	}
+
+	reqBody, err := json.Marshal(copyReq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal copy request: %w", err)
+	}
+
+	resp, err := c.pool.send(ctx, c.httpClient, source, "POST", "/api/copy", reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to send copy request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	c.logger.Info().
+		Str("source", source).
+		Str("destination", destination).
+		Msg("Model copied in Ollama")
+
+	return nil
+}
+
+// HasBlob reports whether Ollama already has the blob with the given sha256:<hex> digest
+func (c *OllamaClient) HasBlob(ctx context.Context, digest string) (bool, error) {
+	resp, err := c.pool.send(ctx, c.httpClient, "", "HEAD", "/api/blobs/"+digest, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("ollama returned status %d", resp.StatusCode)
+}
+
+// PushBlob uploads a blob to Ollama under its sha256:<hex> digest, which Ollama verifies.
+// The upload cannot be replayed, so it goes to one host without failover; pin ctx with
+// PinHost to create a model from the blob on the same host.
+func (c *OllamaClient) PushBlob(ctx context.Context, digest string, body io.Reader, size int64) error {
+	host := c.pool.pick(ctx, "")
+	atomic.AddInt64(&host.inFlight, 1)
+	defer atomic.AddInt64(&host.inFlight, -1)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", host.baseURL+"/api/blobs/"+digest, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.ContentLength = size
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+
+	c.logger.Info().
+		Str("digest", digest).
+		Int64("size", size).
+		Str("host", host.name).
+		Msg("Uploading blob to Ollama")
+
+	// Blobs are model weights of many gigabytes, so only the context limits the upload
+	uploadClient := &http.Client{Timeout: 0}
+	resp, err := uploadClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to upload blob: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// ListRunningModels lists the models loaded into memory on every Ollama host
+func (c *OllamaClient) ListRunningModels(ctx context.Context) ([]models.RunningModel, error) {
+	var running []models.RunningModel
+	err := c.eachHost(ctx, func(ctx context.Context, host string) error {
+		hostRunning, err := c.listRunningModels(ctx)
+		if err != nil {
+			return err
+		}
+		for _, model := range hostRunning {
+			model.Host = host
+			running = append(running, model)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return running, nil
+}
+
+// listRunningModels lists the models one Ollama host has loaded into memory
+func (c *OllamaClient) listRunningModels(ctx context.Context) ([]models.RunningModel, error) {
+	resp, err := c.pool.send(ctx, c.httpClient, "", "GET", "/api/ps", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Models []struct {
+			Name          string    `json:"name"`
+			Digest        string    `json:"digest"`
+			Size          int64     `json:"size"`
+			SizeVRAM      int64     `json:"size_vram"`
+			ContextLength int       `json:"context_length"`
+			ExpiresAt     time.Time `json:"expires_at"`
+			Details       struct {
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	running := make([]models.RunningModel, len(result.Models))
+	for i, model := range result.Models {
+		running[i] = models.RunningModel{
+			Name:          model.Name,
+			Digest:        model.Digest,
+			Parameters:    model.Details.ParameterSize,
+			Quantization:  model.Details.QuantizationLevel,
+			Size:          model.Size,
+			SizeVRAM:      model.SizeVRAM,
+			ContextLength: model.ContextLength,
+			ExpiresAt:     model.ExpiresAt,
+		}
+	}
+
+	return running, nil
+}
+
+// LoadModel loads a model into memory without generating anything and keeps it loaded
+// for keepAlive, or Ollama's default when it is empty
+func (c *OllamaClient) LoadModel(ctx context.Context, modelName, keepAlive string, embedding bool) error {
+	if err := c.setKeepAlive(ctx, modelName, keepAlive, embedding); err != nil {
+		return fmt.Errorf("failed to load model: %w", err)
+	}
+
+	c.logger.Info().
+		Str("model", modelName).
+		Str("keep_alive", keepAlive).
+		Msg("Model loaded")
+
+	return nil
+}
+
+// UnloadModel unloads a model from memory on every Ollama host that has it loaded
+func (c *OllamaClient) UnloadModel(ctx context.Context, modelName string, embedding bool) error {
+	running, err := c.ListRunningModels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to unload model: %w", err)
+	}
+
+	name := normalizeModelName(modelName)
+	for _, model := range running {
+		if model.Name != name {
+			continue
+		}
+		if err := c.setKeepAlive(withOllamaHost(ctx, model.Host), modelName, "0", embedding); err != nil {
+			return fmt.Errorf("failed to unload model from %s: %w", model.Host, err)
+		}
+	}
+
+	c.logger.Info().Str("model", modelName).Msg("Model unloaded")
+	return nil
+}
+
+// setKeepAlive sends a request without input, which loads the model if needed and sets
+// how long it stays loaded; a keep_alive of 0 unloads it. Embedding models go through
+// the embed endpoint, since they cannot generate.
+func (c *OllamaClient) setKeepAlive(ctx context.Context, modelName, keepAlive string, embedding bool) error {
+	endpoint := "/api/generate"
+	if embedding {
+		endpoint = "/api/embed"
+	}
+
+	keepAliveReq := struct {
+		Model     string `json:"model"`
+		Stream    bool   `json:"stream"`
+		KeepAlive string `json:"keep_alive,omitempty"`
+	}{
+		Model:     modelName,
+		KeepAlive: keepAlive,
+	}
+
+	reqBody, err := json.Marshal(keepAliveReq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Loading a large model can take longer than the client timeout, so only the context
+	// limits it
+	keepAliveClient := &http.Client{Timeout: 0}
+	resp, err := c.pool.send(ctx, keepAliveClient, modelName, "POST", endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,552 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+// ollamaHealthTimeout bounds a single health check of an Ollama host
+const ollamaHealthTimeout = 5 * time.Second
+
+// ollamaBackend is one Ollama host of a pool, with what its last health check found
+type ollamaBackend struct {
+	name     string
+	baseURL  string
+	inFlight int64 // Requests in progress, updated atomically
+
+	mu        sync.RWMutex
+	checked   time.Time
+	healthy   bool
+	lastError string
+	latency   time.Duration
+	models    map[string]bool // Installed models; nil until the first check
+	loaded    map[string]bool // Models loaded into memory
+}
+
+// isHealthy reports whether the host can take requests. Hosts that were not checked yet
+// are assumed healthy so requests do not wait for the first check.
+func (b *ollamaBackend) isHealthy() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.healthy || b.checked.IsZero()
+}
+
+// hasModel reports whether the host has a model installed, and whether that is known
+func (b *ollamaBackend) hasModel(name string) (has, known bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.models == nil {
+		return false, false
+	}
+	return b.models[name], true
+}
+
+// hasLoaded reports whether the host has a model loaded into memory
+func (b *ollamaBackend) hasLoaded(name string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.loaded[name]
+}
+
+// markFailed records a failed request so the host is skipped until it passes a health check
+func (b *ollamaBackend) markFailed(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.healthy = false
+	b.checked = time.Now()
+	b.lastError = err.Error()
+}
+
+// backendBody releases a host's in-flight slot when the response body is closed
+type backendBody struct {
+	io.ReadCloser
+	backend *ollamaBackend
+	once    sync.Once
+}
+
+func (b *backendBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(&b.backend.inFlight, -1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// placementRule places the models matching a pattern on a set of hosts
+type placementRule struct {
+	pattern string
+	hosts   []string // Empty for all hosts
+}
+
+// OllamaPool spreads requests over the Ollama hosts of OLLAMA_HOST. It checks the hosts
+// periodically, keeps an inventory of the models each one has installed and loaded, and
+// sends each request to the least-loaded healthy host that has the model, failing over
+// to the next host when a host cannot be reached or fails.
+type OllamaPool struct {
+	backends  []*ollamaBackend
+	placement []placementRule
+	interval  time.Duration
+	client    *http.Client
+	logger    *utils.Logger
+	chats     int64 // Chats in progress in this process, updated atomically
+
+	chatsChanged chan struct{} // Signalled when a chat starts or ends
+}
+
+// ollamaPools holds one pool per OLLAMA_HOST value, so that the clients of all services
+// share the load and health of the hosts
+var ollamaPools = struct {
+	sync.Mutex
+	pools map[string]*OllamaPool
+}{pools: make(map[string]*OllamaPool)}
+
+// beginChat counts a chat as in progress until the returned function is called
+func (p *OllamaPool) beginChat() func() {
+	atomic.AddInt64(&p.chats, 1)
+	p.signalChats()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt64(&p.chats, -1)
+			p.signalChats()
+		})
+	}
+}
+
+// signalChats notifies a waiting ChatsChanged receiver without blocking the chat
+func (p *OllamaPool) signalChats() {
+	select {
+	case p.chatsChanged <- struct{}{}:
+	default:
+	}
+}
+
+// ChatsChanged is signalled when a chat starts or ends; signals are coalesced
+func (p *OllamaPool) ChatsChanged() <-chan struct{} {
+	return p.chatsChanged
+}
+
+// ActiveChats returns the number of chats this process is sending to the pool
+func (p *OllamaPool) ActiveChats() int64 {
+	return atomic.LoadInt64(&p.chats)
+}
+
+// sharedOllamaPool returns the pool for the hosts of cfg, creating it on first use
+func sharedOllamaPool(cfg *config.Config, logger *utils.Logger) *OllamaPool {
+	ollamaPools.Lock()
+	defer ollamaPools.Unlock()
+
+	if pool, ok := ollamaPools.pools[cfg.OllamaHost]; ok {
+		return pool
+	}
+	pool := newOllamaPool(cfg, logger)
+	ollamaPools.pools[cfg.OllamaHost] = pool
+	return pool
+}
+
+// newOllamaPool creates a pool for the hosts of cfg
+func newOllamaPool(cfg *config.Config, logger *utils.Logger) *OllamaPool {
+	pool := &OllamaPool{
+		interval:     cfg.OllamaHealthInterval,
+		client:       &http.Client{Timeout: ollamaHealthTimeout},
+		logger:       logger.WithComponent("ollama_pool"),
+		chatsChanged: make(chan struct{}, 1),
+	}
+
+	for _, host := range config.SplitOllamaHosts(cfg.OllamaHost) {
+		pool.backends = append(pool.backends, &ollamaBackend{name: host.Name, baseURL: ollamaBaseURL(host.Address)})
+	}
+
+	pool.placement = parsePlacementRules(cfg.OllamaPlacement)
+	for _, rule := range pool.placement {
+		for _, host := range rule.hosts {
+			if pool.backend(host) == nil {
+				pool.logger.Warn().Str("host", host).Str("pattern", rule.pattern).Msg("Placement rule names an unknown Ollama host")
+			}
+		}
+	}
+
+	return pool
+}
+
+// parsePlacementRules parses OLLAMA_PLACEMENT, a semicolon-separated list of
+// pattern=host,host rules where the hosts may be "all"
+func parsePlacementRules(value string) []placementRule {
+	var rules []placementRule
+	for _, entry := range strings.Split(value, ";") {
+		pattern, hosts, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		rule := placementRule{pattern: strings.TrimSpace(pattern)}
+		for _, host := range strings.Split(hosts, ",") {
+			host = strings.TrimSpace(host)
+			if host == "all" {
+				rule.hosts = nil
+				break
+			}
+			if host != "" {
+				rule.hosts = append(rule.hosts, host)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Name implements Worker
+func (p *OllamaPool) Name() string {
+	return "ollama_pool"
+}
+
+// Run checks the health of the hosts every OLLAMA_HEALTH_INTERVAL until ctx is cancelled
+func (p *OllamaPool) Run(ctx context.Context) {
+	runPeriodically(ctx, p.interval, p.logger, func(ctx context.Context) error {
+		p.CheckAll(ctx)
+		return nil
+	})
+}
+
+// CheckAll checks the health and inventory of every host concurrently
+func (p *OllamaPool) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range p.backends {
+		wg.Add(1)
+		go func(b *ollamaBackend) {
+			defer wg.Done()
+			p.check(ctx, b)
+		}(b)
+	}
+	wg.Wait()
+}
+
+// check reads the installed and loaded models of a host, which doubles as its health check
+func (p *OllamaPool) check(ctx context.Context, b *ollamaBackend) {
+	start := time.Now()
+	installed, err := p.listNames(ctx, b, "/api/tags")
+	var loaded map[string]bool
+	if err == nil {
+		loaded, err = p.listNames(ctx, b, "/api/ps")
+	}
+	latency := time.Since(start)
+
+	b.mu.Lock()
+	wasHealthy := b.healthy || b.checked.IsZero()
+	b.checked = time.Now()
+	b.latency = latency
+	if err != nil {
+		b.healthy = false
+		b.lastError = err.Error()
+	} else {
+		b.healthy = true
+		b.lastError = ""
+		b.models = installed
+		b.loaded = loaded
+	}
+	healthy := b.healthy
+	b.mu.Unlock()
+
+	switch {
+	case !healthy && wasHealthy:
+		p.logger.Warn().Err(err).Str("host", b.name).Msg("Ollama host is unhealthy")
+	case healthy && !wasHealthy:
+		p.logger.Info().Str("host", b.name).Msg("Ollama host is healthy again")
+	}
+}
+
+// listNames lists the model names of /api/tags or /api/ps on a host
+func (p *OllamaPool) listNames(ctx context.Context, b *ollamaBackend, endpoint string) (map[string]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.baseURL+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", endpoint, err)
+	}
+
+	names := make(map[string]bool, len(result.Models))
+	for _, model := range result.Models {
+		names[model.Name] = true
+	}
+	return names, nil
+}
+
+// Status returns the state of every host
+func (p *OllamaPool) Status() []models.OllamaHostStatus {
+	statuses := make([]models.OllamaHostStatus, 0, len(p.backends))
+	for _, b := range p.backends {
+		b.mu.RLock()
+		status := models.OllamaHostStatus{
+			Name:      b.name,
+			URL:       b.baseURL,
+			Status:    "unknown",
+			Error:     b.lastError,
+			LatencyMs: b.latency.Milliseconds(),
+			InFlight:  atomic.LoadInt64(&b.inFlight),
+			Models:    sortedNames(b.models),
+			Loaded:    sortedNames(b.loaded),
+		}
+		if !b.checked.IsZero() {
+			checked := b.checked
+			status.CheckedAt = &checked
+			status.Status = models.StatusUnhealthy
+			if b.healthy {
+				status.Status = models.StatusHealthy
+			}
+		}
+		b.mu.RUnlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// sortedNames returns the names of a set in order
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backend returns the host with the given name, or nil
+func (p *OllamaPool) backend(name string) *ollamaBackend {
+	for _, b := range p.backends {
+		if b.name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// ollamaHostKey is the context key pinning requests to one host
+type ollamaHostKey struct{}
+
+// withOllamaHost pins the requests made with ctx to the named host, for work that has to
+// stay on one host such as uploading a blob and creating a model from it
+func withOllamaHost(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ollamaHostKey{}, name)
+}
+
+// pinnedHost returns the host ctx is pinned to, or nil
+func (p *OllamaPool) pinnedHost(ctx context.Context) *ollamaBackend {
+	name, _ := ctx.Value(ollamaHostKey{}).(string)
+	if name == "" {
+		return nil
+	}
+	return p.backend(name)
+}
+
+// candidates orders the hosts to try for a request about a model: healthy hosts before
+// unhealthy ones, then hosts known to have the model before those whose inventory is
+// unknown and those known not to have it, then the least loaded, preferring hosts that
+// already have the model in memory, then the fastest. An empty model name only ranks by
+// health and load.
+func (p *OllamaPool) candidates(ctx context.Context, model string) []*ollamaBackend {
+	if pinned := p.pinnedHost(ctx); pinned != nil {
+		return []*ollamaBackend{pinned}
+	}
+	if model != "" {
+		model = normalizeModelName(model)
+	}
+
+	type ranked struct {
+		backend  *ollamaBackend
+		group    int
+		inFlight int64
+		loaded   bool
+		latency  time.Duration
+	}
+
+	ranking := make([]ranked, 0, len(p.backends))
+	for _, b := range p.backends {
+		r := ranked{backend: b, inFlight: atomic.LoadInt64(&b.inFlight)}
+		if model != "" {
+			switch has, known := b.hasModel(model); {
+			case has:
+				r.group = 0
+			case !known:
+				r.group = 1
+			default:
+				r.group = 2
+			}
+			r.loaded = b.hasLoaded(model)
+		}
+		if !b.isHealthy() {
+			r.group += 3
+		}
+		b.mu.RLock()
+		r.latency = b.latency
+		b.mu.RUnlock()
+		ranking = append(ranking, r)
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		if a.inFlight != b.inFlight {
+			return a.inFlight < b.inFlight
+		}
+		if a.loaded != b.loaded {
+			return a.loaded
+		}
+		return a.latency < b.latency
+	})
+
+	backends := make([]*ollamaBackend, len(ranking))
+	for i, r := range ranking {
+		backends[i] = r.backend
+	}
+	return backends
+}
+
+// placementHosts returns the hosts a model should be installed on: those of the first
+// placement rule whose pattern matches it, or every host if no rule does
+func (p *OllamaPool) placementHosts(model string) []*ollamaBackend {
+	model = normalizeModelName(model)
+	for _, rule := range p.placement {
+		if !placementMatches(rule.pattern, model) {
+			continue
+		}
+		if len(rule.hosts) == 0 {
+			return p.backends
+		}
+		var hosts []*ollamaBackend
+		for _, name := range rule.hosts {
+			if b := p.backend(name); b != nil {
+				hosts = append(hosts, b)
+			}
+		}
+		return hosts
+	}
+	return p.backends
+}
+
+// placementMatches matches a model name against a placement pattern, where a pattern
+// without a tag matches every tag of the model
+func placementMatches(pattern, model string) bool {
+	if matched, _ := path.Match(pattern, model); matched {
+		return true
+	}
+	if !strings.Contains(pattern, ":") {
+		name, _, _ := strings.Cut(model, ":")
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	return false
+}
+
+// send sends a request about a model to the best host for it, failing over to the next
+// host when a host cannot be reached, fails with a server error, or does not have the
+// model. The in-flight count of the host that answered is released when the response
+// body is closed.
+func (p *OllamaPool) send(ctx context.Context, client *http.Client, model, method, endpoint string, body []byte) (*http.Response, error) {
+	candidates := p.candidates(ctx, model)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no Ollama hosts configured")
+	}
+
+	var lastErr error
+	for i, b := range candidates {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, b.baseURL+endpoint, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		atomic.AddInt64(&b.inFlight, 1)
+		resp, err := client.Do(req)
+		if err != nil {
+			atomic.AddInt64(&b.inFlight, -1)
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			b.markFailed(err)
+			p.logger.Warn().Err(err).Str("host", b.name).Str("endpoint", endpoint).Msg("Ollama host failed, trying the next one")
+			lastErr = fmt.Errorf("%s: %w", b.name, err)
+			continue
+		}
+
+		retry := resp.StatusCode >= http.StatusInternalServerError ||
+			(model != "" && resp.StatusCode == http.StatusNotFound)
+		if retry && i < len(candidates)-1 {
+			resp.Body.Close()
+			atomic.AddInt64(&b.inFlight, -1)
+			p.logger.Warn().Str("host", b.name).Str("endpoint", endpoint).Int("status", resp.StatusCode).Msg("Ollama host returned an error, trying the next one")
+			lastErr = fmt.Errorf("%s returned status %d", b.name, resp.StatusCode)
+			continue
+		}
+
+		resp.Body = &backendBody{ReadCloser: resp.Body, backend: b}
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// refresh updates the inventory of a host after a model was added to or removed from it
+func (p *OllamaPool) refresh(name string) {
+	b := p.backend(name)
+	if b == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaHealthTimeout)
+	defer cancel()
+	p.check(ctx, b)
+}
+
+// pick returns the best host for a request about a model
+func (p *OllamaPool) pick(ctx context.Context, model string) *ollamaBackend {
+	return p.candidates(ctx, model)[0]
+}
+
+// pickPlaced returns the best host for a model among those its placement rule names
+func (p *OllamaPool) pickPlaced(ctx context.Context, model string) *ollamaBackend {
+	placed := p.placementHosts(model)
+	for _, b := range p.candidates(ctx, model) {
+		for _, target := range placed {
+			if b == target {
+				return b
+			}
+		}
+	}
+	return p.pick(ctx, model)
+}
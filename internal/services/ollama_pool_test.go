@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/utils"
+)
+
+// fakeOllama is an Ollama host answering the inventory endpoints with fixed models and
+// /api/chat with a fixed status
+type fakeOllama struct {
+	name      string
+	installed []string
+	loaded    []string
+	status    int   // Status of /api/chat; 0 answers 200
+	chats     int64 // /api/chat requests received, updated atomically
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/tags", "/api/ps":
+		names := f.installed
+		if r.URL.Path == "/api/ps" {
+			names = f.loaded
+		}
+		var result struct {
+			Models []map[string]string `json:"models"`
+		}
+		result.Models = []map[string]string{}
+		for _, name := range names {
+			result.Models = append(result.Models, map[string]string{"name": name})
+		}
+		json.NewEncoder(w).Encode(result)
+	case "/api/chat":
+		atomic.AddInt64(&f.chats, 1)
+		if f.status != 0 && f.status != http.StatusOK {
+			http.Error(w, "failed", f.status)
+			return
+		}
+		fmt.Fprint(w, f.name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestPool starts the fake hosts and returns a pool over them in the given order. A nil
+// host is an address nothing listens on.
+func newTestPool(t *testing.T, hosts ...*fakeOllama) *OllamaPool {
+	t.Helper()
+	var specs []string
+	for i, host := range hosts {
+		var url string
+		if host == nil {
+			server := httptest.NewServer(http.NotFoundHandler())
+			url = server.URL
+			server.Close()
+			specs = append(specs, fmt.Sprintf("down%d=%s", i, url))
+			continue
+		}
+		server := httptest.NewServer(host)
+		t.Cleanup(server.Close)
+		specs = append(specs, host.name+"="+server.URL)
+	}
+	cfg := &config.Config{OllamaHost: strings.Join(specs, ","), OllamaHealthInterval: time.Minute}
+	return newOllamaPool(cfg, utils.NewLogger("disabled", "json"))
+}
+
+// candidateNames returns the names of the hosts in the order they would be tried
+func candidateNames(pool *OllamaPool, model string) []string {
+	var names []string
+	for _, b := range pool.candidates(context.Background(), model) {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+func TestOllamaPoolRanking(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []*fakeOllama
+		busy  string // Host given a request in flight
+		model string
+		want  []string
+	}{
+		{
+			name: "host with the model first",
+			hosts: []*fakeOllama{
+				{name: "a", installed: []string{"mistral:latest"}},
+				{name: "b", installed: []string{"llama3:latest"}},
+			},
+			model: "llama3",
+			want:  []string{"b", "a"},
+		},
+		{
+			name: "host with the model first even when busy",
+			hosts: []*fakeOllama{
+				{name: "a"},
+				{name: "b", installed: []string{"llama3:8b"}},
+			},
+			busy:  "b",
+			model: "llama3:8b",
+			want:  []string{"b", "a"},
+		},
+		{
+			name: "least loaded among hosts with the model",
+			hosts: []*fakeOllama{
+				{name: "a", installed: []string{"llama3:latest"}},
+				{name: "b", installed: []string{"llama3:latest"}},
+			},
+			busy:  "a",
+			model: "llama3",
+			want:  []string{"b", "a"},
+		},
+		{
+			name: "loaded model breaks a tie",
+			hosts: []*fakeOllama{
+				{name: "a", installed: []string{"llama3:latest"}},
+				{name: "b", installed: []string{"llama3:latest"}, loaded: []string{"llama3:latest"}},
+			},
+			model: "llama3",
+			want:  []string{"b", "a"},
+		},
+		{
+			name: "unreachable host last",
+			hosts: []*fakeOllama{
+				nil,
+				{name: "b"},
+			},
+			model: "llama3",
+			want:  []string{"b", "down0"},
+		},
+		{
+			name: "no model ranks by load only",
+			hosts: []*fakeOllama{
+				{name: "a", installed: []string{"llama3:latest"}},
+				{name: "b"},
+			},
+			busy: "a",
+			want: []string{"b", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestPool(t, tt.hosts...)
+			pool.CheckAll(context.Background())
+			if tt.busy != "" {
+				atomic.AddInt64(&pool.backend(tt.busy).inFlight, 1)
+			}
+			if got := candidateNames(pool, tt.model); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("candidates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaPoolSendFailover(t *testing.T) {
+	tests := []struct {
+		name       string
+		first      *fakeOllama // nil when the first host cannot be reached
+		model      string
+		wantHost   string
+		wantStatus int
+		unhealthy  bool // Whether the first host is marked unhealthy
+	}{
+		{name: "connection error", first: nil, model: "llama3", wantHost: "second", wantStatus: http.StatusOK, unhealthy: true},
+		{name: "server error", first: &fakeOllama{name: "first", status: http.StatusBadGateway}, model: "llama3", wantHost: "second", wantStatus: http.StatusOK},
+		{name: "model not found", first: &fakeOllama{name: "first", status: http.StatusNotFound}, model: "llama3", wantHost: "second", wantStatus: http.StatusOK},
+		{name: "not found without a model is final", first: &fakeOllama{name: "first", status: http.StatusNotFound}, wantStatus: http.StatusNotFound},
+		{name: "client error is final", first: &fakeOllama{name: "first", status: http.StatusBadRequest}, model: "llama3", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := &fakeOllama{name: "second"}
+			pool := newTestPool(t, tt.first, second)
+
+			resp, err := pool.send(context.Background(), http.DefaultClient, tt.model, "POST", "/api/chat", []byte(`{}`))
+			if err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantHost != "" && string(body) != tt.wantHost {
+				t.Errorf("answered by %q, want %q", body, tt.wantHost)
+			}
+			if tt.wantHost == "" && atomic.LoadInt64(&second.chats) != 0 {
+				t.Errorf("second host received %d requests, want none", atomic.LoadInt64(&second.chats))
+			}
+
+			// Only the host that answered holds a slot until the body is closed
+			answered := pool.backends[1]
+			if tt.wantHost == "" {
+				answered = pool.backends[0]
+			}
+			for _, b := range pool.backends {
+				want := int64(0)
+				if b == answered {
+					want = 1
+				}
+				if got := atomic.LoadInt64(&b.inFlight); got != want {
+					t.Errorf("host %s has %d requests in flight before close, want %d", b.name, got, want)
+				}
+			}
+			resp.Body.Close()
+			resp.Body.Close()
+			for _, b := range pool.backends {
+				if got := atomic.LoadInt64(&b.inFlight); got != 0 {
+					t.Errorf("host %s has %d requests in flight after close, want 0", b.name, got)
+				}
+			}
+
+			if healthy := pool.backends[0].isHealthy(); healthy == tt.unhealthy {
+				t.Errorf("first host healthy = %v, want %v", healthy, !tt.unhealthy)
+			}
+		})
+	}
+}
+
+func TestOllamaPoolSendAllHostsDown(t *testing.T) {
+	pool := newTestPool(t, nil, nil)
+	if _, err := pool.send(context.Background(), http.DefaultClient, "llama3", "POST", "/api/chat", []byte(`{}`)); err == nil {
+		t.Fatal("send succeeded with every host down, want an error")
+	}
+	for _, b := range pool.backends {
+		if got := atomic.LoadInt64(&b.inFlight); got != 0 {
+			t.Errorf("host %s has %d requests in flight, want 0", b.name, got)
+		}
+		if b.isHealthy() {
+			t.Errorf("host %s is healthy, want it marked failed", b.name)
+		}
+	}
+}
@@ -0,0 +1,633 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+)
+
+// ReembedService migrates a user's memory to another embedding model in the background
+type ReembedService struct {
+	db               database.Database
+	vectors          database.VectorStore
+	embeddingService *EmbeddingService
+	registry         *EmbeddingRegistry
+	logger           *utils.Logger
+	config           *config.Config
+}
+
+// NewReembedService creates a new re-embedding service
+func NewReembedService(db database.Database, embeddingService *EmbeddingService, cfg *config.Config, logger *utils.Logger) *ReembedService {
+	registry := NewEmbeddingRegistry(db, embeddingService, cfg.EmbeddingModel, logger)
+	registry.SetIndexes(NewVectorIndexService(db, cfg, logger))
+
+	return &ReembedService{
+		db:               db,
+		vectors:          db.Vectors(),
+		embeddingService: embeddingService,
+		registry:         registry,
+		logger:           logger.WithComponent("reembed_service"),
+		config:           cfg,
+	}
+}
+
+const reembedJobColumns = `id, user_id, source_model, target_model, target_dimensions, status, phase, last_item_id,
+	total_items, processed_items, failed_items, error, created_at, started_at, completed_at, updated_at`
+
+// reembedItem is a piece of memory to embed again
+type reembedItem struct {
+	id        string
+	sessionID string
+	role      string
+	content   string
+	createdAt sql.NullTime
+}
+
+// Name returns the worker name
+func (s *ReembedService) Name() string {
+	return "reembedder"
+}
+
+// Run processes pending re-embedding jobs until ctx is cancelled. Jobs that were
+// interrupted by a shutdown are resumed from where they stopped.
+func (s *ReembedService) Run(ctx context.Context) {
+	result, err := s.db.ExecContext(ctx, "UPDATE reembed_jobs SET status = 'pending' WHERE status = 'running'")
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to requeue interrupted re-embedding jobs")
+	} else if resumed, err := result.RowsAffected(); err == nil && resumed > 0 {
+		s.logger.Info().Int64("jobs", resumed).Msg("Resuming interrupted re-embedding jobs")
+	}
+
+	runPeriodically(ctx, s.config.ReembedPollInterval, s.logger, s.RunOnce)
+}
+
+// RunOnce processes pending jobs one at a time until none are left
+func (s *ReembedService) RunOnce(ctx context.Context) error {
+	for ctx.Err() == nil {
+		job, err := s.claimNextJob(ctx)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return nil
+		}
+		s.processJob(ctx, job)
+	}
+	return nil
+}
+
+// CreateJob queues a job migrating the user's memory to targetModel
+func (s *ReembedService) CreateJob(ctx context.Context, userID, targetModel string) (*models.ReembedJob, error) {
+	targetModel = strings.TrimSpace(targetModel)
+	if targetModel == "" {
+		return nil, fmt.Errorf("target model is required")
+	}
+
+	sourceModel := s.registry.ModelForUser(ctx, userID)
+	if sourceModel == targetModel {
+		return nil, fmt.Errorf("memory already uses this embedding model")
+	}
+
+	var active int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM reembed_jobs WHERE user_id = $1 AND status IN ('pending', 'running')", userID,
+	).Scan(&active); err != nil {
+		return nil, fmt.Errorf("failed to check for active jobs: %w", err)
+	}
+	if active > 0 {
+		return nil, fmt.Errorf("a re-embedding job is already in progress")
+	}
+
+	dims, err := s.registry.RegisterModel(ctx, targetModel)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("model", targetModel).Msg("Embedding model cannot be used for re-embedding")
+		return nil, fmt.Errorf("embedding model not available")
+	}
+
+	total, err := s.countItems(ctx, userID, targetModel)
+	if err != nil {
+		return nil, err
+	}
+
+	jobID := uuid.New().String()
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO reembed_jobs (id, user_id, source_model, target_model, target_dimensions, phase, total_items)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, jobID, userID, sourceModel, targetModel, dims, models.ReembedPhaseMessages, total)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("a re-embedding job is already in progress")
+		}
+		return nil, fmt.Errorf("failed to create re-embedding job: %w", err)
+	}
+
+	s.logger.Info().
+		Str("job_id", jobID).
+		Str("user_id", userID).
+		Str("source_model", sourceModel).
+		Str("target_model", targetModel).
+		Int("dimensions", dims).
+		Int("total_items", total).
+		Msg("Re-embedding job created")
+
+	return s.GetJob(ctx, jobID, userID)
+}
+
+// GetJob retrieves a job owned by the user
+func (s *ReembedService) GetJob(ctx context.Context, jobID, userID string) (*models.ReembedJob, error) {
+	job, err := scanReembedJob(s.db.QueryRowContext(ctx, "SELECT "+reembedJobColumns+" FROM reembed_jobs WHERE id = $1", jobID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("job not found")
+		}
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+	return job, nil
+}
+
+// ListJobs retrieves the user's jobs, newest first
+func (s *ReembedService) ListJobs(ctx context.Context, userID string) ([]models.ReembedJob, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+reembedJobColumns+" FROM reembed_jobs WHERE user_id = $1 ORDER BY created_at DESC", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query re-embedding jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.ReembedJob{}
+	for rows.Next() {
+		job, err := scanReembedJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating re-embedding jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// CancelJob stops a pending or running job; work already done is kept
+func (s *ReembedService) CancelJob(ctx context.Context, jobID, userID string) (*models.ReembedJob, error) {
+	job, err := s.GetJob(ctx, jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ReembedStatusPending && job.Status != models.ReembedStatusRunning {
+		return nil, fmt.Errorf("job is not in progress")
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE reembed_jobs SET status = 'cancelled', completed_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'running')
+	`, jobID); err != nil {
+		return nil, fmt.Errorf("failed to cancel re-embedding job: %w", err)
+	}
+
+	return s.GetJob(ctx, jobID, userID)
+}
+
+// CurrentModel returns the embedding model the user's memory is stored under
+func (s *ReembedService) CurrentModel(ctx context.Context, userID string) string {
+	return s.registry.ModelForUser(ctx, userID)
+}
+
+// claimNextJob marks the oldest pending job as running and returns it
+func (s *ReembedService) claimNextJob(ctx context.Context) (*models.ReembedJob, error) {
+	job, err := scanReembedJob(s.db.QueryRowContext(ctx, `
+		UPDATE reembed_jobs SET status = 'running', started_at = COALESCE(started_at, NOW())
+		WHERE id = (
+			SELECT id FROM reembed_jobs WHERE status = 'pending'
+			ORDER BY created_at ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+reembedJobColumns))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim re-embedding job: %w", err)
+	}
+	return job, nil
+}
+
+// processJob works through the job's phases batch by batch, saving progress after each batch
+func (s *ReembedService) processJob(ctx context.Context, job *models.ReembedJob) {
+	logger := s.logger.With().Str("job_id", job.ID).Str("user_id", job.UserID).Logger()
+	logger.Info().
+		Str("target_model", job.TargetModel).
+		Str("phase", job.Phase).
+		Msg("Processing re-embedding job")
+
+	for {
+		if ctx.Err() != nil {
+			// Shutting down; the job is resumed on the next start
+			return
+		}
+
+		var status string
+		if err := s.db.QueryRowContext(ctx, "SELECT status FROM reembed_jobs WHERE id = $1", job.ID).Scan(&status); err != nil {
+			logger.Error().Err(err).Msg("Failed to check re-embedding job status")
+			return
+		}
+		if status == models.ReembedStatusCancelled {
+			logger.Info().Msg("Re-embedding job cancelled")
+			return
+		}
+
+		if job.Phase == models.ReembedPhaseSwitch {
+			if err := s.switchModel(ctx, job); err != nil {
+				if ctx.Err() == nil {
+					s.failJob(job, err)
+				}
+				return
+			}
+			logger.Info().
+				Int("processed_items", job.ProcessedItems).
+				Int("failed_items", job.FailedItems).
+				Msg("Re-embedding job completed")
+			return
+		}
+
+		items, err := s.nextBatch(ctx, job)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.failJob(job, err)
+			}
+			return
+		}
+
+		if len(items) == 0 {
+			job.Phase = nextReembedPhase(job.Phase)
+			job.LastItemID = ""
+		} else {
+			failed, lastErr := s.embedBatch(ctx, job, items)
+			if ctx.Err() != nil {
+				return
+			}
+			if failed == len(items) {
+				// Nothing in the batch could be embedded, so the model itself is most likely unavailable
+				s.failJob(job, fmt.Errorf("failed to embed batch: %w", lastErr))
+				return
+			}
+			job.ProcessedItems += len(items) - failed
+			job.FailedItems += failed
+			job.LastItemID = items[len(items)-1].id
+		}
+
+		if err := s.saveProgress(ctx, job); err != nil {
+			logger.Error().Err(err).Msg("Failed to save re-embedding progress")
+			return
+		}
+	}
+}
+
+// nextReembedPhase returns the phase that follows phase
+func nextReembedPhase(phase string) string {
+	switch phase {
+	case models.ReembedPhaseMessages:
+		return models.ReembedPhaseSummaries
+	case models.ReembedPhaseSummaries:
+		return models.ReembedPhaseFacts
+	default:
+		return models.ReembedPhaseSwitch
+	}
+}
+
+// nextBatch loads the next items of the job's current phase after its cursor
+func (s *ReembedService) nextBatch(ctx context.Context, job *models.ReembedJob) ([]reembedItem, error) {
+	var query string
+	switch job.Phase {
+	case models.ReembedPhaseMessages:
+		return s.nextMessageBatch(ctx, job)
+	case models.ReembedPhaseSummaries:
+		query = `
+			SELECT ms.id, '', '', ms.content, NULL::timestamptz
+			FROM memory_summaries ms
+			LEFT JOIN sessions s ON ms.session_id = s.id
+			WHERE (ms.user_id = $1 OR s.user_id = $1) AND ms.embedding_model IS DISTINCT FROM $2 AND ms.id > $3
+			ORDER BY ms.id
+			LIMIT $4
+		`
+	case models.ReembedPhaseFacts:
+		query = `
+			SELECT id, '', '', content, NULL::timestamptz
+			FROM user_memories
+			WHERE user_id = $1 AND model_used IS DISTINCT FROM $2 AND id > $3
+			ORDER BY id
+			LIMIT $4
+		`
+	default:
+		return nil, fmt.Errorf("unknown re-embedding phase: %s", job.Phase)
+	}
+
+	return s.queryItems(ctx, job.Phase, query, job.UserID, job.TargetModel, job.LastItemID, s.config.ReembedBatchSize)
+}
+
+// nextMessageBatch loads the next messages after the job's cursor that have no vector for
+// the target model, paging past messages the vector store already has
+func (s *ReembedService) nextMessageBatch(ctx context.Context, job *models.ReembedJob) ([]reembedItem, error) {
+	cursor := job.LastItemID
+	for {
+		page, err := s.queryItems(ctx, job.Phase, `
+			SELECT m.id, m.session_id, m.role, m.content, m.created_at
+			FROM messages m
+			JOIN sessions s ON m.session_id = s.id
+			WHERE s.user_id = $1 AND m.role IN ('user', 'assistant') AND m.id > $2
+			ORDER BY m.id
+			LIMIT $3
+		`, job.UserID, cursor, s.config.ReembedBatchSize)
+		if err != nil || len(page) == 0 {
+			return page, err
+		}
+
+		ids := make([]string, len(page))
+		for i, item := range page {
+			ids[i] = item.id
+		}
+		existing, err := s.vectors.List(ctx, database.VectorFilter{Model: job.TargetModel, MessageIDs: ids}, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for existing embeddings: %w", err)
+		}
+		embedded := make(map[string]bool, len(existing))
+		for _, record := range existing {
+			embedded[record.MessageID] = true
+		}
+
+		var items []reembedItem
+		for _, item := range page {
+			if !embedded[item.id] {
+				items = append(items, item)
+			}
+		}
+		if len(items) > 0 || len(page) < s.config.ReembedBatchSize {
+			return items, nil
+		}
+		cursor = page[len(page)-1].id
+	}
+}
+
+// queryItems runs a query selecting items to re-embed
+func (s *ReembedService) queryItems(ctx context.Context, phase, query string, args ...interface{}) ([]reembedItem, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s to re-embed: %w", phase, err)
+	}
+	defer rows.Close()
+
+	var items []reembedItem
+	for rows.Next() {
+		var item reembedItem
+		if err := rows.Scan(&item.id, &item.sessionID, &item.role, &item.content, &item.createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan item to re-embed: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// embedBatch embeds each item with the target model and stores it. It returns the
+// number of items that failed and the last error seen.
+func (s *ReembedService) embedBatch(ctx context.Context, job *models.ReembedJob, items []reembedItem) (int, error) {
+	failed := 0
+	var lastErr error
+
+	texts := make([]string, len(items))
+	for i, item := range items {
+		texts[i] = item.content
+	}
+
+	// Embed the whole batch in one request, falling back to one item at a time so a single
+	// bad item does not fail its neighbours
+	embeddings, err := s.embeddingService.GenerateEmbeddingBatch(ctx, texts, job.TargetModel)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("job_id", job.ID).Str("phase", job.Phase).Msg("Batch embedding failed, embedding items individually")
+		embeddings = nil
+	}
+
+	for i, item := range items {
+		var err error
+		if embeddings != nil {
+			err = s.storeItem(ctx, job, item, embeddings[i])
+		} else {
+			err = s.embedItem(ctx, job, item)
+		}
+		if err != nil {
+			failed++
+			lastErr = err
+			s.logger.Warn().Err(err).
+				Str("job_id", job.ID).
+				Str("phase", job.Phase).
+				Str("item_id", item.id).
+				Msg("Failed to re-embed item")
+		}
+	}
+
+	return failed, lastErr
+}
+
+// embedItem embeds a single item with the target model and stores the vector
+func (s *ReembedService) embedItem(ctx context.Context, job *models.ReembedJob, item reembedItem) error {
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, item.content, job.TargetModel)
+	if err != nil {
+		return err
+	}
+	return s.storeItem(ctx, job, item, embedding)
+}
+
+// storeItem stores the target model's vector of a single item
+func (s *ReembedService) storeItem(ctx context.Context, job *models.ReembedJob, item reembedItem, embedding []float32) error {
+	var err error
+	if len(embedding) != job.TargetDimensions {
+		return fmt.Errorf("model returned %d dimensions, expected %d", len(embedding), job.TargetDimensions)
+	}
+
+	switch job.Phase {
+	case models.ReembedPhaseMessages:
+		// Messages keep their vectors for every model so searches can switch models at once
+		err = s.vectors.Upsert(ctx, database.VectorRecord{
+			MessageID:        item.id,
+			SessionID:        item.sessionID,
+			UserID:           job.UserID,
+			Role:             item.role,
+			Content:          item.content,
+			Model:            job.TargetModel,
+			Embedding:        embedding,
+			MessageCreatedAt: item.createdAt.Time,
+		})
+	case models.ReembedPhaseSummaries:
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE memory_summaries SET embedding = $1, embedding_model = $2 WHERE id = $3",
+			pgvector.NewVector(embedding), job.TargetModel, item.id,
+		)
+	case models.ReembedPhaseFacts:
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE user_memories SET embedding = $1, model_used = $2 WHERE id = $3",
+			pgvector.NewVector(embedding), job.TargetModel, item.id,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store re-embedded %s: %w", job.Phase, err)
+	}
+	return nil
+}
+
+// switchModel points the user's memory at the target model, embeds messages that arrived
+// while the job was running and drops topics of the old model so they are clustered again
+func (s *ReembedService) switchModel(ctx context.Context, job *models.ReembedJob) error {
+	if _, err := s.db.ExecContext(ctx, "UPDATE users SET embedding_model = $1 WHERE id = $2", job.TargetModel, job.UserID); err != nil {
+		return fmt.Errorf("failed to switch embedding model: %w", err)
+	}
+
+	// New messages are embedded with the target model from now on; catch up on the rest
+	sweep := *job
+	sweep.Phase = models.ReembedPhaseMessages
+	sweep.LastItemID = ""
+	for {
+		items, err := s.nextBatch(ctx, &sweep)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		failed, _ := s.embedBatch(ctx, &sweep, items)
+		job.ProcessedItems += len(items) - failed
+		job.FailedItems += failed
+		sweep.LastItemID = items[len(items)-1].id
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"DELETE FROM semantic_topics WHERE user_id = $1 AND embedding_model IS DISTINCT FROM $2", job.UserID, job.TargetModel,
+	); err != nil {
+		return fmt.Errorf("failed to reset topics: %w", err)
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE reembed_jobs
+		SET status = 'completed', phase = $1, processed_items = $2, failed_items = $3, completed_at = NOW()
+		WHERE id = $4 AND status = 'running'
+	`, models.ReembedPhaseSwitch, job.ProcessedItems, job.FailedItems, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to complete re-embedding job: %w", err)
+	}
+	return nil
+}
+
+// saveProgress records the job's phase, cursor and counters
+func (s *ReembedService) saveProgress(ctx context.Context, job *models.ReembedJob) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE reembed_jobs SET phase = $1, last_item_id = $2, processed_items = $3, failed_items = $4
+		WHERE id = $5 AND status = 'running'
+	`, job.Phase, job.LastItemID, job.ProcessedItems, job.FailedItems, job.ID)
+	return err
+}
+
+// failJob marks a job as failed; creating a new job resumes the migration
+func (s *ReembedService) failJob(job *models.ReembedJob, jobErr error) {
+	s.logger.Error().Err(jobErr).Str("job_id", job.ID).Str("phase", job.Phase).Msg("Re-embedding job failed")
+
+	// Use a fresh context so the failure is recorded even if the worker context is ending
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.OllamaTimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE reembed_jobs
+		SET status = 'failed', error = $1, processed_items = $2, failed_items = $3, completed_at = NOW()
+		WHERE id = $4 AND status = 'running'
+	`, jobErr.Error(), job.ProcessedItems, job.FailedItems, job.ID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("job_id", job.ID).Msg("Failed to record re-embedding job failure")
+	}
+}
+
+// countItems counts the memory a job for targetModel has to embed
+func (s *ReembedService) countItems(ctx context.Context, userID, targetModel string) (int, error) {
+	var messages, rest int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM messages m
+			 JOIN sessions s ON m.session_id = s.id
+			 WHERE s.user_id = $1 AND m.role IN ('user', 'assistant')),
+			(SELECT COUNT(*) FROM memory_summaries ms
+			 LEFT JOIN sessions s ON ms.session_id = s.id
+			 WHERE (ms.user_id = $1 OR s.user_id = $1) AND ms.embedding_model IS DISTINCT FROM $2)
+			+ (SELECT COUNT(*) FROM user_memories WHERE user_id = $1 AND model_used IS DISTINCT FROM $2)
+	`, userID, targetModel).Scan(&messages, &rest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count memory to re-embed: %w", err)
+	}
+
+	// Messages that already have a vector for the target model are skipped
+	sessionIDs, err := userSessionIDs(ctx, s.db, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	embedded, err := s.vectors.Count(ctx, database.VectorFilter{
+		Model:      targetModel,
+		SessionIDs: sessionIDs,
+		Roles:      []string{"user", "assistant"},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count memory to re-embed: %w", err)
+	}
+	if pending := messages - int(embedded); pending > 0 {
+		rest += pending
+	}
+	return rest, nil
+}
+
+// scanReembedJob scans a reembed_jobs row
+func scanReembedJob(row rowScanner) (*models.ReembedJob, error) {
+	var job models.ReembedJob
+	var jobErr sql.NullString
+	var startedAt, completedAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.UserID, &job.SourceModel, &job.TargetModel, &job.TargetDimensions, &job.Status, &job.Phase,
+		&job.LastItemID, &job.TotalItems, &job.ProcessedItems, &job.FailedItems, &jobErr, &job.CreatedAt, &startedAt,
+		&completedAt, &job.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan re-embedding job: %w", err)
+	}
+
+	job.Error = jobErr.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+
+	switch {
+	case job.Status == models.ReembedStatusCompleted:
+		job.Progress = 1
+	case job.TotalItems > 0:
+		job.Progress = float64(job.ProcessedItems+job.FailedItems) / float64(job.TotalItems)
+		if job.Progress > 1 {
+			job.Progress = 1
+		}
+	}
+
+	return &job, nil
+}
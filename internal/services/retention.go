@@ -0,0 +1,564 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+// maxRetentionDays bounds the retention a user or project can set
+const maxRetentionDays = 36500
+
+// cachedContentHash computes the embedding cache key of a row's content in SQL, matching
+// contentHash, so the cached embeddings of forgotten text can be found
+const cachedContentHash = `encode(sha256(convert_to(content, 'UTF8')), 'hex')`
+
+// tracesReferencingSessions matches the retrieval traces of the sessions a subquery
+// returns, and the traces of other sessions that show their messages or summaries among
+// the candidates or the selected memory. It must run before the messages and summaries
+// are deleted.
+const tracesReferencingSessions = `session_id IN (%[1]s)
+	OR EXISTS (
+		SELECT 1 FROM jsonb_array_elements(candidates || selected) item
+		WHERE item->>'session_id' IN (%[1]s)
+		   OR item->>'id' IN (SELECT id FROM messages WHERE session_id IN (%[1]s))
+		   OR item->>'id' IN (SELECT id FROM memory_summaries WHERE session_id IN (%[1]s))
+	)`
+
+// retentionCutoff is the time before which memory of a session expires. It expects the
+// session's project as p and its owner as u, with the server default as $1.
+const retentionCutoff = `NOW() - make_interval(days => COALESCE(p.memory_retention_days, u.memory_retention_days, NULLIF($1::int, 0)))`
+
+// RetentionService enforces memory retention policies and purges forgotten sessions and accounts
+type RetentionService struct {
+	db     database.Database
+	config *config.Config
+	jobs   *JobQueue
+	logger *utils.Logger
+}
+
+// NewRetentionService creates a new retention service
+func NewRetentionService(db database.Database, cfg *config.Config, logger *utils.Logger) *RetentionService {
+	return &RetentionService{
+		db:     db,
+		config: cfg,
+		logger: logger.WithComponent("retention"),
+	}
+}
+
+// RegisterJobs registers the retention job handler on the queue
+func (s *RetentionService) RegisterJobs(queue *JobQueue) {
+	s.jobs = queue
+	queue.Register(JobTypeEnforceRetention, 10*time.Minute, s.handleEnforceRetention)
+	queue.Register(JobTypePruneEmbeddingCache, 10*time.Minute, s.handlePruneEmbeddingCache)
+}
+
+// Name returns the worker name
+func (s *RetentionService) Name() string {
+	return "retention"
+}
+
+// Run periodically queues a retention run and an embedding cache prune until ctx is cancelled
+func (s *RetentionService) Run(ctx context.Context) {
+	pruneCache := s.config.EnableEmbeddingCache && (s.config.EmbeddingCacheTTL > 0 || s.config.EmbeddingCacheMaxEntries > 0)
+	if (!s.config.EnableMemoryRetention && !pruneCache) || s.jobs == nil {
+		s.logger.Info().Msg("Memory retention disabled")
+		return
+	}
+
+	s.logger.Info().
+		Bool("memory_retention", s.config.EnableMemoryRetention).
+		Bool("embedding_cache_pruning", pruneCache).
+		Dur("interval", s.config.MemoryRetentionInterval).
+		Int("default_retention_days", s.config.MemoryRetentionDays).
+		Msg("Starting memory retention")
+
+	runPeriodically(ctx, s.config.MemoryRetentionInterval, s.logger, func(ctx context.Context) error {
+		// Deduplicated, so several instances still only run them once
+		if s.config.EnableMemoryRetention {
+			if _, err := s.jobs.Enqueue(ctx, JobTypeEnforceRetention, nil, JobOptions{DedupeKey: JobTypeEnforceRetention}); err != nil {
+				return err
+			}
+		}
+		if pruneCache {
+			if _, err := s.jobs.Enqueue(ctx, JobTypePruneEmbeddingCache, nil, JobOptions{DedupeKey: JobTypePruneEmbeddingCache}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// handleEnforceRetention runs a queued retention run
+func (s *RetentionService) handleEnforceRetention(ctx context.Context, _ json.RawMessage) error {
+	_, err := s.EnforcePolicies(ctx)
+	return err
+}
+
+// handlePruneEmbeddingCache runs a queued embedding cache prune
+func (s *RetentionService) handlePruneEmbeddingCache(ctx context.Context, _ json.RawMessage) error {
+	_, err := s.PruneEmbeddingCache(ctx)
+	return err
+}
+
+// PruneEmbeddingCache deletes the cached embeddings unused for EMBEDDING_CACHE_TTL, then
+// the least recently used ones beyond EMBEDDING_CACHE_MAX_ENTRIES
+func (s *RetentionService) PruneEmbeddingCache(ctx context.Context) (int64, error) {
+	var expired, evicted int64
+	if s.config.EmbeddingCacheTTL > 0 {
+		steps := []purgeStep{{"expired cached embeddings", &expired, `
+			DELETE FROM embedding_cache WHERE last_used_at < NOW() - $1 * INTERVAL '1 second'`}}
+		if err := runPurge(ctx, s.db, steps, s.config.EmbeddingCacheTTL.Seconds()); err != nil {
+			return 0, err
+		}
+	}
+	if s.config.EmbeddingCacheMaxEntries > 0 {
+		steps := []purgeStep{{"least recently used cached embeddings", &evicted, `
+			DELETE FROM embedding_cache WHERE last_used_at <= (
+				SELECT last_used_at FROM embedding_cache
+				ORDER BY last_used_at DESC
+				OFFSET $1 LIMIT 1
+			)`}}
+		if err := runPurge(ctx, s.db, steps, s.config.EmbeddingCacheMaxEntries); err != nil {
+			return 0, err
+		}
+	}
+
+	if expired+evicted > 0 {
+		s.logger.Info().
+			Int64("expired", expired).
+			Int64("evicted", evicted).
+			Msg("Embedding cache pruned")
+	}
+	return expired + evicted, nil
+}
+
+// EnforcePolicies deletes the embeddings, summaries, gaps and retrieval traces older than the
+// retention policy of their session's project, their owner or the server, in that order,
+// along with the traces showing expired memory of other sessions. Chat history itself is kept.
+func (s *RetentionService) EnforcePolicies(ctx context.Context) (*models.PurgeResult, error) {
+	result := &models.PurgeResult{}
+
+	purges := []purgeStep{
+		{"summaries", &result.Summaries, `
+			DELETE FROM memory_summaries WHERE id IN (
+				SELECT ms.id
+				FROM memory_summaries ms
+				LEFT JOIN sessions s ON ms.session_id = s.id
+				LEFT JOIN projects p ON s.project_id = p.id
+				LEFT JOIN users u ON u.id = COALESCE(s.user_id, ms.user_id)
+				WHERE COALESCE(ms.end_time, ms.created_at) < ` + retentionCutoff + `
+			)`},
+		{"gaps", &result.Gaps, `
+			DELETE FROM memory_gaps WHERE id IN (
+				SELECT mg.id
+				FROM memory_gaps mg
+				JOIN sessions s ON mg.session_id = s.id
+				LEFT JOIN projects p ON s.project_id = p.id
+				LEFT JOIN users u ON s.user_id = u.id
+				WHERE mg.gap_end < ` + retentionCutoff + `
+			)`},
+		{"traces", &result.Traces, `
+			DELETE FROM retrieval_traces WHERE id IN (
+				SELECT rt.id
+				FROM retrieval_traces rt
+				JOIN sessions s ON rt.session_id = s.id
+				LEFT JOIN projects p ON s.project_id = p.id
+				LEFT JOIN users u ON s.user_id = u.id
+				WHERE rt.created_at < ` + retentionCutoff + `
+				UNION
+				-- Traces of live sessions showing memory that expired under its own session's policy
+				SELECT rt.id
+				FROM retrieval_traces rt
+				CROSS JOIN LATERAL jsonb_array_elements(rt.candidates || rt.selected) item
+				JOIN sessions ts ON rt.session_id = ts.id
+				LEFT JOIN sessions s ON s.id = item->>'session_id'
+				LEFT JOIN projects p ON s.project_id = p.id
+				LEFT JOIN users u ON u.id = COALESCE(s.user_id, ts.user_id)
+				WHERE (item->>'created_at')::timestamptz < ` + retentionCutoff + `
+			)`},
+	}
+
+	embeddings, err := s.expireEmbeddings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.Embeddings = embeddings
+
+	if err := runPurge(ctx, s.db, purges, s.config.MemoryRetentionDays); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Int64("embeddings", result.Embeddings).
+		Int64("summaries", result.Summaries).
+		Int64("gaps", result.Gaps).
+		Int64("traces", result.Traces).
+		Msg("Retention policies enforced")
+
+	return result, nil
+}
+
+// expireEmbeddings deletes the vectors older than the retention policy that applies to them.
+// Vectors of deleted sessions fall back to their owner's policy, then the server default.
+func (s *RetentionService) expireEmbeddings(ctx context.Context) (int64, error) {
+	vectors := s.db.Vectors()
+	now := time.Now()
+
+	// Sessions grouped by the number of days their memory is kept
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id, COALESCE(p.memory_retention_days, u.memory_retention_days, NULLIF($1::int, 0))
+		FROM sessions s
+		LEFT JOIN projects p ON s.project_id = p.id
+		LEFT JOIN users u ON s.user_id = u.id
+	`, s.config.MemoryRetentionDays)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load session retention: %w", err)
+	}
+	liveSessions := []string{}
+	sessionsByDays := make(map[int][]string)
+	for rows.Next() {
+		var sessionID string
+		var days sql.NullInt64
+		if err := rows.Scan(&sessionID, &days); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session retention: %w", err)
+		}
+		liveSessions = append(liveSessions, sessionID)
+		if days.Valid {
+			sessionsByDays[int(days.Int64)] = append(sessionsByDays[int(days.Int64)], sessionID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating session retention: %w", err)
+	}
+
+	var deleted int64
+	for days, sessionIDs := range sessionsByDays {
+		n, err := vectors.Delete(ctx, database.VectorFilter{
+			SessionIDs:    sessionIDs,
+			CreatedBefore: now.AddDate(0, 0, -days),
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+
+	// Vectors left behind by deleted sessions
+	rows, err = s.db.QueryContext(ctx, "SELECT id, memory_retention_days FROM users WHERE memory_retention_days IS NOT NULL")
+	if err != nil {
+		return deleted, fmt.Errorf("failed to load user retention: %w", err)
+	}
+	userDays := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var days int
+		if err := rows.Scan(&userID, &days); err != nil {
+			rows.Close()
+			return deleted, fmt.Errorf("failed to scan user retention: %w", err)
+		}
+		userDays[userID] = days
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return deleted, fmt.Errorf("error iterating user retention: %w", err)
+	}
+
+	usersWithPolicy := []string{}
+	for userID, days := range userDays {
+		usersWithPolicy = append(usersWithPolicy, userID)
+		n, err := vectors.Delete(ctx, database.VectorFilter{
+			UserID:            userID,
+			ExcludeSessionIDs: liveSessions,
+			CreatedBefore:     now.AddDate(0, 0, -days),
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	if s.config.MemoryRetentionDays > 0 {
+		n, err := vectors.Delete(ctx, database.VectorFilter{
+			ExcludeUserIDs:    usersWithPolicy,
+			ExcludeSessionIDs: liveSessions,
+			CreatedBefore:     now.AddDate(0, 0, -s.config.MemoryRetentionDays),
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+
+	return deleted, nil
+}
+
+// ForgetSession deletes a session together with everything derived from it: its embeddings,
+// summaries, gaps, the retrieval traces of any session showing its memory and the facts
+// proposed from it that were never confirmed
+func (s *RetentionService) ForgetSession(ctx context.Context, sessionID string) (*models.PurgeResult, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM sessions WHERE id = $1)", sessionID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check session: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	// Vectors are removed first so a failure never leaves them behind a deleted session
+	result := &models.PurgeResult{}
+	embeddings, err := s.db.Vectors().Delete(ctx, database.VectorFilter{SessionIDs: []string{sessionID}})
+	if err != nil {
+		return nil, err
+	}
+	result.Embeddings = embeddings
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deletes := []purgeStep{
+		{"cached embeddings", &result.CachedEmbeddings, `
+			DELETE FROM embedding_cache WHERE content_hash IN (
+				SELECT ` + cachedContentHash + ` FROM messages WHERE session_id = $1
+				UNION SELECT ` + cachedContentHash + ` FROM memory_summaries WHERE session_id = $1
+				UNION SELECT ` + cachedContentHash + ` FROM user_memories WHERE source_session_id = $1 AND status <> 'confirmed'
+			)`},
+		{"traces", &result.Traces, "DELETE FROM retrieval_traces WHERE " + fmt.Sprintf(tracesReferencingSessions, "SELECT $1::text")},
+		{"summaries", &result.Summaries, "DELETE FROM memory_summaries WHERE session_id = $1"},
+		{"gaps", &result.Gaps, "DELETE FROM memory_gaps WHERE session_id = $1"},
+		{"facts", &result.Facts, "DELETE FROM user_memories WHERE source_session_id = $1 AND status <> 'confirmed'"},
+		{"messages", &result.Messages, "DELETE FROM messages WHERE session_id = $1"},
+		{"session", &result.Sessions, "DELETE FROM sessions WHERE id = $1"},
+	}
+	if err := runPurge(ctx, tx, deletes, sessionID); err != nil {
+		return nil, err
+	}
+	if result.Sessions == 0 {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Info().
+		Str("session_id", sessionID).
+		Int64("embeddings", result.Embeddings).
+		Int64("summaries", result.Summaries).
+		Int64("messages", result.Messages).
+		Msg("Session forgotten")
+
+	return result, nil
+}
+
+// PurgeAccount deletes a user and all of their data, including the embeddings of sessions
+// they deleted earlier. Everything else owned by the user goes with it by cascade.
+func (s *RetentionService) PurgeAccount(ctx context.Context, userID string) (*models.PurgeResult, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check user: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// Vectors are removed first so a failure never leaves them behind a deleted account
+	result := &models.PurgeResult{}
+	sessionIDs, err := userSessionIDs(ctx, s.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, filter := range []database.VectorFilter{{SessionIDs: sessionIDs}, {UserID: userID}} {
+		n, err := s.db.Vectors().Delete(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Embeddings += n
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var users int64
+	deletes := []purgeStep{
+		{"cached embeddings", &result.CachedEmbeddings, `
+			DELETE FROM embedding_cache WHERE content_hash IN (
+				SELECT ` + cachedContentHash + ` FROM messages WHERE session_id IN (SELECT id FROM sessions WHERE user_id = $1)
+				UNION SELECT ` + cachedContentHash + ` FROM memory_summaries
+				WHERE user_id = $1 OR session_id IN (SELECT id FROM sessions WHERE user_id = $1)
+				UNION SELECT ` + cachedContentHash + ` FROM user_memories WHERE user_id = $1
+			)`},
+		{"traces", &result.Traces, "DELETE FROM retrieval_traces WHERE " + fmt.Sprintf(tracesReferencingSessions, "SELECT id FROM sessions WHERE user_id = $1")},
+		{"summaries", &result.Summaries, `
+			DELETE FROM memory_summaries
+			WHERE user_id = $1 OR session_id IN (SELECT id FROM sessions WHERE user_id = $1)`},
+		{"gaps", &result.Gaps, "DELETE FROM memory_gaps WHERE session_id IN (SELECT id FROM sessions WHERE user_id = $1)"},
+		{"facts", &result.Facts, "DELETE FROM user_memories WHERE user_id = $1"},
+		{"messages", &result.Messages, "DELETE FROM messages WHERE session_id IN (SELECT id FROM sessions WHERE user_id = $1)"},
+		{"sessions", &result.Sessions, "DELETE FROM sessions WHERE user_id = $1"},
+		{"user", &users, "DELETE FROM users WHERE id = $1"},
+	}
+	if err := runPurge(ctx, tx, deletes, userID); err != nil {
+		return nil, err
+	}
+	if users == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Info().
+		Str("user_id", userID).
+		Int64("embeddings", result.Embeddings).
+		Int64("messages", result.Messages).
+		Int64("sessions", result.Sessions).
+		Msg("Account purged")
+
+	return result, nil
+}
+
+// purgeStep is a delete whose affected rows are counted into a purge result
+type purgeStep struct {
+	name  string
+	count *int64
+	query string
+}
+
+// runPurge runs each step with the same argument, recording how many rows it removed
+func runPurge(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, steps []purgeStep, arg interface{}) error {
+	for _, step := range steps {
+		res, err := db.ExecContext(ctx, step.query, arg)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", step.name, err)
+		}
+		if *step.count, err = res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get deleted %s count: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// GetUserPolicy returns a user's retention policy
+func (s *RetentionService) GetUserPolicy(ctx context.Context, userID string) (*models.RetentionPolicyResponse, error) {
+	var days sql.NullInt64
+	err := s.db.QueryRowContext(ctx, "SELECT memory_retention_days FROM users WHERE id = $1", userID).Scan(&days)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retention policy: %w", err)
+	}
+
+	return s.policyResponse(days, models.RetentionSourceUser, sql.NullInt64{}), nil
+}
+
+// UpdateUserPolicy sets a user's retention policy; a nil policy inherits the server default
+func (s *RetentionService) UpdateUserPolicy(ctx context.Context, userID string, policy models.RetentionPolicy) (*models.RetentionPolicyResponse, error) {
+	if err := ValidateRetentionPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET memory_retention_days = $1 WHERE id = $2", nullableDays(policy.RetentionDays), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update retention policy: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return s.GetUserPolicy(ctx, userID)
+}
+
+// GetProjectPolicy returns a project's retention policy
+func (s *RetentionService) GetProjectPolicy(ctx context.Context, projectID, userID string) (*models.RetentionPolicyResponse, error) {
+	var ownerID string
+	var projectDays, userDays sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT p.user_id, p.memory_retention_days, u.memory_retention_days
+		FROM projects p
+		LEFT JOIN users u ON p.user_id = u.id
+		WHERE p.id = $1
+	`, projectID).Scan(&ownerID, &projectDays, &userDays)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("project not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project: %w", err)
+	}
+	if ownerID != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	return s.policyResponse(projectDays, models.RetentionSourceProject, userDays), nil
+}
+
+// UpdateProjectPolicy sets a project's retention policy; a nil policy inherits the owner's
+func (s *RetentionService) UpdateProjectPolicy(ctx context.Context, projectID, userID string, policy models.RetentionPolicy) (*models.RetentionPolicyResponse, error) {
+	if err := ValidateRetentionPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	if err := checkProjectOwner(ctx, s.db, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE projects SET memory_retention_days = $1 WHERE id = $2", nullableDays(policy.RetentionDays), projectID); err != nil {
+		return nil, fmt.Errorf("failed to update retention policy: %w", err)
+	}
+
+	return s.GetProjectPolicy(ctx, projectID, userID)
+}
+
+// policyResponse resolves the policy in effect from the stored policy and, for projects,
+// the owner's policy it inherits
+func (s *RetentionService) policyResponse(stored sql.NullInt64, source string, inherited sql.NullInt64) *models.RetentionPolicyResponse {
+	response := &models.RetentionPolicyResponse{
+		EffectiveDays: s.config.MemoryRetentionDays,
+		Source:        models.RetentionSourceDefault,
+	}
+	if inherited.Valid {
+		response.EffectiveDays = int(inherited.Int64)
+		response.Source = models.RetentionSourceUser
+	}
+	if stored.Valid {
+		days := int(stored.Int64)
+		response.RetentionDays = &days
+		response.EffectiveDays = days
+		response.Source = source
+	}
+	return response
+}
+
+// ValidateRetentionPolicy checks a retention policy
+func ValidateRetentionPolicy(policy models.RetentionPolicy) error {
+	if policy.RetentionDays != nil && (*policy.RetentionDays < 1 || *policy.RetentionDays > maxRetentionDays) {
+		return fmt.Errorf("retention_days must be between 1 and %d", maxRetentionDays)
+	}
+	return nil
+}
+
+// nullableDays converts an optional number of days for storage
+func nullableDays(days *int) sql.NullInt64 {
+	if days == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*days), Valid: true}
+}
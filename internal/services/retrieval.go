@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+const (
+	// retrievalCandidateFactor is how many candidates are fetched per result to re-rank
+	retrievalCandidateFactor = 4
+	// maxRetrievalResults bounds the max_results a user or project can ask for
+	maxRetrievalResults = 50
+)
+
+// RetrievedContext is the semantic memory selected for a chat message, with what is needed
+// to trace how it was selected
+type RetrievedContext struct {
+	Model      string // Embedding model of the query
+	Settings   models.RetrievalSettings
+	Candidates []models.TraceCandidate
+	Items      []models.ContextItem
+	Text       string // Items rendered with the context template
+}
+
+// RetrievalSettingsService manages the per-user and per-project retrieval settings
+type RetrievalSettingsService struct {
+	db       database.Database
+	defaults models.RetrievalSettings
+	logger   *utils.Logger
+}
+
+// NewRetrievalSettingsService creates a new retrieval settings service
+func NewRetrievalSettingsService(db database.Database, cfg *config.Config, logger *utils.Logger) *RetrievalSettingsService {
+	return &RetrievalSettingsService{
+		db:       db,
+		defaults: DefaultRetrievalSettings(cfg),
+		logger:   logger.WithComponent("retrieval_settings"),
+	}
+}
+
+// DefaultRetrievalSettings returns the server-wide retrieval settings
+func DefaultRetrievalSettings(cfg *config.Config) models.RetrievalSettings {
+	return models.RetrievalSettings{
+		MaxResults:          cfg.MaxContextResults,
+		MinSimilarity:       cfg.RetrievalMinSimilarity,
+		MMRLambda:           cfg.RetrievalMMRLambda,
+		RecencyWeight:       cfg.RetrievalRecencyWeight,
+		RecencyHalfLifeDays: cfg.RetrievalRecencyHalfLife.Hours() / 24,
+		ExcludeRecent:       cfg.RetrievalExcludeRecent,
+		DedupThreshold:      cfg.RetrievalDedupThreshold,
+		Scope:               cfg.RetrievalScope,
+	}
+}
+
+// EffectiveForSession resolves the settings for a chat in a session: the server defaults,
+// overridden by the user's settings, overridden by the settings of the session's project.
+// Lookup failures fall back to the defaults.
+func (s *RetrievalSettingsService) EffectiveForSession(ctx context.Context, userID, sessionID string) models.RetrievalSettings {
+	var userRaw, projectRaw []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT u.retrieval_settings, p.retrieval_settings
+		FROM users u
+		LEFT JOIN sessions s ON s.id = $2 AND s.user_id = u.id
+		LEFT JOIN projects p ON p.id = s.project_id
+		WHERE u.id = $1
+	`, userID, sessionID).Scan(&userRaw, &projectRaw)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			s.logger.Warn().Err(err).Str("user_id", userID).Msg("Failed to load retrieval settings, using defaults")
+		}
+		return s.defaults
+	}
+
+	settings := s.defaults
+	for _, raw := range [][]byte{userRaw, projectRaw} {
+		overrides, err := decodeRetrievalOverrides(raw)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("user_id", userID).Msg("Ignoring invalid retrieval settings")
+			continue
+		}
+		settings = overrides.Apply(settings)
+	}
+	return settings
+}
+
+// GetUserSettings returns a user's retrieval overrides and the settings in effect for them
+func (s *RetrievalSettingsService) GetUserSettings(ctx context.Context, userID string) (*models.RetrievalSettingsResponse, error) {
+	overrides, err := s.userOverrides(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RetrievalSettingsResponse{
+		Overrides: overrides,
+		Effective: overrides.Apply(s.defaults),
+	}, nil
+}
+
+// UpdateUserSettings replaces a user's retrieval overrides
+func (s *RetrievalSettingsService) UpdateUserSettings(ctx context.Context, userID string, overrides models.RetrievalOverrides) (*models.RetrievalSettingsResponse, error) {
+	if err := ValidateRetrievalOverrides(overrides); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode retrieval settings: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET retrieval_settings = $1 WHERE id = $2", raw, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update retrieval settings: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return s.GetUserSettings(ctx, userID)
+}
+
+// GetProjectSettings returns a project's retrieval overrides and the settings in effect for its sessions
+func (s *RetrievalSettingsService) GetProjectSettings(ctx context.Context, projectID, userID string) (*models.RetrievalSettingsResponse, error) {
+	var ownerID string
+	var raw []byte
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id, retrieval_settings FROM projects WHERE id = $1", projectID,
+	).Scan(&ownerID, &raw)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("project not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project: %w", err)
+	}
+	if ownerID != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	overrides, err := decodeRetrievalOverrides(raw)
+	if err != nil {
+		return nil, err
+	}
+	userOverrides, err := s.userOverrides(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RetrievalSettingsResponse{
+		Overrides: overrides,
+		Effective: overrides.Apply(userOverrides.Apply(s.defaults)),
+	}, nil
+}
+
+// UpdateProjectSettings replaces a project's retrieval overrides
+func (s *RetrievalSettingsService) UpdateProjectSettings(ctx context.Context, projectID, userID string, overrides models.RetrievalOverrides) (*models.RetrievalSettingsResponse, error) {
+	if err := ValidateRetrievalOverrides(overrides); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode retrieval settings: %w", err)
+	}
+
+	if err := checkProjectOwner(ctx, s.db, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE projects SET retrieval_settings = $1 WHERE id = $2", raw, projectID); err != nil {
+		return nil, fmt.Errorf("failed to update retrieval settings: %w", err)
+	}
+
+	return s.GetProjectSettings(ctx, projectID, userID)
+}
+
+// checkProjectOwner returns an error unless the project exists and belongs to the user
+func checkProjectOwner(ctx context.Context, db database.Database, projectID, userID string) error {
+	var ownerID string
+	err := db.QueryRowContext(ctx, "SELECT user_id FROM projects WHERE id = $1", projectID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("project not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load project: %w", err)
+	}
+	if ownerID != userID {
+		return fmt.Errorf("access denied")
+	}
+	return nil
+}
+
+// userOverrides loads the retrieval overrides stored for a user
+func (s *RetrievalSettingsService) userOverrides(ctx context.Context, userID string) (models.RetrievalOverrides, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(ctx, "SELECT retrieval_settings FROM users WHERE id = $1", userID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return models.RetrievalOverrides{}, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return models.RetrievalOverrides{}, fmt.Errorf("failed to load retrieval settings: %w", err)
+	}
+	return decodeRetrievalOverrides(raw)
+}
+
+// decodeRetrievalOverrides parses stored overrides; an empty value overrides nothing
+func decodeRetrievalOverrides(raw []byte) (models.RetrievalOverrides, error) {
+	var overrides models.RetrievalOverrides
+	if len(raw) == 0 {
+		return overrides, nil
+	}
+	if err := json.Unmarshal(raw, &overrides); err != nil {
+		return overrides, fmt.Errorf("failed to decode retrieval settings: %w", err)
+	}
+	return overrides, nil
+}
+
+// ValidateRetrievalOverrides checks that every override is within range
+func ValidateRetrievalOverrides(o models.RetrievalOverrides) error {
+	if o.MaxResults != nil && (*o.MaxResults < 1 || *o.MaxResults > maxRetrievalResults) {
+		return fmt.Errorf("max_results must be between 1 and %d", maxRetrievalResults)
+	}
+	if o.MinSimilarity != nil && (*o.MinSimilarity < 0 || *o.MinSimilarity > 1) {
+		return fmt.Errorf("min_similarity must be between 0 and 1")
+	}
+	if o.MMRLambda != nil && (*o.MMRLambda < 0 || *o.MMRLambda > 1) {
+		return fmt.Errorf("mmr_lambda must be between 0 and 1")
+	}
+	if o.RecencyWeight != nil && (*o.RecencyWeight < 0 || *o.RecencyWeight > 1) {
+		return fmt.Errorf("recency_weight must be between 0 and 1")
+	}
+	if o.RecencyHalfLifeDays != nil && *o.RecencyHalfLifeDays <= 0 {
+		return fmt.Errorf("recency_half_life_days must be positive")
+	}
+	if o.ExcludeRecent != nil && *o.ExcludeRecent < 0 {
+		return fmt.Errorf("exclude_recent cannot be negative")
+	}
+	if o.DedupThreshold != nil && (*o.DedupThreshold <= 0 || *o.DedupThreshold > 1) {
+		return fmt.Errorf("dedup_threshold must be greater than 0 and at most 1")
+	}
+	if o.Scope != nil {
+		switch *o.Scope {
+		case models.RetrievalScopeUser, models.RetrievalScopeProject, models.RetrievalScopeSession:
+		default:
+			return fmt.Errorf("scope must be one of user, project or session")
+		}
+	}
+	return nil
+}
+
+// retrievalCandidate is a message considered for the context, with its vector for re-ranking
+type retrievalCandidate struct {
+	item      models.ContextItem
+	embedding []float32
+}
+
+// RetrieveContext selects the user's semantic memory relevant to a message in a session.
+// Candidates within the settings' scope are fetched, the current session's most recent
+// messages excluded, those below the similarity cut-off dropped and the rest weighted by
+// recency. Near-duplicates are collapsed into the best of them and the final selection is
+// re-ranked with maximal marginal relevance so the context covers different ground.
+func (s *SemanticMemoryService) RetrieveContext(ctx context.Context, query, sessionID, userID string, settings models.RetrievalSettings) (*RetrievedContext, error) {
+	retrieved := &RetrievedContext{}
+	// Memory is only ever retrieved from the user's own sessions
+	if userID == "" || settings.MaxResults <= 0 {
+		return retrieved, nil
+	}
+
+	model := s.registry.ModelForUser(ctx, userID)
+	retrieved.Model = model
+	retrieved.Settings = settings
+
+	candidates, err := s.retrievalCandidates(ctx, query, model, sessionID, userID, settings)
+	if err != nil {
+		return nil, err
+	}
+	messages, trace := selectContextMessages(candidates, query, settings, time.Now())
+
+	var summaries []MemorySummary
+	if settings.Scope == models.RetrievalScopeUser {
+		summaries, err = s.SearchSimilarSummaries(ctx, query, userID, maxContextSummaries)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("user_id", userID).Msg("Failed to search memory summaries, continuing with messages only")
+		}
+	}
+
+	// Summaries first, then individual messages
+	for _, summary := range summaries {
+		candidate := models.TraceCandidate{
+			Type:       "summary",
+			ID:         summary.ID,
+			SessionID:  summary.SessionID,
+			Similarity: summary.Similarity,
+			Score:      summary.Similarity,
+			Outcome:    models.TraceOutcomeSelected,
+			CreatedAt:  summary.CreatedAt,
+		}
+		if summary.Similarity < settings.MinSimilarity {
+			candidate.Score = 0
+			candidate.Outcome = models.TraceOutcomeBelowThreshold
+			retrieved.Candidates = append(retrieved.Candidates, candidate)
+			continue
+		}
+		retrieved.Candidates = append(retrieved.Candidates, candidate)
+
+		label := summary.SummaryType + " summary"
+		if summary.Title != "" {
+			label = fmt.Sprintf("%s: %s", label, summary.Title)
+		}
+		retrieved.Items = append(retrieved.Items, models.ContextItem{
+			Type:       "summary",
+			ID:         summary.ID,
+			SessionID:  summary.SessionID,
+			Title:      label,
+			Content:    summary.Content,
+			Similarity: summary.Similarity,
+			Score:      summary.Similarity,
+			CreatedAt:  summary.CreatedAt,
+		})
+	}
+	retrieved.Items = append(retrieved.Items, messages...)
+	retrieved.Candidates = append(retrieved.Candidates, trace...)
+
+	// Number the items so answers can cite them
+	for i := range retrieved.Items {
+		retrieved.Items[i].Marker = fmt.Sprintf("[%d]", i+1)
+	}
+
+	s.logger.Debug().
+		Str("session_id", sessionID).
+		Int("candidates", len(candidates)).
+		Int("messages", len(messages)).
+		Int("summaries", len(retrieved.Items)-len(messages)).
+		Msg("Context retrieved")
+
+	return retrieved, nil
+}
+
+// retrievalCandidates fetches the messages most similar to the query within the settings' scope
+func (s *SemanticMemoryService) retrievalCandidates(ctx context.Context, query, model, sessionID, userID string, settings models.RetrievalSettings) ([]retrievalCandidate, error) {
+	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	var sessionIDs []string
+	switch settings.Scope {
+	case models.RetrievalScopeSession:
+		sessionIDs = []string{sessionID}
+	case models.RetrievalScopeProject:
+		sessionIDs, err = queryIDs(ctx, s.db, `
+			SELECT id FROM sessions
+			WHERE user_id = $1 AND project_id = (SELECT project_id FROM sessions WHERE id = $2)
+		`, userID, sessionID)
+	default:
+		sessionIDs, err = userSessionIDs(ctx, s.db, userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve retrieval scope: %w", err)
+	}
+
+	// The most recent turns are already in the prompt
+	recent, err := queryIDs(ctx, s.db, `
+		SELECT id FROM messages WHERE session_id = $1 ORDER BY created_at DESC LIMIT $2
+	`, sessionID, settings.ExcludeRecent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent messages: %w", err)
+	}
+
+	// Only vectors of the query's model are comparable
+	matches, err := s.vectors.Search(ctx, queryEmbedding, database.VectorFilter{
+		Model:             model,
+		SessionIDs:        sessionIDs,
+		ExcludeMessageIDs: recent,
+	}, settings.MaxResults*retrievalCandidateFactor, database.SearchTuning{})
+	if err != nil {
+		return nil, err
+	}
+
+	return candidatesFromMatches(matches), nil
+}
+
+// candidatesFromMatches turns the messages found by a vector search into retrieval candidates
+func candidatesFromMatches(matches []database.VectorMatch) []retrievalCandidate {
+	candidates := make([]retrievalCandidate, 0, len(matches))
+	for _, match := range matches {
+		candidates = append(candidates, retrievalCandidate{
+			item: models.ContextItem{
+				Type:       "message",
+				ID:         match.MessageID,
+				SessionID:  match.SessionID,
+				Role:       match.Role,
+				Content:    match.Content,
+				Similarity: match.Similarity,
+				CreatedAt:  match.MessageCreatedAt,
+			},
+			embedding: match.Embedding,
+		})
+	}
+	return candidates
+}
+
+// selectContextMessages applies the similarity cut-off, recency weighting, duplicate
+// collapsing and MMR re-ranking to the candidates. It returns the selected items and the
+// outcome of every candidate for the retrieval trace.
+func selectContextMessages(candidates []retrievalCandidate, query string, settings models.RetrievalSettings, now time.Time) ([]models.ContextItem, []models.TraceCandidate) {
+	normalizedQuery := normalizeContent(query)
+	outcomes := make(map[string]string, len(candidates))
+	scores := make(map[string]float64, len(candidates))
+
+	var pool []retrievalCandidate
+	for _, candidate := range candidates {
+		if candidate.item.Similarity < settings.MinSimilarity {
+			outcomes[candidate.item.ID] = models.TraceOutcomeBelowThreshold
+			continue
+		}
+		// A past message repeating the query verbatim adds nothing to it
+		if normalizeContent(candidate.item.Content) == normalizedQuery {
+			outcomes[candidate.item.ID] = models.TraceOutcomeRepeatsQuery
+			continue
+		}
+		candidate.item.Score = candidate.item.Similarity * recencyFactor(now.Sub(candidate.item.CreatedAt), settings)
+		scores[candidate.item.ID] = candidate.item.Score
+		pool = append(pool, candidate)
+	}
+
+	sort.SliceStable(pool, func(i, j int) bool {
+		return pool[i].item.Score > pool[j].item.Score
+	})
+
+	// Collapse near-duplicates into the best-scoring copy
+	var distinct []retrievalCandidate
+	for _, candidate := range pool {
+		duplicate := false
+		for i := range distinct {
+			if normalizeContent(distinct[i].item.Content) == normalizeContent(candidate.item.Content) ||
+				CosineSimilarity(distinct[i].embedding, candidate.embedding) >= settings.DedupThreshold {
+				distinct[i].item.Duplicates++
+				outcomes[candidate.item.ID] = models.TraceOutcomeDuplicate
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			distinct = append(distinct, candidate)
+		}
+	}
+
+	// Maximal marginal relevance: trade the score of each pick against its similarity to earlier picks
+	var selected []retrievalCandidate
+	for len(selected) < settings.MaxResults && len(distinct) > 0 {
+		best, bestValue := 0, math.Inf(-1)
+		for i, candidate := range distinct {
+			redundancy := 0.0
+			for _, picked := range selected {
+				if similarity := CosineSimilarity(candidate.embedding, picked.embedding); similarity > redundancy {
+					redundancy = similarity
+				}
+			}
+			value := settings.MMRLambda*candidate.item.Score - (1-settings.MMRLambda)*redundancy
+			if value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		outcomes[distinct[best].item.ID] = models.TraceOutcomeSelected
+		selected = append(selected, distinct[best])
+		distinct = append(distinct[:best], distinct[best+1:]...)
+	}
+	for _, candidate := range distinct {
+		outcomes[candidate.item.ID] = models.TraceOutcomeNotSelected
+	}
+
+	items := make([]models.ContextItem, len(selected))
+	for i, candidate := range selected {
+		items[i] = candidate.item
+	}
+
+	trace := make([]models.TraceCandidate, len(candidates))
+	for i, candidate := range candidates {
+		trace[i] = models.TraceCandidate{
+			Type:       candidate.item.Type,
+			ID:         candidate.item.ID,
+			SessionID:  candidate.item.SessionID,
+			Role:       candidate.item.Role,
+			Similarity: candidate.item.Similarity,
+			Score:      scores[candidate.item.ID],
+			Outcome:    outcomes[candidate.item.ID],
+			CreatedAt:  candidate.item.CreatedAt,
+		}
+	}
+
+	return items, trace
+}
+
+// recencyFactor is the weight of a memory of the given age: the recency weight share of the
+// score halves every half-life, the rest is independent of age
+func recencyFactor(age time.Duration, settings models.RetrievalSettings) float64 {
+	if settings.RecencyWeight <= 0 || settings.RecencyHalfLifeDays <= 0 {
+		return 1
+	}
+	if age < 0 {
+		age = 0
+	}
+	decay := math.Pow(0.5, age.Hours()/(settings.RecencyHalfLifeDays*24))
+	return 1 - settings.RecencyWeight + settings.RecencyWeight*decay
+}
+
+// normalizeContent lowercases text and collapses whitespace for duplicate comparisons
+func normalizeContent(content string) string {
+	return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
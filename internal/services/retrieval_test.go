@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+)
+
+func TestSelectContextMessagesFromMemoryStore(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := database.NewMemoryVectorStore()
+	record := func(id, sessionID, model, content string, embedding ...float32) database.VectorRecord {
+		return database.VectorRecord{
+			MessageID:        id,
+			SessionID:        sessionID,
+			UserID:           "u1",
+			Role:             "user",
+			Content:          content,
+			Model:            model,
+			Embedding:        embedding,
+			MessageCreatedAt: now.Add(-time.Hour),
+		}
+	}
+	err := store.Upsert(context.Background(),
+		record("repeat", "s1", "a", "bake sourdough  BREAD", 1, 0),
+		record("starter", "s1", "a", "Use a starter", 0.9, 0.1),
+		record("starter-copy", "s2", "a", "use a  starter", 0.9, 0.12),
+		record("proof", "s2", "a", "Proof it overnight", 0.7, 0.3),
+		record("oven", "s2", "a", "Bake at 250C", 0.6, 0.5),
+		record("unrelated", "s2", "a", "Unrelated", 0, 1),
+		record("other-session", "s3", "a", "Out of scope", 1, 0),
+		record("recent", "s1", "a", "Already in the prompt", 1, 0),
+		record("other-model", "s1", "b", "Another model", 1, 0),
+	)
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	settings := models.RetrievalSettings{
+		MaxResults:     2,
+		MinSimilarity:  0.5,
+		MMRLambda:      1,
+		DedupThreshold: 0.99,
+	}
+	matches, err := store.Search(context.Background(), []float32{1, 0}, database.VectorFilter{
+		Model:             "a",
+		SessionIDs:        []string{"s1", "s2"},
+		ExcludeMessageIDs: []string{"recent"},
+	}, settings.MaxResults*retrievalCandidateFactor, database.SearchTuning{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	items, trace := selectContextMessages(candidatesFromMatches(matches), "Bake sourdough bread", settings, now)
+
+	var selected []string
+	for _, item := range items {
+		selected = append(selected, item.ID)
+	}
+	if want := []string{"starter", "proof"}; !reflect.DeepEqual(selected, want) {
+		t.Errorf("selected %v, want %v", selected, want)
+	}
+	if len(items) > 0 && items[0].Duplicates != 1 {
+		t.Errorf("starter collapsed %d duplicates, want 1", items[0].Duplicates)
+	}
+
+	outcomes := make(map[string]string, len(trace))
+	for _, candidate := range trace {
+		outcomes[candidate.ID] = candidate.Outcome
+	}
+	want := map[string]string{
+		"repeat":       models.TraceOutcomeRepeatsQuery,
+		"starter":      models.TraceOutcomeSelected,
+		"starter-copy": models.TraceOutcomeDuplicate,
+		"proof":        models.TraceOutcomeSelected,
+		"oven":         models.TraceOutcomeNotSelected,
+		"unrelated":    models.TraceOutcomeBelowThreshold,
+	}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("trace outcomes %v, want %v", outcomes, want)
+	}
+}
+
+func TestRecencyFactor(t *testing.T) {
+	settings := models.RetrievalSettings{RecencyWeight: 0.4, RecencyHalfLifeDays: 10}
+	tests := []struct {
+		name     string
+		age      time.Duration
+		settings models.RetrievalSettings
+		want     float64
+	}{
+		{"no recency weight", 100 * 24 * time.Hour, models.RetrievalSettings{RecencyHalfLifeDays: 10}, 1},
+		{"new", 0, settings, 1},
+		{"from the future", -time.Hour, settings, 1},
+		{"one half-life", 10 * 24 * time.Hour, settings, 0.8},
+		{"two half-lives", 20 * 24 * time.Hour, settings, 0.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recencyFactor(tt.age, tt.settings); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("recencyFactor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// RetrievalTraceService records and serves how the memory of each chat turn was selected
+type RetrievalTraceService struct {
+	db     database.Database
+	logger *utils.Logger
+}
+
+// NewRetrievalTraceService creates a new retrieval trace service
+func NewRetrievalTraceService(db database.Database, logger *utils.Logger) *RetrievalTraceService {
+	return &RetrievalTraceService{
+		db:     db,
+		logger: logger.WithComponent("retrieval_trace"),
+	}
+}
+
+// SaveTrace stores the retrieval trace of a chat turn against its assistant message
+func (s *RetrievalTraceService) SaveTrace(ctx context.Context, messageID, userMessageID, sessionID, query string, retrieved *RetrievedContext) error {
+	settings, err := json.Marshal(retrieved.Settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode retrieval settings: %w", err)
+	}
+	candidates, err := json.Marshal(nonNilSlice(retrieved.Candidates))
+	if err != nil {
+		return fmt.Errorf("failed to encode trace candidates: %w", err)
+	}
+	selected, err := json.Marshal(nonNilSlice(retrieved.Items))
+	if err != nil {
+		return fmt.Errorf("failed to encode selected context: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO retrieval_traces (id, message_id, user_message_id, session_id, query, embedding_model,
+			settings, candidates, selected, injected_context)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (message_id) DO NOTHING
+	`, uuid.New().String(), messageID, nullableString(userMessageID), sessionID, query, retrieved.Model,
+		settings, candidates, selected, retrieved.Text)
+	if err != nil {
+		return fmt.Errorf("failed to store retrieval trace: %w", err)
+	}
+	return nil
+}
+
+// GetTrace returns the retrieval trace of a chat turn by its assistant or user message
+func (s *RetrievalTraceService) GetTrace(ctx context.Context, messageID, userID string) (*models.RetrievalTrace, error) {
+	var trace models.RetrievalTrace
+	var userMessageID, ownerID sql.NullString
+	var settings, candidates, selected []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT rt.id, rt.message_id, rt.user_message_id, rt.session_id, rt.query, rt.embedding_model,
+			   rt.settings, rt.candidates, rt.selected, rt.injected_context, rt.created_at, s.user_id
+		FROM retrieval_traces rt
+		JOIN sessions s ON rt.session_id = s.id
+		WHERE rt.message_id = $1 OR rt.user_message_id = $1
+		LIMIT 1
+	`, messageID).Scan(
+		&trace.ID, &trace.MessageID, &userMessageID, &trace.SessionID, &trace.Query, &trace.EmbeddingModel,
+		&settings, &candidates, &selected, &trace.InjectedContext, &trace.CreatedAt, &ownerID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("trace not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retrieval trace: %w", err)
+	}
+	if ownerID.String != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+	trace.UserMessageID = userMessageID.String
+
+	if err := json.Unmarshal(settings, &trace.Settings); err != nil {
+		return nil, fmt.Errorf("failed to decode retrieval settings: %w", err)
+	}
+	if err := json.Unmarshal(candidates, &trace.Candidates); err != nil {
+		return nil, fmt.Errorf("failed to decode trace candidates: %w", err)
+	}
+	if err := json.Unmarshal(selected, &trace.Selected); err != nil {
+		return nil, fmt.Errorf("failed to decode selected context: %w", err)
+	}
+
+	return &trace, nil
+}
+
+// nonNilSlice makes empty slices encode as [] rather than null
+func nonNilSlice[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
@@ -3,8 +3,8 @@ package services
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"chat_ollama/internal/database"
@@ -17,10 +17,15 @@ import (
 
 // SemanticMemoryService handles semantic memory operations
 type SemanticMemoryService struct {
-	db               database.Database
-	embeddingService *EmbeddingService
-	logger           *utils.Logger
-	defaultModel     string
+	db                 database.Database
+	vectors            database.VectorStore
+	embeddingService   *EmbeddingService
+	logger             *utils.Logger
+	defaultModel       string
+	registry           *EmbeddingRegistry
+	jobs               *JobQueue
+	gapThreshold       time.Duration
+	minTopicSimilarity float64
 }
 
 // MemorySearchResult represents a semantic search result
@@ -46,6 +51,7 @@ type MemorySummary struct {
 	EndTime        time.Time `json:"end_time,omitempty"`
 	MessageCount   int       `json:"message_count"`
 	CreatedAt      time.Time `json:"created_at"`
+	Similarity     float64   `json:"similarity,omitempty"`
 }
 
 // MemoryGap represents a detected memory gap
@@ -57,6 +63,7 @@ type MemoryGap struct {
 	ContextSummary string    `json:"context_summary,omitempty"`
 	BridgeContent  string    `json:"bridge_content,omitempty"`
 	GapType        string    `json:"gap_type"`
+	Similarity     float64   `json:"similarity,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
 }
 
@@ -64,9 +71,12 @@ type MemoryGap struct {
 func NewSemanticMemoryService(db database.Database, embeddingService *EmbeddingService, logger *utils.Logger) *SemanticMemoryService {
 	return &SemanticMemoryService{
 		db:               db,
+		vectors:          db.Vectors(),
 		embeddingService: embeddingService,
 		logger:           logger.WithComponent("semantic_memory"),
 		defaultModel:     "nomic-embed-text", // This will be overridden by config if needed
+		registry:         NewEmbeddingRegistry(db, embeddingService, "nomic-embed-text", logger),
+		gapThreshold:     1 * time.Hour,
 	}
 }
 
@@ -74,12 +84,25 @@ func NewSemanticMemoryService(db database.Database, embeddingService *EmbeddingS
 func NewSemanticMemoryServiceWithModel(db database.Database, embeddingService *EmbeddingService, logger *utils.Logger, embeddingModel string) *SemanticMemoryService {
 	return &SemanticMemoryService{
 		db:               db,
+		vectors:          db.Vectors(),
 		embeddingService: embeddingService,
 		logger:           logger.WithComponent("semantic_memory"),
 		defaultModel:     embeddingModel,
+		registry:         NewEmbeddingRegistry(db, embeddingService, embeddingModel, logger),
+		gapThreshold:     1 * time.Hour,
 	}
 }
 
+// SetGapDetection configures the pause that counts as a temporal gap and the
+// similarity between consecutive user turns below which a topical gap is recorded.
+// A minTopicSimilarity of zero disables topical gap detection.
+func (s *SemanticMemoryService) SetGapDetection(threshold time.Duration, minTopicSimilarity float64) {
+	if threshold > 0 {
+		s.gapThreshold = threshold
+	}
+	s.minTopicSimilarity = minTopicSimilarity
+}
+
 // StoreMessageEmbedding generates and stores an embedding for a message
 func (s *SemanticMemoryService) StoreMessageEmbedding(ctx context.Context, message models.Message) error {
 	// Log the incoming message for debugging with more detail
@@ -133,8 +156,9 @@ func (s *SemanticMemoryService) StoreMessageEmbedding(ctx context.Context, messa
 		return fmt.Errorf("sessionID variable is empty after assignment from message.SessionID")
 	}
 
-	// Generate embedding for the message content
-	embedding, err := s.embeddingService.GenerateEmbedding(ctx, message.Content, s.defaultModel)
+	// Generate embedding for the message content with the model the owner's memory is stored under
+	model := s.registry.ModelForSession(ctx, sessionID)
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, message.Content, model)
 	if err != nil {
 		return fmt.Errorf("failed to generate embedding: %w", err)
 	}
@@ -147,130 +171,116 @@ func (s *SemanticMemoryService) StoreMessageEmbedding(ctx context.Context, messa
 		Int("embedding_dimensions", len(embedding)).
 		Msg("Generated embedding, storing in database")
 
-	// Store embedding in database with denormalized message data
-	if pgDB, ok := s.db.(*database.PostgresDB); ok {
-		// Use the validated sessionID variable instead of message.SessionID
-		embeddingID := uuid.New().String()
-		
-		s.logger.Info().
+	// Store embedding with denormalized message data
+	embeddingID := uuid.New().String()
+	err = s.vectors.Upsert(ctx, database.VectorRecord{
+		ID:               embeddingID,
+		MessageID:        message.ID,
+		SessionID:        sessionID, // Use validated sessionID variable
+		UserID:           sessionOwner(ctx, s.db, sessionID),
+		Role:             message.Role,
+		Content:          message.Content,
+		Model:            model,
+		Embedding:        embedding,
+		MessageCreatedAt: message.CreatedAt,
+		CreatedAt:        time.Now(),
+	})
+	if err != nil {
+		s.logger.Error().
+			Err(err).
 			Str("embedding_id", embeddingID).
 			Str("message_id", message.ID).
 			Str("session_id", sessionID).
 			Str("role", message.Role).
-			Msg("Inserting embedding into database")
-		
-		err := pgDB.InsertVector(
-			"message_embeddings",
-			[]string{"id", "message_id", "session_id", "role", "content", "embedding", "model_used", "message_created_at", "created_at"},
-			[]interface{}{
-				embeddingID,
-				message.ID,
-				sessionID, // Use validated sessionID variable
-				message.Role,
-				message.Content,
-				pgvector.NewVector(embedding),
-				s.defaultModel,
-				message.CreatedAt,
-				time.Now(),
-			},
-		)
-		
-		if err != nil {
-			s.logger.Error().
-				Err(err).
-				Str("embedding_id", embeddingID).
-				Str("message_id", message.ID).
-				Str("session_id", sessionID).
-				Str("role", message.Role).
-				Msg("Failed to insert embedding into database")
-			return fmt.Errorf("failed to insert embedding into database: %w", err)
-		}
-		
-		s.logger.Info().
-			Str("embedding_id", embeddingID).
-			Str("message_id", message.ID).
-			Str("session_id", sessionID).
-			Msg("Successfully stored embedding in database")
-		
-		return nil
+			Msg("Failed to store embedding")
+		return fmt.Errorf("failed to store embedding: %w", err)
 	}
 
-	return fmt.Errorf("vector storage not supported for this database type")
-}
+	s.logger.Info().
+		Str("embedding_id", embeddingID).
+		Str("message_id", message.ID).
+		Str("session_id", sessionID).
+		Msg("Successfully stored embedding")
 
-// SearchSimilarMessages finds messages similar to the given query
-func (s *SemanticMemoryService) SearchSimilarMessages(ctx context.Context, query string, limit int, sessionID string) ([]MemorySearchResult, error) {
-	// Generate embedding for the query
-	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, s.defaultModel)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
-	}
+	return nil
+}
 
-	_, ok := s.db.(*database.PostgresDB)
-	if !ok {
-		return nil, fmt.Errorf("semantic search not supported for this database type")
+// sessionOwner returns the user a session belongs to, or "" if it has none
+func sessionOwner(ctx context.Context, db database.Database, sessionID string) string {
+	var userID sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = $1", sessionID).Scan(&userID); err != nil {
+		return ""
 	}
+	return userID.String
+}
 
-	// Build query with optional session filter - no need to join with messages table anymore
-	var sqlQuery string
-	var args []interface{}
-	
-	if sessionID != "" {
-		sqlQuery = `
-			SELECT message_id, session_id, content, role, message_created_at, model_used,
-				   (embedding <=> $1) as distance
-			FROM message_embeddings
-			WHERE session_id = $2
-			ORDER BY embedding <=> $1
-			LIMIT $3
-		`
-		args = []interface{}{pgvector.NewVector(queryEmbedding), sessionID, limit}
-	} else {
-		sqlQuery = `
-			SELECT message_id, session_id, content, role, message_created_at, model_used,
-				   (embedding <=> $1) as distance
-			FROM message_embeddings
-			ORDER BY embedding <=> $1
-			LIMIT $2
-		`
-		args = []interface{}{pgvector.NewVector(queryEmbedding), limit}
-	}
+// userSessionIDs returns the IDs of a user's sessions; never nil, so that as a vector
+// filter a user without sessions matches nothing
+func userSessionIDs(ctx context.Context, db database.Database, userID string) ([]string, error) {
+	return queryIDs(ctx, db, "SELECT id FROM sessions WHERE user_id = $1", userID)
+}
 
-	rows, err := s.db.Query(sqlQuery, args...)
+// queryIDs returns the single text column of a query's rows; never nil
+func queryIDs(ctx context.Context, db database.Database, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute similarity search: %w", err)
+		return nil, fmt.Errorf("failed to query IDs: %w", err)
 	}
 	defer rows.Close()
 
-	var results []MemorySearchResult
+	ids := []string{}
 	for rows.Next() {
-		var result MemorySearchResult
-		var distance float64
-		var model sql.NullString
-
-		err := rows.Scan(
-			&result.MessageID,
-			&result.SessionID,
-			&result.Content,
-			&result.Role,
-			&result.CreatedAt,
-			&model,
-			&distance,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan ID: %w", err)
 		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
 
-		if model.Valid {
-			result.Model = model.String
-		}
+// searchResults converts vector matches to memory search results
+func searchResults(matches []database.VectorMatch) []MemorySearchResult {
+	var results []MemorySearchResult
+	for _, match := range matches {
+		results = append(results, MemorySearchResult{
+			MessageID:  match.MessageID,
+			SessionID:  match.SessionID,
+			Content:    match.Content,
+			Role:       match.Role,
+			Similarity: match.Similarity,
+			CreatedAt:  match.MessageCreatedAt,
+			Model:      match.Model,
+		})
+	}
+	return results
+}
 
-		// Convert distance to similarity (1 - distance for cosine distance)
-		result.Similarity = 1.0 - distance
+// SearchSimilarMessages finds messages similar to the given query. Zero tuning values use the
+// configured index scan settings.
+func (s *SemanticMemoryService) SearchSimilarMessages(ctx context.Context, query string, limit int, sessionID string, tuning database.SearchTuning) ([]MemorySearchResult, error) {
+	// Generate embedding for the query, using the session owner's model when searching a single session
+	model := s.defaultModel
+	if sessionID != "" {
+		model = s.registry.ModelForSession(ctx, sessionID)
+	}
+	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
 
-		results = append(results, result)
+	// Only vectors of the query's model are comparable
+	filter := database.VectorFilter{Model: model}
+	if sessionID != "" {
+		filter.SessionIDs = []string{sessionID}
 	}
 
+	matches, err := s.vectors.Search(ctx, queryEmbedding, filter, limit, tuning)
+	if err != nil {
+		return nil, err
+	}
+	results := searchResults(matches)
+
 	s.logger.Info().
 		Str("query", query).
 		Int("results_count", len(results)).
@@ -282,79 +292,34 @@ func (s *SemanticMemoryService) SearchSimilarMessages(ctx context.Context, query
 
 // SearchSimilarMessagesByUser finds messages similar to the given query for a specific user
 func (s *SemanticMemoryService) SearchSimilarMessagesByUser(ctx context.Context, query string, limit int, userID string, sessionID string) ([]MemorySearchResult, error) {
-	// Generate embedding for the query
-	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, s.defaultModel)
+	// Generate embedding for the query with the model the user's memory is stored under
+	model := s.registry.ModelForUser(ctx, userID)
+	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, model)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
-	_, ok := s.db.(*database.PostgresDB)
-	if !ok {
-		return nil, fmt.Errorf("semantic search not supported for this database type")
+	// Search the user's sessions, or the given one if it is theirs
+	sessionIDs, err := userSessionIDs(ctx, s.db, userID)
+	if err != nil {
+		return nil, err
 	}
-
-	// Build query with user filter and optional session filter
-	var sqlQuery string
-	var args []interface{}
-	
 	if sessionID != "" {
-		sqlQuery = `
-			SELECT me.message_id, me.session_id, me.content, me.role, me.message_created_at, me.model_used,
-				   (me.embedding <=> $1) as distance
-			FROM message_embeddings me
-			JOIN sessions s ON me.session_id = s.id
-			WHERE s.user_id = $2 AND me.session_id = $3
-			ORDER BY me.embedding <=> $1
-			LIMIT $4
-		`
-		args = []interface{}{pgvector.NewVector(queryEmbedding), userID, sessionID, limit}
-	} else {
-		sqlQuery = `
-			SELECT me.message_id, me.session_id, me.content, me.role, me.message_created_at, me.model_used,
-				   (me.embedding <=> $1) as distance
-			FROM message_embeddings me
-			JOIN sessions s ON me.session_id = s.id
-			WHERE s.user_id = $2
-			ORDER BY me.embedding <=> $1
-			LIMIT $3
-		`
-		args = []interface{}{pgvector.NewVector(queryEmbedding), userID, limit}
+		owned := []string{}
+		for _, id := range sessionIDs {
+			if id == sessionID {
+				owned = append(owned, id)
+			}
+		}
+		sessionIDs = owned
 	}
 
-	rows, err := s.db.Query(sqlQuery, args...)
+	// Only vectors of the query's model are comparable
+	matches, err := s.vectors.Search(ctx, queryEmbedding, database.VectorFilter{Model: model, SessionIDs: sessionIDs}, limit, database.SearchTuning{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute similarity search: %w", err)
-	}
-	defer rows.Close()
-
-	var results []MemorySearchResult
-	for rows.Next() {
-		var result MemorySearchResult
-		var distance float64
-		var model sql.NullString
-
-		err := rows.Scan(
-			&result.MessageID,
-			&result.SessionID,
-			&result.Content,
-			&result.Role,
-			&result.CreatedAt,
-			&model,
-			&distance,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan search result: %w", err)
-		}
-
-		if model.Valid {
-			result.Model = model.String
-		}
-
-		// Convert distance to similarity (1 - distance for cosine distance)
-		result.Similarity = 1.0 - distance
-
-		results = append(results, result)
+		return nil, err
 	}
+	results := searchResults(matches)
 
 	s.logger.Info().
 		Str("query", query).
@@ -492,28 +457,15 @@ func (s *SemanticMemoryService) CreateMemorySummary(ctx context.Context, session
 	}
 
 	// Store summary in database
-	if pgDB, ok := s.db.(*database.PostgresDB); ok {
-		err = pgDB.InsertVector(
-			"memory_summaries",
-			[]string{"id", "session_id", "summary_type", "title", "content", "embedding", "start_time", "end_time", "message_count", "created_at"},
-			[]interface{}{
-				summary.ID,
-				summary.SessionID,
-				summary.SummaryType,
-				summary.Title,
-				summary.Content,
-				pgvector.NewVector(embedding),
-				summary.StartTime,
-				summary.EndTime,
-				summary.MessageCount,
-				summary.CreatedAt,
-			},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to store memory summary: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("memory summaries not supported for this database type")
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO memory_summaries (id, session_id, summary_type, title, content, embedding, embedding_model,
+			start_time, end_time, message_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, summary.ID, summary.SessionID, summary.SummaryType, summary.Title, summary.Content,
+		pgvector.NewVector(embedding), s.defaultModel, summary.StartTime, summary.EndTime,
+		summary.MessageCount, summary.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store memory summary: %w", err)
 	}
 
 	s.logger.Info().
@@ -528,7 +480,8 @@ func (s *SemanticMemoryService) CreateMemorySummary(ctx context.Context, session
 // CreateMemorySummaryWithUser creates a summary of a conversation or topic with user association
 func (s *SemanticMemoryService) CreateMemorySummaryWithUser(ctx context.Context, userID, sessionID, summaryType, title, content string, startTime, endTime time.Time, messageCount int) (*MemorySummary, error) {
 	// Generate embedding for the summary content
-	embedding, err := s.embeddingService.GenerateEmbedding(ctx, content, s.defaultModel)
+	model := s.registry.ModelForUser(ctx, userID)
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, content, model)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate summary embedding: %w", err)
 	}
@@ -546,29 +499,15 @@ func (s *SemanticMemoryService) CreateMemorySummaryWithUser(ctx context.Context,
 	}
 
 	// Store summary in database with user_id
-	if pgDB, ok := s.db.(*database.PostgresDB); ok {
-		err = pgDB.InsertVector(
-			"memory_summaries",
-			[]string{"id", "user_id", "session_id", "summary_type", "title", "content", "embedding", "start_time", "end_time", "message_count", "created_at"},
-			[]interface{}{
-				summary.ID,
-				userID,
-				summary.SessionID,
-				summary.SummaryType,
-				summary.Title,
-				summary.Content,
-				pgvector.NewVector(embedding),
-				summary.StartTime,
-				summary.EndTime,
-				summary.MessageCount,
-				summary.CreatedAt,
-			},
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to store memory summary: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("memory summaries not supported for this database type")
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO memory_summaries (id, user_id, session_id, summary_type, title, content, embedding, embedding_model,
+			start_time, end_time, message_count, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, summary.ID, userID, summary.SessionID, summary.SummaryType, summary.Title, summary.Content,
+		pgvector.NewVector(embedding), model, summary.StartTime, summary.EndTime,
+		summary.MessageCount, summary.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store memory summary: %w", err)
 	}
 
 	s.logger.Info().
@@ -680,156 +619,582 @@ func (s *SemanticMemoryService) GetMemorySummaries(ctx context.Context, sessionI
 	return summaries, nil
 }
 
-// DetectMemoryGaps identifies gaps in conversation context
+// DetectMemoryGaps identifies gaps across a whole session and returns all gaps stored for it.
+// Detection is idempotent: gaps that were already recorded are not inserted again.
 func (s *SemanticMemoryService) DetectMemoryGaps(ctx context.Context, sessionID string, timeThreshold time.Duration) ([]MemoryGap, error) {
-	// Query messages in chronological order
-	query := `
-		SELECT id, created_at, content
-		FROM messages
-		WHERE session_id = $1
-		ORDER BY created_at ASC
+	if _, err := s.detectGapsSince(ctx, sessionID, timeThreshold, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return s.GetMemoryGaps(ctx, sessionID)
+}
+
+// detectGapsSince records temporal and topical gaps whose later message was created at or after since.
+// It returns the number of newly recorded gaps.
+func (s *SemanticMemoryService) detectGapsSince(ctx context.Context, sessionID string, timeThreshold time.Duration, since time.Time) (int, error) {
+	var gaps []MemoryGap
+
+	// Temporal gaps: long pauses between consecutive messages
+	temporalQuery := `
+		SELECT prev_created_at, created_at, prev_content, content
+		FROM (
+			SELECT created_at, content,
+				   LAG(created_at) OVER (ORDER BY created_at) AS prev_created_at,
+				   LAG(content) OVER (ORDER BY created_at) AS prev_content
+			FROM messages
+			WHERE session_id = $1 AND role <> 'system'
+		) pairs
+		WHERE prev_created_at IS NOT NULL
+		  AND created_at >= $2
+		  AND created_at - prev_created_at > $3 * INTERVAL '1 second'
 	`
 
-	rows, err := s.db.Query(query, sessionID)
+	rows, err := s.db.QueryContext(ctx, temporalQuery, sessionID, since, timeThreshold.Seconds())
 	if err != nil {
-		return nil, fmt.Errorf("failed to query messages for gap detection: %w", err)
+		return 0, fmt.Errorf("failed to query messages for gap detection: %w", err)
 	}
-	defer rows.Close()
+	for rows.Next() {
+		var gapStart, gapEnd time.Time
+		var contextBefore, contextAfter string
+		if err := rows.Scan(&gapStart, &gapEnd, &contextBefore, &contextAfter); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan message: %w", err)
+		}
+		gaps = append(gaps, MemoryGap{
+			ID:        uuid.New().String(),
+			SessionID: sessionID,
+			GapStart:  gapStart,
+			GapEnd:    gapEnd,
+			GapType:   "temporal",
+			ContextSummary: fmt.Sprintf("Gap of %v between: '%s' and '%s'",
+				gapEnd.Sub(gapStart).Round(time.Second), truncateText(contextBefore, 100), truncateText(contextAfter, 100)),
+			CreatedAt: time.Now(),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("error iterating messages for gap detection: %w", err)
+	}
+	rows.Close()
 
-	var messages []struct {
-		ID        string
-		CreatedAt time.Time
-		Content   string
+	// Topical gaps: consecutive user turns whose embeddings have drifted apart
+	if s.minTopicSimilarity > 0 {
+		topical, err := s.detectTopicalGaps(ctx, sessionID, since)
+		if err != nil {
+			return 0, err
+		}
+		gaps = append(gaps, topical...)
 	}
 
-	for rows.Next() {
-		var msg struct {
-			ID        string
-			CreatedAt time.Time
-			Content   string
+	// Store detected gaps, skipping ones that were already recorded
+	inserted := 0
+	for _, gap := range gaps {
+		var similarity sql.NullFloat64
+		if gap.GapType == "topical" {
+			similarity = sql.NullFloat64{Float64: gap.Similarity, Valid: true}
+		}
+
+		result, err := s.db.ExecContext(ctx, `
+			INSERT INTO memory_gaps (id, session_id, gap_start, gap_end, context_summary, gap_type, similarity, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (session_id, gap_start, gap_end, gap_type) DO NOTHING
+		`, gap.ID, gap.SessionID, gap.GapStart, gap.GapEnd, gap.ContextSummary, gap.GapType, similarity, gap.CreatedAt)
+		if err != nil {
+			s.logger.Error().Err(err).Str("gap_id", gap.ID).Msg("Failed to store memory gap")
+			continue
+		}
+		if rowsAffected, err := result.RowsAffected(); err == nil {
+			inserted += int(rowsAffected)
+		}
+	}
+
+	s.logger.Info().
+		Str("session_id", sessionID).
+		Int("gaps_detected", len(gaps)).
+		Int("gaps_recorded", inserted).
+		Msg("Memory gap detection completed")
+
+	return inserted, nil
+}
+
+// gapDetectionBatch is how many user turns topical gap detection reads at a time
+const gapDetectionBatch = 200
+
+// detectTopicalGaps finds the user turns created at or after since whose embedding drifted
+// away from the turn before. Only those turns and the last user turn before since are read.
+func (s *SemanticMemoryService) detectTopicalGaps(ctx context.Context, sessionID string, since time.Time) ([]MemoryGap, error) {
+	filter := database.VectorFilter{
+		Model:        s.registry.ModelForSession(ctx, sessionID),
+		SessionIDs:   []string{sessionID},
+		Roles:        []string{"user"},
+		CreatedAfter: since,
+	}
+	if !since.IsZero() {
+		// The first new turn is compared with the one before it
+		var previous sql.NullTime
+		err := s.db.QueryRowContext(ctx, `
+			SELECT MAX(created_at) FROM messages WHERE session_id = $1 AND role = 'user' AND created_at < $2
+		`, sessionID, since).Scan(&previous)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the turn before gap detection: %w", err)
 		}
-		if err := rows.Scan(&msg.ID, &msg.CreatedAt, &msg.Content); err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
+		if previous.Valid {
+			filter.CreatedAfter = previous.Time
 		}
-		messages = append(messages, msg)
 	}
 
 	var gaps []MemoryGap
+	var prev *database.VectorRecord
+	for {
+		turns, err := s.vectors.List(ctx, filter, gapDetectionBatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query embeddings for gap detection: %w", err)
+		}
+		for i := range turns {
+			turn := &turns[i]
+			if prev != nil && !turn.MessageCreatedAt.Before(since) {
+				if similarity := CosineSimilarity(prev.Embedding, turn.Embedding); similarity < s.minTopicSimilarity {
+					gaps = append(gaps, MemoryGap{
+						ID:         uuid.New().String(),
+						SessionID:  sessionID,
+						GapStart:   prev.MessageCreatedAt,
+						GapEnd:     turn.MessageCreatedAt,
+						GapType:    "topical",
+						Similarity: similarity,
+						ContextSummary: fmt.Sprintf("Topic shift (similarity %.2f) from: '%s' to '%s'",
+							similarity, truncateText(prev.Content, 100), truncateText(turn.Content, 100)),
+						CreatedAt: time.Now(),
+					})
+				}
+			}
+			prev = turn
+		}
+		if len(turns) < gapDetectionBatch {
+			return gaps, nil
+		}
 
-	// Detect temporal gaps
-	for i := 1; i < len(messages); i++ {
-		timeDiff := messages[i].CreatedAt.Sub(messages[i-1].CreatedAt)
-		if timeDiff > timeThreshold {
-			gap := MemoryGap{
-				ID:        uuid.New().String(),
-				SessionID: sessionID,
-				GapStart:  messages[i-1].CreatedAt,
-				GapEnd:    messages[i].CreatedAt,
-				GapType:   "temporal",
-				CreatedAt: time.Now(),
+		// Continue after the last turn read, skipping the turns read at its time
+		last := prev.MessageCreatedAt
+		if !last.Equal(filter.CreatedAfter) {
+			filter.ExcludeMessageIDs = nil
+		}
+		filter.CreatedAfter = last
+		for _, turn := range turns {
+			if turn.MessageCreatedAt.Equal(last) {
+				filter.ExcludeMessageIDs = append(filter.ExcludeMessageIDs, turn.MessageID)
 			}
+		}
+	}
+}
 
-			// Generate context summary for the gap
-			contextBefore := messages[i-1].Content
-			contextAfter := messages[i].Content
-			gap.ContextSummary = fmt.Sprintf("Gap of %v between: '%s' and '%s'",
-				timeDiff, truncateText(contextBefore, 100), truncateText(contextAfter, 100))
+// GetMemoryGaps retrieves the recorded gaps of a session in chronological order
+func (s *SemanticMemoryService) GetMemoryGaps(ctx context.Context, sessionID string) ([]MemoryGap, error) {
+	query := `
+		SELECT id, session_id, gap_start, gap_end, context_summary, bridge_content, gap_type, similarity, created_at
+		FROM memory_gaps
+		WHERE session_id = $1
+		ORDER BY gap_end ASC, gap_type ASC
+	`
 
-			gaps = append(gaps, gap)
+	rows, err := s.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memory gaps: %w", err)
+	}
+	defer rows.Close()
+
+	gaps := []MemoryGap{}
+	for rows.Next() {
+		var gap MemoryGap
+		var contextSummary, bridgeContent sql.NullString
+		var similarity sql.NullFloat64
+
+		if err := rows.Scan(&gap.ID, &gap.SessionID, &gap.GapStart, &gap.GapEnd, &contextSummary, &bridgeContent, &gap.GapType, &similarity, &gap.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan memory gap: %w", err)
 		}
+		gap.ContextSummary = contextSummary.String
+		gap.BridgeContent = bridgeContent.String
+		gap.Similarity = similarity.Float64
+
+		gaps = append(gaps, gap)
 	}
 
-	// Store detected gaps
-	for _, gap := range gaps {
-		_, err := s.db.Exec(`
-			INSERT INTO memory_gaps (id, session_id, gap_start, gap_end, context_summary, gap_type, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`, gap.ID, gap.SessionID, gap.GapStart, gap.GapEnd, gap.ContextSummary, gap.GapType, gap.CreatedAt)
-		
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating memory gaps: %w", err)
+	}
+
+	return gaps, nil
+}
+
+// SaveBridgeContent stores a resume recap on the temporal gap ending at gapEnd, recording the gap if needed
+func (s *SemanticMemoryService) SaveBridgeContent(ctx context.Context, sessionID string, gapStart, gapEnd time.Time, bridgeContent string) error {
+	query := `
+		INSERT INTO memory_gaps (id, session_id, gap_start, gap_end, context_summary, bridge_content, gap_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'temporal', $7)
+		ON CONFLICT (session_id, gap_start, gap_end, gap_type) DO UPDATE SET bridge_content = EXCLUDED.bridge_content
+	`
+
+	contextSummary := fmt.Sprintf("Session resumed after %v", gapEnd.Sub(gapStart).Round(time.Second))
+	if _, err := s.db.ExecContext(ctx, query, uuid.New().String(), sessionID, gapStart, gapEnd, contextSummary, bridgeContent, time.Now()); err != nil {
+		return fmt.Errorf("failed to store bridge content: %w", err)
+	}
+
+	return nil
+}
+
+// maxContextSummaries caps how many summaries are added to the retrieved context
+const maxContextSummaries = 2
+
+// SearchSimilarSummaries finds a user's memory summaries similar to the given query.
+// Summary embeddings live in memory_summaries rather than the vector store.
+func (s *SemanticMemoryService) SearchSimilarSummaries(ctx context.Context, query string, userID string, limit int) ([]MemorySummary, error) {
+	model := s.registry.ModelForUser(ctx, userID)
+	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	distance := fmt.Sprintf("%s <=> %s", vectorOf("ms.embedding", len(queryEmbedding)), vectorOf("$1", len(queryEmbedding)))
+	sqlQuery := `
+		SELECT ms.id, ms.session_id, ms.summary_type, ms.title, ms.content, ms.relevance_score,
+			   ms.start_time, ms.end_time, ms.message_count, ms.created_at,
+			   (` + distance + `) as distance
+		FROM memory_summaries ms
+		LEFT JOIN sessions s ON ms.session_id = s.id
+		WHERE ms.embedding IS NOT NULL AND ms.embedding_model = $2 AND (ms.user_id = $3 OR s.user_id = $3)
+		ORDER BY ` + distance + `
+		LIMIT $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, pgvector.NewVector(queryEmbedding), model, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute summary similarity search: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []MemorySummary
+	for rows.Next() {
+		var summary MemorySummary
+		var sessionIDPtr sql.NullString
+		var titlePtr sql.NullString
+		var startTimePtr sql.NullTime
+		var endTimePtr sql.NullTime
+		var distance float64
+
+		err := rows.Scan(
+			&summary.ID,
+			&sessionIDPtr,
+			&summary.SummaryType,
+			&titlePtr,
+			&summary.Content,
+			&summary.RelevanceScore,
+			&startTimePtr,
+			&endTimePtr,
+			&summary.MessageCount,
+			&summary.CreatedAt,
+			&distance,
+		)
 		if err != nil {
-			s.logger.Error().Err(err).Str("gap_id", gap.ID).Msg("Failed to store memory gap")
+			return nil, fmt.Errorf("failed to scan summary search result: %w", err)
+		}
+
+		// Handle nullable fields
+		if sessionIDPtr.Valid {
+			summary.SessionID = sessionIDPtr.String
+		}
+		if titlePtr.Valid {
+			summary.Title = titlePtr.String
+		}
+		if startTimePtr.Valid {
+			summary.StartTime = startTimePtr.Time
+		}
+		if endTimePtr.Valid {
+			summary.EndTime = endTimePtr.Time
 		}
+
+		// Convert distance to similarity (1 - distance for cosine distance)
+		summary.Similarity = 1.0 - distance
+
+		summaries = append(summaries, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating summary search results: %w", err)
 	}
 
 	s.logger.Info().
-		Str("session_id", sessionID).
-		Int("gaps_detected", len(gaps)).
-		Msg("Memory gap detection completed")
+		Str("user_id", userID).
+		Int("results_count", len(summaries)).
+		Msg("Summary semantic search completed")
 
-	return gaps, nil
+	return summaries, nil
+}
+
+// embedMessagePayload is the payload of an embed_message job
+type embedMessagePayload struct {
+	MessageID string `json:"message_id"`
+}
+
+// detectGapsPayload is the payload of a detect_gaps job
+type detectGapsPayload struct {
+	SessionID string    `json:"session_id"`
+	Since     time.Time `json:"since"`
+}
+
+// backfillPageSize is how many messages the backfill job embeds per query
+const backfillPageSize = 500
+
+// RegisterJobs registers the semantic memory job handlers on the queue
+func (s *SemanticMemoryService) RegisterJobs(queue *JobQueue) {
+	s.jobs = queue
+	queue.Register(JobTypeEmbedMessage, 2*time.Minute, s.handleEmbedMessage)
+	queue.Register(JobTypeDetectGaps, 2*time.Minute, s.handleDetectGaps)
+	queue.Register(JobTypeEmbeddingBackfill, 0, s.handleEmbeddingBackfill)
 }
 
-// GetRelevantContext retrieves relevant context for a query using semantic search
-func (s *SemanticMemoryService) GetRelevantContext(ctx context.Context, query string, sessionID string, maxResults int) (string, error) {
-	// Search for similar messages
-	results, err := s.SearchSimilarMessages(ctx, query, maxResults, sessionID)
+// EnqueueMessageEmbedding queues a message for embedding; gap detection follows once a
+// user message is embedded. Backfilled messages are queued with a lower priority.
+func EnqueueMessageEmbedding(ctx context.Context, queue *JobQueue, messageID string, priority int) error {
+	_, err := queue.Enqueue(ctx, JobTypeEmbedMessage, embedMessagePayload{MessageID: messageID}, JobOptions{
+		DedupeKey: JobTypeEmbedMessage + ":" + messageID,
+		Priority:  priority,
+	})
+	return err
+}
+
+// handleEmbedMessage stores the embedding of a message unless it already has one for the
+// owner's model, then queues gap detection for user messages
+func (s *SemanticMemoryService) handleEmbedMessage(ctx context.Context, raw json.RawMessage) error {
+	var payload embedMessagePayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Errorf("invalid embed message payload: %w", err)
+	}
+
+	var message models.Message
+	err := s.db.QueryRowContext(ctx,
+		"SELECT id, session_id, role, content, created_at FROM messages WHERE id = $1", payload.MessageID,
+	).Scan(&message.ID, &message.SessionID, &message.Role, &message.Content, &message.CreatedAt)
+	if err == sql.ErrNoRows {
+		// The message was deleted before it could be embedded
+		return nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to search similar messages: %w", err)
+		return fmt.Errorf("failed to load message: %w", err)
 	}
 
-	if len(results) == 0 {
-		return "", nil
+	existing, err := s.vectors.Count(ctx, database.VectorFilter{
+		Model:      s.registry.ModelForSession(ctx, message.SessionID),
+		MessageIDs: []string{message.ID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check for existing embedding: %w", err)
+	}
+
+	if existing == 0 {
+		if err := s.StoreMessageEmbedding(ctx, message); err != nil {
+			return err
+		}
 	}
 
-	// Build context from search results
-	var contextParts []string
-	for _, result := range results {
-		// Debug: Include ALL results regardless of similarity
-		contextParts = append(contextParts, fmt.Sprintf("[%s] %s", result.Role, result.Content))
+	// Topical gaps need the user turn's embedding, so detection runs after it is stored
+	if message.Role == "user" && s.jobs != nil {
+		_, err := s.jobs.Enqueue(ctx, JobTypeDetectGaps, detectGapsPayload{SessionID: message.SessionID, Since: message.CreatedAt}, JobOptions{
+			DedupeKey: JobTypeDetectGaps + ":" + message.SessionID,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	return strings.Join(contextParts, "\n"), nil
+	return nil
 }
 
-// ProcessMessageForSemanticMemory processes a new message for semantic memory features
-func (s *SemanticMemoryService) ProcessMessageForSemanticMemory(ctx context.Context, message models.Message) error {
-	// Store message embedding
-	if err := s.StoreMessageEmbedding(ctx, message); err != nil {
-		s.logger.Error().Err(err).Str("message_id", message.ID).Msg("Failed to store message embedding")
-		// Don't return error as this shouldn't block the main chat flow
+// handleDetectGaps records the memory gaps of a session ending at or after the given time
+func (s *SemanticMemoryService) handleDetectGaps(ctx context.Context, raw json.RawMessage) error {
+	var payload detectGapsPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Errorf("invalid detect gaps payload: %w", err)
+	}
+
+	inserted, err := s.detectGapsSince(ctx, payload.SessionID, s.gapThreshold, payload.Since)
+	if err != nil {
+		return err
+	}
+	if inserted > 0 {
+		s.logger.Info().Str("session_id", payload.SessionID).Int("gaps", inserted).Msg("Memory gaps detected")
 	}
+	return nil
+}
 
-	// Detect memory gaps (run asynchronously)
-	go func() {
-		gaps, err := s.DetectMemoryGaps(context.Background(), message.SessionID, 1*time.Hour)
+// backfillMessage is a message the backfill job embeds, with the model of its owner's memory
+type backfillMessage struct {
+	models.Message
+	userID string
+	model  string
+}
+
+// handleEmbeddingBackfill embeds every message that has no embedding for its owner's
+// model. Pages are embedded in batches; messages of a batch that fails are queued as
+// individual embedding jobs instead.
+func (s *SemanticMemoryService) handleEmbeddingBackfill(ctx context.Context, raw json.RawMessage) error {
+	if s.jobs == nil {
+		return fmt.Errorf("job queue not configured")
+	}
+
+	embedded, queued := 0, 0
+	lastID := ""
+	for {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT m.id, m.session_id, m.role, m.content, m.created_at, COALESCE(s.user_id, ''), COALESCE(u.embedding_model, $2)
+			FROM messages m
+			JOIN sessions s ON m.session_id = s.id
+			LEFT JOIN users u ON s.user_id = u.id
+			WHERE m.role IN ('user', 'assistant') AND m.content <> '' AND m.id > $1
+			ORDER BY m.id
+			LIMIT $3
+		`, lastID, s.defaultModel, backfillPageSize)
 		if err != nil {
-			s.logger.Error().Err(err).Str("session_id", message.SessionID).Msg("Failed to detect memory gaps")
-		} else if len(gaps) > 0 {
-			s.logger.Info().Str("session_id", message.SessionID).Int("gaps", len(gaps)).Msg("Memory gaps detected")
+			return fmt.Errorf("failed to query messages without embeddings: %w", err)
+		}
+
+		var page []backfillMessage
+		for rows.Next() {
+			var message backfillMessage
+			if err := rows.Scan(&message.ID, &message.SessionID, &message.Role, &message.Content, &message.CreatedAt, &message.userID, &message.model); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan message: %w", err)
+			}
+			page = append(page, message)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating messages without embeddings: %w", err)
+		}
+
+		byModel := make(map[string][]backfillMessage)
+		for _, message := range page {
+			byModel[message.model] = append(byModel[message.model], message)
 		}
-	}()
 
+		for model, messages := range byModel {
+			messages, err := s.withoutEmbeddings(ctx, model, messages)
+			if err != nil {
+				return err
+			}
+			if len(messages) == 0 {
+				continue
+			}
+
+			if err := s.storeEmbeddingBatch(ctx, model, messages); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				s.logger.Warn().Err(err).Str("model", model).Int("messages", len(messages)).
+					Msg("Batch embedding failed, queueing messages individually")
+				for _, message := range messages {
+					if err := EnqueueMessageEmbedding(ctx, s.jobs, message.ID, -1); err != nil {
+						return err
+					}
+				}
+				queued += len(messages)
+				continue
+			}
+			embedded += len(messages)
+		}
+
+		if len(page) < backfillPageSize {
+			break
+		}
+		lastID = page[len(page)-1].ID
+	}
+
+	s.logger.Info().Int("embedded", embedded).Int("queued", queued).Msg("Embedding backfill finished")
 	return nil
 }
 
+// storeEmbeddingBatch embeds messages of a single model in one batch and stores the vectors
+func (s *SemanticMemoryService) storeEmbeddingBatch(ctx context.Context, model string, messages []backfillMessage) error {
+	texts := make([]string, len(messages))
+	for i, message := range messages {
+		texts[i] = message.Content
+	}
+
+	embeddings, err := s.embeddingService.GenerateEmbeddingBatch(ctx, texts, model)
+	if err != nil {
+		return err
+	}
+
+	records := make([]database.VectorRecord, len(messages))
+	for i, message := range messages {
+		records[i] = database.VectorRecord{
+			MessageID:        message.ID,
+			SessionID:        message.SessionID,
+			UserID:           message.userID,
+			Role:             message.Role,
+			Content:          message.Content,
+			Model:            model,
+			Embedding:        embeddings[i],
+			MessageCreatedAt: message.CreatedAt,
+		}
+	}
+	return s.vectors.Upsert(ctx, records...)
+}
+
+// withoutEmbeddings returns the messages that have no vector for the model yet
+func (s *SemanticMemoryService) withoutEmbeddings(ctx context.Context, model string, messages []backfillMessage) ([]backfillMessage, error) {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	existing, err := s.vectors.List(ctx, database.VectorFilter{Model: model, MessageIDs: ids}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing embeddings: %w", err)
+	}
+
+	embedded := make(map[string]bool, len(existing))
+	for _, record := range existing {
+		embedded[record.MessageID] = true
+	}
+	var missing []backfillMessage
+	for _, message := range messages {
+		if !embedded[message.ID] {
+			missing = append(missing, message)
+		}
+	}
+	return missing, nil
+}
+
 // CleanupObsoleteEmbeddings removes embeddings that are no longer useful
 // This is a maintenance function that can be called periodically
 func (s *SemanticMemoryService) CleanupObsoleteEmbeddings(ctx context.Context, options CleanupOptions) (*CleanupResult, error) {
 	result := &CleanupResult{}
 	
-	// Build cleanup query based on options
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
+	// Build the vector filter based on options
+	var filter database.VectorFilter
+	conditions := 0
 	
 	if options.OlderThanDays > 0 {
-		conditions = append(conditions, fmt.Sprintf("message_created_at < NOW() - INTERVAL '%d days'", options.OlderThanDays))
+		filter.CreatedBefore = time.Now().AddDate(0, 0, -options.OlderThanDays)
+		conditions++
 	}
 	
 	if options.SessionID != "" {
-		conditions = append(conditions, fmt.Sprintf("session_id = $%d", argIndex))
-		args = append(args, options.SessionID)
-		argIndex++
+		filter.SessionIDs = []string{options.SessionID}
+		conditions++
 	}
 	
 	if len(options.ExcludeRoles) > 0 {
-		placeholders := make([]string, len(options.ExcludeRoles))
-		for i, role := range options.ExcludeRoles {
-			placeholders[i] = fmt.Sprintf("$%d", argIndex)
-			args = append(args, role)
-			argIndex++
+		filter.ExcludeRoles = options.ExcludeRoles
+		conditions++
+	}
+	
+	if options.Orphaned {
+		// Orphans are the embeddings of every session that no longer exists
+		liveSessions, err := queryIDs(ctx, s.db, "SELECT id FROM sessions")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list sessions: %w", err)
 		}
-		conditions = append(conditions, fmt.Sprintf("role NOT IN (%s)", strings.Join(placeholders, ",")))
+		filter.ExcludeSessionIDs = liveSessions
+		conditions++
 	}
 	
 	if options.MinSimilarityThreshold > 0 {
@@ -838,18 +1203,16 @@ func (s *SemanticMemoryService) CleanupObsoleteEmbeddings(ctx context.Context, o
 		s.logger.Warn().Msg("MinSimilarityThreshold cleanup not yet implemented")
 	}
 	
-	if len(conditions) == 0 {
+	if conditions == 0 {
 		return result, fmt.Errorf("no cleanup conditions specified - this would delete all embeddings")
 	}
 	
-	// Count embeddings to be deleted
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM message_embeddings WHERE %s", strings.Join(conditions, " AND "))
-	err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&result.EmbeddingsDeleted)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count embeddings for cleanup: %w", err)
-	}
-	
 	if options.DryRun {
+		count, err := s.vectors.Count(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count embeddings for cleanup: %w", err)
+		}
+		result.EmbeddingsDeleted = count
 		result.DryRun = true
 		s.logger.Info().
 			Int64("would_delete", result.EmbeddingsDeleted).
@@ -858,18 +1221,12 @@ func (s *SemanticMemoryService) CleanupObsoleteEmbeddings(ctx context.Context, o
 	}
 	
 	// Perform the actual deletion
-	deleteQuery := fmt.Sprintf("DELETE FROM message_embeddings WHERE %s", strings.Join(conditions, " AND "))
-	deleteResult, err := s.db.ExecContext(ctx, deleteQuery, args...)
+	deleted, err := s.vectors.Delete(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete obsolete embeddings: %w", err)
 	}
 	
-	actualDeleted, err := deleteResult.RowsAffected()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get deleted count: %w", err)
-	}
-	
-	result.EmbeddingsDeleted = actualDeleted
+	result.EmbeddingsDeleted = deleted
 	
 	s.logger.Info().
 		Int64("embeddings_deleted", result.EmbeddingsDeleted).
@@ -880,47 +1237,28 @@ func (s *SemanticMemoryService) CleanupObsoleteEmbeddings(ctx context.Context, o
 
 // GetEmbeddingStats returns statistics about stored embeddings
 func (s *SemanticMemoryService) GetEmbeddingStats(ctx context.Context) (*EmbeddingStats, error) {
-	stats := &EmbeddingStats{}
-	
-	// Total embeddings
-	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM message_embeddings").Scan(&stats.TotalEmbeddings)
+	vectorStats, err := s.vectors.Stats(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total embeddings count: %w", err)
+		return nil, fmt.Errorf("failed to get embedding statistics: %w", err)
 	}
 	
-	// Embeddings by role
-	roleQuery := `
-		SELECT role, COUNT(*)
-		FROM message_embeddings
-		GROUP BY role
-	`
-	rows, err := s.db.QueryContext(ctx, roleQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get embeddings by role: %w", err)
+	stats := &EmbeddingStats{
+		TotalEmbeddings: vectorStats.Total,
+		ByRole:          vectorStats.ByRole,
+		ByModel:         vectorStats.ByModel,
+		OldestEmbedding: vectorStats.Oldest,
+		NewestEmbedding: vectorStats.Newest,
+		UniqueSessions:  vectorStats.Sessions,
 	}
-	defer rows.Close()
 	
-	stats.ByRole = make(map[string]int64)
-	for rows.Next() {
-		var role string
-		var count int64
-		if err := rows.Scan(&role, &count); err != nil {
-			return nil, fmt.Errorf("failed to scan role stats: %w", err)
-		}
-		stats.ByRole[role] = count
-	}
-	
-	// Oldest and newest embeddings
-	err = s.db.QueryRowContext(ctx, "SELECT MIN(message_created_at), MAX(message_created_at) FROM message_embeddings").
-		Scan(&stats.OldestEmbedding, &stats.NewestEmbedding)
+	// Embeddings left behind by deleted sessions
+	liveSessions, err := queryIDs(ctx, s.db, "SELECT id FROM sessions")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get embedding date range: %w", err)
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
-	
-	// Unique sessions with embeddings
-	err = s.db.QueryRowContext(ctx, "SELECT COUNT(DISTINCT session_id) FROM message_embeddings").Scan(&stats.UniqueSessions)
+	stats.OrphanedEmbeddings, err = s.vectors.Count(ctx, database.VectorFilter{ExcludeSessionIDs: liveSessions})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get unique sessions count: %w", err)
+		return nil, fmt.Errorf("failed to get orphaned embeddings count: %w", err)
 	}
 	
 	return stats, nil
@@ -931,6 +1269,7 @@ type CleanupOptions struct {
 	OlderThanDays           int      // Delete embeddings older than this many days
 	SessionID               string   // Only clean embeddings from this session (empty = all sessions)
 	ExcludeRoles            []string // Don't delete embeddings with these roles
+	Orphaned                bool     // Only clean embeddings whose session has been deleted
 	MinSimilarityThreshold  float64  // Delete embeddings that haven't been similar to recent queries (not implemented yet)
 	DryRun                  bool     // If true, only count what would be deleted
 }
@@ -943,9 +1282,11 @@ type CleanupResult struct {
 
 // EmbeddingStats contains statistics about stored embeddings
 type EmbeddingStats struct {
-	TotalEmbeddings  int64            `json:"total_embeddings"`
-	ByRole           map[string]int64 `json:"by_role"`
-	OldestEmbedding  time.Time        `json:"oldest_embedding"`
-	NewestEmbedding  time.Time        `json:"newest_embedding"`
-	UniqueSessions   int64            `json:"unique_sessions"`
+	TotalEmbeddings    int64            `json:"total_embeddings"`
+	ByRole             map[string]int64 `json:"by_role"`
+	ByModel            map[string]int64 `json:"by_model"`
+	OldestEmbedding    *time.Time       `json:"oldest_embedding"`
+	NewestEmbedding    *time.Time       `json:"newest_embedding"`
+	UniqueSessions     int64            `json:"unique_sessions"`
+	OrphanedEmbeddings int64            `json:"orphaned_embeddings"` // Embeddings whose session has been deleted
 }
\ No newline at end of file
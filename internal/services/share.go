@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// ShareService handles read-only share links for sessions
+type ShareService struct {
+	db     database.Database
+	logger *utils.Logger
+}
+
+// NewShareService creates a new share service
+func NewShareService(db database.Database, logger *utils.Logger) *ShareService {
+	return &ShareService{
+		db:     db,
+		logger: logger.WithComponent("share_service"),
+	}
+}
+
+// CreateShare creates a new share link for a session owned by the user
+func (s *ShareService) CreateShare(ctx context.Context, sessionID, userID string, req models.CreateShareRequest) (*models.SessionShare, error) {
+	if err := s.verifySessionOwner(ctx, sessionID, userID); err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		duration, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid expiry duration")
+		}
+		expiry := time.Now().Add(duration)
+		expiresAt = &expiry
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+
+	share := &models.SessionShare{
+		ID:                   uuid.New().String(),
+		SessionID:            sessionID,
+		UserID:               userID,
+		Token:                token,
+		RedactSystemMessages: req.RedactSystemMessages,
+		ExpiresAt:            expiresAt,
+		CreatedAt:            time.Now(),
+	}
+
+	query := `
+		INSERT INTO session_shares (id, session_id, user_id, token, redact_system_messages, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err = s.db.ExecContext(ctx, query,
+		share.ID,
+		share.SessionID,
+		share.UserID,
+		share.Token,
+		share.RedactSystemMessages,
+		share.ExpiresAt,
+		share.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create share: %w", err)
+	}
+
+	s.logger.Info().
+		Str("share_id", share.ID).
+		Str("session_id", sessionID).
+		Str("user_id", userID).
+		Bool("redact_system_messages", share.RedactSystemMessages).
+		Msg("Session share created")
+
+	return share, nil
+}
+
+// ListShares retrieves all share links for a session owned by the user
+func (s *ShareService) ListShares(ctx context.Context, sessionID, userID string) ([]models.SessionShare, error) {
+	if err := s.verifySessionOwner(ctx, sessionID, userID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, session_id, user_id, token, redact_system_messages, expires_at,
+		       revoked_at, view_count, last_viewed_at, created_at
+		FROM session_shares
+		WHERE session_id = $1 AND user_id = $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []models.SessionShare
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shares: %w", err)
+	}
+
+	return shares, nil
+}
+
+// RevokeShare revokes a share link owned by the user
+func (s *ShareService) RevokeShare(ctx context.Context, sessionID, shareID, userID string) error {
+	query := `
+		UPDATE session_shares
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND session_id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := s.db.ExecContext(ctx, query, shareID, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("share not found")
+	}
+
+	s.logger.Info().
+		Str("share_id", shareID).
+		Str("session_id", sessionID).
+		Str("user_id", userID).
+		Msg("Session share revoked")
+
+	return nil
+}
+
+// GetSharedSnapshot returns the read-only snapshot behind a share token and records the view.
+// Only messages that existed when the link was created are included.
+func (s *ShareService) GetSharedSnapshot(ctx context.Context, token string) (*models.SharedSessionSnapshot, error) {
+	share, err := s.getActiveShare(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.loadSnapshot(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the view without failing the request
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE session_shares SET view_count = view_count + 1, last_viewed_at = CURRENT_TIMESTAMP WHERE id = $1",
+		share.ID,
+	); err != nil {
+		s.logger.Warn().Err(err).Str("share_id", share.ID).Msg("Failed to record share view")
+	}
+
+	return snapshot, nil
+}
+
+// loadSnapshot reads the title and the shared messages of an active share
+func (s *ShareService) loadSnapshot(ctx context.Context, share *models.SessionShare) (*models.SharedSessionSnapshot, error) {
+	var title string
+	if err := s.db.QueryRowContext(ctx, "SELECT title FROM sessions WHERE id = $1", share.SessionID).Scan(&title); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("share not found")
+		}
+		return nil, fmt.Errorf("failed to get shared session: %w", err)
+	}
+
+	query := `
+		SELECT id, role, content, model, tokens_used, created_at
+		FROM messages
+		WHERE session_id = $1 AND created_at <= $2 AND ($3 = FALSE OR role <> 'system')
+		ORDER BY created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, share.SessionID, share.CreatedAt, share.RedactSystemMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var msg models.Message
+		var model sql.NullString
+
+		if err := rows.Scan(&msg.ID, &msg.Role, &msg.Content, &model, &msg.TokensUsed, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shared message: %w", err)
+		}
+		if model.Valid {
+			msg.Model = model.String
+		}
+
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shared messages: %w", err)
+	}
+
+	return &models.SharedSessionSnapshot{
+		Token:     share.Token,
+		Title:     title,
+		SharedAt:  share.CreatedAt,
+		ExpiresAt: share.ExpiresAt,
+		Redacted:  share.RedactSystemMessages,
+		Messages:  messages,
+	}, nil
+}
+
+// ForkSharedSession copies the snapshot behind a share token into a new session owned by
+// the user. Forking does not count as a view of the share.
+func (s *ShareService) ForkSharedSession(ctx context.Context, token, userID string) (*models.Session, error) {
+	share, err := s.getActiveShare(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := s.loadSnapshot(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Title:        snapshot.Title,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		MessageCount: len(snapshot.Messages),
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, title, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, session.ID, session.UserID, session.Title, session.CreatedAt, session.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create forked session: %w", err)
+	}
+
+	for _, msg := range snapshot.Messages {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO messages (id, session_id, role, content, model, tokens_used, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, uuid.New().String(), session.ID, msg.Role, msg.Content, msg.Model, msg.TokensUsed, msg.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy message: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Info().
+		Str("session_id", session.ID).
+		Str("user_id", userID).
+		Int("message_count", len(snapshot.Messages)).
+		Msg("Shared session forked")
+
+	return session, nil
+}
+
+// getActiveShare looks up a share by token and checks it has not expired or been revoked
+func (s *ShareService) getActiveShare(ctx context.Context, token string) (*models.SessionShare, error) {
+	query := `
+		SELECT id, session_id, user_id, token, redact_system_messages, expires_at,
+		       revoked_at, view_count, last_viewed_at, created_at
+		FROM session_shares
+		WHERE token = $1
+	`
+
+	share, err := scanShare(s.db.QueryRowContext(ctx, query, token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("share not found")
+		}
+		return nil, err
+	}
+
+	if !share.IsActive(time.Now()) {
+		return nil, fmt.Errorf("share not found")
+	}
+
+	return share, nil
+}
+
+// verifySessionOwner checks that the session exists and belongs to the user
+func (s *ShareService) verifySessionOwner(ctx context.Context, sessionID, userID string) error {
+	var ownerID sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = $1", sessionID).Scan(&ownerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("session not found")
+		}
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if ownerID.String != userID {
+		return fmt.Errorf("access denied")
+	}
+
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanShare scans a session_shares row
+func scanShare(row rowScanner) (*models.SessionShare, error) {
+	var share models.SessionShare
+	var expiresAt, revokedAt, lastViewedAt sql.NullTime
+
+	err := row.Scan(
+		&share.ID,
+		&share.SessionID,
+		&share.UserID,
+		&share.Token,
+		&share.RedactSystemMessages,
+		&expiresAt,
+		&revokedAt,
+		&share.ViewCount,
+		&lastViewedAt,
+		&share.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan share: %w", err)
+	}
+
+	if expiresAt.Valid {
+		share.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		share.RevokedAt = &revokedAt.Time
+	}
+	if lastViewedAt.Valid {
+		share.LastViewedAt = &lastViewedAt.Time
+	}
+
+	return &share, nil
+}
+
+// generateShareToken generates an unguessable URL-safe token
+func generateShareToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
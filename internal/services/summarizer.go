@@ -0,0 +1,691 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+)
+
+// SummarizerService generates conversation, period and global memory summaries with an LLM
+type SummarizerService struct {
+	db               database.Database
+	ollamaClient     *OllamaClient
+	embeddingService *EmbeddingService
+	registry         *EmbeddingRegistry
+	modelManager     *ModelManager
+	jobs             *JobQueue
+	logger           *utils.Logger
+	config           *config.Config
+}
+
+// NewSummarizerService creates a new summarizer service
+func NewSummarizerService(db database.Database, ollamaClient *OllamaClient, embeddingService *EmbeddingService, cfg *config.Config, logger *utils.Logger) *SummarizerService {
+	return &SummarizerService{
+		db:               db,
+		ollamaClient:     ollamaClient,
+		embeddingService: embeddingService,
+		registry:         NewEmbeddingRegistry(db, embeddingService, cfg.EmbeddingModel, logger),
+		modelManager:     NewModelManager(db, ollamaClient, logger),
+		logger:           logger.WithComponent("summarizer"),
+		config:           cfg,
+	}
+}
+
+// Summary periods
+const (
+	SummaryPeriodDaily  = "daily"
+	SummaryPeriodWeekly = "weekly"
+)
+
+const (
+	// maxSummaryInputChars bounds the transcript sent to the summary model
+	maxSummaryInputChars = 12000
+	// maxSummaryMessageChars bounds a single message within a transcript
+	maxSummaryMessageChars = 800
+	// idleSessionBatchSize caps how many sessions are summarized per run
+	idleSessionBatchSize = 20
+)
+
+const conversationSummaryPrompt = `Summarize the following conversation between a user and an assistant.
+Capture the user's goals, the key facts, decisions and conclusions, and any open questions.
+Write 3 to 6 sentences of plain prose without headings or bullet points.
+%s
+Conversation:
+%s`
+
+const periodSummaryPrompt = `Summarize what the user worked on during this %s period, based on the material below.
+Group related activity, mention recurring themes, and note anything left unfinished.
+Write one short paragraph of plain prose without headings or bullet points.
+
+%s`
+
+const globalSummaryPrompt = `Maintain a profile of the user based on their conversations with an assistant.
+Describe their long-running interests, projects, preferences and expertise. Drop details that are
+only of passing relevance. Write one or two paragraphs of plain prose without headings or bullet points.
+
+Current profile:
+%s
+
+New activity:
+%s`
+
+// RegisterJobs registers the summarization job handler on the queue
+func (s *SummarizerService) RegisterJobs(queue *JobQueue) {
+	s.jobs = queue
+	queue.Register(JobTypeSummarize, 30*time.Minute, func(ctx context.Context, _ json.RawMessage) error {
+		return s.RunOnce(ctx)
+	})
+}
+
+// Name returns the worker name
+func (s *SummarizerService) Name() string {
+	return "summarizer"
+}
+
+// Run periodically queues a summarization run until ctx is cancelled
+func (s *SummarizerService) Run(ctx context.Context) {
+	if !s.config.EnableSummarization || s.jobs == nil {
+		s.logger.Info().Msg("Memory summarization disabled")
+		return
+	}
+
+	s.logger.Info().
+		Dur("interval", s.config.SummarizerInterval).
+		Dur("idle_timeout", s.config.SessionIdleTimeout).
+		Msg("Starting memory summarizer")
+
+	runPeriodically(ctx, s.config.SummarizerInterval, s.logger, func(ctx context.Context) error {
+		// Deduplicated, so several instances still only run it once
+		_, err := s.jobs.Enqueue(ctx, JobTypeSummarize, nil, JobOptions{DedupeKey: JobTypeSummarize})
+		return err
+	})
+}
+
+// RunOnce summarizes idle sessions, completed periods and refreshes global summaries
+func (s *SummarizerService) RunOnce(ctx context.Context) error {
+	if err := s.SummarizeIdleSessions(ctx); err != nil {
+		return fmt.Errorf("failed to summarize idle sessions: %w", err)
+	}
+	if err := s.SummarizePeriods(ctx, time.Now()); err != nil {
+		return fmt.Errorf("failed to summarize periods: %w", err)
+	}
+	if err := s.UpdateGlobalSummaries(ctx); err != nil {
+		return fmt.Errorf("failed to update global summaries: %w", err)
+	}
+	return nil
+}
+
+// SummarizeIdleSessions summarizes sessions that have gone idle since their last summary
+func (s *SummarizerService) SummarizeIdleSessions(ctx context.Context) error {
+	query := `
+		SELECT s.id
+		FROM sessions s
+		JOIN messages m ON m.session_id = s.id AND m.role <> 'system'
+		LEFT JOIN memory_summaries ms
+			ON ms.session_id = s.id AND ms.source = 'generated' AND ms.summary_type = 'conversation'
+		GROUP BY s.id, ms.end_time
+		HAVING MAX(m.created_at) < $1 AND (ms.end_time IS NULL OR ms.end_time < MAX(m.created_at))
+		ORDER BY MAX(m.created_at) ASC
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, time.Now().Add(-s.config.SessionIdleTimeout), idleSessionBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to query idle sessions: %w", err)
+	}
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan idle session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+
+	for _, sessionID := range sessionIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, err := s.SummarizeSession(ctx, sessionID); err != nil {
+			s.logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to summarize session")
+		}
+	}
+
+	return nil
+}
+
+// SummarizeSession generates or refreshes the conversation summary of a session.
+// An existing summary is extended with the messages added since it was written.
+func (s *SummarizerService) SummarizeSession(ctx context.Context, sessionID string) (*MemorySummary, error) {
+	var title string
+	var userID sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT title, user_id FROM sessions WHERE id = $1", sessionID).Scan(&title, &userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	var previousContent string
+	var previousStart, previousEnd sql.NullTime
+	err = s.db.QueryRowContext(ctx, `
+		SELECT content, start_time, end_time FROM memory_summaries
+		WHERE session_id = $1 AND source = 'generated' AND summary_type = 'conversation'
+	`, sessionID).Scan(&previousContent, &previousStart, &previousEnd)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get previous summary: %w", err)
+	}
+
+	var since time.Time
+	if previousEnd.Valid {
+		since = previousEnd.Time
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT role, content, created_at
+		FROM messages
+		WHERE session_id = $1 AND role <> 'system' AND created_at > $2
+		ORDER BY created_at ASC
+	`, sessionID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session messages: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	var startTime, endTime time.Time
+	for rows.Next() {
+		var role, content string
+		var createdAt time.Time
+		if err := rows.Scan(&role, &content, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		if startTime.IsZero() {
+			startTime = createdAt
+		}
+		endTime = createdAt
+		lines = append(lines, fmt.Sprintf("%s: %s", role, truncateText(content, maxSummaryMessageChars)))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messages: %w", err)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	if previousStart.Valid {
+		startTime = previousStart.Time
+	}
+
+	var messageCount int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM messages WHERE session_id = $1 AND role <> 'system'", sessionID,
+	).Scan(&messageCount); err != nil {
+		return nil, fmt.Errorf("failed to count session messages: %w", err)
+	}
+
+	previous := ""
+	if previousContent != "" {
+		previous = fmt.Sprintf("\nSummary of the earlier part of the conversation:\n%s\n", previousContent)
+	}
+
+	prompt := fmt.Sprintf(conversationSummaryPrompt, previous, joinWithinBudget(lines, maxSummaryInputChars))
+	content, model, err := s.generateSummary(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &MemorySummary{
+		ID:           uuid.New().String(),
+		SessionID:    sessionID,
+		SummaryType:  "conversation",
+		Title:        title,
+		Content:      content,
+		StartTime:    startTime,
+		EndTime:      endTime,
+		MessageCount: messageCount,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := s.upsertSummary(ctx, summary, userID.String, "", model); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("session_id", sessionID).
+		Int("message_count", messageCount).
+		Bool("incremental", previousContent != "").
+		Msg("Conversation summary generated")
+
+	return summary, nil
+}
+
+// SummarizePeriods generates the daily summary for the previous day and the weekly
+// summary for the previous week for every user with activity in those periods.
+// Older periods are not backfilled.
+func (s *SummarizerService) SummarizePeriods(ctx context.Context, now time.Time) error {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	if err := s.summarizeDailyPeriod(ctx, yesterday, today); err != nil {
+		return err
+	}
+
+	// Weeks start on Monday
+	weekday := (int(today.Weekday()) + 6) % 7
+	thisWeek := today.AddDate(0, 0, -weekday)
+	lastWeek := thisWeek.AddDate(0, 0, -7)
+
+	return s.summarizeWeeklyPeriod(ctx, lastWeek, thisWeek)
+}
+
+// summarizeDailyPeriod summarizes the messages of each user within [start, end)
+func (s *SummarizerService) summarizeDailyPeriod(ctx context.Context, start, end time.Time) error {
+	userIDs, err := s.usersWithoutPeriodSummary(ctx, `
+		SELECT DISTINCT s.user_id
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		WHERE m.created_at >= $1 AND m.created_at < $2 AND s.user_id IS NOT NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM memory_summaries ms
+			WHERE ms.user_id = s.user_id AND ms.source = 'generated' AND ms.summary_type = 'period'
+			  AND ms.period = $3 AND ms.start_time = $1
+		  )
+	`, start, end, SummaryPeriodDaily)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT s.title, m.role, m.content
+			FROM messages m
+			JOIN sessions s ON m.session_id = s.id
+			WHERE s.user_id = $1 AND m.created_at >= $2 AND m.created_at < $3 AND m.role <> 'system'
+			ORDER BY s.id, m.created_at ASC
+		`, userID, start, end)
+		if err != nil {
+			return fmt.Errorf("failed to query period messages: %w", err)
+		}
+
+		var lines []string
+		var currentTitle string
+		messageCount := 0
+		for rows.Next() {
+			var title, role, content string
+			if err := rows.Scan(&title, &role, &content); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan period message: %w", err)
+			}
+			if title != currentTitle || len(lines) == 0 {
+				lines = append(lines, fmt.Sprintf("\nConversation: %s", title))
+				currentTitle = title
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", role, truncateText(content, maxSummaryMessageChars)))
+			messageCount++
+		}
+		rows.Close()
+
+		if messageCount == 0 {
+			continue
+		}
+
+		s.storePeriodSummary(ctx, userID, SummaryPeriodDaily, start, end, messageCount, joinWithinBudget(lines, maxSummaryInputChars))
+	}
+
+	return nil
+}
+
+// summarizeWeeklyPeriod rolls the daily summaries of each user within [start, end) into a weekly summary
+func (s *SummarizerService) summarizeWeeklyPeriod(ctx context.Context, start, end time.Time) error {
+	userIDs, err := s.usersWithoutPeriodSummary(ctx, `
+		SELECT DISTINCT d.user_id
+		FROM memory_summaries d
+		WHERE d.source = 'generated' AND d.summary_type = 'period' AND d.period = 'daily'
+		  AND d.start_time >= $1 AND d.start_time < $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM memory_summaries ms
+			WHERE ms.user_id = d.user_id AND ms.source = 'generated' AND ms.summary_type = 'period'
+			  AND ms.period = $3 AND ms.start_time = $1
+		  )
+	`, start, end, SummaryPeriodWeekly)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		lines, messageCount, err := s.periodSummaryLines(ctx, userID, SummaryPeriodDaily, start, end)
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		s.storePeriodSummary(ctx, userID, SummaryPeriodWeekly, start, end, messageCount, joinWithinBudget(lines, maxSummaryInputChars))
+	}
+
+	return nil
+}
+
+// UpdateGlobalSummaries folds new daily summaries into each user's rolling global profile summary
+func (s *SummarizerService) UpdateGlobalSummaries(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.user_id, g.content, g.start_time, g.end_time, COALESCE(g.message_count, 0)
+		FROM memory_summaries d
+		LEFT JOIN memory_summaries g
+			ON g.user_id = d.user_id AND g.source = 'generated' AND g.summary_type = 'global'
+		WHERE d.source = 'generated' AND d.summary_type = 'period' AND d.period = 'daily'
+		  AND (g.end_time IS NULL OR d.end_time > g.end_time)
+		GROUP BY d.user_id, g.content, g.start_time, g.end_time, g.message_count
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query users for global summaries: %w", err)
+	}
+
+	type pendingGlobal struct {
+		userID       string
+		content      sql.NullString
+		startTime    sql.NullTime
+		endTime      sql.NullTime
+		messageCount int
+	}
+
+	var pending []pendingGlobal
+	for rows.Next() {
+		var p pendingGlobal
+		if err := rows.Scan(&p.userID, &p.content, &p.startTime, &p.endTime, &p.messageCount); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan global summary candidate: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+
+	for _, p := range pending {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		since := time.Time{}
+		if p.endTime.Valid {
+			since = p.endTime.Time
+		}
+
+		lines, newMessages, err := s.periodSummaryLines(ctx, p.userID, SummaryPeriodDaily, since, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		var lastEnd time.Time
+		if err := s.db.QueryRowContext(ctx, `
+			SELECT MAX(end_time) FROM memory_summaries
+			WHERE user_id = $1 AND source = 'generated' AND summary_type = 'period' AND period = 'daily'
+		`, p.userID).Scan(&lastEnd); err != nil {
+			return fmt.Errorf("failed to get latest daily summary: %w", err)
+		}
+
+		current := "(none yet)"
+		if p.content.Valid && p.content.String != "" {
+			current = p.content.String
+		}
+
+		content, model, err := s.generateSummary(ctx, fmt.Sprintf(globalSummaryPrompt, current, joinWithinBudget(lines, maxSummaryInputChars)))
+		if err != nil {
+			s.logger.Error().Err(err).Str("user_id", p.userID).Msg("Failed to generate global summary")
+			continue
+		}
+
+		startTime := since
+		if p.startTime.Valid {
+			startTime = p.startTime.Time
+		} else if err := s.db.QueryRowContext(ctx, `
+			SELECT MIN(start_time) FROM memory_summaries
+			WHERE user_id = $1 AND source = 'generated' AND summary_type = 'period' AND period = 'daily'
+		`, p.userID).Scan(&startTime); err != nil {
+			return fmt.Errorf("failed to get earliest daily summary: %w", err)
+		}
+
+		summary := &MemorySummary{
+			ID:           uuid.New().String(),
+			SummaryType:  "global",
+			Title:        "User profile",
+			Content:      content,
+			StartTime:    startTime,
+			EndTime:      lastEnd,
+			MessageCount: p.messageCount + newMessages,
+			CreatedAt:    time.Now(),
+		}
+
+		if err := s.upsertSummary(ctx, summary, p.userID, "", model); err != nil {
+			s.logger.Error().Err(err).Str("user_id", p.userID).Msg("Failed to store global summary")
+			continue
+		}
+
+		s.logger.Info().
+			Str("user_id", p.userID).
+			Int("message_count", summary.MessageCount).
+			Msg("Global summary updated")
+	}
+
+	return nil
+}
+
+// storePeriodSummary generates and stores a period summary, logging failures so one user does not block the rest
+func (s *SummarizerService) storePeriodSummary(ctx context.Context, userID, period string, start, end time.Time, messageCount int, material string) {
+	content, model, err := s.generateSummary(ctx, fmt.Sprintf(periodSummaryPrompt, period, material))
+	if err != nil {
+		s.logger.Error().Err(err).Str("user_id", userID).Str("period", period).Msg("Failed to generate period summary")
+		return
+	}
+
+	summary := &MemorySummary{
+		ID:           uuid.New().String(),
+		SummaryType:  "period",
+		Title:        fmt.Sprintf("%s summary for %s", strings.ToUpper(period[:1])+period[1:], start.Format("2006-01-02")),
+		Content:      content,
+		StartTime:    start,
+		EndTime:      end,
+		MessageCount: messageCount,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := s.upsertSummary(ctx, summary, userID, period, model); err != nil {
+		s.logger.Error().Err(err).Str("user_id", userID).Str("period", period).Msg("Failed to store period summary")
+		return
+	}
+
+	s.logger.Info().
+		Str("user_id", userID).
+		Str("period", period).
+		Time("start_time", start).
+		Int("message_count", messageCount).
+		Msg("Period summary generated")
+}
+
+// periodSummaryLines returns the period summaries of a user that start within [start, end)
+func (s *SummarizerService) periodSummaryLines(ctx context.Context, userID, period string, start, end time.Time) ([]string, int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT start_time, content, message_count
+		FROM memory_summaries
+		WHERE user_id = $1 AND source = 'generated' AND summary_type = 'period' AND period = $2
+		  AND start_time >= $3 AND start_time < $4
+		ORDER BY start_time ASC
+	`, userID, period, start, end)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query period summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	total := 0
+	for rows.Next() {
+		var startTime time.Time
+		var content string
+		var messageCount int
+		if err := rows.Scan(&startTime, &content, &messageCount); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan period summary: %w", err)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", startTime.Format("Mon 2006-01-02"), content))
+		total += messageCount
+	}
+
+	return lines, total, rows.Err()
+}
+
+// usersWithoutPeriodSummary runs a user lookup query and returns the matching user IDs
+func (s *SummarizerService) usersWithoutPeriodSummary(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users for period summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+// generateSummary runs a prompt against the summary model
+func (s *SummarizerService) generateSummary(ctx context.Context, prompt string) (string, string, error) {
+	model, err := s.summaryModel(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	resp, err := s.ollamaClient.Generate(ctx, OllamaGenerateRequest{
+		Model:   model,
+		Prompt:  prompt,
+		Options: map[string]interface{}{"temperature": 0.2},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate summary: %w", err)
+	}
+
+	content := strings.TrimSpace(resp.Response)
+	if content == "" {
+		return "", "", fmt.Errorf("summary model returned an empty summary")
+	}
+
+	return content, model, nil
+}
+
+// summaryModel returns the configured summary model, falling back to the default chat model
+func (s *SummarizerService) summaryModel(ctx context.Context) (string, error) {
+	if s.config.SummaryModel != "" {
+		return s.config.SummaryModel, nil
+	}
+
+	defaultModel, err := s.modelManager.GetDefaultModel(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no summary model configured and no default model available: %w", err)
+	}
+	return defaultModel.Name, nil
+}
+
+// summaryConflictTargets identifies the generated summary a new one replaces
+var summaryConflictTargets = map[string]string{
+	"conversation": "(session_id) WHERE source = 'generated' AND summary_type = 'conversation'",
+	"period":       "(user_id, period, start_time) WHERE source = 'generated' AND summary_type = 'period'",
+	"global":       "(user_id) WHERE source = 'generated' AND summary_type = 'global'",
+}
+
+// upsertSummary embeds a generated summary and stores it, replacing the previous version
+func (s *SummarizerService) upsertSummary(ctx context.Context, summary *MemorySummary, userID, period, model string) error {
+	conflictTarget, ok := summaryConflictTargets[summary.SummaryType]
+	if !ok {
+		return fmt.Errorf("unsupported summary type: %s", summary.SummaryType)
+	}
+
+	embeddingModel := s.registry.ModelForUser(ctx, userID)
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, summary.Content, embeddingModel)
+	if err != nil {
+		return fmt.Errorf("failed to generate summary embedding: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO memory_summaries (id, user_id, session_id, summary_type, title, content, embedding, embedding_model,
+		                              start_time, end_time, message_count, source, period, model_used, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 'generated', $12, $13, $14)
+		ON CONFLICT %s DO UPDATE SET
+			title = EXCLUDED.title,
+			content = EXCLUDED.content,
+			embedding = EXCLUDED.embedding,
+			embedding_model = EXCLUDED.embedding_model,
+			start_time = EXCLUDED.start_time,
+			end_time = EXCLUDED.end_time,
+			message_count = EXCLUDED.message_count,
+			model_used = EXCLUDED.model_used
+	`, conflictTarget)
+
+	_, err = s.db.ExecContext(ctx, query,
+		summary.ID,
+		sql.NullString{String: userID, Valid: userID != ""},
+		sql.NullString{String: summary.SessionID, Valid: summary.SessionID != ""},
+		summary.SummaryType,
+		summary.Title,
+		summary.Content,
+		pgvector.NewVector(embedding),
+		embeddingModel,
+		summary.StartTime,
+		summary.EndTime,
+		summary.MessageCount,
+		sql.NullString{String: period, Valid: period != ""},
+		model,
+		summary.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store summary: %w", err)
+	}
+
+	return nil
+}
+
+// joinWithinBudget joins lines, keeping the most recent ones that fit in maxChars
+func joinWithinBudget(lines []string, maxChars int) string {
+	total := 0
+	start := len(lines)
+	for start > 0 {
+		next := len(lines[start-1]) + 1
+		if total+next > maxChars {
+			break
+		}
+		total += next
+		start--
+	}
+	return strings.Join(lines[start:], "\n")
+}
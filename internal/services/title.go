@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+)
+
+// TitleService generates session titles and follow-up suggestions with an LLM
+type TitleService struct {
+	db           database.Database
+	ollamaClient *OllamaClient
+	logger       *utils.Logger
+	config       *config.Config
+}
+
+// NewTitleService creates a new title service
+func NewTitleService(db database.Database, ollamaClient *OllamaClient, cfg *config.Config, logger *utils.Logger) *TitleService {
+	return &TitleService{
+		db:           db,
+		ollamaClient: ollamaClient,
+		logger:       logger.WithComponent("title_service"),
+		config:       cfg,
+	}
+}
+
+const (
+	// titleTranscriptMessages is how many recent messages are shown to the title model
+	titleTranscriptMessages = 6
+	// maxTitleLength caps generated titles so they fit in the sidebar
+	maxTitleLength = 60
+	// maxSuggestions caps the number of follow-up questions stored per session
+	maxSuggestions = 3
+)
+
+const sessionMetadataPrompt = `You are labelling a chat conversation between a user and an assistant.
+
+Conversation:
+%s
+
+Respond with a JSON object with two fields:
+- "title": a concise title for the conversation, at most 6 words, no quotes or trailing punctuation
+- "suggestions": an array of 2 or 3 short follow-up questions the user might ask next, written from the user's point of view`
+
+// GenerateSessionMetadata asks the title model for a session title and follow-up suggestions.
+// The title is only replaced when force is set or it has not been generated before.
+func (s *TitleService) GenerateSessionMetadata(ctx context.Context, sessionID, chatModel string, force bool) (*models.SessionMetadata, error) {
+	var currentTitle, titleSource string
+	err := s.db.QueryRowContext(ctx, "SELECT title, title_source FROM sessions WHERE id = $1", sessionID).Scan(&currentTitle, &titleSource)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	metadata := &models.SessionMetadata{
+		SessionID:   sessionID,
+		Title:       currentTitle,
+		Suggestions: []string{},
+	}
+
+	wantTitle := force || titleSource != models.TitleSourceGenerated
+	wantSuggestions := s.config.EnableFollowUpSuggestions
+	if !wantTitle && !wantSuggestions {
+		return metadata, nil
+	}
+
+	transcript, err := s.buildTranscript(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if transcript == "" {
+		return metadata, nil
+	}
+
+	model := s.config.TitleModel
+	if model == "" {
+		model = chatModel
+	}
+	if model == "" {
+		return nil, fmt.Errorf("no title model configured")
+	}
+
+	resp, err := s.ollamaClient.Generate(ctx, OllamaGenerateRequest{
+		Model:   model,
+		Prompt:  fmt.Sprintf(sessionMetadataPrompt, transcript),
+		Format:  "json",
+		Options: map[string]interface{}{"temperature": 0.3},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session metadata: %w", err)
+	}
+
+	var generated struct {
+		Title       string   `json:"title"`
+		Suggestions []string `json:"suggestions"`
+	}
+	if err := json.Unmarshal([]byte(resp.Response), &generated); err != nil {
+		return nil, fmt.Errorf("failed to parse session metadata: %w", err)
+	}
+
+	if wantTitle {
+		if title := cleanGeneratedTitle(generated.Title); title != "" {
+			if err := s.setGeneratedTitle(ctx, sessionID, title); err != nil {
+				return nil, err
+			}
+			metadata.Title = title
+			metadata.TitleUpdated = title != currentTitle
+		}
+	}
+
+	if wantSuggestions {
+		metadata.Suggestions = cleanSuggestions(generated.Suggestions)
+		if err := s.setSuggestions(ctx, sessionID, metadata.Suggestions); err != nil {
+			return nil, err
+		}
+	}
+
+	s.logger.Info().
+		Str("session_id", sessionID).
+		Str("model", model).
+		Str("title", metadata.Title).
+		Bool("title_updated", metadata.TitleUpdated).
+		Int("suggestions", len(metadata.Suggestions)).
+		Msg("Session metadata generated")
+
+	return metadata, nil
+}
+
+// sessionMetadataPayload is the payload of a session metadata job
+type sessionMetadataPayload struct {
+	SessionID string `json:"session_id"`
+	Model     string `json:"model"`
+}
+
+// RegisterJobs registers the session metadata job handler on the queue
+func (s *TitleService) RegisterJobs(queue *JobQueue) {
+	queue.Register(JobTypeSessionMetadata, s.config.OllamaTimeout, func(ctx context.Context, raw json.RawMessage) error {
+		var payload sessionMetadataPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("invalid session metadata payload: %w", err)
+		}
+
+		_, err := s.GenerateSessionMetadata(ctx, payload.SessionID, payload.Model, false)
+		if err != nil && err.Error() == "session not found" {
+			// The session was deleted in the meantime; nothing left to do
+			return nil
+		}
+		return err
+	})
+}
+
+// GetSessionMetadata returns the stored title and follow-up suggestions of a session
+func (s *TitleService) GetSessionMetadata(ctx context.Context, sessionID string) (*models.SessionMetadata, error) {
+	var title string
+	var raw []byte
+	err := s.db.QueryRowContext(ctx, "SELECT title, suggestions FROM sessions WHERE id = $1", sessionID).Scan(&title, &raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session metadata: %w", err)
+	}
+
+	metadata := &models.SessionMetadata{
+		SessionID:   sessionID,
+		Title:       title,
+		Suggestions: []string{},
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &metadata.Suggestions); err != nil {
+			return nil, fmt.Errorf("failed to decode session suggestions: %w", err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// NeedsSessionMetadata reports whether a titling job has anything to generate for a
+// session: a title that was not generated yet, or follow-up suggestions
+func (s *TitleService) NeedsSessionMetadata(ctx context.Context, sessionID string) (bool, error) {
+	if s.config.EnableFollowUpSuggestions {
+		return true, nil
+	}
+
+	var titleSource string
+	err := s.db.QueryRowContext(ctx, "SELECT title_source FROM sessions WHERE id = $1", sessionID).Scan(&titleSource)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("session not found")
+		}
+		return false, fmt.Errorf("failed to get session: %w", err)
+	}
+	return titleSource != models.TitleSourceGenerated, nil
+}
+
+// GetSessionSuggestions returns the stored follow-up suggestions for a session
+func (s *TitleService) GetSessionSuggestions(ctx context.Context, sessionID string) ([]string, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(ctx, "SELECT suggestions FROM sessions WHERE id = $1", sessionID).Scan(&raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session suggestions: %w", err)
+	}
+
+	var suggestions []string
+	if err := json.Unmarshal(raw, &suggestions); err != nil {
+		return nil, fmt.Errorf("failed to decode session suggestions: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+// buildTranscript renders the most recent messages of a session for the title prompt
+func (s *TitleService) buildTranscript(ctx context.Context, sessionID string) (string, error) {
+	query := `
+		SELECT role, content FROM (
+			SELECT role, content, created_at
+			FROM messages
+			WHERE session_id = $1 AND role <> 'system'
+			ORDER BY created_at DESC
+			LIMIT $2
+		) recent
+		ORDER BY created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, sessionID, titleTranscriptMessages)
+	if err != nil {
+		return "", fmt.Errorf("failed to query messages for title: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var role, content string
+		if err := rows.Scan(&role, &content); err != nil {
+			return "", fmt.Errorf("failed to scan message: %w", err)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", role, truncateText(content, 1000)))
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating messages: %w", err)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// setGeneratedTitle stores an LLM-generated title for a session
+func (s *TitleService) setGeneratedTitle(ctx context.Context, sessionID, title string) error {
+	query := `UPDATE sessions SET title = $1, title_source = $2 WHERE id = $3`
+	if _, err := s.db.ExecContext(ctx, query, title, models.TitleSourceGenerated, sessionID); err != nil {
+		return fmt.Errorf("failed to update session title: %w", err)
+	}
+	return nil
+}
+
+// setSuggestions stores the follow-up suggestions for a session
+func (s *TitleService) setSuggestions(ctx context.Context, sessionID string, suggestions []string) error {
+	raw, err := json.Marshal(suggestions)
+	if err != nil {
+		return fmt.Errorf("failed to encode suggestions: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE sessions SET suggestions = $1 WHERE id = $2", string(raw), sessionID); err != nil {
+		return fmt.Errorf("failed to update session suggestions: %w", err)
+	}
+	return nil
+}
+
+// cleanGeneratedTitle strips quotes and punctuation the model tends to add
+func cleanGeneratedTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	title = strings.Trim(title, "\"'`*#.:; ")
+
+	// Counted in runes so a title is never cut inside a multi-byte character
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength-3])) + "..."
+	}
+
+	if utf8.RuneCountInString(title) < 3 {
+		return ""
+	}
+	return title
+}
+
+// cleanSuggestions trims, de-duplicates and caps follow-up suggestions
+func cleanSuggestions(suggestions []string) []string {
+	cleaned := []string{}
+	seen := make(map[string]bool)
+	for _, suggestion := range suggestions {
+		suggestion = strings.TrimSpace(strings.Trim(suggestion, "\"'-*• "))
+		key := strings.ToLower(suggestion)
+		if suggestion == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, suggestion)
+		if len(cleaned) == maxSuggestions {
+			break
+		}
+	}
+	return cleaned
+}
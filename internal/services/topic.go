@@ -0,0 +1,507 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+)
+
+// TopicService clusters message embeddings into per-user topics
+type TopicService struct {
+	db           database.Database
+	ollamaClient *OllamaClient
+	modelManager *ModelManager
+	registry     *EmbeddingRegistry
+	jobs         *JobQueue
+	logger       *utils.Logger
+	config       *config.Config
+}
+
+// NewTopicService creates a new topic service
+func NewTopicService(db database.Database, ollamaClient *OllamaClient, cfg *config.Config, logger *utils.Logger) *TopicService {
+	return &TopicService{
+		db:           db,
+		ollamaClient: ollamaClient,
+		modelManager: NewModelManager(db, ollamaClient, logger),
+		registry:     NewEmbeddingRegistry(db, NewEmbeddingService(db, cfg, logger), cfg.EmbeddingModel, logger),
+		logger:       logger.WithComponent("topic_service"),
+		config:       cfg,
+	}
+}
+
+const (
+	// topicBatchSize caps how many new embeddings are clustered per user per run
+	topicBatchSize = 500
+	// topicLabelSamples is how many representative messages are shown to the label model
+	topicLabelSamples = 8
+	// untitledTopicName is used until a topic has been labelled
+	untitledTopicName = "Untitled topic"
+)
+
+const topicLabelPrompt = `The following messages were grouped together because they are about the same subject.
+
+%s
+
+Respond with a JSON object with two fields:
+- "name": a short name for the subject, 2 to 4 words, title case, no quotes or trailing punctuation
+- "description": one sentence describing what the messages have in common`
+
+// topicCentroid is the in-memory state of a topic while clustering
+type topicCentroid struct {
+	id           string
+	centroid     []float32
+	messageCount int
+	isNew        bool
+	dirty        bool
+}
+
+// topicAssignment links a message to the topic it was clustered into
+type topicAssignment struct {
+	messageID string
+	topic     *topicCentroid
+	relevance float64
+}
+
+// RegisterJobs registers the topic clustering job handler on the queue
+func (s *TopicService) RegisterJobs(queue *JobQueue) {
+	s.jobs = queue
+	queue.Register(JobTypeClusterTopics, 30*time.Minute, func(ctx context.Context, _ json.RawMessage) error {
+		return s.RunOnce(ctx)
+	})
+}
+
+// Name returns the worker name
+func (s *TopicService) Name() string {
+	return "topic_clustering"
+}
+
+// Run periodically queues a clustering run for new embeddings until ctx is cancelled
+func (s *TopicService) Run(ctx context.Context) {
+	if !s.config.EnableTopicClustering || !s.config.EnableSemanticMemory || s.jobs == nil {
+		s.logger.Info().Msg("Topic clustering disabled")
+		return
+	}
+
+	s.logger.Info().
+		Dur("interval", s.config.TopicClusteringInterval).
+		Float64("similarity_threshold", s.config.TopicSimilarityThreshold).
+		Int("max_topics_per_user", s.config.MaxTopicsPerUser).
+		Msg("Starting topic clustering")
+
+	runPeriodically(ctx, s.config.TopicClusteringInterval, s.logger, func(ctx context.Context) error {
+		// Deduplicated, so several instances still only run it once
+		_, err := s.jobs.Enqueue(ctx, JobTypeClusterTopics, nil, JobOptions{DedupeKey: JobTypeClusterTopics})
+		return err
+	})
+}
+
+// RunOnce clusters unassigned embeddings of every user and refreshes stale topic labels
+func (s *TopicService) RunOnce(ctx context.Context) error {
+	// Unclustered vectors live in the vector store, so every user with sessions is checked
+	userIDs, err := queryIDs(ctx, s.db, "SELECT DISTINCT user_id FROM sessions WHERE user_id IS NOT NULL")
+	if err != nil {
+		return fmt.Errorf("failed to query users with sessions: %w", err)
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := s.ClusterUserMessages(ctx, userID); err != nil {
+			s.logger.Error().Err(err).Str("user_id", userID).Msg("Failed to cluster user messages")
+		}
+	}
+
+	return s.LabelTopics(ctx)
+}
+
+// ClusterUserMessages assigns a user's unclustered message embeddings to topics using
+// online k-means: each embedding joins the nearest topic when it is similar enough,
+// otherwise it seeds a new topic until the per-user topic limit is reached.
+func (s *TopicService) ClusterUserMessages(ctx context.Context, userID string) error {
+	// Topics live in the embedding space of the user's current model
+	embeddingModel := s.registry.ModelForUser(ctx, userID)
+
+	topics, err := s.loadCentroids(ctx, userID, embeddingModel)
+	if err != nil {
+		return err
+	}
+
+	sessionIDs, err := userSessionIDs(ctx, s.db, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	pending, err := s.db.Vectors().List(ctx, database.VectorFilter{
+		Model:       embeddingModel,
+		SessionIDs:  sessionIDs,
+		Unclustered: true,
+	}, topicBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to query unclustered embeddings: %w", err)
+	}
+
+	var assignments []topicAssignment
+	for _, record := range pending {
+		messageID, vector := record.MessageID, record.Embedding
+		nearest, similarity := nearestCentroid(topics, vector)
+
+		if nearest == nil || (similarity < s.config.TopicSimilarityThreshold && len(topics) < s.config.MaxTopicsPerUser) {
+			nearest = &topicCentroid{
+				id:       uuid.New().String(),
+				centroid: append([]float32(nil), vector...),
+				isNew:    true,
+			}
+			topics = append(topics, nearest)
+			similarity = 1.0
+		} else {
+			// Move the centroid towards the new member
+			n := float32(nearest.messageCount + 1)
+			for i := range nearest.centroid {
+				nearest.centroid[i] += (vector[i] - nearest.centroid[i]) / n
+			}
+		}
+
+		nearest.messageCount++
+		nearest.dirty = true
+		assignments = append(assignments, topicAssignment{messageID: messageID, topic: nearest, relevance: similarity})
+	}
+
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	if err := s.saveClusters(ctx, userID, embeddingModel, topics, assignments); err != nil {
+		return err
+	}
+
+	s.logger.Info().
+		Str("user_id", userID).
+		Int("messages_clustered", len(assignments)).
+		Int("topic_count", len(topics)).
+		Msg("User messages clustered into topics")
+
+	return nil
+}
+
+// loadCentroids loads a user's topics for an embedding model and their centroids
+func (s *TopicService) loadCentroids(ctx context.Context, userID, embeddingModel string) ([]*topicCentroid, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, embedding, message_count
+		FROM semantic_topics
+		WHERE user_id = $1 AND embedding_model = $2 AND embedding IS NOT NULL
+	`, userID, embeddingModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query topics: %w", err)
+	}
+	defer rows.Close()
+
+	var topics []*topicCentroid
+	for rows.Next() {
+		var topic topicCentroid
+		var embedding pgvector.Vector
+		if err := rows.Scan(&topic.id, &embedding, &topic.messageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan topic: %w", err)
+		}
+		topic.centroid = embedding.Slice()
+		topics = append(topics, &topic)
+	}
+
+	return topics, rows.Err()
+}
+
+// saveClusters persists new and updated topics together with the message assignments
+func (s *TopicService) saveClusters(ctx context.Context, userID, embeddingModel string, topics []*topicCentroid, assignments []topicAssignment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, topic := range topics {
+		if !topic.dirty {
+			continue
+		}
+
+		if topic.isNew {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO semantic_topics (id, user_id, name, embedding, embedding_model, message_count, needs_label)
+				VALUES ($1, $2, $3, $4, $5, $6, TRUE)
+			`, topic.id, userID, untitledTopicName, pgvector.NewVector(topic.centroid), embeddingModel, topic.messageCount)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE semantic_topics SET embedding = $1, message_count = $2 WHERE id = $3
+			`, pgvector.NewVector(topic.centroid), topic.messageCount, topic.id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save topic: %w", err)
+		}
+	}
+
+	for _, assignment := range assignments {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO message_topics (message_id, topic_id, relevance_score)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (message_id, topic_id) DO UPDATE SET relevance_score = EXCLUDED.relevance_score
+		`, assignment.messageID, assignment.topic.id, assignment.relevance)
+		if err != nil {
+			return fmt.Errorf("failed to assign message to topic: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// LabelTopics generates names for new topics and refreshes labels of topics that have doubled in size
+func (s *TopicService) LabelTopics(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id FROM semantic_topics
+		WHERE user_id IS NOT NULL AND (needs_label OR message_count >= 2 * labeled_message_count)
+		ORDER BY message_count DESC
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query topics to label: %w", err)
+	}
+
+	var topicIDs []string
+	for rows.Next() {
+		var topicID string
+		if err := rows.Scan(&topicID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan topic: %w", err)
+		}
+		topicIDs = append(topicIDs, topicID)
+	}
+	rows.Close()
+
+	for _, topicID := range topicIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := s.labelTopic(ctx, topicID); err != nil {
+			s.logger.Error().Err(err).Str("topic_id", topicID).Msg("Failed to label topic")
+		}
+	}
+
+	return nil
+}
+
+// labelTopic asks the label model to name a topic from its most representative messages
+func (s *TopicService) labelTopic(ctx context.Context, topicID string) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.role, m.content
+		FROM message_topics mt
+		JOIN messages m ON mt.message_id = m.id
+		WHERE mt.topic_id = $1
+		ORDER BY mt.relevance_score DESC
+		LIMIT $2
+	`, topicID, topicLabelSamples)
+	if err != nil {
+		return fmt.Errorf("failed to query topic samples: %w", err)
+	}
+
+	var samples []string
+	for rows.Next() {
+		var role, content string
+		if err := rows.Scan(&role, &content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan topic sample: %w", err)
+		}
+		samples = append(samples, fmt.Sprintf("- %s: %s", role, truncateText(content, 300)))
+	}
+	rows.Close()
+
+	if len(samples) == 0 {
+		return nil
+	}
+
+	model, err := s.labelModel(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.ollamaClient.Generate(ctx, OllamaGenerateRequest{
+		Model:   model,
+		Prompt:  fmt.Sprintf(topicLabelPrompt, strings.Join(samples, "\n")),
+		Format:  "json",
+		Options: map[string]interface{}{"temperature": 0.2},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate topic label: %w", err)
+	}
+
+	var label struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(resp.Response), &label); err != nil {
+		return fmt.Errorf("failed to parse topic label: %w", err)
+	}
+
+	name := cleanGeneratedTitle(label.Name)
+	if name == "" {
+		return fmt.Errorf("label model returned an empty topic name")
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE semantic_topics
+		SET name = $1, description = $2, needs_label = FALSE, labeled_message_count = message_count
+		WHERE id = $3
+	`, name, strings.TrimSpace(label.Description), topicID)
+	if err != nil {
+		return fmt.Errorf("failed to update topic label: %w", err)
+	}
+
+	s.logger.Info().
+		Str("topic_id", topicID).
+		Str("name", name).
+		Msg("Topic labelled")
+
+	return nil
+}
+
+// labelModel returns the configured title model, falling back to the default chat model
+func (s *TopicService) labelModel(ctx context.Context) (string, error) {
+	if s.config.TitleModel != "" {
+		return s.config.TitleModel, nil
+	}
+
+	defaultModel, err := s.modelManager.GetDefaultModel(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no title model configured and no default model available: %w", err)
+	}
+	return defaultModel.Name, nil
+}
+
+// GetTopicsByUser retrieves a user's topics, largest first
+func (s *TopicService) GetTopicsByUser(ctx context.Context, userID string) ([]models.Topic, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, name, description, message_count, created_at, updated_at
+		FROM semantic_topics
+		WHERE user_id = $1
+		ORDER BY message_count DESC, name ASC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query topics: %w", err)
+	}
+	defer rows.Close()
+
+	topics := []models.Topic{}
+	for rows.Next() {
+		topic, err := scanTopic(rows)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, *topic)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating topics: %w", err)
+	}
+
+	return topics, nil
+}
+
+// GetTopic retrieves a single topic owned by the user
+func (s *TopicService) GetTopic(ctx context.Context, topicID, userID string) (*models.Topic, error) {
+	topic, err := scanTopic(s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, name, description, message_count, created_at, updated_at
+		FROM semantic_topics
+		WHERE id = $1
+	`, topicID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("topic not found")
+		}
+		return nil, err
+	}
+
+	if topic.UserID != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	return topic, nil
+}
+
+// GetTopicMessages retrieves the messages assigned to a topic, most relevant first
+func (s *TopicService) GetTopicMessages(ctx context.Context, topicID string, limit, offset int) ([]models.TopicMessage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.session_id, s.title, m.role, m.content, mt.relevance_score, m.created_at
+		FROM message_topics mt
+		JOIN messages m ON mt.message_id = m.id
+		JOIN sessions s ON m.session_id = s.id
+		WHERE mt.topic_id = $1
+		ORDER BY mt.relevance_score DESC, m.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, topicID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query topic messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.TopicMessage{}
+	for rows.Next() {
+		var msg models.TopicMessage
+		if err := rows.Scan(&msg.MessageID, &msg.SessionID, &msg.SessionTitle, &msg.Role, &msg.Content, &msg.RelevanceScore, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan topic message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating topic messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// scanTopic scans a semantic_topics row
+func scanTopic(row rowScanner) (*models.Topic, error) {
+	var topic models.Topic
+	var userID, description sql.NullString
+	var messageCount sql.NullInt64
+	var createdAt, updatedAt sql.NullTime
+
+	if err := row.Scan(&topic.ID, &userID, &topic.Name, &description, &messageCount, &createdAt, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan topic: %w", err)
+	}
+
+	topic.UserID = userID.String
+	topic.Description = description.String
+	topic.MessageCount = int(messageCount.Int64)
+	topic.CreatedAt = createdAt.Time
+	topic.UpdatedAt = updatedAt.Time
+
+	return &topic, nil
+}
+
+// nearestCentroid returns the topic closest to the vector and its cosine similarity
+func nearestCentroid(topics []*topicCentroid, vector []float32) (*topicCentroid, float64) {
+	var nearest *topicCentroid
+	best := -1.0
+	for _, topic := range topics {
+		if similarity := CosineSimilarity(topic.centroid, vector); similarity > best {
+			nearest = topic
+			best = similarity
+		}
+	}
+	return nearest, best
+}
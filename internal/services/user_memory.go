@@ -0,0 +1,617 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+)
+
+// UserMemoryService manages explicit long-term facts about users
+type UserMemoryService struct {
+	db               database.Database
+	ollamaClient     *OllamaClient
+	embeddingService *EmbeddingService
+	registry         *EmbeddingRegistry
+	modelManager     *ModelManager
+	jobs             *JobQueue
+	logger           *utils.Logger
+	config           *config.Config
+}
+
+// NewUserMemoryService creates a new user memory service
+func NewUserMemoryService(db database.Database, ollamaClient *OllamaClient, embeddingService *EmbeddingService, cfg *config.Config, logger *utils.Logger) *UserMemoryService {
+	return &UserMemoryService{
+		db:               db,
+		ollamaClient:     ollamaClient,
+		embeddingService: embeddingService,
+		registry:         NewEmbeddingRegistry(db, embeddingService, cfg.EmbeddingModel, logger),
+		modelManager:     NewModelManager(db, ollamaClient, logger),
+		logger:           logger.WithComponent("user_memory_service"),
+		config:           cfg,
+	}
+}
+
+const (
+	// maxFactLength bounds the content of a single fact
+	maxFactLength = 500
+	// duplicateFactSimilarity is the similarity above which a proposal repeats a known fact
+	duplicateFactSimilarity = 0.9
+	// factSessionBatchSize caps how many sessions are scanned for facts per run
+	factSessionBatchSize = 20
+)
+
+const factExtractionPrompt = `Below are messages a user wrote to an assistant. Identify durable facts about the user
+that would help in future conversations: preferences, expertise, projects they work on, their role,
+tools and languages they use. Ignore one-off questions, opinions about the current task and anything
+about the assistant. Write each fact as a short third-person statement, e.g. "Prefers Go examples".
+%s
+Messages:
+%s
+
+Respond with a JSON object with a "facts" array. Each item has:
+- "content": the fact
+- "category": one of "preference", "expertise", "project", "background", "other"
+- "confidence": a number from 0 to 1
+- "message": the number of the message the fact comes from
+Return {"facts": []} when there is nothing worth remembering.`
+
+const userMemoryColumns = `id, user_id, content, category, status, source, confidence,
+	source_session_id, source_message_id, created_at, updated_at`
+
+// RegisterJobs registers the fact extraction job handler on the queue
+func (s *UserMemoryService) RegisterJobs(queue *JobQueue) {
+	s.jobs = queue
+	queue.Register(JobTypeExtractFacts, 30*time.Minute, func(ctx context.Context, _ json.RawMessage) error {
+		return s.ExtractFromIdleSessions(ctx)
+	})
+}
+
+// Name returns the worker name
+func (s *UserMemoryService) Name() string {
+	return "fact_extractor"
+}
+
+// Run periodically queues fact extraction from idle sessions until ctx is cancelled
+func (s *UserMemoryService) Run(ctx context.Context) {
+	if !s.config.EnableFactExtraction || s.jobs == nil {
+		s.logger.Info().Msg("Fact extraction disabled")
+		return
+	}
+
+	s.logger.Info().
+		Dur("interval", s.config.FactExtractionInterval).
+		Dur("idle_timeout", s.config.SessionIdleTimeout).
+		Msg("Starting fact extractor")
+
+	runPeriodically(ctx, s.config.FactExtractionInterval, s.logger, func(ctx context.Context) error {
+		// Deduplicated, so several instances still only run it once
+		_, err := s.jobs.Enqueue(ctx, JobTypeExtractFacts, nil, JobOptions{DedupeKey: JobTypeExtractFacts})
+		return err
+	})
+}
+
+// ExtractFromIdleSessions proposes facts from sessions that have gone idle since they were last scanned
+func (s *UserMemoryService) ExtractFromIdleSessions(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT s.id
+		FROM sessions s
+		JOIN messages m ON m.session_id = s.id AND m.role = 'user'
+		WHERE s.user_id IS NOT NULL
+		GROUP BY s.id, s.facts_extracted_at
+		HAVING MAX(m.created_at) < $1 AND (s.facts_extracted_at IS NULL OR s.facts_extracted_at < MAX(m.created_at))
+		ORDER BY MAX(m.created_at) ASC
+		LIMIT $2
+	`, time.Now().Add(-s.config.SessionIdleTimeout), factSessionBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to query idle sessions: %w", err)
+	}
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan idle session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+
+	for _, sessionID := range sessionIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, err := s.ExtractFacts(ctx, sessionID); err != nil {
+			s.logger.Error().Err(err).Str("session_id", sessionID).Msg("Failed to extract facts")
+		}
+	}
+
+	return nil
+}
+
+// ExtractFacts proposes new facts from the user messages added to a session since it
+// was last scanned. Proposals stay pending until the user confirms them.
+func (s *UserMemoryService) ExtractFacts(ctx context.Context, sessionID string) ([]models.UserMemory, error) {
+	var userID sql.NullString
+	var extractedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id, facts_extracted_at FROM sessions WHERE id = $1", sessionID,
+	).Scan(&userID, &extractedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !userID.Valid {
+		return nil, fmt.Errorf("session has no owner")
+	}
+
+	var since time.Time
+	if extractedAt.Valid {
+		since = extractedAt.Time
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, content, created_at
+		FROM messages
+		WHERE session_id = $1 AND role = 'user' AND created_at > $2
+		ORDER BY created_at ASC
+	`, sessionID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session messages: %w", err)
+	}
+
+	var messageIDs, lines []string
+	var lastMessageAt time.Time
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content, &lastMessageAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messageIDs = append(messageIDs, id)
+		lines = append(lines, fmt.Sprintf("[%d] %s", len(messageIDs), truncateText(content, maxSummaryMessageChars)))
+	}
+	rows.Close()
+
+	proposed := []models.UserMemory{}
+	if len(lines) == 0 {
+		return proposed, nil
+	}
+
+	known, err := s.GetFacts(ctx, userID.String, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var knownLines []string
+	for _, fact := range known {
+		if fact.Status != models.UserMemoryStatusRejected {
+			knownLines = append(knownLines, "- "+fact.Content)
+		}
+	}
+	knownFacts := ""
+	if len(knownLines) > 0 {
+		knownFacts = fmt.Sprintf("\nAlready known, do not repeat:\n%s\n", strings.Join(knownLines, "\n"))
+	}
+
+	model, err := s.factModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.ollamaClient.Generate(ctx, OllamaGenerateRequest{
+		Model:   model,
+		Prompt:  fmt.Sprintf(factExtractionPrompt, knownFacts, joinWithinBudget(lines, maxSummaryInputChars)),
+		Format:  "json",
+		Options: map[string]interface{}{"temperature": 0.1},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract facts: %w", err)
+	}
+
+	var extraction struct {
+		Facts []struct {
+			Content    string  `json:"content"`
+			Category   string  `json:"category"`
+			Confidence float64 `json:"confidence"`
+			Message    int     `json:"message"`
+		} `json:"facts"`
+	}
+	if err := json.Unmarshal([]byte(resp.Response), &extraction); err != nil {
+		return nil, fmt.Errorf("failed to parse extracted facts: %w", err)
+	}
+
+	for _, candidate := range extraction.Facts {
+		content := cleanFactContent(candidate.Content)
+		if content == "" || candidate.Confidence < s.config.MinFactConfidence {
+			continue
+		}
+
+		embedding := s.embedFact(ctx, userID.String, content)
+		if embedding != nil {
+			duplicate, err := s.isDuplicateFact(ctx, userID.String, embedding)
+			if err != nil {
+				return nil, err
+			}
+			if duplicate {
+				continue
+			}
+		}
+
+		fact := models.UserMemory{
+			ID:              uuid.New().String(),
+			UserID:          userID.String,
+			Content:         content,
+			Category:        strings.ToLower(strings.TrimSpace(candidate.Category)),
+			Status:          models.UserMemoryStatusProposed,
+			Source:          models.UserMemorySourceExtracted,
+			Confidence:      candidate.Confidence,
+			SourceSessionID: sessionID,
+		}
+		if candidate.Message >= 1 && candidate.Message <= len(messageIDs) {
+			fact.SourceMessageID = messageIDs[candidate.Message-1]
+		}
+
+		saved, err := s.insertFact(ctx, fact, embedding)
+		if err != nil {
+			return nil, err
+		}
+		proposed = append(proposed, *saved)
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE sessions SET facts_extracted_at = $1 WHERE id = $2", lastMessageAt, sessionID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to record fact extraction: %w", err)
+	}
+
+	s.logger.Info().
+		Str("session_id", sessionID).
+		Str("model", model).
+		Int("messages_scanned", len(messageIDs)).
+		Int("facts_proposed", len(proposed)).
+		Msg("Facts extracted from session")
+
+	return proposed, nil
+}
+
+// ExtractFactsForUser runs fact extraction on a session after checking that the user owns it
+func (s *UserMemoryService) ExtractFactsForUser(ctx context.Context, sessionID, userID string) ([]models.UserMemory, error) {
+	var owner sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = $1", sessionID).Scan(&owner)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if owner.String != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	return s.ExtractFacts(ctx, sessionID)
+}
+
+// CreateFact stores a confirmed fact entered by the user
+func (s *UserMemoryService) CreateFact(ctx context.Context, userID string, req models.CreateUserMemoryRequest) (*models.UserMemory, error) {
+	content := cleanFactContent(req.Content)
+	if content == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+
+	fact := models.UserMemory{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Content:    content,
+		Category:   strings.ToLower(strings.TrimSpace(req.Category)),
+		Status:     models.UserMemoryStatusConfirmed,
+		Source:     models.UserMemorySourceManual,
+		Confidence: 1.0,
+	}
+
+	return s.insertFact(ctx, fact, s.embedFact(ctx, userID, content))
+}
+
+// GetFacts retrieves a user's facts, optionally filtered by status
+func (s *UserMemoryService) GetFacts(ctx context.Context, userID, status string) ([]models.UserMemory, error) {
+	query := "SELECT " + userMemoryColumns + " FROM user_memories WHERE user_id = $1"
+	args := []interface{}{userID}
+	if status != "" {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query facts: %w", err)
+	}
+	defer rows.Close()
+
+	facts := []models.UserMemory{}
+	for rows.Next() {
+		fact, err := scanUserMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		facts = append(facts, *fact)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating facts: %w", err)
+	}
+
+	return facts, nil
+}
+
+// GetFact retrieves a single fact owned by the user
+func (s *UserMemoryService) GetFact(ctx context.Context, factID, userID string) (*models.UserMemory, error) {
+	fact, err := scanUserMemory(s.db.QueryRowContext(ctx,
+		"SELECT "+userMemoryColumns+" FROM user_memories WHERE id = $1", factID,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("fact not found")
+		}
+		return nil, err
+	}
+
+	if fact.UserID != userID {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	return fact, nil
+}
+
+// UpdateFact changes the content, category or status of a fact
+func (s *UserMemoryService) UpdateFact(ctx context.Context, factID, userID string, req models.UpdateUserMemoryRequest) (*models.UserMemory, error) {
+	fact, err := s.GetFact(ctx, factID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Status != nil {
+		switch *req.Status {
+		case models.UserMemoryStatusProposed, models.UserMemoryStatusConfirmed, models.UserMemoryStatusRejected:
+			fact.Status = *req.Status
+		default:
+			return nil, fmt.Errorf("invalid status")
+		}
+	}
+	if req.Category != nil {
+		fact.Category = strings.ToLower(strings.TrimSpace(*req.Category))
+	}
+
+	contentChanged := false
+	if req.Content != nil {
+		content := cleanFactContent(*req.Content)
+		if content == "" {
+			return nil, fmt.Errorf("content is required")
+		}
+		contentChanged = content != fact.Content
+		fact.Content = content
+	}
+
+	if contentChanged {
+		vector, model := s.embedFact(ctx, userID, fact.Content).columns()
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE user_memories SET content = $1, category = $2, status = $3, embedding = $4, model_used = $5
+			WHERE id = $6
+		`, fact.Content, nullableString(fact.Category), fact.Status, vector, model, factID)
+	} else {
+		_, err = s.db.ExecContext(ctx,
+			"UPDATE user_memories SET category = $1, status = $2 WHERE id = $3",
+			nullableString(fact.Category), fact.Status, factID,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fact: %w", err)
+	}
+
+	return s.GetFact(ctx, factID, userID)
+}
+
+// SetFactStatus confirms or rejects a fact
+func (s *UserMemoryService) SetFactStatus(ctx context.Context, factID, userID, status string) (*models.UserMemory, error) {
+	return s.UpdateFact(ctx, factID, userID, models.UpdateUserMemoryRequest{Status: &status})
+}
+
+// DeleteFact removes a fact
+func (s *UserMemoryService) DeleteFact(ctx context.Context, factID, userID string) error {
+	if _, err := s.GetFact(ctx, factID, userID); err != nil {
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM user_memories WHERE id = $1", factID); err != nil {
+		return fmt.Errorf("failed to delete fact: %w", err)
+	}
+
+	return nil
+}
+
+// GetRelevantFacts returns the user's confirmed facts most similar to the query. Fact
+// embeddings live in user_memories rather than the vector store.
+func (s *UserMemoryService) GetRelevantFacts(ctx context.Context, userID, query string, limit int) ([]models.UserMemory, error) {
+	if userID == "" || limit <= 0 {
+		return nil, nil
+	}
+
+	model := s.registry.ModelForUser(ctx, userID)
+	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+userMemoryColumns+`
+		FROM user_memories
+		WHERE user_id = $1 AND status = 'confirmed' AND model_used = $2 AND embedding IS NOT NULL
+		  AND 1 - (embedding <=> $3) >= $4
+		ORDER BY embedding <=> $3
+		LIMIT $5
+	`, userID, model, pgvector.NewVector(queryEmbedding), s.config.FactRelevanceThreshold, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relevant facts: %w", err)
+	}
+	defer rows.Close()
+
+	var facts []models.UserMemory
+	for rows.Next() {
+		fact, err := scanUserMemory(rows)
+		if err != nil {
+			return nil, err
+		}
+		facts = append(facts, *fact)
+	}
+
+	return facts, rows.Err()
+}
+
+// insertFact stores a fact and returns it as saved
+func (s *UserMemoryService) insertFact(ctx context.Context, fact models.UserMemory, embedding *factEmbedding) (*models.UserMemory, error) {
+	vector, model := embedding.columns()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_memories (id, user_id, content, category, status, source, confidence,
+		                           source_session_id, source_message_id, embedding, model_used)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`,
+		fact.ID,
+		fact.UserID,
+		fact.Content,
+		nullableString(fact.Category),
+		fact.Status,
+		fact.Source,
+		fact.Confidence,
+		nullableString(fact.SourceSessionID),
+		nullableString(fact.SourceMessageID),
+		vector,
+		model,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store fact: %w", err)
+	}
+
+	return s.GetFact(ctx, fact.ID, fact.UserID)
+}
+
+// isDuplicateFact reports whether the user already has a fact (including rejected ones) with nearly the same meaning
+func (s *UserMemoryService) isDuplicateFact(ctx context.Context, userID string, embedding *factEmbedding) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_memories
+			WHERE user_id = $1 AND model_used = $2 AND embedding IS NOT NULL AND 1 - (embedding <=> $3) >= $4
+		)
+	`, userID, embedding.model, pgvector.NewVector(embedding.vector), duplicateFactSimilarity).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for duplicate facts: %w", err)
+	}
+	return exists, nil
+}
+
+// factEmbedding is a fact's vector together with the model that produced it
+type factEmbedding struct {
+	vector []float32
+	model  string
+}
+
+// columns returns the embedding and model_used column values, NULL when there is no embedding
+func (e *factEmbedding) columns() (interface{}, interface{}) {
+	if e == nil {
+		return nil, nil
+	}
+	return pgvector.NewVector(e.vector), e.model
+}
+
+// embedFact embeds a fact with the user's embedding model, returning nil if no embedding
+// could be generated. Facts without an embedding are kept but never injected into chats.
+func (s *UserMemoryService) embedFact(ctx context.Context, userID, content string) *factEmbedding {
+	model := s.registry.ModelForUser(ctx, userID)
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, content, model)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Failed to embed fact, storing it without an embedding")
+		return nil
+	}
+	return &factEmbedding{vector: embedding, model: model}
+}
+
+// factModel returns the configured fact model, falling back to the summary model and then the default chat model
+func (s *UserMemoryService) factModel(ctx context.Context) (string, error) {
+	if s.config.FactModel != "" {
+		return s.config.FactModel, nil
+	}
+	if s.config.SummaryModel != "" {
+		return s.config.SummaryModel, nil
+	}
+
+	defaultModel, err := s.modelManager.GetDefaultModel(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no fact model configured and no default model available: %w", err)
+	}
+	return defaultModel.Name, nil
+}
+
+// FormatFactsPrompt renders facts as a system prompt for the chat model
+func FormatFactsPrompt(facts []models.UserMemory) string {
+	if len(facts) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		lines = append(lines, "- "+fact.Content)
+	}
+	return fmt.Sprintf("Facts the user has asked you to remember about them. Use them where relevant:\n%s", strings.Join(lines, "\n"))
+}
+
+// scanUserMemory scans a user_memories row
+func scanUserMemory(row rowScanner) (*models.UserMemory, error) {
+	var fact models.UserMemory
+	var category, sourceSessionID, sourceMessageID sql.NullString
+	var createdAt, updatedAt sql.NullTime
+
+	err := row.Scan(&fact.ID, &fact.UserID, &fact.Content, &category, &fact.Status, &fact.Source, &fact.Confidence,
+		&sourceSessionID, &sourceMessageID, &createdAt, &updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan fact: %w", err)
+	}
+
+	fact.Category = category.String
+	fact.SourceSessionID = sourceSessionID.String
+	fact.SourceMessageID = sourceMessageID.String
+	fact.CreatedAt = createdAt.Time
+	fact.UpdatedAt = updatedAt.Time
+
+	return &fact, nil
+}
+
+// cleanFactContent normalizes whitespace and bounds the length of a fact
+func cleanFactContent(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if len(content) > maxFactLength {
+		content = strings.TrimSpace(content[:maxFactLength])
+	}
+	return content
+}
+
+// nullableString maps an empty string to NULL
+func nullableString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"chat_ollama/internal/config"
+	"chat_ollama/internal/database"
+	"chat_ollama/internal/models"
+	"chat_ollama/internal/utils"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// vectorIndexRecallK is the number of nearest neighbours index recall is measured at
+const vectorIndexRecallK = 10
+
+// vectorIndexTarget is a table with per-model vector indexes
+type vectorIndexTarget struct {
+	table       string
+	modelColumn string
+}
+
+// vectorIndexTargets lists the tables with per-model vector indexes and their model column
+var vectorIndexTargets = []vectorIndexTarget{
+	{"message_embeddings", "model_used"},
+	{"memory_summaries", "embedding_model"},
+	{"user_memories", "model_used"},
+}
+
+var indexNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// VectorIndexService builds, rebuilds and inspects the per-model vector indexes
+type VectorIndexService struct {
+	db     database.Database
+	config *config.Config
+	logger *utils.Logger
+}
+
+// NewVectorIndexService creates a new vector index service
+func NewVectorIndexService(db database.Database, cfg *config.Config, logger *utils.Logger) *VectorIndexService {
+	return &VectorIndexService{
+		db:     db,
+		config: cfg,
+		logger: logger.WithComponent("vector_index"),
+	}
+}
+
+// RegisterJobs registers the reindex job handler on the queue
+func (s *VectorIndexService) RegisterJobs(queue *JobQueue) {
+	queue.Register(JobTypeReindexVectors, 2*time.Hour, s.handleReindex)
+}
+
+// vectorIndexName returns the name of a model's vector index on a table
+func vectorIndexName(table, model string, dims int) string {
+	suffix := indexNameSanitizer.ReplaceAllString(strings.ToLower(fmt.Sprintf("%s_%d", model, dims)), "_")
+	name := fmt.Sprintf("idx_%s_vec_%s", table, suffix)
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// EnsureIndexes creates the model's missing vector indexes with the configured type.
+// Columns hold vectors of any dimension, so each index casts to the model's dimension.
+func (s *VectorIndexService) EnsureIndexes(ctx context.Context, model string, dims int) error {
+	for _, target := range vectorIndexTargets {
+		definition, err := s.indexDefinition(ctx, target, model, dims)
+		if err != nil {
+			return err
+		}
+		name := vectorIndexName(target.table, model, dims)
+		if _, err := s.db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+pq.QuoteIdentifier(name)+" "+definition); err != nil {
+			return fmt.Errorf("failed to create vector index on %s: %w", target.table, err)
+		}
+	}
+	return nil
+}
+
+// indexDefinition returns the part of a CREATE INDEX statement after the index name
+func (s *VectorIndexService) indexDefinition(ctx context.Context, target vectorIndexTarget, model string, dims int) (string, error) {
+	var parameters string
+	switch s.config.VectorIndexType {
+	case models.VectorIndexIVFFlat:
+		lists := s.config.IVFFlatLists
+		if lists == 0 {
+			rows, err := s.countRows(ctx, target, model)
+			if err != nil {
+				return "", err
+			}
+			lists = ivfflatLists(rows)
+		}
+		parameters = fmt.Sprintf("lists = %d", lists)
+	default:
+		parameters = fmt.Sprintf("m = %d, ef_construction = %d", s.config.HNSWM, s.config.HNSWEfConstruction)
+	}
+
+	return fmt.Sprintf("ON %s USING %s ((embedding::vector(%d)) vector_cosine_ops) WITH (%s) WHERE %s = %s",
+		target.table, s.config.VectorIndexType, dims, parameters, target.modelColumn, pq.QuoteLiteral(model),
+	), nil
+}
+
+// ivfflatLists sizes IVFFlat lists from the row count as pgvector recommends:
+// rows / 1000 up to a million rows, the square root of the rows beyond
+func ivfflatLists(rows int64) int {
+	if rows > 1000000 {
+		return int(math.Sqrt(float64(rows)))
+	}
+	if lists := int(rows / 1000); lists > 1 {
+		return lists
+	}
+	return 1
+}
+
+// countRows counts the model's vectors on a table
+func (s *VectorIndexService) countRows(ctx context.Context, target vectorIndexTarget, model string) (int64, error) {
+	var rows int64
+	err := s.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = $1 AND embedding IS NOT NULL", target.table, target.modelColumn), model,
+	).Scan(&rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count vectors on %s: %w", target.table, err)
+	}
+	return rows, nil
+}
+
+// registeredModels returns the embedding models and their dimensions
+func (s *VectorIndexService) registeredModels(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, dimensions FROM embedding_models")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedding models: %w", err)
+	}
+	defer rows.Close()
+
+	dimensions := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var dims int
+		if err := rows.Scan(&name, &dims); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding model: %w", err)
+		}
+		dimensions[name] = dims
+	}
+	return dimensions, rows.Err()
+}
+
+// handleReindex runs a queued rebuild
+func (s *VectorIndexService) handleReindex(ctx context.Context, raw json.RawMessage) error {
+	var req models.ReindexRequest
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return fmt.Errorf("invalid reindex payload: %w", err)
+		}
+	}
+	_, err := s.Reindex(ctx, req)
+	return err
+}
+
+// Reindex rebuilds the matching vector indexes with the configured type and parameters and
+// returns how many were rebuilt. Indexes are built concurrently, so searches and writes carry
+// on while it runs.
+func (s *VectorIndexService) Reindex(ctx context.Context, req models.ReindexRequest) (int, error) {
+	dimensions, err := s.registeredModels(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rebuilt := 0
+	for model, dims := range dimensions {
+		if req.Model != "" && model != req.Model {
+			continue
+		}
+		for _, target := range vectorIndexTargets {
+			if req.Table != "" && target.table != req.Table {
+				continue
+			}
+			if err := s.rebuild(ctx, target, model, dims); err != nil {
+				return rebuilt, err
+			}
+			rebuilt++
+		}
+	}
+
+	s.logger.Info().
+		Str("index_type", s.config.VectorIndexType).
+		Int("indexes", rebuilt).
+		Msg("Vector indexes rebuilt")
+
+	return rebuilt, nil
+}
+
+// rebuild builds a replacement index next to the current one, then swaps them
+func (s *VectorIndexService) rebuild(ctx context.Context, target vectorIndexTarget, model string, dims int) error {
+	name := vectorIndexName(target.table, model, dims)
+	building := name
+	if len(building) > 55 {
+		building = building[:55]
+	}
+	building += "_rebuild"
+
+	definition, err := s.indexDefinition(ctx, target, model, dims)
+	if err != nil {
+		return err
+	}
+
+	// A failed concurrent build leaves an invalid index behind
+	statements := []string{
+		"DROP INDEX CONCURRENTLY IF EXISTS " + pq.QuoteIdentifier(building),
+		"CREATE INDEX CONCURRENTLY " + pq.QuoteIdentifier(building) + " " + definition,
+		"DROP INDEX CONCURRENTLY IF EXISTS " + pq.QuoteIdentifier(name),
+		"ALTER INDEX " + pq.QuoteIdentifier(building) + " RENAME TO " + pq.QuoteIdentifier(name),
+	}
+	started := time.Now()
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to rebuild vector index %s: %w", name, err)
+		}
+	}
+
+	s.logger.Info().
+		Str("index", name).
+		Str("model", model).
+		Dur("duration", time.Since(started)).
+		Msg("Vector index rebuilt")
+	return nil
+}
+
+// Diagnostics reports every model's vector indexes with their type, size, row count and
+// recall estimated by comparing sampled index searches with exact searches
+func (s *VectorIndexService) Diagnostics(ctx context.Context, tuning database.SearchTuning) (*models.VectorIndexReport, error) {
+	tuning = tuning.WithDefaults(database.SearchTuning{
+		EfSearch: s.config.VectorSearchEfSearch,
+		Probes:   s.config.VectorSearchProbes,
+	})
+
+	dimensions, err := s.registeredModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.VectorIndexReport{
+		VectorStore: s.config.VectorStore,
+		IndexType:   s.config.VectorIndexType,
+		EfSearch:    tuning.EfSearch,
+		Probes:      tuning.Probes,
+		RecallAtK:   vectorIndexRecallK,
+		Indexes:     []models.VectorIndexInfo{},
+	}
+	for model, dims := range dimensions {
+		for _, target := range vectorIndexTargets {
+			info, err := s.inspect(ctx, target, model, dims, tuning)
+			if err != nil {
+				return nil, err
+			}
+			report.Indexes = append(report.Indexes, *info)
+		}
+	}
+
+	return report, nil
+}
+
+// inspect describes a model's vector index on a table
+func (s *VectorIndexService) inspect(ctx context.Context, target vectorIndexTarget, model string, dims int, tuning database.SearchTuning) (*models.VectorIndexInfo, error) {
+	info := &models.VectorIndexInfo{
+		Name:       vectorIndexName(target.table, model, dims),
+		Table:      target.table,
+		Model:      model,
+		Dimensions: dims,
+	}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT am.amname, COALESCE(c.reloptions, '{}'), i.indisvalid, pg_relation_size(c.oid)
+		FROM pg_class c
+		JOIN pg_index i ON i.indexrelid = c.oid
+		JOIN pg_am am ON am.oid = c.relam
+		WHERE c.relname = $1 AND c.relkind = 'i'
+	`, info.Name).Scan(&info.Type, pq.Array(&info.Parameters), &info.Valid, &info.SizeBytes)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to inspect vector index %s: %w", info.Name, err)
+	}
+	info.Exists = err == nil
+	info.MatchesConfig = info.Type == s.config.VectorIndexType
+
+	if info.Rows, err = s.countRows(ctx, target, model); err != nil {
+		return nil, err
+	}
+
+	if info.Exists && info.Valid && info.Rows > 0 {
+		recall, sampled, err := s.estimateRecall(ctx, target, model, dims, tuning)
+		if err != nil {
+			return nil, err
+		}
+		if sampled > 0 {
+			info.Recall = &recall
+			info.SampledQueries = sampled
+		}
+	}
+
+	return info, nil
+}
+
+// estimateRecall searches for the nearest neighbours of sampled stored vectors through the
+// index and exactly, and returns the share of exact neighbours the index search found
+func (s *VectorIndexService) estimateRecall(ctx context.Context, target vectorIndexTarget, model string, dims int, tuning database.SearchTuning) (float64, int, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT embedding FROM %s WHERE %s = $1 AND vector_dims(embedding) = $2 ORDER BY random() LIMIT $3",
+		target.table, target.modelColumn,
+	), model, dims, s.config.VectorRecallSampleSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sample vectors on %s: %w", target.table, err)
+	}
+	var samples []pgvector.Vector
+	for rows.Next() {
+		var sample pgvector.Vector
+		if err := rows.Scan(&sample); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan sampled vector: %w", err)
+		}
+		samples = append(samples, sample)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("error iterating sampled vectors: %w", err)
+	}
+
+	found, expected := 0, 0
+	for _, sample := range samples {
+		approximate, err := s.nearestIDs(ctx, target, model, dims, sample, tuning, false)
+		if err != nil {
+			return 0, 0, err
+		}
+		exact, err := s.nearestIDs(ctx, target, model, dims, sample, tuning, true)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		returned := make(map[string]bool, len(approximate))
+		for _, id := range approximate {
+			returned[id] = true
+		}
+		for _, id := range exact {
+			if returned[id] {
+				found++
+			}
+		}
+		expected += len(exact)
+	}
+
+	if expected == 0 {
+		return 0, 0, nil
+	}
+	return float64(found) / float64(expected), len(samples), nil
+}
+
+// nearestIDs returns the IDs of the rows nearest to the query, through the index with the
+// given tuning or, when exact is set, by scanning every row
+func (s *VectorIndexService) nearestIDs(ctx context.Context, target vectorIndexTarget, model string, dims int, query pgvector.Vector, tuning database.SearchTuning, exact bool) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if exact {
+		for _, setting := range []string{"enable_indexscan", "enable_bitmapscan"} {
+			if _, err := tx.ExecContext(ctx, "SET LOCAL "+setting+" = off"); err != nil {
+				return nil, fmt.Errorf("failed to disable index scans: %w", err)
+			}
+		}
+	} else if err := tuning.Apply(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		"SELECT id FROM %s WHERE %s = $2 ORDER BY %s <=> %s LIMIT $3",
+		target.table, target.modelColumn, vectorOf("embedding", dims), vectorOf("$1", dims),
+	), query, model, vectorIndexRecallK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", target.table, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan neighbour: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ValidateReindexRequest checks that a reindex request names a known table
+func ValidateReindexRequest(req models.ReindexRequest) error {
+	if req.Table == "" {
+		return nil
+	}
+	for _, target := range vectorIndexTargets {
+		if target.table == req.Table {
+			return nil
+		}
+	}
+	return fmt.Errorf("table must be one of message_embeddings, memory_summaries or user_memories")
+}
+
+// ValidateSearchTuning checks per-query index scan settings
+func ValidateSearchTuning(tuning database.SearchTuning) error {
+	if tuning.EfSearch < 0 || tuning.EfSearch > 1000 {
+		return fmt.Errorf("ef_search must be between 1 and 1000")
+	}
+	if tuning.Probes < 0 {
+		return fmt.Errorf("probes must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"chat_ollama/internal/utils"
+)
+
+// Worker is a long-running background task started alongside the HTTP server.
+// Run must return once ctx is cancelled.
+type Worker interface {
+	Name() string
+	Run(ctx context.Context)
+}
+
+// runPeriodically calls fn immediately and then on every tick until ctx is cancelled
+func runPeriodically(ctx context.Context, interval time.Duration, logger *utils.Logger, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			logger.Error().Err(err).Msg("Background run failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
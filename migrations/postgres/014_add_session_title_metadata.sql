@@ -0,0 +1,7 @@
+-- Track how a session title was produced and store follow-up suggestions
+ALTER TABLE sessions ADD COLUMN title_source TEXT NOT NULL DEFAULT 'default'
+    CHECK (title_source IN ('default', 'heuristic', 'generated'));
+ALTER TABLE sessions ADD COLUMN suggestions JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- Existing non-default titles were produced from the first message
+UPDATE sessions SET title_source = 'heuristic' WHERE title <> 'New Chat';
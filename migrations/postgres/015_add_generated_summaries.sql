@@ -0,0 +1,17 @@
+-- Distinguish summaries produced by the summarizer from client-supplied ones
+ALTER TABLE memory_summaries ADD COLUMN source TEXT NOT NULL DEFAULT 'manual'
+    CHECK (source IN ('manual', 'generated'));
+ALTER TABLE memory_summaries ADD COLUMN period TEXT
+    CHECK (period IS NULL OR period IN ('daily', 'weekly'));
+ALTER TABLE memory_summaries ADD COLUMN model_used TEXT;
+
+-- Generated summaries are upserted: one per session, one per user period, one global per user
+CREATE UNIQUE INDEX idx_memory_summaries_generated_conversation
+    ON memory_summaries(session_id)
+    WHERE source = 'generated' AND summary_type = 'conversation';
+CREATE UNIQUE INDEX idx_memory_summaries_generated_period
+    ON memory_summaries(user_id, period, start_time)
+    WHERE source = 'generated' AND summary_type = 'period';
+CREATE UNIQUE INDEX idx_memory_summaries_generated_global
+    ON memory_summaries(user_id)
+    WHERE source = 'generated' AND summary_type = 'global';
@@ -0,0 +1,14 @@
+-- Remove duplicate gaps left behind by repeated full-session detection runs
+DELETE FROM memory_gaps a
+USING memory_gaps b
+WHERE a.session_id = b.session_id
+  AND a.gap_start = b.gap_start
+  AND a.gap_end = b.gap_end
+  AND a.gap_type = b.gap_type
+  AND (a.created_at > b.created_at OR (a.created_at = b.created_at AND a.id > b.id));
+
+-- Each gap is detected once per session, boundary pair and type
+CREATE UNIQUE INDEX idx_memory_gaps_unique ON memory_gaps(session_id, gap_start, gap_end, gap_type);
+
+-- Embedding similarity across a topical gap
+ALTER TABLE memory_gaps ADD COLUMN similarity REAL;
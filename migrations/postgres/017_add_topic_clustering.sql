@@ -0,0 +1,6 @@
+-- Topics are clustered per user, so names only need to be unique within a user
+ALTER TABLE semantic_topics DROP CONSTRAINT IF EXISTS semantic_topics_name_key;
+
+-- Track when a topic's label was last generated so it can be refreshed as the topic grows
+ALTER TABLE semantic_topics ADD COLUMN needs_label BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE semantic_topics ADD COLUMN labeled_message_count INTEGER NOT NULL DEFAULT 0;
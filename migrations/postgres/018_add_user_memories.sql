@@ -0,0 +1,32 @@
+-- Explicit long-term facts about a user, entered manually or proposed by the extractor
+CREATE TABLE user_memories (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    content TEXT NOT NULL,
+    category TEXT,
+    status TEXT NOT NULL DEFAULT 'confirmed' CHECK (status IN ('proposed', 'confirmed', 'rejected')),
+    source TEXT NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'extracted')),
+    confidence REAL NOT NULL DEFAULT 1.0,
+    source_session_id TEXT,
+    source_message_id TEXT,
+    embedding vector(768),
+    model_used TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_session_id) REFERENCES sessions(id) ON DELETE SET NULL,
+    FOREIGN KEY (source_message_id) REFERENCES messages(id) ON DELETE SET NULL
+);
+
+-- Create indexes for performance
+CREATE INDEX idx_user_memories_user_status ON user_memories(user_id, status);
+CREATE INDEX idx_user_memories_source_session ON user_memories(source_session_id);
+
+-- Trigger to update user_memories updated_at
+CREATE TRIGGER update_user_memories_updated_at
+    BEFORE UPDATE ON user_memories
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Track how far each session has been scanned for facts
+ALTER TABLE sessions ADD COLUMN facts_extracted_at TIMESTAMP WITH TIME ZONE;
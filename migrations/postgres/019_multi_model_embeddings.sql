@@ -0,0 +1,63 @@
+-- Store embeddings of any dimension so several embedding models can be used side by side.
+-- Vectors are only ever compared with vectors of the same model; per-model indexes are
+-- created by the application once the model's dimension is known.
+DROP INDEX IF EXISTS idx_message_embeddings_vector;
+DROP INDEX IF EXISTS idx_memory_summaries_vector;
+DROP INDEX IF EXISTS idx_semantic_topics_vector;
+
+ALTER TABLE message_embeddings ALTER COLUMN embedding TYPE vector;
+ALTER TABLE memory_summaries ALTER COLUMN embedding TYPE vector;
+ALTER TABLE semantic_topics ALTER COLUMN embedding TYPE vector;
+ALTER TABLE user_memories ALTER COLUMN embedding TYPE vector;
+
+-- Record which embedding model produced each inline vector
+-- (memory_summaries.model_used is the model that wrote the summary text)
+ALTER TABLE memory_summaries ADD COLUMN embedding_model TEXT;
+ALTER TABLE semantic_topics ADD COLUMN embedding_model TEXT;
+
+CREATE INDEX idx_message_embeddings_message_model ON message_embeddings(message_id, model_used);
+CREATE INDEX idx_memory_summaries_embedding_model ON memory_summaries(embedding_model);
+CREATE INDEX idx_semantic_topics_user_model ON semantic_topics(user_id, embedding_model);
+
+-- Embedding models that have been used, keyed by name and dimension
+CREATE TABLE embedding_models (
+    name TEXT PRIMARY KEY,
+    dimensions INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The embedding model a user's memory is stored under; NULL means the configured default
+ALTER TABLE users ADD COLUMN embedding_model TEXT;
+
+-- Background jobs that migrate a user's memory to another embedding model
+CREATE TABLE reembed_jobs (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    source_model TEXT NOT NULL,
+    target_model TEXT NOT NULL,
+    target_dimensions INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
+    phase TEXT NOT NULL DEFAULT 'messages',
+    last_item_id TEXT NOT NULL DEFAULT '',
+    total_items INTEGER NOT NULL DEFAULT 0,
+    processed_items INTEGER NOT NULL DEFAULT 0,
+    failed_items INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reembed_jobs_user_id ON reembed_jobs(user_id);
+CREATE INDEX idx_reembed_jobs_status ON reembed_jobs(status, created_at);
+
+-- Only one job per user may be in progress at a time
+CREATE UNIQUE INDEX idx_reembed_jobs_active_user ON reembed_jobs(user_id) WHERE status IN ('pending', 'running');
+
+-- Trigger to update reembed_jobs updated_at
+CREATE TRIGGER update_reembed_jobs_updated_at
+    BEFORE UPDATE ON reembed_jobs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,33 @@
+-- Durable queue for background work (embeddings, gap detection, session titles, model downloads)
+CREATE TABLE jobs (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'dead')),
+    priority INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_by TEXT,
+    locked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    dedupe_key TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Workers pick the most urgent runnable job first
+CREATE INDEX idx_jobs_runnable ON jobs(priority DESC, run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_running ON jobs(locked_at) WHERE status = 'running';
+CREATE INDEX idx_jobs_status_type ON jobs(status, type);
+CREATE INDEX idx_jobs_completed_at ON jobs(completed_at) WHERE status = 'completed';
+
+-- A job with a dedupe key is only queued once until a worker picks it up
+CREATE UNIQUE INDEX idx_jobs_pending_dedupe ON jobs(dedupe_key) WHERE status = 'pending';
+
+-- Trigger to update jobs updated_at
+CREATE TRIGGER update_jobs_updated_at
+    BEFORE UPDATE ON jobs
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
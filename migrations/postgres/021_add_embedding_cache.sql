@@ -0,0 +1,12 @@
+-- Cache of embeddings keyed by model and SHA-256 of the embedded text, so identical
+-- text is only ever sent to the embedding model once
+CREATE TABLE embedding_cache (
+    model TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    embedding vector NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (model, content_hash)
+);
+
+CREATE INDEX idx_embedding_cache_last_used_at ON embedding_cache(last_used_at);
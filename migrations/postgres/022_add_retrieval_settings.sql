@@ -0,0 +1,4 @@
+-- Per-user and per-project overrides of the semantic memory retrieval pipeline.
+-- Keys that are absent fall back to the server defaults (user) or the user's settings (project).
+ALTER TABLE users ADD COLUMN retrieval_settings JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE projects ADD COLUMN retrieval_settings JSONB NOT NULL DEFAULT '{}';
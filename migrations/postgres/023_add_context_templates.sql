@@ -0,0 +1,3 @@
+-- Per-project template for the system message that carries retrieved memory.
+-- NULL uses the server's default template.
+ALTER TABLE projects ADD COLUMN context_template TEXT;
@@ -0,0 +1,17 @@
+-- How the memory injected into each chat turn was selected, stored against the assistant message
+CREATE TABLE retrieval_traces (
+    id TEXT PRIMARY KEY,
+    message_id TEXT NOT NULL UNIQUE REFERENCES messages(id) ON DELETE CASCADE,
+    user_message_id TEXT REFERENCES messages(id) ON DELETE SET NULL,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    query TEXT NOT NULL,
+    embedding_model TEXT NOT NULL,
+    settings JSONB NOT NULL DEFAULT '{}',
+    candidates JSONB NOT NULL DEFAULT '[]',
+    selected JSONB NOT NULL DEFAULT '[]',
+    injected_context TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_retrieval_traces_user_message_id ON retrieval_traces(user_message_id);
+CREATE INDEX idx_retrieval_traces_session_id ON retrieval_traces(session_id);
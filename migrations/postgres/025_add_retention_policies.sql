@@ -0,0 +1,35 @@
+-- Retention policies: semantic memory older than this many days is purged. NULL inherits
+-- (project -> user -> server default), and a project's policy applies to its sessions.
+ALTER TABLE users ADD COLUMN memory_retention_days INTEGER CHECK (memory_retention_days > 0);
+ALTER TABLE projects ADD COLUMN memory_retention_days INTEGER CHECK (memory_retention_days > 0);
+
+-- Embeddings outlive their sessions, so record their owner to be able to purge them later
+ALTER TABLE message_embeddings ADD COLUMN user_id TEXT;
+
+UPDATE message_embeddings
+SET user_id = s.user_id
+FROM sessions s
+WHERE message_embeddings.session_id = s.id;
+
+ALTER TABLE message_embeddings ADD CONSTRAINT fk_message_embeddings_user_id
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_message_embeddings_user_id ON message_embeddings(user_id);
+
+-- Fill in the owner of new embeddings from their session
+CREATE OR REPLACE FUNCTION set_message_embedding_user_id()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.user_id IS NULL THEN
+        SELECT user_id INTO NEW.user_id FROM sessions WHERE id = NEW.session_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER set_message_embeddings_user_id
+    BEFORE INSERT ON message_embeddings
+    FOR EACH ROW
+    EXECUTE FUNCTION set_message_embedding_user_id();
+
+COMMENT ON TABLE message_embeddings IS 'Embeddings are preserved independently of messages to maintain semantic memory across session deletions, until a retention policy, a forgotten session or an account purge removes them. Contains denormalized message data for efficient querying.';
@@ -0,0 +1,10 @@
+-- A message has at most one vector per embedding model, so vector stores can upsert by
+-- (message_id, model_used). Keep the newest of any duplicates written before.
+DELETE FROM message_embeddings me
+USING message_embeddings newer
+WHERE me.message_id = newer.message_id
+  AND me.model_used = newer.model_used
+  AND (me.created_at, me.id) < (newer.created_at, newer.id);
+
+DROP INDEX IF EXISTS idx_message_embeddings_message_model;
+CREATE UNIQUE INDEX idx_message_embeddings_message_model ON message_embeddings(message_id, model_used);
@@ -0,0 +1,28 @@
+-- Model downloads survive restarts: each pull is recorded with the progress of every layer
+CREATE TABLE model_downloads (
+    id TEXT PRIMARY KEY,
+    model_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    model_name TEXT NOT NULL,
+    job_id TEXT,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'downloading', 'completed', 'failed', 'cancelled')),
+    phase TEXT NOT NULL DEFAULT '',
+    layers JSONB NOT NULL DEFAULT '[]',
+    total_bytes BIGINT NOT NULL DEFAULT 0,
+    completed_bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A model is downloaded at most once at a time
+CREATE UNIQUE INDEX idx_model_downloads_active ON model_downloads(model_id) WHERE status IN ('queued', 'downloading');
+CREATE INDEX idx_model_downloads_model_created ON model_downloads(model_id, created_at DESC);
+CREATE INDEX idx_model_downloads_status ON model_downloads(status);
+
+-- Trigger to update model_downloads updated_at
+CREATE TRIGGER update_model_downloads_updated_at
+    BEFORE UPDATE ON model_downloads
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
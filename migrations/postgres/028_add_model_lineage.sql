@@ -0,0 +1,8 @@
+-- Model lineage: where a model came from and, for models created from a Modelfile or
+-- copied, the model it was derived from
+ALTER TABLE models ADD COLUMN source TEXT NOT NULL DEFAULT 'library' CHECK (source IN ('library', 'created', 'copied', 'alias'));
+ALTER TABLE models ADD COLUMN base_model TEXT NOT NULL DEFAULT '';
+ALTER TABLE models ADD COLUMN parent_model_id TEXT REFERENCES models(id) ON DELETE SET NULL;
+ALTER TABLE models ADD COLUMN modelfile TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_models_parent_model_id ON models(parent_model_id);
@@ -0,0 +1,6 @@
+-- Models imported from GGUF files, with the context length read from their header
+ALTER TABLE models DROP CONSTRAINT models_source_check;
+ALTER TABLE models ADD CONSTRAINT models_source_check
+    CHECK (source IN ('library', 'created', 'copied', 'alias', 'imported'));
+
+ALTER TABLE models ADD COLUMN context_length INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Model capabilities discovered with Ollama's /api/show. The digest is the one the
+-- capabilities were read for, so they are only read again when the model changes.
+ALTER TABLE models ADD COLUMN digest TEXT NOT NULL DEFAULT '';
+ALTER TABLE models ADD COLUMN capabilities TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE models ADD COLUMN supports_vision BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE models ADD COLUMN supports_tools BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE models ADD COLUMN template TEXT NOT NULL DEFAULT '';
+ALTER TABLE models ADD COLUMN license TEXT NOT NULL DEFAULT '';
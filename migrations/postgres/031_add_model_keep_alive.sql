@@ -0,0 +1,2 @@
+-- How long Ollama keeps a model loaded after a chat; empty leaves it to Ollama's default
+ALTER TABLE model_configs ADD COLUMN keep_alive TEXT NOT NULL DEFAULT '';
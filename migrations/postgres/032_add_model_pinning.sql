@@ -0,0 +1,2 @@
+-- Pinned models are never evicted to stay within the model storage budget
+ALTER TABLE models ADD COLUMN is_pinned BOOLEAN NOT NULL DEFAULT FALSE;
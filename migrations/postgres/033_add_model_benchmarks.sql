@@ -0,0 +1,31 @@
+-- Model benchmarks: each run of the prompt suite against a model, with per-prompt results
+CREATE TABLE model_benchmarks (
+    id TEXT PRIMARY KEY,
+    model_id TEXT NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    model_name TEXT NOT NULL,
+    job_id TEXT,
+    host TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    prompts JSONB NOT NULL DEFAULT '[]',
+    runs INTEGER NOT NULL DEFAULT 1,
+    max_tokens INTEGER NOT NULL DEFAULT 0,
+    results JSONB NOT NULL DEFAULT '[]',
+    tokens_per_second DOUBLE PRECISION,
+    prompt_tokens_per_second DOUBLE PRECISION,
+    time_to_first_token_ms DOUBLE PRECISION,
+    load_ms DOUBLE PRECISION,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_model_benchmarks_model_created ON model_benchmarks(model_id, created_at DESC);
+CREATE INDEX idx_model_benchmarks_status ON model_benchmarks(status);
+
+-- Trigger to update model_benchmarks updated_at
+CREATE TRIGGER update_model_benchmarks_updated_at
+    BEFORE UPDATE ON model_benchmarks
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
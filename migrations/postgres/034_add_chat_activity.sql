@@ -0,0 +1,8 @@
+-- Chats in progress on each instance, so work that must not run during chats, such as
+-- model benchmarks, sees the chats of every instance. Rows are refreshed while the
+-- instance runs; stale rows belong to instances that stopped.
+CREATE TABLE chat_activity (
+    instance_id TEXT PRIMARY KEY,
+    active_chats INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,10 @@
+-- Cached embeddings are keyed by the digest of the model as well, so a model pulled
+-- again under the same name does not serve the embeddings of its previous version.
+-- Existing entries cannot be attributed to a digest and are dropped.
+DELETE FROM embedding_cache;
+ALTER TABLE embedding_cache ADD COLUMN model_digest TEXT NOT NULL DEFAULT '';
+ALTER TABLE embedding_cache DROP CONSTRAINT embedding_cache_pkey;
+ALTER TABLE embedding_cache ADD PRIMARY KEY (model, model_digest, content_hash);
+
+-- Entries are deleted by content when the text they embed is forgotten
+CREATE INDEX idx_embedding_cache_content_hash ON embedding_cache(content_hash);
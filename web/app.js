@@ -930,6 +930,7 @@ class ChatApp {
         let assistantMessage = '';
         let messageElement = null;
         let buffer = ''; // Buffer to accumulate partial data
+        let handedOff = false; // The reader was passed on to wait for session metadata
 
         try {
             while (true) {
@@ -945,7 +946,7 @@ class ChatApp {
                 // Keep the last line in buffer as it might be incomplete
                 buffer = lines.pop() || '';
 
-                for (const line of lines) {
+                for (const [index, line] of lines.entries()) {
                     if (line.startsWith('data: ')) {
                         const jsonStr = line.slice(6).trim();
                         if (jsonStr === '') continue; // Skip empty data lines
@@ -953,7 +954,12 @@ class ChatApp {
                         try {
                             const data = JSON.parse(jsonStr);
                             
-                            if (data.type === 'token') {
+                            if (data.type === 'loading_model') {
+                                // Shown until the first token replaces it
+                                if (!messageElement) {
+                                    messageElement = this.addMessageToUI('assistant', `Loading ${model}...`);
+                                }
+                            } else if (data.type === 'token') {
                                 assistantMessage += data.content;
                                 
                                 if (!messageElement) {
@@ -969,6 +975,11 @@ class ChatApp {
                                         tokens: data.metadata.total_tokens
                                     });
                                 }
+                                // The session title and follow-up suggestions come in a
+                                // metadata event later; read it without holding up the reply
+                                const rest = lines.slice(index + 1).concat(buffer).join('\n');
+                                this.readSessionMetadata(reader, decoder, rest, messageElement);
+                                handedOff = true;
                                 return; // Exit the function when done
                             } else if (data.type === 'error') {
                                 throw new Error(data.error);
@@ -998,11 +1009,86 @@ class ChatApp {
                     }
                 }
             }
+        } finally {
+            if (!handedOff) {
+                reader.releaseLock();
+            }
+        }
+    }
+
+    async readSessionMetadata(reader, decoder, buffer, messageElement) {
+        const handleLine = (line) => {
+            if (!line.startsWith('data: ')) return;
+            const jsonStr = line.slice(6).trim();
+            if (jsonStr === '') return;
+            try {
+                const data = JSON.parse(jsonStr);
+                if (data.type === 'metadata' && data.metadata) {
+                    this.applySessionMetadata(data.session_id, data.metadata, messageElement);
+                }
+            } catch (e) {
+                console.error('Failed to parse session metadata:', e, 'Raw line:', line);
+            }
+        };
+
+        try {
+            while (true) {
+                const lines = buffer.split('\n');
+                buffer = lines.pop() || '';
+                lines.forEach(handleLine);
+
+                const { done, value } = await reader.read();
+                if (done) break;
+                buffer += decoder.decode(value, { stream: true });
+            }
+            handleLine(buffer.trim());
+        } catch (error) {
+            console.error('Failed to read session metadata:', error);
         } finally {
             reader.releaseLock();
         }
     }
 
+    applySessionMetadata(sessionId, metadata, messageElement) {
+        if (metadata.title) {
+            const session = this.sessions.find(s => s.id === sessionId);
+            if (session) {
+                session.title = metadata.title;
+                this.renderSessions();
+            }
+        }
+
+        // Suggestions are only offered under the latest reply of the open session
+        const suggestions = metadata.suggestions || [];
+        if (sessionId !== this.currentSessionId || !messageElement || !messageElement.isConnected ||
+            messageElement !== this.chatMessages.lastElementChild || suggestions.length === 0) {
+            return;
+        }
+
+        this.chatMessages.querySelectorAll('.message-suggestions').forEach(el => el.remove());
+        const container = document.createElement('div');
+        container.className = 'message-suggestions';
+        suggestions.forEach(suggestion => {
+            const button = document.createElement('button');
+            button.className = 'suggestion-btn';
+            button.textContent = suggestion;
+            button.addEventListener('click', () => {
+                this.messageInput.value = suggestion;
+                this.adjustTextareaHeight();
+                this.updateSendButton();
+                this.messageInput.focus();
+                container.remove();
+            });
+            container.appendChild(button);
+        });
+
+        const content = messageElement.querySelector('.message-content');
+        if (content) {
+            content.appendChild(container);
+            this.scrollToBottom();
+        }
+    }
+
     addMessageToUI(role, content, metadata = {}) {
         if (!this.chatMessages) return null;
         
@@ -4380,7 +4466,20 @@ class ChatApp {
             this.loadSessions();
             this.loadModels();
             this.loadProjects();
+            this.subscribeModelEvents();
+        }
+    }
+
+    subscribeModelEvents() {
+        if (this.modelEvents || typeof EventSource === 'undefined') {
+            return;
         }
+
+        // Reload the models when a sync finds models added, removed or changed in Ollama
+        this.modelEvents = new EventSource(`${this.apiBase}/v1/models/events`);
+        ['model.added', 'model.removed', 'model.updated'].forEach(type => {
+            this.modelEvents.addEventListener(type, () => this.loadModels());
+        });
     }
 
     // Override API methods to include authentication headers